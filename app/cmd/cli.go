@@ -1,12 +1,20 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/Rakhulsr/go-ecommerce/app/configs"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
 	"github.com/Rakhulsr/go-ecommerce/app/models/migrations"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/services"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/spreadsheet"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/storage"
 	"github.com/urfave/cli/v3"
 )
 
@@ -28,6 +36,186 @@ func RunCli() {
 					return nil
 				},
 			},
+			{
+				Name:  "regenerate-images",
+				Usage: "Regenerate resized variants (xl, lg, md, sm) for all existing product images",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					db, err := configs.OpenConnection()
+					if err != nil {
+						return err
+					}
+					store, err := storage.New(ctx, storage.ConfigFromEnv(configs.LoadEnv()))
+					if err != nil {
+						return err
+					}
+					productRepo := repositories.NewProductRepository(db, store)
+					imageSvc := services.NewProductImageService(productRepo, store)
+
+					processed, failed, err := imageSvc.RegenerateAll(ctx, func(image models.ProductImage, err error) {
+						if err != nil {
+							log.Printf("❌ %s (%s): %v", image.ID, image.Path, err)
+							return
+						}
+						log.Printf("✔ %s -> %s", image.ID, image.Path)
+					})
+					if err != nil {
+						return err
+					}
+					log.Printf("✅ Image regeneration complete: %d processed, %d failed", processed, failed)
+					return nil
+				},
+			},
+			{
+				Name:  "migrate-storage",
+				Usage: "Copy uploaded product images, review photos and payment proofs between storage backends and rewrite their paths",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "from", Usage: "source storage driver (local or s3)", Required: true},
+					&cli.StringFlag{Name: "to", Usage: "destination storage driver (local or s3)", Required: true},
+					&cli.BoolFlag{Name: "delete-source", Usage: "delete files from the source storage after their paths are rewritten"},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if c.String("from") == c.String("to") {
+						return fmt.Errorf("--from and --to must be different drivers")
+					}
+					db, err := configs.OpenConnection()
+					if err != nil {
+						return err
+					}
+
+					cfg := storage.ConfigFromEnv(configs.LoadEnv())
+					cfg.Driver = c.String("from")
+					from, err := storage.New(ctx, cfg)
+					if err != nil {
+						return err
+					}
+					cfg.Driver = c.String("to")
+					to, err := storage.New(ctx, cfg)
+					if err != nil {
+						return err
+					}
+
+					productRepo := repositories.NewProductRepository(db, from)
+					reviewRepo := repositories.NewReviewRepository(db)
+					proofRepo := repositories.NewPaymentProofRepository(db)
+					migrationSvc := services.NewStorageMigrationService(productRepo, reviewRepo, proofRepo, from, to)
+
+					logResult := func(result services.StorageMigrationResult) {
+						switch {
+						case result.Err != nil:
+							log.Printf("❌ %s %s (%s): %v", result.Kind, result.ID, result.Path, result.Err)
+						case result.Migrated:
+							log.Printf("✔ %s %s -> %s", result.Kind, result.ID, result.Path)
+						}
+					}
+
+					deleteSource := c.Bool("delete-source")
+					imagesMigrated, imagesFailed, err := migrationSvc.MigrateProductImages(ctx, deleteSource, logResult)
+					if err != nil {
+						return err
+					}
+					photosMigrated, photosFailed, err := migrationSvc.MigrateReviewPhotos(ctx, deleteSource, logResult)
+					if err != nil {
+						return err
+					}
+					proofsMigrated, proofsFailed, err := migrationSvc.MigratePaymentProofs(ctx, deleteSource, logResult)
+					if err != nil {
+						return err
+					}
+					log.Printf("✅ Storage migration complete: %d product images (%d failed), %d review photos (%d failed), %d payment proofs (%d failed)",
+						imagesMigrated, imagesFailed, photosMigrated, photosFailed, proofsMigrated, proofsFailed)
+					return nil
+				},
+			},
+			{
+				Name:  "import-products",
+				Usage: "Validate (dry-run) or apply a CSV/XLSX product import, upserting by SKU",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "file", Usage: "path to a .csv or .xlsx file", Required: true},
+					&cli.BoolFlag{Name: "apply", Usage: "save the changes; without this flag only a dry-run report is printed"},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					path := c.String("file")
+					format, err := spreadsheet.FormatFromFilename(path)
+					if err != nil {
+						return err
+					}
+					file, err := os.Open(path)
+					if err != nil {
+						return err
+					}
+					defer file.Close()
+
+					importSvc, wishlistSvc, err := newProductImportService(ctx)
+					if err != nil {
+						return err
+					}
+					wishlistSvc.StartAlertWorker(ctx)
+					rows, err := importSvc.ParseRows(file, format)
+					if err != nil {
+						return err
+					}
+
+					opts := services.ProductImportOptions{AllowLocalFiles: true, Reference: "cli:import-products"}
+					var report *services.ProductImportReport
+					if c.Bool("apply") {
+						report, err = importSvc.Apply(ctx, rows, opts)
+					} else {
+						report, err = importSvc.Preview(ctx, rows, opts)
+					}
+					if report != nil {
+						for _, row := range report.Rows {
+							if len(row.Errors) > 0 {
+								log.Printf("❌ line %d (%s): %s", row.Line, row.SKU, strings.Join(row.Errors, "; "))
+								continue
+							}
+							log.Printf("✔ line %d (%s): %s", row.Line, row.SKU, row.Action)
+						}
+					}
+					if err != nil {
+						return err
+					}
+					if report.HasErrors() {
+						return fmt.Errorf("%d invalid rows, nothing was saved", report.Invalid)
+					}
+					if !c.Bool("apply") {
+						log.Printf("✅ Dry-run OK: %d to create, %d to update. Re-run with --apply to save.", report.Created, report.Updated)
+						return nil
+					}
+					wishlistSvc.SendQueuedAlerts()
+					log.Printf("✅ Import complete: %d created, %d updated", report.Created, report.Updated)
+					return nil
+				},
+			},
+			{
+				Name:  "export-products",
+				Usage: "Export the full product catalog to a CSV/XLSX file in the import format",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "file", Usage: "output path ending in .csv or .xlsx", Required: true},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					path := c.String("file")
+					format, err := spreadsheet.FormatFromFilename(path)
+					if err != nil {
+						return err
+					}
+
+					importSvc, _, err := newProductImportService(ctx)
+					if err != nil {
+						return err
+					}
+
+					var buf bytes.Buffer
+					count, err := importSvc.Export(ctx, &buf, format)
+					if err != nil {
+						return err
+					}
+					if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+						return err
+					}
+					log.Printf("✅ Exported %d products to %s", count, path)
+					return nil
+				},
+			},
 			{
 				Name:  "generate-keys",
 				Usage: "Generate new session authentication and encryption keys for .env",
@@ -47,3 +235,36 @@ func RunCli() {
 		log.Fatal(err)
 	}
 }
+
+// newProductImportService juga mengembalikan WishlistService agar notifikasi wishlist dari import bisa dikirim
+// sebelum perintah selesai.
+func newProductImportService(ctx context.Context) (*services.ProductImportService, *services.WishlistService, error) {
+	db, err := configs.OpenConnection()
+	if err != nil {
+		return nil, nil, err
+	}
+	env := configs.LoadEnv()
+	store, err := storage.New(ctx, storage.ConfigFromEnv(env))
+	if err != nil {
+		return nil, nil, err
+	}
+	productRepo := repositories.NewProductRepository(db, store)
+	variantRepo := repositories.NewProductVariantRepository(db, store)
+	categoryRepo := repositories.NewCategoryRepository(db)
+	cartItemRepo := repositories.NewCartItemRepository(db)
+	cartRepo := repositories.NewCartRepository(db, cartItemRepo)
+	stockReservationSvc := services.NewStockReservationService(repositories.NewStockReservationRepository(db))
+	voucherSvc := services.NewVoucherService(repositories.NewVoucherRepository(db), db)
+	taxSvc := services.NewTaxService(repositories.NewTaxRepository(db), repositories.NewSettingRepository(db))
+	cartSvc := services.NewCartService(cartRepo, cartItemRepo, productRepo, repositories.NewPromotionRepository(db), stockReservationSvc, voucherSvc, taxSvc, db)
+	mailer := services.NewMailer(services.Config{
+		Host:     env.EmailHost,
+		Port:     env.EmailPort,
+		Username: env.EmailUsername,
+		Password: env.EmailPassword,
+		From:     env.EmailFrom,
+	})
+	wishlistSvc := services.NewWishlistService(repositories.NewWishlistRepository(db), productRepo, cartSvc, mailer, env.APP_URL)
+	imageSvc := services.NewProductImageService(productRepo, store)
+	return services.NewProductImportService(productRepo, variantRepo, categoryRepo, imageSvc, wishlistSvc, store), wishlistSvc, nil
+}
@@ -0,0 +1,13 @@
+package configs
+
+import (
+	"log"
+)
+
+func GetAppBaseURL() string {
+	baseURL := LoadEnv().APP_URL
+	if baseURL == "" {
+		log.Fatal("APP_BASE_URL environment variable not set. This is required for payment callbacks.")
+	}
+	return baseURL
+}
@@ -0,0 +1,62 @@
+package configs
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+const DefaultGuestCartTTL = 7 * 24 * time.Hour
+
+// GetGuestCartTTL membaca GUEST_CART_TTL dalam jam, yaitu berapa lama keranjang tamu dipertahankan
+// sejak terakhir diubah sebelum dianggap kedaluwarsa dan dihapus.
+func GetGuestCartTTL() time.Duration {
+	return hoursFromEnv("GUEST_CART_TTL", LoadENV.GUEST_CART_TTL, DefaultGuestCartTTL)
+}
+
+const (
+	DefaultAbandonedCartAfter    = 24 * time.Hour
+	DefaultCartReminderInterval  = 48 * time.Hour
+	DefaultCartReminderMaxEmails = 2
+)
+
+// AbandonedCartConfig mengatur kapan keranjang dianggap ditinggalkan dan seberapa sering pengingat dikirim.
+type AbandonedCartConfig struct {
+	AbandonAfter     time.Duration
+	ReminderInterval time.Duration
+	MaxReminders     int
+}
+
+// GetAbandonedCartConfig membaca CART_ABANDON_AFTER dan CART_REMINDER_INTERVAL dalam jam, serta
+// CART_REMINDER_MAX. Nilai 0 untuk jumlah pengingat mematikan email pengingat.
+func GetAbandonedCartConfig() AbandonedCartConfig {
+	return AbandonedCartConfig{
+		AbandonAfter:     hoursFromEnv("CART_ABANDON_AFTER", LoadENV.CART_ABANDON_AFTER, DefaultAbandonedCartAfter),
+		ReminderInterval: hoursFromEnv("CART_REMINDER_INTERVAL", LoadENV.CART_REMINDER_INTERVAL, DefaultCartReminderInterval),
+		MaxReminders:     maxRemindersFromEnv(LoadENV.CART_REMINDER_MAX),
+	}
+}
+
+func hoursFromEnv(name, raw string, fallback time.Duration) time.Duration {
+	if raw == "" {
+		return fallback
+	}
+	hours, err := strconv.Atoi(raw)
+	if err != nil || hours <= 0 {
+		log.Printf("Warning: %s tidak valid (%q), memakai default %v", name, raw, fallback)
+		return fallback
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func maxRemindersFromEnv(raw string) int {
+	if raw == "" {
+		return DefaultCartReminderMaxEmails
+	}
+	count, err := strconv.Atoi(raw)
+	if err != nil || count < 0 {
+		log.Printf("Warning: CART_REMINDER_MAX tidak valid (%q), memakai default %d", raw, DefaultCartReminderMaxEmails)
+		return DefaultCartReminderMaxEmails
+	}
+	return count
+}
@@ -8,30 +8,51 @@ import (
 )
 
 type ENV struct {
-	DBHost                      string
-	DBUser                      string
-	DBPassword                  string
-	DBName                      string
-	DBPort                      string
-	Port                        string
-	SESSION_KEY                 string
-	API_ONGKIR_BASE_URL_KOMERCE string
-	API_ONGKIR_KEY_KOMERCE      string
-	API_ONGKIR_ORIGIN           string
-	JWTSecret                   string
-	AppAuthKey                  string
-	AppEncKey                   string
-	EmailHost                   string
-	EmailPort                   string
-	EmailUsername               string
-	EmailPassword               string
-	EmailFrom                   string
-	MIDTRANS_MERCHANT_KEY       string
-	MIDTRANS_CLIENT_KEY         string
-	MIDTRANS_SERVER_KEY         string
-	APP_URL                     string
-	APP_ENV                     string
-	CRSFKEY                     string
+	DBHost                       string
+	DBUser                       string
+	DBPassword                   string
+	DBName                       string
+	DBPort                       string
+	Port                         string
+	SESSION_KEY                  string
+	API_ONGKIR_BASE_URL_KOMERCE  string
+	API_ONGKIR_KEY_KOMERCE       string
+	API_ONGKIR_ORIGIN            string
+	JWTSecret                    string
+	AppAuthKey                   string
+	AppEncKey                    string
+	EmailHost                    string
+	EmailPort                    string
+	EmailUsername                string
+	EmailPassword                string
+	EmailFrom                    string
+	MIDTRANS_MERCHANT_KEY        string
+	MIDTRANS_CLIENT_KEY          string
+	MIDTRANS_SERVER_KEY          string
+	PAYMENT_PROVIDER             string
+	APP_URL                      string
+	APP_ENV                      string
+	CRSFKEY                      string
+	STOCK_RESERVATION_TTL        string
+	SHIPPING_QUOTE_TTL           string
+	GUEST_CART_TTL               string
+	CART_ABANDON_AFTER           string
+	CART_REMINDER_INTERVAL       string
+	CART_REMINDER_MAX            string
+	STORAGE_DRIVER               string
+	STORAGE_LOCAL_DIR            string
+	STORAGE_LOCAL_URL            string
+	S3_ENDPOINT                  string
+	S3_REGION                    string
+	S3_BUCKET                    string
+	S3_ACCESS_KEY                string
+	S3_SECRET_KEY                string
+	S3_USE_SSL                   string
+	S3_PUBLIC_URL                string
+	MANUAL_TRANSFER_BANK         string
+	MANUAL_TRANSFER_ACCOUNT_NO   string
+	MANUAL_TRANSFER_ACCOUNT_NAME string
+	MANUAL_TRANSFER_TTL          string
 }
 
 func LoadEnv() ENV {
@@ -41,30 +62,51 @@ func LoadEnv() ENV {
 	}
 
 	return ENV{
-		DBHost:                      os.Getenv("DB_HOST"),
-		DBUser:                      os.Getenv("DB_USER"),
-		DBPassword:                  os.Getenv("DB_PASSWORD"),
-		DBName:                      os.Getenv("DB_NAME"),
-		DBPort:                      os.Getenv("DB_PORT"),
-		Port:                        os.Getenv("APP_PORT"),
-		SESSION_KEY:                 os.Getenv("SESSION_KEY"),
-		API_ONGKIR_ORIGIN:           os.Getenv("API_ONGKIR_ORIGIN"),
-		JWTSecret:                   os.Getenv("JWT_SECRET"),
-		AppAuthKey:                  os.Getenv("APP_AUTH_KEY"),
-		AppEncKey:                   os.Getenv("APP_ENC_KEY"),
-		EmailHost:                   os.Getenv("EMAIL_HOST"),
-		EmailPort:                   os.Getenv("EMAIL_PORT"),
-		EmailUsername:               os.Getenv("EMAIL_USERNAME"),
-		EmailPassword:               os.Getenv("EMAIL_PASSWORD"),
-		EmailFrom:                   os.Getenv("EMAIL_USERNAME"),
-		MIDTRANS_MERCHANT_KEY:       os.Getenv("MIDTRANS_MERCHANT_KEY"),
-		MIDTRANS_CLIENT_KEY:         os.Getenv("MIDTRANS_CLIENT_KEY"),
-		MIDTRANS_SERVER_KEY:         os.Getenv("MIDTRANS_SERVER_KEY"),
-		APP_URL:                     os.Getenv("APP_URL"),
-		API_ONGKIR_BASE_URL_KOMERCE: os.Getenv("API_ONGKIR_BASE_URL_KOMERCE"),
-		API_ONGKIR_KEY_KOMERCE:      os.Getenv("API_ONGKIR_KEY_KOMERCE"),
-		APP_ENV:                     os.Getenv("APP_ENV"),
-		CRSFKEY:                     os.Getenv("CRSFKEY"),
+		DBHost:                       os.Getenv("DB_HOST"),
+		DBUser:                       os.Getenv("DB_USER"),
+		DBPassword:                   os.Getenv("DB_PASSWORD"),
+		DBName:                       os.Getenv("DB_NAME"),
+		DBPort:                       os.Getenv("DB_PORT"),
+		Port:                         os.Getenv("APP_PORT"),
+		SESSION_KEY:                  os.Getenv("SESSION_KEY"),
+		API_ONGKIR_ORIGIN:            os.Getenv("API_ONGKIR_ORIGIN"),
+		JWTSecret:                    os.Getenv("JWT_SECRET"),
+		AppAuthKey:                   os.Getenv("APP_AUTH_KEY"),
+		AppEncKey:                    os.Getenv("APP_ENC_KEY"),
+		EmailHost:                    os.Getenv("EMAIL_HOST"),
+		EmailPort:                    os.Getenv("EMAIL_PORT"),
+		EmailUsername:                os.Getenv("EMAIL_USERNAME"),
+		EmailPassword:                os.Getenv("EMAIL_PASSWORD"),
+		EmailFrom:                    os.Getenv("EMAIL_USERNAME"),
+		MIDTRANS_MERCHANT_KEY:        os.Getenv("MIDTRANS_MERCHANT_KEY"),
+		MIDTRANS_CLIENT_KEY:          os.Getenv("MIDTRANS_CLIENT_KEY"),
+		MIDTRANS_SERVER_KEY:          os.Getenv("MIDTRANS_SERVER_KEY"),
+		PAYMENT_PROVIDER:             os.Getenv("PAYMENT_PROVIDER"),
+		APP_URL:                      os.Getenv("APP_URL"),
+		API_ONGKIR_BASE_URL_KOMERCE:  os.Getenv("API_ONGKIR_BASE_URL_KOMERCE"),
+		API_ONGKIR_KEY_KOMERCE:       os.Getenv("API_ONGKIR_KEY_KOMERCE"),
+		APP_ENV:                      os.Getenv("APP_ENV"),
+		CRSFKEY:                      os.Getenv("CRSFKEY"),
+		STOCK_RESERVATION_TTL:        os.Getenv("STOCK_RESERVATION_TTL"),
+		SHIPPING_QUOTE_TTL:           os.Getenv("SHIPPING_QUOTE_TTL"),
+		GUEST_CART_TTL:               os.Getenv("GUEST_CART_TTL"),
+		CART_ABANDON_AFTER:           os.Getenv("CART_ABANDON_AFTER"),
+		CART_REMINDER_INTERVAL:       os.Getenv("CART_REMINDER_INTERVAL"),
+		CART_REMINDER_MAX:            os.Getenv("CART_REMINDER_MAX"),
+		STORAGE_DRIVER:               os.Getenv("STORAGE_DRIVER"),
+		STORAGE_LOCAL_DIR:            os.Getenv("STORAGE_LOCAL_DIR"),
+		STORAGE_LOCAL_URL:            os.Getenv("STORAGE_LOCAL_URL"),
+		S3_ENDPOINT:                  os.Getenv("S3_ENDPOINT"),
+		S3_REGION:                    os.Getenv("S3_REGION"),
+		S3_BUCKET:                    os.Getenv("S3_BUCKET"),
+		S3_ACCESS_KEY:                os.Getenv("S3_ACCESS_KEY"),
+		S3_SECRET_KEY:                os.Getenv("S3_SECRET_KEY"),
+		S3_USE_SSL:                   os.Getenv("S3_USE_SSL"),
+		S3_PUBLIC_URL:                os.Getenv("S3_PUBLIC_URL"),
+		MANUAL_TRANSFER_BANK:         os.Getenv("MANUAL_TRANSFER_BANK"),
+		MANUAL_TRANSFER_ACCOUNT_NO:   os.Getenv("MANUAL_TRANSFER_ACCOUNT_NO"),
+		MANUAL_TRANSFER_ACCOUNT_NAME: os.Getenv("MANUAL_TRANSFER_ACCOUNT_NAME"),
+		MANUAL_TRANSFER_TTL:          os.Getenv("MANUAL_TRANSFER_TTL"),
 	}
 
 }
@@ -0,0 +1,37 @@
+package configs
+
+import "time"
+
+const (
+	DefaultManualTransferBank = "BCA"
+	DefaultManualTransferTTL  = 24 * time.Hour
+)
+
+// ManualTransferConfig adalah rekening toko untuk pembayaran transfer manual dan batas waktu pembeli
+// mengirim bukti transfer sebelum order dibatalkan.
+type ManualTransferConfig struct {
+	Bank          string
+	AccountNumber string
+	AccountName   string
+	TTL           time.Duration
+}
+
+// Enabled bernilai false jika nomor rekening belum diatur, sehingga metode ini tidak ditawarkan di checkout.
+func (c ManualTransferConfig) Enabled() bool {
+	return c.AccountNumber != ""
+}
+
+// GetManualTransferConfig membaca MANUAL_TRANSFER_BANK, MANUAL_TRANSFER_ACCOUNT_NO,
+// MANUAL_TRANSFER_ACCOUNT_NAME serta MANUAL_TRANSFER_TTL dalam jam.
+func GetManualTransferConfig() ManualTransferConfig {
+	bank := LoadENV.MANUAL_TRANSFER_BANK
+	if bank == "" {
+		bank = DefaultManualTransferBank
+	}
+	return ManualTransferConfig{
+		Bank:          bank,
+		AccountNumber: LoadENV.MANUAL_TRANSFER_ACCOUNT_NO,
+		AccountName:   LoadENV.MANUAL_TRANSFER_ACCOUNT_NAME,
+		TTL:           hoursFromEnv("MANUAL_TRANSFER_TTL", LoadENV.MANUAL_TRANSFER_TTL, DefaultManualTransferTTL),
+	}
+}
@@ -0,0 +1,24 @@
+package configs
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+const DefaultShippingQuoteTTL = 30 * time.Minute
+
+// GetShippingQuoteTTL membaca SHIPPING_QUOTE_TTL dalam menit, yaitu berapa lama quote ongkir boleh
+// dipakai untuk checkout sebelum pelanggan harus menghitung ulang.
+func GetShippingQuoteTTL() time.Duration {
+	raw := LoadENV.SHIPPING_QUOTE_TTL
+	if raw == "" {
+		return DefaultShippingQuoteTTL
+	}
+	minutes, err := strconv.Atoi(raw)
+	if err != nil || minutes <= 0 {
+		log.Printf("Warning: SHIPPING_QUOTE_TTL tidak valid (%q), memakai default %v", raw, DefaultShippingQuoteTTL)
+		return DefaultShippingQuoteTTL
+	}
+	return time.Duration(minutes) * time.Minute
+}
@@ -0,0 +1,24 @@
+package configs
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+const DefaultStockReservationTTL = 60 * time.Minute
+
+// GetStockReservationTTL membaca STOCK_RESERVATION_TTL dalam menit.
+// Nilai ini juga dipakai sebagai batas waktu transaksi Snap agar keduanya selaras.
+func GetStockReservationTTL() time.Duration {
+	raw := LoadENV.STOCK_RESERVATION_TTL
+	if raw == "" {
+		return DefaultStockReservationTTL
+	}
+	minutes, err := strconv.Atoi(raw)
+	if err != nil || minutes <= 0 {
+		log.Printf("Warning: STOCK_RESERVATION_TTL tidak valid (%q), memakai default %v", raw, DefaultStockReservationTTL)
+		return DefaultStockReservationTTL
+	}
+	return time.Duration(minutes) * time.Minute
+}
@@ -0,0 +1,55 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+)
+
+const cartRecoveryRecentLimit = 50
+
+func (h *AdminHandler) GetAbandonedCartsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days <= 0 {
+		days = 30
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	pageData := &AdminCartRecoveryPageData{}
+	h.populateBaseDataForAdmin(r, pageData)
+
+	pageData.Title = "Keranjang Ditinggalkan"
+	pageData.IsAuthPage = true
+	pageData.IsAdminPage = true
+	pageData.HideAdminWelcomeMessage = true
+	pageData.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Keranjang Ditinggalkan", URL: "/admin/abandoned-carts"},
+	}
+	pageData.Days = days
+	pageData.DayOptions = searchReportDayOptions
+
+	report, err := h.recoveryRepo.GetReport(ctx, since)
+	if err != nil {
+		log.Printf("GetAbandonedCartsPage: Gagal mengambil laporan keranjang ditinggalkan: %v", err)
+		pageData.Message = "Gagal mengambil laporan keranjang ditinggalkan."
+		pageData.MessageStatus = "error"
+	}
+	pageData.Report = report
+
+	recent, err := h.recoveryRepo.GetRecent(ctx, since, cartRecoveryRecentLimit)
+	if err != nil {
+		log.Printf("GetAbandonedCartsPage: Gagal mengambil daftar keranjang ditinggalkan: %v", err)
+		pageData.Message = "Gagal mengambil laporan keranjang ditinggalkan."
+		pageData.MessageStatus = "error"
+	}
+	pageData.Recent = recent
+
+	h.render.HTML(w, http.StatusOK, "admin/abandoned_carts/index", pageData)
+}
@@ -58,6 +58,7 @@ func (h *AdminHandler) AddCategoryPage(w http.ResponseWriter, r *http.Request) {
 		data.MessageStatus = "error"
 	}
 	data.Sections = sections
+	data.TaxClasses = h.taxClassOptions(r)
 
 	data.Title = "Tambah Kategori Baru"
 	data.IsAuthPage = true
@@ -98,6 +99,7 @@ func (h *AdminHandler) AddCategoryPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	form.Name = r.PostFormValue("name")
+	form.TaxClassID = r.PostFormValue("tax_class_id")
 
 	form.SectionID = section.ID
 
@@ -108,6 +110,7 @@ func (h *AdminHandler) AddCategoryPost(w http.ResponseWriter, r *http.Request) {
 		data.CategoryData = &form
 		data.Errors = helpers.FormatValidationErrors(validationErrors)
 		data.Title = "Tambah Kategori Baru"
+		data.TaxClasses = h.taxClassOptions(r)
 		h.populateBaseDataForAdmin(r, &data)
 		h.render.HTML(w, http.StatusOK, "admin/categories/form", &data)
 		return
@@ -116,12 +119,13 @@ func (h *AdminHandler) AddCategoryPost(w http.ResponseWriter, r *http.Request) {
 	categorySlug := helpers.GenerateSlug(form.Name)
 
 	newCategory := &models.Category{
-		ID:        uuid.New().String(),
-		Name:      form.Name,
-		Slug:      categorySlug,
-		SectionID: form.SectionID,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:         uuid.New().String(),
+		Name:       form.Name,
+		Slug:       categorySlug,
+		SectionID:  form.SectionID,
+		TaxClassID: form.TaxClassID,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
 	}
 
 	if err := h.categoryRepo.Create(r.Context(), newCategory); err != nil {
@@ -150,10 +154,11 @@ func (h *AdminHandler) EditCategoryPage(w http.ResponseWriter, r *http.Request)
 	}
 
 	formData := CategoryForm{
-		ID:        category.ID,
-		Name:      category.Name,
-		Slug:      category.Slug,
-		SectionID: category.SectionID,
+		ID:         category.ID,
+		Name:       category.Name,
+		Slug:       category.Slug,
+		SectionID:  category.SectionID,
+		TaxClassID: category.TaxClassID,
 	}
 
 	data := &AdminCategoryPageData{
@@ -171,6 +176,7 @@ func (h *AdminHandler) EditCategoryPage(w http.ResponseWriter, r *http.Request)
 		data.MessageStatus = "error"
 	}
 	data.Sections = sections
+	data.TaxClasses = h.taxClassOptions(r)
 
 	data.Title = "Edit Kategori"
 	data.IsAuthPage = true
@@ -205,6 +211,7 @@ func (h *AdminHandler) EditCategoryPost(w http.ResponseWriter, r *http.Request)
 
 	form.ID = categoryID
 	form.Name = r.PostFormValue("name")
+	form.TaxClassID = r.PostFormValue("tax_class_id")
 
 	form.SectionID = r.PostFormValue("section_id")
 
@@ -225,6 +232,7 @@ func (h *AdminHandler) EditCategoryPost(w http.ResponseWriter, r *http.Request)
 			log.Printf("EditCategoryPost: Gagal mengambil section saat validasi gagal: %v", secErr)
 		}
 		data.Sections = sections
+		data.TaxClasses = h.taxClassOptions(r)
 
 		data.Title = "Edit Kategori"
 		data.IsAuthPage = true
@@ -244,6 +252,7 @@ func (h *AdminHandler) EditCategoryPost(w http.ResponseWriter, r *http.Request)
 
 	category.Name = form.Name
 	category.SectionID = form.SectionID
+	category.TaxClassID = form.TaxClassID
 
 	category.UpdatedAt = time.Now()
 
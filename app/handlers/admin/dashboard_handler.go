@@ -1,12 +1,9 @@
 package admin
 
 import (
-	"context"
-	"fmt"
 	"log"
 	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
 	"time"
 
@@ -16,9 +13,7 @@ import (
 	"github.com/Rakhulsr/go-ecommerce/app/repositories"
 	"github.com/Rakhulsr/go-ecommerce/app/services"
 	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
-	"github.com/Rakhulsr/go-ecommerce/app/utils/calc"
 	"github.com/go-playground/validator/v10"
-	"github.com/shopspring/decimal"
 	"github.com/unrolled/render"
 )
 
@@ -26,38 +21,92 @@ type AdminHandler struct {
 	render       *render.Render
 	validator    *validator.Validate
 	productRepo  repositories.ProductRepositoryImpl
+	variantRepo  repositories.ProductVariantRepositoryImpl
+	stockRepo    repositories.StockMovementRepository
+	imageSvc     *services.ProductImageService
+	importSvc    *services.ProductImportService
+	searchRepo   repositories.SearchQueryRepository
+	reviewRepo   repositories.ReviewRepository
 	categoryRepo repositories.CategoryRepositoryImpl
 	sectionRepo  repositories.SectionRepositoryImpl
 	userRepo     repositories.UserRepositoryImpl
 	cartRepo     repositories.CartRepositoryImpl
 	cartItemRepo repositories.CartItemRepositoryImpl
 	cartSvc      services.CartService
+	wishlistSvc  *services.WishlistService
 	orderRepo    repositories.OrderRepository
+	voucherRepo  repositories.VoucherRepository
+	voucherSvc   *services.VoucherService
+	promoRepo    repositories.PromotionRepository
+	taxRepo      repositories.TaxRepository
+	taxSvc       *services.TaxService
+	recoveryRepo repositories.CartRecoveryRepository
+	notifyRepo   repositories.PaymentNotificationRepository
+	paymentSvc   *services.PaymentService
+	refundSvc    *services.RefundService
+	proofRepo    repositories.PaymentProofRepository
+	transferSvc  *services.ManualTransferService
 }
 
 func NewAdminHandler(
 	render *render.Render,
 	validator *validator.Validate,
 	productRepo repositories.ProductRepositoryImpl,
+	variantRepo repositories.ProductVariantRepositoryImpl,
+	stockRepo repositories.StockMovementRepository,
+	imageSvc *services.ProductImageService,
+	importSvc *services.ProductImportService,
+	searchRepo repositories.SearchQueryRepository,
+	reviewRepo repositories.ReviewRepository,
 	categoryRepo repositories.CategoryRepositoryImpl,
 	sectionRepo repositories.SectionRepositoryImpl,
 	userRepo repositories.UserRepositoryImpl,
 	cartRepo repositories.CartRepositoryImpl,
 	cartItemRepo repositories.CartItemRepositoryImpl,
 	cartSvc services.CartService,
+	wishlistSvc *services.WishlistService,
 	orderRepo repositories.OrderRepository,
+	voucherRepo repositories.VoucherRepository,
+	voucherSvc *services.VoucherService,
+	promoRepo repositories.PromotionRepository,
+	taxRepo repositories.TaxRepository,
+	taxSvc *services.TaxService,
+	recoveryRepo repositories.CartRecoveryRepository,
+	notifyRepo repositories.PaymentNotificationRepository,
+	paymentSvc *services.PaymentService,
+	refundSvc *services.RefundService,
+	proofRepo repositories.PaymentProofRepository,
+	transferSvc *services.ManualTransferService,
 ) *AdminHandler {
 	return &AdminHandler{
 		render:       render,
 		validator:    validator,
 		productRepo:  productRepo,
+		variantRepo:  variantRepo,
+		stockRepo:    stockRepo,
+		imageSvc:     imageSvc,
+		importSvc:    importSvc,
+		searchRepo:   searchRepo,
+		reviewRepo:   reviewRepo,
 		categoryRepo: categoryRepo,
 		sectionRepo:  sectionRepo,
 		userRepo:     userRepo,
 		cartRepo:     cartRepo,
 		cartItemRepo: cartItemRepo,
 		cartSvc:      cartSvc,
+		wishlistSvc:  wishlistSvc,
 		orderRepo:    orderRepo,
+		voucherRepo:  voucherRepo,
+		voucherSvc:   voucherSvc,
+		promoRepo:    promoRepo,
+		taxRepo:      taxRepo,
+		taxSvc:       taxSvc,
+		recoveryRepo: recoveryRepo,
+		notifyRepo:   notifyRepo,
+		paymentSvc:   paymentSvc,
+		refundSvc:    refundSvc,
+		proofRepo:    proofRepo,
+		transferSvc:  transferSvc,
 	}
 }
 
@@ -71,7 +120,7 @@ type AdminPageData struct {
 		Activity string
 		Time     time.Time
 	}
-	CurrentGlobalDiscount float64
+	RunningPromotions []models.Promotion
 }
 
 type AdminProductPageData struct {
@@ -82,6 +131,7 @@ type AdminProductPageData struct {
 	FormAction  string
 	Errors      map[string]string
 	Categories  []models.Category
+	TaxClasses  []models.TaxClass
 }
 
 type ProductForm struct {
@@ -94,8 +144,107 @@ type ProductForm struct {
 	Weight          string `form:"weight" validate:"required,numeric,min=0"`
 	CategoryID      string `form:"category_id" validate:"required"`
 	DiscountPercent string `form:"discount_percent" validate:"omitempty,numeric,min=0,max=100"`
+	TaxClassID      string `form:"tax_class_id"`
 
 	ExistingImages []models.ProductImage
+
+	OptionNames []string
+	Variants    []VariantForm
+}
+
+type VariantForm struct {
+	Key          string
+	ID           string
+	Values       []string
+	SKU          string
+	Price        string
+	Stock        string
+	Weight       string
+	ExistingPath string
+}
+
+type AdminStockMovementPageData struct {
+	other.BasePageData
+	Product       *models.Product
+	Movements     []models.StockMovement
+	MovementSum   int
+	IsReconciled  bool
+	VariantChecks []VariantStockCheck
+	CurrentPage   int
+	TotalPages    int
+}
+
+type VariantStockCheck struct {
+	Name         string
+	Sku          string
+	Stock        int
+	MovementSum  int
+	IsReconciled bool
+}
+
+type AdminSearchReportPageData struct {
+	other.BasePageData
+	Days       int
+	DayOptions []int
+	Popular    []other.SearchQueryStat
+	ZeroResult []other.SearchQueryStat
+}
+
+type AdminCartRecoveryPageData struct {
+	other.BasePageData
+	Days       int
+	DayOptions []int
+	Report     other.CartRecoveryReport
+	Recent     []models.CartRecovery
+}
+
+type AdminReviewPageData struct {
+	other.BasePageData
+	Reviews       []models.Review
+	Status        string
+	StatusCounts  map[string]int64
+	StatusOptions []ReviewStatusOption
+	CurrentPage   int
+	TotalPages    int
+}
+
+type AdminProductImportPageData struct {
+	other.BasePageData
+	Report   *services.ProductImportReport
+	Payload  string
+	FileName string
+}
+
+type AdminPaymentNotificationPageData struct {
+	other.BasePageData
+	Notifications  []models.PaymentNotification
+	Outcome        string
+	OrderCode      string
+	OutcomeCounts  map[string]int64
+	OutcomeOptions []ReviewStatusOption
+	CurrentPage    int
+	TotalPages     int
+}
+
+type AdminOrderDetailPageData struct {
+	other.BasePageData
+	Refund *services.OrderRefundSummary
+}
+
+type AdminPaymentProofPageData struct {
+	other.BasePageData
+	Proofs        []models.PaymentProof
+	Status        string
+	OrderCode     string
+	StatusCounts  map[string]int64
+	StatusOptions []ReviewStatusOption
+	CurrentPage   int
+	TotalPages    int
+}
+
+type ReviewStatusOption struct {
+	Value string
+	Label string
 }
 
 type AdminCategoryPageData struct {
@@ -106,6 +255,7 @@ type AdminCategoryPageData struct {
 	FormAction   string
 	Errors       map[string]string
 	Sections     []models.Section
+	TaxClasses   []models.TaxClass
 }
 
 type CategoryForm struct {
@@ -113,7 +263,8 @@ type CategoryForm struct {
 	Name string `form:"name" validate:"required,min=3,max=100"`
 	Slug string
 
-	SectionID string `form:"section_id"`
+	SectionID  string `form:"section_id"`
+	TaxClassID string `form:"tax_class_id"`
 }
 
 type AdminUserPageData struct {
@@ -147,6 +298,28 @@ func (h *AdminHandler) populateBaseDataForAdmin(r *http.Request, pageData interf
 		base = &pd.BasePageData
 	case *AdminUserPageData:
 		base = &pd.BasePageData
+	case *AdminStockMovementPageData:
+		base = &pd.BasePageData
+	case *AdminSearchReportPageData:
+		base = &pd.BasePageData
+	case *AdminCartRecoveryPageData:
+		base = &pd.BasePageData
+	case *AdminReviewPageData:
+		base = &pd.BasePageData
+	case *AdminProductImportPageData:
+		base = &pd.BasePageData
+	case *AdminVoucherPageData:
+		base = &pd.BasePageData
+	case *AdminPromotionPageData:
+		base = &pd.BasePageData
+	case *AdminTaxPageData:
+		base = &pd.BasePageData
+	case *AdminPaymentNotificationPageData:
+		base = &pd.BasePageData
+	case *AdminOrderDetailPageData:
+		base = &pd.BasePageData
+	case *AdminPaymentProofPageData:
+		base = &pd.BasePageData
 	default:
 		log.Printf("populateBaseDataForAdmin: Unknown pageData type: %T", pageData)
 		return
@@ -198,41 +371,6 @@ func (h *AdminHandler) populateBaseDataForAdmin(r *http.Request, pageData interf
 		base.IsAdminRoute = false
 	}
 }
-func (h *AdminHandler) applyGlobalDiscount(ctx context.Context, discountPercent float64) error {
-	discountDecimal := decimal.NewFromFloat(discountPercent)
-
-	products, err := h.productRepo.GetProducts(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get all products for global discount: %w", err)
-	}
-
-	for _, product := range products {
-
-		productDiscountAmount := calc.CalculateDiscount(product.Price, discountDecimal)
-
-		if err := h.productRepo.UpdateProductDiscount(ctx, product.ID, discountDecimal, productDiscountAmount); err != nil {
-			log.Printf("applyGlobalDiscount: Failed to update discount for product %s: %v", product.ID, err)
-
-		}
-	}
-
-	carts, err := h.cartRepo.GetAllCarts(ctx)
-	if err != nil {
-		log.Printf("applyGlobalDiscount: Failed to get all carts to update summaries after product discount: %v", err)
-		return fmt.Errorf("failed to update cart summaries after global product discount: %w", err)
-	}
-
-	for _, cart := range carts {
-
-		if err := h.cartRepo.UpdateCartSummary(ctx, cart.ID); err != nil {
-			log.Printf("applyGlobalDiscount: Failed to update cart summary for cart %s after product discount: %v", cart.ID, err)
-
-		}
-	}
-
-	return nil
-}
-
 func (h *AdminHandler) GetDashboard(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	data := &AdminPageData{}
@@ -280,12 +418,11 @@ func (h *AdminHandler) GetDashboard(w http.ResponseWriter, r *http.Request) {
 		data.RecentOrders = recentOrders
 	}
 
-	products, err := h.productRepo.GetProducts(ctx)
-	if err == nil && len(products) > 0 {
-		data.CurrentGlobalDiscount = products[0].DiscountPercent.InexactFloat64()
-	} else {
-		data.CurrentGlobalDiscount = 0.0
+	runningPromotions, err := h.promoRepo.GetRunning(ctx, time.Now())
+	if err != nil {
+		log.Printf("GetDashboard: Gagal mengambil promosi yang berjalan: %v", err)
 	}
+	data.RunningPromotions = runningPromotions
 
 	data.RecentActivities = []struct {
 		Activity string
@@ -298,28 +435,3 @@ func (h *AdminHandler) GetDashboard(w http.ResponseWriter, r *http.Request) {
 
 	h.render.HTML(w, http.StatusOK, "admin/dashboard/index", data)
 }
-
-func (h *AdminHandler) ApplyGlobalDiscountPost(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		log.Printf("ApplyGlobalDiscountPost: Kesalahan parsing form: %v", err)
-		http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
-		return
-	}
-
-	discountPercentStr := r.PostFormValue("global_discount_percent")
-	discountPercent, err := strconv.ParseFloat(discountPercentStr, 64)
-	if err != nil || discountPercent < 0 || discountPercent > 100 {
-		log.Printf("ApplyGlobalDiscountPost: Persentase diskon tidak valid: %v", err)
-		http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
-		return
-	}
-
-	err = h.applyGlobalDiscount(r.Context(), discountPercent)
-	if err != nil {
-		log.Printf("ApplyGlobalDiscountPost: Gagal menerapkan diskon global: %v", err)
-		http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
-		return
-	}
-
-	http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
-}
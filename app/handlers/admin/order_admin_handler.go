@@ -1,14 +1,20 @@
 package admin
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/Rakhulsr/go-ecommerce/app/helpers"
 	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/services"
 	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	format "github.com/Rakhulsr/go-ecommerce/app/utils/format"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
 )
 
 func (h *AdminHandler) GetOrdersPage(w http.ResponseWriter, r *http.Request) {
@@ -47,6 +53,8 @@ func (h *AdminHandler) GetOrdersPage(w http.ResponseWriter, r *http.Request) {
 		models.OrderStatusCancelled:  "Dibatalkan",
 		models.OrderStatusRefunded:   "Pengembalian Dana",
 		models.OrderStatusFailed:     "Gagal",
+
+		models.OrderStatusPartiallyRefunded: "Dikembalikan Sebagian",
 	}
 
 	h.render.HTML(w, http.StatusOK, "admin/orders/list", pageData)
@@ -76,5 +84,115 @@ func (h *AdminHandler) UpdateOrderStatusPost(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if err := h.voucherSvc.ReleaseForOrderStatus(ctx, orderID, newStatus); err != nil {
+		log.Printf("AdminHandler.UpdateOrderStatusPost: Gagal melepas voucher pesanan %s: %v", orderID, err)
+	}
+
 	http.Redirect(w, r, "/admin/orders?status=success&message="+url.QueryEscape("Status pesanan berhasil diperbarui."), http.StatusSeeOther)
 }
+
+func (h *AdminHandler) GetOrderDetailPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orderCode := mux.Vars(r)["orderCode"]
+
+	summary, err := h.refundSvc.GetOrderRefunds(ctx, orderCode)
+	if err != nil {
+		log.Printf("AdminHandler.GetOrderDetailPage: Gagal mengambil pesanan %s: %v", orderCode, err)
+		message := "Gagal memuat detail pesanan."
+		if errors.Is(err, services.ErrRefundOrderNotFound) {
+			message = "Pesanan tidak ditemukan."
+		}
+		http.Redirect(w, r, "/admin/orders?status=error&message="+url.QueryEscape(message), http.StatusSeeOther)
+		return
+	}
+
+	pageData := &AdminOrderDetailPageData{}
+	h.populateBaseDataForAdmin(r, pageData)
+
+	pageData.Title = "Pesanan " + orderCode
+	pageData.IsAuthPage = true
+	pageData.IsAdminPage = true
+	pageData.HideAdminWelcomeMessage = true
+	pageData.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Orders", URL: "/admin/orders"},
+		{Name: orderCode, URL: "/admin/orders/" + orderCode},
+	}
+	pageData.Refund = summary
+
+	h.render.HTML(w, http.StatusOK, "admin/orders/detail", pageData)
+}
+
+// RefundOrderPost membaca jumlah refund per item dari field qty_<id item order> dan nominal bebas dari field amount.
+func (h *AdminHandler) RefundOrderPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orderCode := mux.Vars(r)["orderCode"]
+	returnURL := "/admin/orders/" + url.PathEscape(orderCode) + "?"
+
+	if err := r.ParseForm(); err != nil {
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Form refund tidak valid."), http.StatusSeeOther)
+		return
+	}
+
+	req := services.RefundRequest{
+		OrderCode: orderCode,
+		Reason:    r.FormValue("reason"),
+		Restock:   r.FormValue("restock") == "on",
+	}
+	req.ActorID, _ = ctx.Value(helpers.ContextKeyUserID).(string)
+
+	for key, values := range r.PostForm {
+		orderItemID, ok := strings.CutPrefix(key, "qty_")
+		if !ok || len(values) == 0 || strings.TrimSpace(values[0]) == "" {
+			continue
+		}
+		qty, err := strconv.Atoi(strings.TrimSpace(values[0]))
+		if err != nil {
+			http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Jumlah item refund tidak valid."), http.StatusSeeOther)
+			return
+		}
+		req.Items = append(req.Items, services.RefundItemRequest{OrderItemID: orderItemID, Qty: qty})
+	}
+
+	if amountStr := strings.TrimSpace(r.FormValue("amount")); amountStr != "" {
+		amount, err := decimal.NewFromString(amountStr)
+		if err != nil || amount.IsNegative() {
+			http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Jumlah refund tidak valid."), http.StatusSeeOther)
+			return
+		}
+		req.Amount = amount
+	}
+
+	refund, err := h.refundSvc.Refund(ctx, req)
+	if err != nil {
+		log.Printf("AdminHandler.RefundOrderPost: Gagal refund pesanan %s: %v", orderCode, err)
+		message := "Refund gagal: " + err.Error()
+		if errors.Is(err, services.ErrRefundUncertain) {
+			message = "Refund belum selesai: " + err.Error()
+		}
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape(message), http.StatusSeeOther)
+		return
+	}
+
+	message := "Refund sebesar " + format.FormatRupiah(refund.Amount) + " berhasil diproses."
+	http.Redirect(w, r, returnURL+"status=success&message="+url.QueryEscape(message), http.StatusSeeOther)
+}
+
+// RetryRefundPost menyelesaikan refund yang masih pending dengan RefundKey yang sama.
+func (h *AdminHandler) RetryRefundPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	orderCode := vars["orderCode"]
+	returnURL := "/admin/orders/" + url.PathEscape(orderCode) + "?"
+
+	refund, err := h.refundSvc.RetryRefund(ctx, orderCode, vars["refundID"])
+	if err != nil {
+		log.Printf("AdminHandler.RetryRefundPost: Gagal menyelesaikan refund %s pesanan %s: %v", vars["refundID"], orderCode, err)
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Refund belum selesai: "+err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	message := "Refund sebesar " + format.FormatRupiah(refund.Amount) + " berhasil diproses."
+	http.Redirect(w, r, returnURL+"status=success&message="+url.QueryEscape(message), http.StatusSeeOther)
+}
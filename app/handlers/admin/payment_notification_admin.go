@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	"github.com/gorilla/mux"
+)
+
+const paymentNotificationsPerPage = 30
+
+var paymentNotificationOutcomeOptions = []ReviewStatusOption{
+	{Value: "", Label: "Semua"},
+	{Value: models.PaymentNotificationProcessed, Label: "Diproses"},
+	{Value: models.PaymentNotificationFailed, Label: "Gagal"},
+	{Value: models.PaymentNotificationRejected, Label: "Ditolak"},
+	{Value: models.PaymentNotificationReceived, Label: "Diterima"},
+}
+
+func (h *AdminHandler) GetPaymentNotificationsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	outcome := r.URL.Query().Get("filter")
+	orderCode := strings.TrimSpace(r.URL.Query().Get("order_code"))
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageData := &AdminPaymentNotificationPageData{}
+	h.populateBaseDataForAdmin(r, pageData)
+
+	pageData.Title = "Notifikasi Pembayaran"
+	pageData.IsAuthPage = true
+	pageData.IsAdminPage = true
+	pageData.HideAdminWelcomeMessage = true
+	pageData.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Notifikasi Pembayaran", URL: "/admin/payment-notifications"},
+	}
+	pageData.Outcome = outcome
+	pageData.OrderCode = orderCode
+	pageData.OutcomeOptions = paymentNotificationOutcomeOptions
+	pageData.CurrentPage = page
+
+	notifications, total, err := h.notifyRepo.GetForAdmin(ctx, outcome, orderCode, paymentNotificationsPerPage, (page-1)*paymentNotificationsPerPage)
+	if err != nil {
+		log.Printf("GetPaymentNotificationsPage: Gagal mengambil notifikasi pembayaran: %v", err)
+		pageData.Message = "Gagal mengambil daftar notifikasi pembayaran."
+		pageData.MessageStatus = "error"
+	}
+	pageData.Notifications = notifications
+	pageData.TotalPages = int((total + paymentNotificationsPerPage - 1) / paymentNotificationsPerPage)
+
+	counts, err := h.notifyRepo.CountByOutcome(ctx)
+	if err != nil {
+		log.Printf("GetPaymentNotificationsPage: Gagal menghitung notifikasi pembayaran: %v", err)
+	}
+	pageData.OutcomeCounts = counts
+
+	h.render.HTML(w, http.StatusOK, "admin/payment_notifications/index", pageData)
+}
+
+func (h *AdminHandler) ReplayPaymentNotificationPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	returnURL := "/admin/payment-notifications?"
+
+	result, err := h.paymentSvc.ReplayNotification(ctx, id)
+	if err != nil {
+		log.Printf("AdminHandler.ReplayPaymentNotificationPost: Gagal replay notifikasi %s: %v", id, err)
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Replay notifikasi gagal: "+err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	message := "Notifikasi untuk order " + result.Order.OrderCode + " berhasil diproses ulang: " + result.Summary() + "."
+	http.Redirect(w, r, returnURL+"status=success&message="+url.QueryEscape(message), http.StatusSeeOther)
+}
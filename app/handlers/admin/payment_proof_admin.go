@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/helpers"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/services"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	"github.com/gorilla/mux"
+)
+
+const paymentProofsPerPage = 20
+
+var paymentProofStatusOptions = []ReviewStatusOption{
+	{Value: models.PaymentProofPending, Label: "Menunggu Verifikasi"},
+	{Value: models.PaymentProofApproved, Label: "Disetujui"},
+	{Value: models.PaymentProofRejected, Label: "Ditolak"},
+	{Value: "all", Label: "Semua"},
+}
+
+// GetPaymentProofsPage menampilkan antrean verifikasi bukti transfer manual, secara default hanya yang
+// masih menunggu.
+func (h *AdminHandler) GetPaymentProofsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	status := r.URL.Query().Get("filter")
+	if status == "" {
+		status = models.PaymentProofPending
+	}
+	statusQuery := status
+	if status == "all" {
+		statusQuery = ""
+	}
+	orderCode := strings.TrimSpace(r.URL.Query().Get("order_code"))
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageData := &AdminPaymentProofPageData{}
+	h.populateBaseDataForAdmin(r, pageData)
+
+	pageData.Title = "Verifikasi Transfer"
+	pageData.IsAuthPage = true
+	pageData.IsAdminPage = true
+	pageData.HideAdminWelcomeMessage = true
+	pageData.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Verifikasi Transfer", URL: "/admin/payment-proofs"},
+	}
+	pageData.Status = status
+	pageData.OrderCode = orderCode
+	pageData.StatusOptions = paymentProofStatusOptions
+	pageData.CurrentPage = page
+
+	proofs, total, err := h.proofRepo.GetForAdmin(ctx, statusQuery, orderCode, paymentProofsPerPage, (page-1)*paymentProofsPerPage)
+	if err != nil {
+		log.Printf("GetPaymentProofsPage: Gagal mengambil bukti transfer: %v", err)
+		pageData.Message = "Gagal mengambil daftar bukti transfer."
+		pageData.MessageStatus = "error"
+	}
+	pageData.Proofs = proofs
+	pageData.TotalPages = int((total + paymentProofsPerPage - 1) / paymentProofsPerPage)
+
+	counts, err := h.proofRepo.CountByStatus(ctx)
+	if err != nil {
+		log.Printf("GetPaymentProofsPage: Gagal menghitung bukti transfer: %v", err)
+	}
+	pageData.StatusCounts = counts
+
+	h.render.HTML(w, http.StatusOK, "admin/payment_proofs/index", pageData)
+}
+
+func (h *AdminHandler) ApprovePaymentProofPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	returnURL := "/admin/payment-proofs?"
+	reviewerID, _ := ctx.Value(helpers.ContextKeyUserID).(string)
+
+	result, err := h.transferSvc.ApproveProof(ctx, id, reviewerID)
+	if err != nil {
+		log.Printf("AdminHandler.ApprovePaymentProofPost: Gagal menyetujui bukti transfer %s: %v", id, err)
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Bukti transfer gagal disetujui: "+paymentProofAdminErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+
+	message := "Pembayaran order " + result.Order.OrderCode + " disetujui: " + result.Summary() + "."
+	http.Redirect(w, r, returnURL+"status=success&message="+url.QueryEscape(message), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) RejectPaymentProofPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	returnURL := "/admin/payment-proofs?"
+	reviewerID, _ := ctx.Value(helpers.ContextKeyUserID).(string)
+
+	if _, err := h.transferSvc.RejectProof(ctx, id, reviewerID, r.FormValue("reason")); err != nil {
+		log.Printf("AdminHandler.RejectPaymentProofPost: Gagal menolak bukti transfer %s: %v", id, err)
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Bukti transfer gagal ditolak: "+paymentProofAdminErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, returnURL+"status=success&message="+url.QueryEscape("Bukti transfer ditolak. Pembeli dapat mengunggah ulang sebelum batas waktu pembayaran."), http.StatusSeeOther)
+}
+
+func paymentProofAdminErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, services.ErrPaymentProofNotFound),
+		errors.Is(err, services.ErrPaymentProofReviewed),
+		errors.Is(err, services.ErrPaymentProofReasonRequired),
+		errors.Is(err, services.ErrManualTransferClosed):
+		return err.Error()
+	default:
+		return "terjadi kesalahan, silakan coba lagi"
+	}
+}
@@ -1,20 +1,19 @@
 package admin
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Rakhulsr/go-ecommerce/app/helpers"
 	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/services"
 	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
 	"github.com/Rakhulsr/go-ecommerce/app/utils/calc"
 	"github.com/go-playground/validator/v10"
@@ -24,7 +23,6 @@ import (
 )
 
 const (
-	UploadDir = "./static/uploads/products/"
 	MaxImages = 3
 )
 
@@ -91,6 +89,7 @@ func (h *AdminHandler) AddProductPage(w http.ResponseWriter, r *http.Request) {
 		},
 		Errors: make(map[string]string),
 	}
+	normalizeVariantForm(data.ProductData)
 	h.populateBaseDataForAdmin(r, data)
 
 	categories, err := h.categoryRepo.GetAll(r.Context())
@@ -100,6 +99,7 @@ func (h *AdminHandler) AddProductPage(w http.ResponseWriter, r *http.Request) {
 		data.MessageStatus = "error"
 	}
 	data.Categories = categories
+	data.TaxClasses = h.taxClassOptions(r)
 
 	data.Title = "Tambah Produk Baru"
 	data.IsAuthPage = true
@@ -134,6 +134,9 @@ func (h *AdminHandler) AddProductPost(w http.ResponseWriter, r *http.Request) {
 	form.Weight = r.PostFormValue("weight")
 	form.CategoryID = r.PostFormValue("category_id")
 	form.DiscountPercent = r.PostFormValue("discount_percent")
+	form.TaxClassID = r.PostFormValue("tax_class_id")
+	form.OptionNames, form.Variants = h.parseVariantForm(r)
+	normalizeVariantForm(&form)
 
 	if err := h.validator.Struct(&form); err != nil {
 		validationErrors := err.(validator.ValidationErrors)
@@ -154,6 +157,7 @@ func (h *AdminHandler) AddProductPost(w http.ResponseWriter, r *http.Request) {
 		}
 
 		data.Categories = categories
+		data.TaxClasses = h.taxClassOptions(r)
 		data.Title = "Tambah Produk Baru"
 		data.IsAuthPage = true
 		data.IsAdminPage = true
@@ -228,14 +232,20 @@ func (h *AdminHandler) AddProductPost(w http.ResponseWriter, r *http.Request) {
 		Description:     form.Description,
 		Sku:             form.SKU,
 		Price:           price,
-		Stock:           stock,
 		Weight:          weight,
 		Slug:            productSlug,
 		DiscountPercent: discountPercent,
 		DiscountAmount:  discountAmount,
+		TaxClassID:      form.TaxClassID,
 	}
 	product.Categories = []models.Category{*category}
 
+	options, variants, variantErrs := h.buildVariants(r.Context(), product, form.OptionNames, form.Variants)
+	if len(variantErrs) > 0 {
+		h.handleFormError(w, r, "/admin/products/add", "Data varian tidak valid.", &form, variantErrs)
+		return
+	}
+
 	files := r.MultipartForm.File["product_images"]
 	if len(files) > MaxImages {
 		h.handleFormError(w, r, "/admin/products/add", fmt.Sprintf("Anda hanya dapat mengunggah maksimal %d gambar.", MaxImages), &form, map[string]string{"product_images": fmt.Sprintf("Maksimal %d gambar.", MaxImages)})
@@ -245,24 +255,27 @@ func (h *AdminHandler) AddProductPost(w http.ResponseWriter, r *http.Request) {
 	var productImages []models.ProductImage
 	for _, fileHeader := range files {
 		log.Printf("AddProductPost: Memproses file: %s", fileHeader.Filename)
-		imagePath, err := h.saveProductImage(fileHeader)
+		image, err := h.saveProductImage(r.Context(), fileHeader)
 		if err != nil {
 			log.Printf("AddProductPost: Gagal menyimpan gambar: %v", err)
-			h.handleFormError(w, r, "/admin/products/add", "Gagal menyimpan salah satu gambar.", &form, nil)
+			for _, saved := range productImages {
+				h.imageSvc.RemoveFiles(r.Context(), saved)
+			}
+			h.handleFormError(w, r, "/admin/products/add", imageUploadErrorMessage(err, "Gagal menyimpan salah satu gambar."), &form, map[string]string{"product_images": imageUploadErrorMessage(err, "Gambar tidak valid.")})
 			return
 		}
-		productImages = append(productImages, models.ProductImage{
-			ID:         uuid.New().String(),
-			ProductID:  newProductID,
-			Path:       imagePath,
-			ExtraLarge: imagePath,
-			Large:      imagePath,
-			Medium:     imagePath,
-			Small:      imagePath,
-		})
+		image.ID = uuid.New().String()
+		image.ProductID = newProductID
+		productImages = append(productImages, *image)
 	}
 	product.ProductImages = productImages
 
+	if err := h.attachVariantImages(r, form.Variants, variants); err != nil {
+		log.Printf("AddProductPost: %v", err)
+		h.handleFormError(w, r, "/admin/products/add", "Gagal menyimpan gambar varian.", &form, nil)
+		return
+	}
+
 	err = h.productRepo.CreateProduct(r.Context(), product)
 	if err != nil {
 		log.Printf("AddProductPost: Gagal membuat produk di repository: %v", err)
@@ -270,6 +283,21 @@ func (h *AdminHandler) AddProductPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	stockRef := stockActorReference(r)
+	if err := h.variantRepo.SyncVariants(r.Context(), product.ID, options, variants, stockRef); err != nil {
+		log.Printf("AddProductPost: Gagal menyimpan varian produk %s: %v", product.ID, err)
+		http.Redirect(w, r, fmt.Sprintf("/admin/products/edit/%s?status=error&message=%s", product.ID, url.QueryEscape("Produk tersimpan, tetapi varian gagal disimpan: "+err.Error())), http.StatusSeeOther)
+		return
+	}
+
+	if len(variants) == 0 {
+		if _, err := h.stockRepo.SetStock(r.Context(), product.ID, "", stock, models.StockMovementManual, stockRef, "Stok awal"); err != nil {
+			log.Printf("AddProductPost: Gagal mencatat stok awal produk %s: %v", product.ID, err)
+			http.Redirect(w, r, fmt.Sprintf("/admin/products/edit/%s?status=error&message=%s", product.ID, url.QueryEscape("Produk tersimpan, tetapi stok gagal disimpan: "+err.Error())), http.StatusSeeOther)
+			return
+		}
+	}
+
 	http.Redirect(w, r, fmt.Sprintf("/admin/products?status=success&message=%s", url.QueryEscape("Produk berhasil ditambahkan!")), http.StatusSeeOther)
 }
 
@@ -298,8 +326,11 @@ func (h *AdminHandler) EditProductPage(w http.ResponseWriter, r *http.Request) {
 		Stock:           fmt.Sprintf("%d", product.Stock),
 		Weight:          product.Weight.String(),
 		DiscountPercent: product.DiscountPercent.String(),
-		ExistingImages:  product.ProductImages,
+		TaxClassID:      product.TaxClassID,
+		ExistingImages:  productLevelImages(product.ProductImages),
 	}
+	formData.OptionNames, formData.Variants = variantFormsFromProduct(product)
+	normalizeVariantForm(&formData)
 
 	if len(product.Categories) > 0 {
 		formData.CategoryID = product.Categories[0].ID
@@ -320,6 +351,7 @@ func (h *AdminHandler) EditProductPage(w http.ResponseWriter, r *http.Request) {
 		data.MessageStatus = "error"
 	}
 	data.Categories = categories
+	data.TaxClasses = h.taxClassOptions(r)
 
 	data.Title = "Edit Produk"
 	data.IsAuthPage = true
@@ -351,6 +383,8 @@ func (h *AdminHandler) EditProductPost(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, fmt.Sprintf("/admin/products?status=error&message=%s", url.QueryEscape("Produk tidak ditemukan.")), http.StatusSeeOther)
 		return
 	}
+	alertBefore := services.NewProductAlertState(product)
+	product.ProductImages = productLevelImages(product.ProductImages)
 
 	err = r.ParseMultipartForm(10 << 20)
 	if err != nil {
@@ -370,6 +404,9 @@ func (h *AdminHandler) EditProductPost(w http.ResponseWriter, r *http.Request) {
 	form.Weight = r.PostFormValue("weight")
 	form.CategoryID = r.PostFormValue("category_id")
 	form.DiscountPercent = r.PostFormValue("discount_percent")
+	form.TaxClassID = r.PostFormValue("tax_class_id")
+	form.OptionNames, form.Variants = h.parseVariantForm(r)
+	normalizeVariantForm(&form)
 
 	log.Printf("EditProductPost: Form diterima untuk produk %s - Nama: %s, SKU: %s, Harga: %s, Stok: %s, Weight: %s, CategoryID: %s, DiscountPercent: %s",
 		productID, form.Name, form.SKU, form.Price, form.Stock, form.Weight, form.CategoryID, form.DiscountPercent)
@@ -420,6 +457,13 @@ func (h *AdminHandler) EditProductPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	options, variants, variantErrs := h.buildVariants(r.Context(), product, form.OptionNames, form.Variants)
+	if len(variantErrs) > 0 {
+		form.ExistingImages = product.ProductImages
+		h.handleFormError(w, r, fmt.Sprintf("/admin/products/edit/%s", productID), "Data varian tidak valid.", &form, variantErrs)
+		return
+	}
+
 	if product.Name != form.Name {
 		product.Slug = helpers.GenerateSlug(form.Name) + "-" + product.ID[:8]
 	}
@@ -428,10 +472,10 @@ func (h *AdminHandler) EditProductPost(w http.ResponseWriter, r *http.Request) {
 	product.Description = form.Description
 	product.Sku = form.SKU
 	product.Price = price
-	product.Stock = stock
 	product.Weight = weight
 	product.DiscountPercent = discountPercent
 	product.DiscountAmount = calc.CalculateDiscount(price, discountPercent)
+	product.TaxClassID = form.TaxClassID
 	product.UpdatedAt = time.Now()
 	product.UserID = userID
 
@@ -455,24 +499,10 @@ func (h *AdminHandler) EditProductPost(w http.ResponseWriter, r *http.Request) {
 
 	}
 
+	// gambar yang tidak dipertahankan dihapus (record dan file di storage) oleh UpdateProduct setelah commit
 	for _, img := range product.ProductImages {
-
 		if retainedImageIDsMap[img.ID] {
 			finalProductImages = append(finalProductImages, img)
-		} else {
-
-			if img.Path != "" {
-				fullPath := filepath.Join(".", img.Path)
-				if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-					log.Printf("EditProductPost: Gagal menghapus file fisik gambar lama yang tidak dipertahankan %s: remove %s: The system cannot find the file specified. (File already gone)", fullPath, fullPath)
-				} else if err := os.Remove(fullPath); err != nil {
-					log.Printf("EditProductPost: Gagal menghapus file fisik gambar lama yang tidak dipertahankan %s: %v", fullPath, err)
-				} else {
-					log.Printf("EditProductPost: Berhasil menghapus file fisik gambar lama yang tidak dipertahankan: %s", fullPath)
-				}
-			} else {
-				log.Printf("EditProductPost: Melewatkan penghapusan gambar lama karena path kosong untuk ID: %s", img.ID)
-			}
 		}
 	}
 
@@ -483,25 +513,22 @@ func (h *AdminHandler) EditProductPost(w http.ResponseWriter, r *http.Request) {
 		if fileHeader.Size == 0 {
 			continue
 		}
-		imagePath, err := h.saveProductImage(fileHeader)
+		image, err := h.saveProductImage(r.Context(), fileHeader)
 		if err != nil {
 			log.Printf("EditProductPost: Gagal menyimpan gambar baru: %v", err)
+			for _, saved := range uploadedProductImages {
+				h.imageSvc.RemoveFiles(r.Context(), saved)
+			}
 
 			form.ExistingImages = finalProductImages
-			h.handleFormError(w, r, fmt.Sprintf("/admin/products/edit/%s", productID), "Gagal menyimpan salah satu gambar baru.", &form, nil)
+			h.handleFormError(w, r, fmt.Sprintf("/admin/products/edit/%s", productID), imageUploadErrorMessage(err, "Gagal menyimpan salah satu gambar baru."), &form, map[string]string{"product_images": imageUploadErrorMessage(err, "Gambar tidak valid.")})
 			return
 		}
-		uploadedProductImages = append(uploadedProductImages, models.ProductImage{
-			ID:         uuid.New().String(),
-			ProductID:  productID,
-			Path:       imagePath,
-			ExtraLarge: imagePath,
-			Large:      imagePath,
-			Medium:     imagePath,
-			Small:      imagePath,
-			CreatedAt:  time.Now(),
-			UpdatedAt:  time.Now(),
-		})
+		image.ID = uuid.New().String()
+		image.ProductID = productID
+		image.CreatedAt = time.Now()
+		image.UpdatedAt = time.Now()
+		uploadedProductImages = append(uploadedProductImages, *image)
 	}
 
 	product.ProductImages = append(finalProductImages, uploadedProductImages...)
@@ -509,12 +536,8 @@ func (h *AdminHandler) EditProductPost(w http.ResponseWriter, r *http.Request) {
 	if len(product.ProductImages) > MaxImages {
 
 		for _, img := range uploadedProductImages {
-			fullPath := filepath.Join(".", img.Path)
-			if err := os.Remove(fullPath); err != nil {
-				log.Printf("EditProductPost: Gagal menghapus file fisik baru karena melebihi batas %s: %v", fullPath, err)
-			} else {
-				log.Printf("EditProductPost: Berhasil menghapus file fisik baru karena melebihi batas: %s", fullPath)
-			}
+			h.imageSvc.RemoveFiles(r.Context(), img)
+			log.Printf("EditProductPost: Menghapus file gambar baru karena melebihi batas: %s", img.Path)
 		}
 
 		product.ProductImages = finalProductImages
@@ -523,6 +546,13 @@ func (h *AdminHandler) EditProductPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.attachVariantImages(r, form.Variants, variants); err != nil {
+		log.Printf("EditProductPost: %v", err)
+		form.ExistingImages = product.ProductImages
+		h.handleFormError(w, r, fmt.Sprintf("/admin/products/edit/%s", productID), "Gagal menyimpan gambar varian.", &form, nil)
+		return
+	}
+
 	err = h.productRepo.UpdateProduct(r.Context(), product)
 	if err != nil {
 		log.Printf("EditProductPost: GAGAL memperbarui produk %s: %v", productID, err)
@@ -532,6 +562,25 @@ func (h *AdminHandler) EditProductPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	stockRef := stockActorReference(r)
+	if err := h.variantRepo.SyncVariants(r.Context(), product.ID, options, variants, stockRef); err != nil {
+		log.Printf("EditProductPost: Gagal menyimpan varian produk %s: %v", productID, err)
+		form.ExistingImages = product.ProductImages
+		h.handleFormError(w, r, fmt.Sprintf("/admin/products/edit/%s", productID), "Gagal menyimpan varian: "+err.Error(), &form, nil)
+		return
+	}
+
+	if len(variants) == 0 {
+		if _, err := h.stockRepo.SetStock(r.Context(), product.ID, "", stock, models.StockMovementManual, stockRef, "Perubahan stok dari form produk"); err != nil {
+			log.Printf("EditProductPost: Gagal memperbarui stok produk %s: %v", productID, err)
+			form.ExistingImages = product.ProductImages
+			h.handleFormError(w, r, fmt.Sprintf("/admin/products/edit/%s", productID), "Gagal memperbarui stok: "+err.Error(), &form, nil)
+			return
+		}
+	}
+
+	h.wishlistSvc.NotifyProductChange(r.Context(), product.ID, alertBefore)
+
 	http.Redirect(w, r, "/admin/products?status=success&message="+url.QueryEscape("Produk berhasil diperbarui!"), http.StatusSeeOther)
 }
 
@@ -571,15 +620,6 @@ func (h *AdminHandler) DeleteProductPost(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	for _, img := range product.ProductImages {
-		fullPath := filepath.Join(".", img.Path)
-		if err := os.Remove(fullPath); err != nil {
-			log.Printf("DeleteProductPost: Gagal menghapus file gambar fisik %s untuk produk %s: %v", fullPath, productID, err)
-		} else {
-			log.Printf("DeleteProductPost: Berhasil menghapus file gambar fisik: %s", fullPath)
-		}
-	}
-
 	err = h.productRepo.DeleteProduct(r.Context(), productID)
 	if err != nil {
 		log.Printf("DeleteProductPost: Gagal menghapus produk %s dari database: %v", productID, err)
@@ -606,6 +646,7 @@ func (h *AdminHandler) handleFormError(w http.ResponseWriter, r *http.Request, r
 		log.Printf("handleFormError: Gagal mengambil kategori: %v", catErr)
 	}
 	data.Categories = categories
+	data.TaxClasses = h.taxClassOptions(r)
 	data.Message = msg
 	data.MessageStatus = "error"
 	data.Title = "Tambah Produk Baru"
@@ -622,10 +663,15 @@ func (h *AdminHandler) handleFormError(w http.ResponseWriter, r *http.Request, r
 			{Name: "Produk", URL: "/admin/products"}, {Name: "Edit", URL: redirectURL},
 		}
 
-		if len(formData.ExistingImages) == 0 {
+		if len(formData.ExistingImages) == 0 || formData.Variants == nil {
 			product, err := h.productRepo.GetByID(r.Context(), formData.ID)
 			if err == nil && product != nil {
-				formData.ExistingImages = product.ProductImages
+				if len(formData.ExistingImages) == 0 {
+					formData.ExistingImages = productLevelImages(product.ProductImages)
+				}
+				if formData.Variants == nil {
+					formData.OptionNames, formData.Variants = variantFormsFromProduct(product)
+				}
 			}
 		}
 	} else {
@@ -634,39 +680,20 @@ func (h *AdminHandler) handleFormError(w http.ResponseWriter, r *http.Request, r
 			{Name: "Produk", URL: "/admin/products"}, {Name: "Tambah Baru", URL: redirectURL},
 		}
 	}
+	normalizeVariantForm(formData)
 	h.render.HTML(w, http.StatusOK, "admin/products/form", data)
 }
 
-func (h *AdminHandler) saveProductImage(fileHeader *multipart.FileHeader) (string, error) {
-
-	if err := os.MkdirAll(UploadDir, 0755); err != nil {
-		log.Printf("saveProductImage: Gagal membuat direktori upload: %v", err)
-		return "", fmt.Errorf("gagal membuat direktori upload: %w", err)
-	}
-
-	file, err := fileHeader.Open()
-	if err != nil {
-		log.Printf("saveProductImage: Gagal membuka file yang diunggah: %v", err)
-		return "", fmt.Errorf("gagal membuka file yang diunggah: %w", err)
-	}
-	defer file.Close()
-
-	extension := filepath.Ext(fileHeader.Filename)
-	uniqueFileName := uuid.New().String() + extension
-	filePath := filepath.Join(UploadDir, uniqueFileName)
-
-	outFile, err := os.Create(filePath)
-	if err != nil {
-		log.Printf("saveProductImage: Gagal menyalin file yang diunggah: %v", err)
-		log.Printf("saveProductImage: Menyimpan file ke: %s", filePath)
-		return "", fmt.Errorf("gagal membuat file di server: %w", err)
-	}
-	defer outFile.Close()
+// saveProductImage memproses upload lewat pipeline gambar dan mengembalikan ProductImage berisi path
+// master serta keempat ukurannya.
+func (h *AdminHandler) saveProductImage(ctx context.Context, fileHeader *multipart.FileHeader) (*models.ProductImage, error) {
+	return h.imageSvc.SaveUpload(ctx, fileHeader)
+}
 
-	_, err = io.Copy(outFile, file)
-	if err != nil {
-		return "", fmt.Errorf("gagal menyalin file yang diunggah: %w", err)
+// imageUploadErrorMessage menampilkan alasan validasi gambar ke admin; error lain cukup pesan umum.
+func imageUploadErrorMessage(err error, fallback string) string {
+	if services.IsImageValidationError(err) {
+		return fallback + " " + err.Error() + "."
 	}
-
-	return "/static/uploads/products/" + uniqueFileName, nil
+	return fallback
 }
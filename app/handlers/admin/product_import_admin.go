@@ -0,0 +1,157 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/services"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/spreadsheet"
+)
+
+const productImportMaxFileSize = 10 << 20
+
+func (h *AdminHandler) ImportProductsPage(w http.ResponseWriter, r *http.Request) {
+	h.renderImportPage(w, r, &AdminProductImportPageData{})
+}
+
+// ImportProductsPreviewPost membaca file upload lalu menampilkan hasil dry-run per baris. Baris yang sudah
+// dinormalisasi dibawa ke langkah apply lewat field tersembunyi sehingga file tidak perlu disimpan di server.
+func (h *AdminHandler) ImportProductsPreviewPost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, productImportMaxFileSize+1<<20)
+	if err := r.ParseMultipartForm(productImportMaxFileSize); err != nil {
+		h.renderImportError(w, r, &AdminProductImportPageData{}, "File terlalu besar, maksimal 10MB.")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		h.renderImportError(w, r, &AdminProductImportPageData{}, "Pilih file CSV atau XLSX terlebih dahulu.")
+		return
+	}
+	defer file.Close()
+
+	pageData := &AdminProductImportPageData{FileName: header.Filename}
+	format, err := spreadsheet.FormatFromFilename(header.Filename)
+	if err != nil {
+		h.renderImportError(w, r, pageData, "Format file harus .csv atau .xlsx.")
+		return
+	}
+
+	rows, err := h.importSvc.ParseRows(file, format)
+	if err != nil {
+		h.renderImportError(w, r, pageData, "File tidak dapat dibaca: "+err.Error())
+		return
+	}
+
+	report, err := h.importSvc.Preview(r.Context(), rows, services.ProductImportOptions{Reference: stockActorReference(r)})
+	if err != nil {
+		log.Printf("ImportProductsPreviewPost: Gagal memvalidasi import: %v", err)
+		h.renderImportError(w, r, pageData, "Gagal memvalidasi file import.")
+		return
+	}
+
+	var payload bytes.Buffer
+	if err := h.importSvc.EncodeRows(&payload, rows); err != nil {
+		log.Printf("ImportProductsPreviewPost: Gagal menyiapkan data import: %v", err)
+		h.renderImportError(w, r, pageData, "Gagal menyiapkan data import.")
+		return
+	}
+	pageData.Report = report
+	pageData.Payload = payload.String()
+
+	if report.HasErrors() {
+		pageData.Message = fmt.Sprintf("%d baris tidak valid. Perbaiki file lalu unggah ulang.", report.Invalid)
+		pageData.MessageStatus = "error"
+	} else {
+		pageData.Message = fmt.Sprintf("Preview: %d produk baru dan %d produk diperbarui. Belum ada data yang disimpan.", report.Created, report.Updated)
+		pageData.MessageStatus = "info"
+	}
+	h.renderImportPage(w, r, pageData)
+}
+
+func (h *AdminHandler) ImportProductsApplyPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.renderImportError(w, r, &AdminProductImportPageData{}, "Data import tidak valid.")
+		return
+	}
+
+	pageData := &AdminProductImportPageData{FileName: r.FormValue("file_name")}
+	payload := r.FormValue("payload")
+	rows, err := h.importSvc.ParseRows(strings.NewReader(payload), spreadsheet.FormatCSV)
+	if err != nil {
+		h.renderImportError(w, r, pageData, "Data import tidak valid: "+err.Error())
+		return
+	}
+
+	report, err := h.importSvc.Apply(r.Context(), rows, services.ProductImportOptions{Reference: stockActorReference(r)})
+	if err != nil {
+		log.Printf("ImportProductsApplyPost: Import gagal: %v", err)
+		pageData.Report = report
+		pageData.Payload = payload
+		if errors.Is(err, services.ErrProductImportInvalid) {
+			h.renderImportError(w, r, pageData, "Import dibatalkan karena ada baris yang tidak valid. Tidak ada data yang disimpan.")
+			return
+		}
+		h.renderImportError(w, r, pageData, "Import gagal, tidak ada data yang disimpan: "+err.Error())
+		return
+	}
+
+	message := fmt.Sprintf("Import selesai: %d produk baru, %d produk diperbarui.", report.Created, report.Updated)
+	http.Redirect(w, r, "/admin/products?status=success&message="+url.QueryEscape(message), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = spreadsheet.FormatCSV
+	}
+	if format != spreadsheet.FormatCSV && format != spreadsheet.FormatXLSX {
+		http.Redirect(w, r, "/admin/products?status=error&message="+url.QueryEscape("Format export harus csv atau xlsx."), http.StatusSeeOther)
+		return
+	}
+
+	var buf bytes.Buffer
+	if _, err := h.importSvc.Export(r.Context(), &buf, format); err != nil {
+		log.Printf("ExportProducts: Gagal export produk: %v", err)
+		http.Redirect(w, r, "/admin/products?status=error&message="+url.QueryEscape("Gagal export produk."), http.StatusSeeOther)
+		return
+	}
+
+	fileName := fmt.Sprintf("products-%s.%s", time.Now().Format("20060102-150405"), format)
+	w.Header().Set("Content-Type", spreadsheet.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	w.Write(buf.Bytes())
+}
+
+func (h *AdminHandler) renderImportError(w http.ResponseWriter, r *http.Request, pageData *AdminProductImportPageData, message string) {
+	pageData.Message = message
+	pageData.MessageStatus = "error"
+	h.renderImportPage(w, r, pageData)
+}
+
+func (h *AdminHandler) renderImportPage(w http.ResponseWriter, r *http.Request, pageData *AdminProductImportPageData) {
+	message, status := pageData.Message, pageData.MessageStatus
+	h.populateBaseDataForAdmin(r, pageData)
+	if message != "" {
+		pageData.Message, pageData.MessageStatus = message, status
+	}
+
+	pageData.Title = "Import Produk"
+	pageData.IsAuthPage = true
+	pageData.IsAdminPage = true
+	pageData.HideAdminWelcomeMessage = true
+	pageData.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Produk", URL: "/admin/products"},
+		{Name: "Import", URL: "/admin/products/import"},
+	}
+	h.render.HTML(w, http.StatusOK, "admin/products/import", pageData)
+}
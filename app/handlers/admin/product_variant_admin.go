@@ -0,0 +1,258 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/shopspring/decimal"
+)
+
+const MaxVariantOptions = 2
+
+var variantSKUPattern = regexp.MustCompile(`^[A-Za-z0-9\-_]{3,50}$`)
+
+func (h *AdminHandler) parseVariantForm(r *http.Request) ([]string, []VariantForm) {
+	optionNames := make([]string, MaxVariantOptions)
+	for i, name := range r.PostForm["option_name"] {
+		if i >= MaxVariantOptions {
+			break
+		}
+		optionNames[i] = strings.TrimSpace(name)
+	}
+
+	keys := r.PostForm["variant_key"]
+	ids := r.PostForm["variant_id"]
+	skus := r.PostForm["variant_sku"]
+	prices := r.PostForm["variant_price"]
+	stocks := r.PostForm["variant_stock"]
+	weights := r.PostForm["variant_weight"]
+
+	valueColumns := make([][]string, MaxVariantOptions)
+	for i := 0; i < MaxVariantOptions; i++ {
+		valueColumns[i] = r.PostForm[fmt.Sprintf("variant_value_%d", i+1)]
+	}
+
+	variants := make([]VariantForm, 0, len(keys))
+	for i, key := range keys {
+		v := VariantForm{
+			Key:    key,
+			ID:     formValueAt(ids, i),
+			SKU:    strings.TrimSpace(formValueAt(skus, i)),
+			Price:  strings.TrimSpace(formValueAt(prices, i)),
+			Stock:  strings.TrimSpace(formValueAt(stocks, i)),
+			Weight: strings.TrimSpace(formValueAt(weights, i)),
+			Values: make([]string, MaxVariantOptions),
+		}
+		for j := 0; j < MaxVariantOptions; j++ {
+			v.Values[j] = strings.TrimSpace(formValueAt(valueColumns[j], i))
+		}
+		variants = append(variants, v)
+	}
+
+	return optionNames, variants
+}
+
+func formValueAt(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+func (h *AdminHandler) buildVariants(ctx context.Context, product *models.Product, optionNames []string, forms []VariantForm) ([]models.ProductOption, []models.ProductVariant, map[string]string) {
+	errs := make(map[string]string)
+	if len(forms) == 0 {
+		return nil, nil, errs
+	}
+
+	activeOptions := []int{}
+	for i, name := range optionNames {
+		if name != "" {
+			activeOptions = append(activeOptions, i)
+		}
+	}
+	if len(activeOptions) == 0 {
+		errs["variants"] = "Isi minimal satu nama opsi (misal: Ukuran) untuk varian."
+		return nil, nil, errs
+	}
+
+	options := make([]models.ProductOption, len(activeOptions))
+	seenValues := make([]map[string]bool, len(activeOptions))
+	for pos, optIdx := range activeOptions {
+		options[pos] = models.ProductOption{Name: optionNames[optIdx]}
+		seenValues[pos] = make(map[string]bool)
+	}
+
+	seenSKUs := make(map[string]bool)
+	seenCombos := make(map[string]bool)
+	variants := make([]models.ProductVariant, 0, len(forms))
+
+	for rowIdx, f := range forms {
+		row := rowIdx + 1
+
+		if f.ID != "" && product.FindVariant(f.ID) == nil {
+			errs["variants"] = fmt.Sprintf("Varian baris %d tidak valid untuk produk ini.", row)
+			return nil, nil, errs
+		}
+
+		values := make([]string, 0, len(activeOptions))
+		optionValues := make([]models.ProductOptionValue, 0, len(activeOptions))
+		for pos, optIdx := range activeOptions {
+			value := f.Values[optIdx]
+			if value == "" {
+				errs["variants"] = fmt.Sprintf("Nilai opsi %s pada baris %d wajib diisi.", optionNames[optIdx], row)
+				return nil, nil, errs
+			}
+			if !seenValues[pos][value] {
+				seenValues[pos][value] = true
+				options[pos].Values = append(options[pos].Values, models.ProductOptionValue{Value: value})
+			}
+			values = append(values, value)
+			optionValues = append(optionValues, models.ProductOptionValue{Value: value})
+		}
+
+		combo := strings.ToLower(strings.Join(values, "|"))
+		if seenCombos[combo] {
+			errs["variants"] = fmt.Sprintf("Kombinasi varian %s muncul lebih dari sekali.", strings.Join(values, " / "))
+			return nil, nil, errs
+		}
+		seenCombos[combo] = true
+
+		if !variantSKUPattern.MatchString(f.SKU) {
+			errs["variants"] = fmt.Sprintf("SKU varian baris %d harus 3-50 karakter (huruf, angka, - atau _).", row)
+			return nil, nil, errs
+		}
+		if seenSKUs[f.SKU] {
+			errs["variants"] = fmt.Sprintf("SKU varian %s digunakan lebih dari sekali.", f.SKU)
+			return nil, nil, errs
+		}
+		seenSKUs[f.SKU] = true
+
+		skuExists, err := h.variantRepo.IsSKUExists(ctx, f.SKU, f.ID)
+		if err != nil {
+			log.Printf("buildVariants: Gagal mengecek SKU varian %s: %v", f.SKU, err)
+			errs["variants"] = "Gagal mengecek SKU varian."
+			return nil, nil, errs
+		}
+		if skuExists {
+			errs["variants"] = fmt.Sprintf("SKU varian %s sudah digunakan.", f.SKU)
+			return nil, nil, errs
+		}
+
+		price, err := decimal.NewFromString(f.Price)
+		if err != nil || price.LessThan(decimal.Zero) {
+			errs["variants"] = fmt.Sprintf("Harga varian baris %d tidak valid.", row)
+			return nil, nil, errs
+		}
+		stock, err := strconv.Atoi(f.Stock)
+		if err != nil || stock < 0 {
+			errs["variants"] = fmt.Sprintf("Stok varian baris %d tidak valid.", row)
+			return nil, nil, errs
+		}
+		weight, err := decimal.NewFromString(f.Weight)
+		if err != nil || weight.LessThan(decimal.Zero) {
+			errs["variants"] = fmt.Sprintf("Berat varian baris %d tidak valid.", row)
+			return nil, nil, errs
+		}
+
+		variant := models.ProductVariant{
+			ID:           f.ID,
+			Sku:          f.SKU,
+			Name:         strings.Join(values, " / "),
+			Price:        price,
+			Stock:        stock,
+			Weight:       weight,
+			OptionValues: optionValues,
+		}
+		variants = append(variants, variant)
+	}
+
+	return options, variants, errs
+}
+
+// attachVariantImages menyimpan gambar yang diunggah per baris varian (field variant_image_<key>).
+func (h *AdminHandler) attachVariantImages(r *http.Request, forms []VariantForm, variants []models.ProductVariant) error {
+	if r.MultipartForm == nil {
+		return nil
+	}
+	for i, f := range forms {
+		if i >= len(variants) {
+			break
+		}
+		files := r.MultipartForm.File["variant_image_"+f.Key]
+		if len(files) == 0 || files[0].Size == 0 {
+			continue
+		}
+		image, err := h.saveProductImage(r.Context(), files[0])
+		if err != nil {
+			return fmt.Errorf("gagal menyimpan gambar varian %s: %w", f.SKU, err)
+		}
+		variants[i].Images = []models.ProductImage{*image}
+	}
+	return nil
+}
+
+func variantFormsFromProduct(product *models.Product) ([]string, []VariantForm) {
+	optionNames := make([]string, MaxVariantOptions)
+	for i, opt := range product.Options {
+		if i >= MaxVariantOptions {
+			break
+		}
+		optionNames[i] = opt.Name
+	}
+
+	forms := make([]VariantForm, 0, len(product.Variants))
+	for _, v := range product.Variants {
+		f := VariantForm{
+			Key:    v.ID,
+			ID:     v.ID,
+			SKU:    v.Sku,
+			Price:  v.Price.String(),
+			Stock:  strconv.Itoa(v.Stock),
+			Weight: v.Weight.String(),
+			Values: make([]string, MaxVariantOptions),
+		}
+		for i, opt := range product.Options {
+			if i >= MaxVariantOptions {
+				break
+			}
+			for _, ov := range v.OptionValues {
+				if ov.OptionID == opt.ID {
+					f.Values[i] = ov.Value
+				}
+			}
+		}
+		if len(v.Images) > 0 {
+			f.ExistingPath = v.Images[0].Small
+		}
+		forms = append(forms, f)
+	}
+	return optionNames, forms
+}
+
+func productLevelImages(images []models.ProductImage) []models.ProductImage {
+	result := make([]models.ProductImage, 0, len(images))
+	for _, img := range images {
+		if img.VariantID == "" {
+			result = append(result, img)
+		}
+	}
+	return result
+}
+
+func normalizeVariantForm(form *ProductForm) {
+	for len(form.OptionNames) < MaxVariantOptions {
+		form.OptionNames = append(form.OptionNames, "")
+	}
+	for i := range form.Variants {
+		for len(form.Variants[i].Values) < MaxVariantOptions {
+			form.Variants[i].Values = append(form.Variants[i].Values, "")
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/helpers"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/models/other"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+type AdminPromotionPageData struct {
+	other.BasePageData
+	Promotions         []models.Promotion
+	PromotionData      *PromotionForm
+	IsEdit             bool
+	FormAction         string
+	Errors             map[string]string
+	Categories         []models.Category
+	Products           []models.Product
+	SelectedCategories map[string]bool
+	SelectedProducts   map[string]bool
+	Now                time.Time
+}
+
+type PromotionForm struct {
+	ID          string
+	Name        string `form:"name" validate:"required,min=3,max=100"`
+	Scope       string `form:"scope" validate:"required,oneof=all categories products"`
+	Type        string `form:"type" validate:"required,oneof=percent fixed"`
+	Value       string `form:"value" validate:"required,numeric"`
+	StartsAt    string `form:"starts_at" validate:"required"`
+	EndsAt      string `form:"ends_at"`
+	Priority    string `form:"priority" validate:"omitempty,numeric"`
+	Stackable   bool
+	IsActive    bool
+	CategoryIDs []string
+	ProductIDs  []string
+}
+
+func (h *AdminHandler) GetPromotionsPage(w http.ResponseWriter, r *http.Request) {
+	data := &AdminPromotionPageData{Now: time.Now()}
+	h.populateBaseDataForAdmin(r, data)
+
+	data.Title = "Manajemen Promosi"
+	data.IsAuthPage = true
+	data.IsAdminPage = true
+	data.HideAdminWelcomeMessage = true
+	data.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Promosi", URL: "/admin/promotions"},
+	}
+
+	promotions, err := h.promoRepo.GetAll(r.Context())
+	if err != nil {
+		log.Printf("GetPromotionsPage: Gagal mengambil daftar promosi: %v", err)
+		data.Message = "Gagal mengambil daftar promosi."
+		data.MessageStatus = "error"
+	}
+	data.Promotions = promotions
+
+	h.render.HTML(w, http.StatusOK, "admin/promotions/index", data)
+}
+
+func (h *AdminHandler) AddPromotionPage(w http.ResponseWriter, r *http.Request) {
+	data := &AdminPromotionPageData{
+		FormAction: "/admin/promotions/add",
+		IsEdit:     false,
+		PromotionData: &PromotionForm{
+			Scope:    models.PromotionScopeAll,
+			Type:     models.PromotionTypePercent,
+			StartsAt: time.Now().Format(adminDateTimeLayout),
+			Priority: "0",
+			IsActive: true,
+		},
+		Errors: make(map[string]string),
+	}
+	h.renderPromotionForm(w, r, data)
+}
+
+func (h *AdminHandler) AddPromotionPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		log.Printf("AddPromotionPost: Kesalahan parsing form: %v", err)
+		http.Redirect(w, r, "/admin/promotions/add?status=error&message="+url.QueryEscape("Kesalahan parsing form."), http.StatusSeeOther)
+		return
+	}
+
+	form := promotionFormFromRequest(r)
+	promotion := &models.Promotion{}
+	errs := h.applyPromotionForm(&form, promotion)
+	if len(errs) > 0 {
+		h.renderPromotionForm(w, r, &AdminPromotionPageData{
+			FormAction:    "/admin/promotions/add",
+			IsEdit:        false,
+			PromotionData: &form,
+			Errors:        errs,
+		})
+		return
+	}
+
+	before := h.wishlistSvc.SnapshotWatchedProducts(r.Context())
+	promotion.Categories = categoriesFromIDs(form.CategoryIDs)
+	promotion.Products = productsFromIDs(form.ProductIDs)
+	if err := h.promoRepo.Create(r.Context(), promotion); err != nil {
+		log.Printf("AddPromotionPost: Gagal membuat promosi: %v", err)
+		http.Redirect(w, r, "/admin/promotions/add?status=error&message="+url.QueryEscape("Gagal menambahkan promosi: "+err.Error()), http.StatusSeeOther)
+		return
+	}
+	h.wishlistSvc.NotifyWatchedProducts(r.Context(), before)
+
+	http.Redirect(w, r, "/admin/promotions?status=success&message="+url.QueryEscape("Promosi berhasil ditambahkan."), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) EditPromotionPage(w http.ResponseWriter, r *http.Request) {
+	promotionID := mux.Vars(r)["id"]
+
+	promotion, err := h.promoRepo.FindByID(r.Context(), promotionID)
+	if err != nil || promotion == nil {
+		log.Printf("EditPromotionPage: Promosi %s tidak ditemukan: %v", promotionID, err)
+		http.Redirect(w, r, "/admin/promotions?status=error&message="+url.QueryEscape("Promosi tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+
+	form := PromotionForm{
+		ID:        promotion.ID,
+		Name:      promotion.Name,
+		Scope:     promotion.Scope,
+		Type:      promotion.Type,
+		Value:     promotion.Value.String(),
+		StartsAt:  promotion.StartsAt.Local().Format(adminDateTimeLayout),
+		Priority:  strconv.Itoa(promotion.Priority),
+		Stackable: promotion.Stackable,
+		IsActive:  promotion.IsActive,
+	}
+	if promotion.EndsAt != nil {
+		form.EndsAt = promotion.EndsAt.Local().Format(adminDateTimeLayout)
+	}
+	for _, category := range promotion.Categories {
+		form.CategoryIDs = append(form.CategoryIDs, category.ID)
+	}
+	for _, product := range promotion.Products {
+		form.ProductIDs = append(form.ProductIDs, product.ID)
+	}
+
+	h.renderPromotionForm(w, r, &AdminPromotionPageData{
+		FormAction:    fmt.Sprintf("/admin/promotions/edit/%s", promotionID),
+		IsEdit:        true,
+		PromotionData: &form,
+		Errors:        make(map[string]string),
+	})
+}
+
+func (h *AdminHandler) EditPromotionPost(w http.ResponseWriter, r *http.Request) {
+	promotionID := mux.Vars(r)["id"]
+
+	promotion, err := h.promoRepo.FindByID(r.Context(), promotionID)
+	if err != nil || promotion == nil {
+		log.Printf("EditPromotionPost: Promosi %s tidak ditemukan untuk pembaruan: %v", promotionID, err)
+		http.Redirect(w, r, "/admin/promotions?status=error&message="+url.QueryEscape("Promosi tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		log.Printf("EditPromotionPost: Kesalahan parsing form: %v", err)
+		http.Redirect(w, r, fmt.Sprintf("/admin/promotions/edit/%s?status=error&message=%s", promotionID, url.QueryEscape("Kesalahan parsing form.")), http.StatusSeeOther)
+		return
+	}
+
+	form := promotionFormFromRequest(r)
+	form.ID = promotionID
+	errs := h.applyPromotionForm(&form, promotion)
+	if len(errs) > 0 {
+		h.renderPromotionForm(w, r, &AdminPromotionPageData{
+			FormAction:    fmt.Sprintf("/admin/promotions/edit/%s", promotionID),
+			IsEdit:        true,
+			PromotionData: &form,
+			Errors:        errs,
+		})
+		return
+	}
+
+	before := h.wishlistSvc.SnapshotWatchedProducts(r.Context())
+	if err := h.promoRepo.Update(r.Context(), promotion, form.CategoryIDs, form.ProductIDs); err != nil {
+		log.Printf("EditPromotionPost: Gagal memperbarui promosi %s: %v", promotionID, err)
+		http.Redirect(w, r, fmt.Sprintf("/admin/promotions/edit/%s?status=error&message=%s", promotionID, url.QueryEscape("Gagal memperbarui promosi: "+err.Error())), http.StatusSeeOther)
+		return
+	}
+	h.wishlistSvc.NotifyWatchedProducts(r.Context(), before)
+
+	http.Redirect(w, r, "/admin/promotions?status=success&message="+url.QueryEscape("Promosi berhasil diperbarui."), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) DeletePromotionPost(w http.ResponseWriter, r *http.Request) {
+	promotionID := mux.Vars(r)["id"]
+
+	if err := h.promoRepo.Delete(r.Context(), promotionID); err != nil {
+		log.Printf("DeletePromotionPost: Gagal menghapus promosi %s: %v", promotionID, err)
+		http.Redirect(w, r, "/admin/promotions?status=error&message="+url.QueryEscape("Gagal menghapus promosi."), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/promotions?status=success&message="+url.QueryEscape("Promosi berhasil dihapus."), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) renderPromotionForm(w http.ResponseWriter, r *http.Request, data *AdminPromotionPageData) {
+	message, messageStatus := "", ""
+	h.populateBaseDataForAdmin(r, data)
+
+	categories, err := h.categoryRepo.GetAll(r.Context())
+	if err != nil {
+		log.Printf("renderPromotionForm: Gagal mengambil daftar kategori: %v", err)
+		message, messageStatus = "Gagal memuat daftar kategori.", "error"
+	}
+	data.Categories = categories
+
+	products, err := h.productRepo.GetProducts(r.Context())
+	if err != nil {
+		log.Printf("renderPromotionForm: Gagal mengambil daftar produk: %v", err)
+		message, messageStatus = "Gagal memuat daftar produk.", "error"
+	}
+	data.Products = products
+
+	data.SelectedCategories = make(map[string]bool, len(data.PromotionData.CategoryIDs))
+	for _, id := range data.PromotionData.CategoryIDs {
+		data.SelectedCategories[id] = true
+	}
+	data.SelectedProducts = make(map[string]bool, len(data.PromotionData.ProductIDs))
+	for _, id := range data.PromotionData.ProductIDs {
+		data.SelectedProducts[id] = true
+	}
+
+	if message != "" {
+		data.Message = message
+		data.MessageStatus = messageStatus
+	}
+	data.IsAuthPage = true
+	data.IsAdminPage = true
+	data.HideAdminWelcomeMessage = true
+	if data.IsEdit {
+		data.Title = "Edit Promosi"
+		data.Breadcrumbs = []breadcrumb.Breadcrumb{
+			{Name: "Beranda", URL: "/"}, {Name: "Admin", URL: "/admin/dashboard"},
+			{Name: "Promosi", URL: "/admin/promotions"}, {Name: "Edit", URL: data.FormAction},
+		}
+	} else {
+		data.Title = "Tambah Promosi Baru"
+		data.Breadcrumbs = []breadcrumb.Breadcrumb{
+			{Name: "Beranda", URL: "/"}, {Name: "Admin", URL: "/admin/dashboard"},
+			{Name: "Promosi", URL: "/admin/promotions"}, {Name: "Tambah Baru", URL: "/admin/promotions/add"},
+		}
+	}
+
+	h.render.HTML(w, http.StatusOK, "admin/promotions/form", data)
+}
+
+func promotionFormFromRequest(r *http.Request) PromotionForm {
+	return PromotionForm{
+		Name:        r.PostFormValue("name"),
+		Scope:       r.PostFormValue("scope"),
+		Type:        r.PostFormValue("type"),
+		Value:       r.PostFormValue("value"),
+		StartsAt:    r.PostFormValue("starts_at"),
+		EndsAt:      r.PostFormValue("ends_at"),
+		Priority:    r.PostFormValue("priority"),
+		Stackable:   r.PostFormValue("stackable") == "on",
+		IsActive:    r.PostFormValue("is_active") == "on",
+		CategoryIDs: r.PostForm["category_ids"],
+		ProductIDs:  r.PostForm["product_ids"],
+	}
+}
+
+// applyPromotionForm memvalidasi form lalu menyalin nilainya ke promosi. Kategori dan produk yang tidak
+// sesuai cakupan dikosongkan agar tidak tersimpan diam-diam.
+func (h *AdminHandler) applyPromotionForm(form *PromotionForm, promotion *models.Promotion) map[string]string {
+	errs := make(map[string]string)
+	if err := h.validator.Struct(form); err != nil {
+		errs = helpers.FormatValidationErrors(err.(validator.ValidationErrors))
+	}
+
+	value, _ := decimal.NewFromString(form.Value)
+	if _, ok := errs["value"]; !ok {
+		if !value.IsPositive() {
+			errs["value"] = "Nilai promosi harus lebih dari 0."
+		} else if form.Type == models.PromotionTypePercent && value.GreaterThan(decimal.NewFromInt(100)) {
+			errs["value"] = "Persentase promosi maksimal 100."
+		}
+	}
+	priority, _ := strconv.Atoi(form.Priority)
+
+	switch form.Scope {
+	case models.PromotionScopeCategories:
+		form.ProductIDs = nil
+		if len(form.CategoryIDs) == 0 {
+			errs["categoryids"] = "Pilih minimal satu kategori."
+		}
+	case models.PromotionScopeProducts:
+		form.CategoryIDs = nil
+		if len(form.ProductIDs) == 0 {
+			errs["productids"] = "Pilih minimal satu produk."
+		}
+	default:
+		form.CategoryIDs = nil
+		form.ProductIDs = nil
+	}
+
+	startsAt, err := parseAdminDateTime(form.StartsAt)
+	if _, ok := errs["startsat"]; !ok && (err != nil || startsAt == nil) {
+		errs["startsat"] = "Format tanggal mulai tidak valid."
+	}
+	endsAt, err := parseAdminDateTime(form.EndsAt)
+	if err != nil {
+		errs["endsat"] = "Format tanggal berakhir tidak valid."
+	}
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		errs["endsat"] = "Tanggal berakhir harus setelah tanggal mulai."
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	promotion.Name = form.Name
+	promotion.Scope = form.Scope
+	promotion.Type = form.Type
+	promotion.Value = value
+	promotion.StartsAt = *startsAt
+	promotion.EndsAt = endsAt
+	promotion.Priority = priority
+	promotion.Stackable = form.Stackable
+	promotion.IsActive = form.IsActive
+	promotion.UpdatedAt = time.Now()
+	return nil
+}
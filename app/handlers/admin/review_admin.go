@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	"github.com/gorilla/mux"
+)
+
+const reviewsPerPage = 20
+
+var reviewStatusOptions = []ReviewStatusOption{
+	{Value: models.ReviewStatusPending, Label: "Menunggu Moderasi"},
+	{Value: models.ReviewStatusApproved, Label: "Ditampilkan"},
+	{Value: models.ReviewStatusHidden, Label: "Disembunyikan"},
+	{Value: "", Label: "Semua"},
+}
+
+func (h *AdminHandler) GetReviewsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	status := r.URL.Query().Get("filter")
+	if _, ok := r.URL.Query()["filter"]; !ok {
+		status = models.ReviewStatusPending
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageData := &AdminReviewPageData{}
+	h.populateBaseDataForAdmin(r, pageData)
+
+	pageData.Title = "Moderasi Ulasan"
+	pageData.IsAuthPage = true
+	pageData.IsAdminPage = true
+	pageData.HideAdminWelcomeMessage = true
+	pageData.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Ulasan", URL: "/admin/reviews"},
+	}
+	pageData.Status = status
+	pageData.StatusOptions = reviewStatusOptions
+	pageData.CurrentPage = page
+
+	reviews, total, err := h.reviewRepo.GetForModeration(ctx, status, reviewsPerPage, (page-1)*reviewsPerPage)
+	if err != nil {
+		log.Printf("GetReviewsPage: Gagal mengambil ulasan: %v", err)
+		pageData.Message = "Gagal mengambil daftar ulasan."
+		pageData.MessageStatus = "error"
+	}
+	pageData.Reviews = reviews
+	pageData.TotalPages = int((total + reviewsPerPage - 1) / reviewsPerPage)
+
+	counts, err := h.reviewRepo.CountByStatus(ctx)
+	if err != nil {
+		log.Printf("GetReviewsPage: Gagal menghitung status ulasan: %v", err)
+	}
+	pageData.StatusCounts = counts
+
+	h.render.HTML(w, http.StatusOK, "admin/reviews/index", pageData)
+}
+
+func (h *AdminHandler) ApproveReviewPost(w http.ResponseWriter, r *http.Request) {
+	h.updateReviewStatus(w, r, models.ReviewStatusApproved, "Ulasan berhasil ditampilkan.")
+}
+
+func (h *AdminHandler) HideReviewPost(w http.ResponseWriter, r *http.Request) {
+	h.updateReviewStatus(w, r, models.ReviewStatusHidden, "Ulasan berhasil disembunyikan.")
+}
+
+func (h *AdminHandler) updateReviewStatus(w http.ResponseWriter, r *http.Request, status, successMessage string) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	returnURL := reviewReturnURL(r)
+
+	review, err := h.reviewRepo.FindByID(ctx, id)
+	if err != nil || review == nil {
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Ulasan tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.reviewRepo.UpdateStatus(ctx, review.ID, status); err != nil {
+		log.Printf("AdminHandler.updateReviewStatus: Gagal mengubah status ulasan %s ke %s: %v", review.ID, status, err)
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Gagal memperbarui status ulasan."), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, returnURL+"status=success&message="+url.QueryEscape(successMessage), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) ReplyReviewPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	returnURL := reviewReturnURL(r)
+
+	review, err := h.reviewRepo.FindByID(ctx, id)
+	if err != nil || review == nil {
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Ulasan tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+
+	reply := strings.TrimSpace(r.FormValue("reply"))
+	if len(reply) > 2000 {
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Balasan maksimal 2000 karakter."), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.reviewRepo.Reply(ctx, review.ID, reply); err != nil {
+		log.Printf("AdminHandler.ReplyReviewPost: Gagal menyimpan balasan ulasan %s: %v", review.ID, err)
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Gagal menyimpan balasan."), http.StatusSeeOther)
+		return
+	}
+
+	message := "Balasan berhasil disimpan."
+	if reply == "" {
+		message = "Balasan berhasil dihapus."
+	}
+	http.Redirect(w, r, returnURL+"status=success&message="+url.QueryEscape(message), http.StatusSeeOther)
+}
+
+// reviewReturnURL mengembalikan admin ke tab antrean yang sedang dibuka. Hasilnya selalu diakhiri "?" atau "&".
+func reviewReturnURL(r *http.Request) string {
+	_ = r.ParseForm()
+	values := url.Values{}
+	if filter, ok := r.PostForm["filter"]; ok && len(filter) > 0 {
+		values.Set("filter", filter[0])
+	}
+	if page := r.PostForm.Get("page"); page != "" {
+		values.Set("page", page)
+	}
+	if encoded := values.Encode(); encoded != "" {
+		return "/admin/reviews?" + encoded + "&"
+	}
+	return "/admin/reviews?"
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+)
+
+const searchReportLimit = 20
+
+var searchReportDayOptions = []int{7, 30, 90}
+
+func (h *AdminHandler) GetSearchReportsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days <= 0 {
+		days = 30
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	pageData := &AdminSearchReportPageData{}
+	h.populateBaseDataForAdmin(r, pageData)
+
+	pageData.Title = "Laporan Pencarian"
+	pageData.IsAuthPage = true
+	pageData.IsAdminPage = true
+	pageData.HideAdminWelcomeMessage = true
+	pageData.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Laporan Pencarian", URL: "/admin/search-reports"},
+	}
+	pageData.Days = days
+	pageData.DayOptions = searchReportDayOptions
+
+	popular, err := h.searchRepo.GetPopular(ctx, since, searchReportLimit)
+	if err != nil {
+		log.Printf("GetSearchReportsPage: Gagal mengambil pencarian populer: %v", err)
+		pageData.Message = "Gagal mengambil laporan pencarian."
+		pageData.MessageStatus = "error"
+	}
+	pageData.Popular = popular
+
+	zeroResult, err := h.searchRepo.GetZeroResult(ctx, since, searchReportLimit)
+	if err != nil {
+		log.Printf("GetSearchReportsPage: Gagal mengambil pencarian tanpa hasil: %v", err)
+		pageData.Message = "Gagal mengambil laporan pencarian."
+		pageData.MessageStatus = "error"
+	}
+	pageData.ZeroResult = zeroResult
+
+	h.render.HTML(w, http.StatusOK, "admin/search_reports/index", pageData)
+}
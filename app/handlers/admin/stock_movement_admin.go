@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Rakhulsr/go-ecommerce/app/helpers"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	"github.com/gorilla/mux"
+)
+
+const stockMovementsPerPage = 20
+
+// stockActorReference mengembalikan email admin yang sedang login sebagai reference pergerakan stok manual.
+func stockActorReference(r *http.Request) string {
+	if user, ok := r.Context().Value(helpers.ContextKeyUser).(*models.User); ok && user != nil {
+		return user.Email
+	}
+	if userID, ok := r.Context().Value(helpers.ContextKeyUserID).(string); ok {
+		return userID
+	}
+	return ""
+}
+
+func (h *AdminHandler) GetStockMovementsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	productID := mux.Vars(r)["id"]
+
+	product, err := h.productRepo.GetByID(ctx, productID)
+	if err != nil || product == nil {
+		log.Printf("GetStockMovementsPage: Produk %s tidak ditemukan: %v", productID, err)
+		http.Redirect(w, r, "/admin/products?status=error&message="+url.QueryEscape("Produk tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageData := &AdminStockMovementPageData{}
+	h.populateBaseDataForAdmin(r, pageData)
+
+	pageData.Title = "Riwayat Stok " + product.Name
+	pageData.IsAuthPage = true
+	pageData.IsAdminPage = true
+	pageData.HideAdminWelcomeMessage = true
+	pageData.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Produk", URL: "/admin/products"},
+		{Name: "Riwayat Stok", URL: "/admin/products/" + product.ID + "/stock-movements"},
+	}
+	pageData.Product = product
+	pageData.CurrentPage = page
+
+	movements, total, err := h.stockRepo.GetByProductIDPaginated(ctx, product.ID, stockMovementsPerPage, (page-1)*stockMovementsPerPage)
+	if err != nil {
+		log.Printf("GetStockMovementsPage: Gagal mengambil riwayat stok produk %s: %v", product.ID, err)
+		pageData.Message = "Gagal mengambil riwayat stok."
+		pageData.MessageStatus = "error"
+	}
+	pageData.Movements = movements
+	pageData.TotalPages = int((total + stockMovementsPerPage - 1) / stockMovementsPerPage)
+
+	sum, err := h.stockRepo.SumByProductID(ctx, product.ID)
+	if err != nil {
+		log.Printf("GetStockMovementsPage: Gagal menjumlahkan pergerakan stok produk %s: %v", product.ID, err)
+	}
+	pageData.MovementSum = sum
+	pageData.IsReconciled = err == nil && sum == product.Stock
+
+	if product.HasVariants() {
+		variantSums, err := h.stockRepo.SumByVariant(ctx, product.ID)
+		if err != nil {
+			log.Printf("GetStockMovementsPage: Gagal menjumlahkan pergerakan stok varian produk %s: %v", product.ID, err)
+		}
+		for _, v := range product.Variants {
+			pageData.VariantChecks = append(pageData.VariantChecks, VariantStockCheck{
+				Name:         v.Name,
+				Sku:          v.Sku,
+				Stock:        v.Stock,
+				MovementSum:  variantSums[v.ID],
+				IsReconciled: err == nil && variantSums[v.ID] == v.Stock,
+			})
+		}
+	}
+
+	h.render.HTML(w, http.StatusOK, "admin/products/stock_movements", pageData)
+}
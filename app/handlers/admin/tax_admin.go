@@ -0,0 +1,279 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/helpers"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/models/other"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+type AdminTaxPageData struct {
+	other.BasePageData
+	TaxClasses       []models.TaxClass
+	TaxClassData     *TaxClassForm
+	IsEdit           bool
+	FormAction       string
+	Errors           map[string]string
+	PricesIncludeTax bool
+}
+
+type TaxClassForm struct {
+	ID        string
+	Code      string `form:"code" validate:"required,min=2,max=50"`
+	Name      string `form:"name" validate:"required,min=3,max=100"`
+	Rate      string `form:"rate" validate:"required,numeric"`
+	IsDefault bool
+}
+
+func (h *AdminHandler) GetTaxClassesPage(w http.ResponseWriter, r *http.Request) {
+	data := &AdminTaxPageData{}
+	h.populateBaseDataForAdmin(r, data)
+
+	data.Title = "Pengaturan Pajak"
+	data.IsAuthPage = true
+	data.IsAdminPage = true
+	data.HideAdminWelcomeMessage = true
+	data.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Pajak", URL: "/admin/taxes"},
+	}
+
+	classes, err := h.taxRepo.GetAll(r.Context())
+	if err != nil {
+		log.Printf("GetTaxClassesPage: Gagal mengambil daftar kelas pajak: %v", err)
+		data.Message = "Gagal mengambil daftar kelas pajak."
+		data.MessageStatus = "error"
+	}
+	data.TaxClasses = classes
+	data.PricesIncludeTax = h.taxSvc.PricesIncludeTax(r.Context())
+
+	h.render.HTML(w, http.StatusOK, "admin/taxes/index", data)
+}
+
+func (h *AdminHandler) UpdateTaxSettingsPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		log.Printf("UpdateTaxSettingsPost: Kesalahan parsing form: %v", err)
+		http.Redirect(w, r, "/admin/taxes?status=error&message="+url.QueryEscape("Kesalahan parsing form."), http.StatusSeeOther)
+		return
+	}
+
+	include := r.PostFormValue("prices_include_tax") == "on"
+	if err := h.taxSvc.SetPricesIncludeTax(r.Context(), include); err != nil {
+		log.Printf("UpdateTaxSettingsPost: Gagal menyimpan pengaturan pajak: %v", err)
+		http.Redirect(w, r, "/admin/taxes?status=error&message="+url.QueryEscape("Gagal menyimpan pengaturan pajak."), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/taxes?status=success&message="+url.QueryEscape("Pengaturan pajak berhasil disimpan."), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) AddTaxClassPage(w http.ResponseWriter, r *http.Request) {
+	h.renderTaxClassForm(w, r, &AdminTaxPageData{
+		FormAction:   "/admin/taxes/add",
+		IsEdit:       false,
+		TaxClassData: &TaxClassForm{Rate: "0"},
+		Errors:       make(map[string]string),
+	})
+}
+
+func (h *AdminHandler) AddTaxClassPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		log.Printf("AddTaxClassPost: Kesalahan parsing form: %v", err)
+		http.Redirect(w, r, "/admin/taxes/add?status=error&message="+url.QueryEscape("Kesalahan parsing form."), http.StatusSeeOther)
+		return
+	}
+
+	form := taxClassFormFromRequest(r)
+	class := &models.TaxClass{}
+	errs := h.applyTaxClassForm(r, &form, class)
+	if len(errs) > 0 {
+		h.renderTaxClassForm(w, r, &AdminTaxPageData{
+			FormAction:   "/admin/taxes/add",
+			IsEdit:       false,
+			TaxClassData: &form,
+			Errors:       errs,
+		})
+		return
+	}
+
+	if err := h.taxRepo.Create(r.Context(), class); err != nil {
+		log.Printf("AddTaxClassPost: Gagal membuat kelas pajak: %v", err)
+		http.Redirect(w, r, "/admin/taxes/add?status=error&message="+url.QueryEscape("Gagal menambahkan kelas pajak: "+err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/taxes?status=success&message="+url.QueryEscape("Kelas pajak berhasil ditambahkan."), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) EditTaxClassPage(w http.ResponseWriter, r *http.Request) {
+	classID := mux.Vars(r)["id"]
+
+	class, err := h.taxRepo.FindByID(r.Context(), classID)
+	if err != nil || class == nil {
+		log.Printf("EditTaxClassPage: Kelas pajak %s tidak ditemukan: %v", classID, err)
+		http.Redirect(w, r, "/admin/taxes?status=error&message="+url.QueryEscape("Kelas pajak tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+
+	h.renderTaxClassForm(w, r, &AdminTaxPageData{
+		FormAction: fmt.Sprintf("/admin/taxes/edit/%s", classID),
+		IsEdit:     true,
+		TaxClassData: &TaxClassForm{
+			ID:        class.ID,
+			Code:      class.Code,
+			Name:      class.Name,
+			Rate:      class.Rate.String(),
+			IsDefault: class.IsDefault,
+		},
+		Errors: make(map[string]string),
+	})
+}
+
+func (h *AdminHandler) EditTaxClassPost(w http.ResponseWriter, r *http.Request) {
+	classID := mux.Vars(r)["id"]
+
+	class, err := h.taxRepo.FindByID(r.Context(), classID)
+	if err != nil || class == nil {
+		log.Printf("EditTaxClassPost: Kelas pajak %s tidak ditemukan untuk pembaruan: %v", classID, err)
+		http.Redirect(w, r, "/admin/taxes?status=error&message="+url.QueryEscape("Kelas pajak tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		log.Printf("EditTaxClassPost: Kesalahan parsing form: %v", err)
+		http.Redirect(w, r, fmt.Sprintf("/admin/taxes/edit/%s?status=error&message=%s", classID, url.QueryEscape("Kesalahan parsing form.")), http.StatusSeeOther)
+		return
+	}
+
+	form := taxClassFormFromRequest(r)
+	form.ID = classID
+	wasDefault := class.IsDefault
+	errs := h.applyTaxClassForm(r, &form, class)
+	if wasDefault && !form.IsDefault {
+		errs["isdefault"] = "Pilih kelas pajak lain sebagai default, jangan menonaktifkan default di sini."
+	}
+	if len(errs) > 0 {
+		h.renderTaxClassForm(w, r, &AdminTaxPageData{
+			FormAction:   fmt.Sprintf("/admin/taxes/edit/%s", classID),
+			IsEdit:       true,
+			TaxClassData: &form,
+			Errors:       errs,
+		})
+		return
+	}
+
+	if err := h.taxRepo.Update(r.Context(), class); err != nil {
+		log.Printf("EditTaxClassPost: Gagal memperbarui kelas pajak %s: %v", classID, err)
+		http.Redirect(w, r, fmt.Sprintf("/admin/taxes/edit/%s?status=error&message=%s", classID, url.QueryEscape("Gagal memperbarui kelas pajak: "+err.Error())), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/taxes?status=success&message="+url.QueryEscape("Kelas pajak berhasil diperbarui."), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) DeleteTaxClassPost(w http.ResponseWriter, r *http.Request) {
+	classID := mux.Vars(r)["id"]
+
+	class, err := h.taxRepo.FindByID(r.Context(), classID)
+	if err != nil || class == nil {
+		log.Printf("DeleteTaxClassPost: Kelas pajak %s tidak ditemukan untuk penghapusan: %v", classID, err)
+		http.Redirect(w, r, "/admin/taxes?status=error&message="+url.QueryEscape("Kelas pajak tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+	if class.IsDefault {
+		http.Redirect(w, r, "/admin/taxes?status=error&message="+url.QueryEscape("Kelas pajak default tidak dapat dihapus."), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.taxRepo.Delete(r.Context(), classID); err != nil {
+		log.Printf("DeleteTaxClassPost: Gagal menghapus kelas pajak %s: %v", classID, err)
+		http.Redirect(w, r, "/admin/taxes?status=error&message="+url.QueryEscape("Gagal menghapus kelas pajak."), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/taxes?status=success&message="+url.QueryEscape("Kelas pajak berhasil dihapus."), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) renderTaxClassForm(w http.ResponseWriter, r *http.Request, data *AdminTaxPageData) {
+	h.populateBaseDataForAdmin(r, data)
+
+	data.IsAuthPage = true
+	data.IsAdminPage = true
+	data.HideAdminWelcomeMessage = true
+	if data.IsEdit {
+		data.Title = "Edit Kelas Pajak"
+		data.Breadcrumbs = []breadcrumb.Breadcrumb{
+			{Name: "Beranda", URL: "/"}, {Name: "Admin", URL: "/admin/dashboard"},
+			{Name: "Pajak", URL: "/admin/taxes"}, {Name: "Edit", URL: data.FormAction},
+		}
+	} else {
+		data.Title = "Tambah Kelas Pajak"
+		data.Breadcrumbs = []breadcrumb.Breadcrumb{
+			{Name: "Beranda", URL: "/"}, {Name: "Admin", URL: "/admin/dashboard"},
+			{Name: "Pajak", URL: "/admin/taxes"}, {Name: "Tambah Baru", URL: "/admin/taxes/add"},
+		}
+	}
+
+	h.render.HTML(w, http.StatusOK, "admin/taxes/form", data)
+}
+
+func taxClassFormFromRequest(r *http.Request) TaxClassForm {
+	return TaxClassForm{
+		Code:      strings.ToLower(strings.TrimSpace(r.PostFormValue("code"))),
+		Name:      strings.TrimSpace(r.PostFormValue("name")),
+		Rate:      strings.TrimSpace(r.PostFormValue("rate")),
+		IsDefault: r.PostFormValue("is_default") == "on",
+	}
+}
+
+// applyTaxClassForm memvalidasi form lalu menyalin nilainya ke kelas pajak. Kode harus unik.
+func (h *AdminHandler) applyTaxClassForm(r *http.Request, form *TaxClassForm, class *models.TaxClass) map[string]string {
+	errs := make(map[string]string)
+	if err := h.validator.Struct(form); err != nil {
+		errs = helpers.FormatValidationErrors(err.(validator.ValidationErrors))
+	}
+
+	rate, _ := decimal.NewFromString(form.Rate)
+	if _, ok := errs["rate"]; !ok && (rate.IsNegative() || rate.GreaterThan(decimal.NewFromInt(100))) {
+		errs["rate"] = "Tarif pajak harus antara 0 dan 100."
+	}
+
+	if _, ok := errs["code"]; !ok {
+		existing, err := h.taxRepo.FindByCode(r.Context(), form.Code)
+		if err != nil {
+			log.Printf("applyTaxClassForm: Gagal memeriksa kode kelas pajak %s: %v", form.Code, err)
+			errs["code"] = "Kode kelas pajak tidak dapat diperiksa saat ini."
+		} else if existing != nil && existing.ID != form.ID {
+			errs["code"] = "Kode kelas pajak sudah dipakai."
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	class.Code = form.Code
+	class.Name = form.Name
+	class.Rate = rate.Round(2)
+	class.IsDefault = form.IsDefault
+	return errs
+}
+
+// taxClassOptions memuat kelas pajak untuk pilihan di form produk dan kategori.
+func (h *AdminHandler) taxClassOptions(r *http.Request) []models.TaxClass {
+	classes, err := h.taxRepo.GetAll(r.Context())
+	if err != nil {
+		log.Printf("taxClassOptions: Gagal mengambil daftar kelas pajak: %v", err)
+	}
+	return classes
+}
@@ -0,0 +1,442 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/helpers"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/models/other"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/services"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+const adminDateTimeLayout = "2006-01-02T15:04"
+
+type AdminVoucherPageData struct {
+	other.BasePageData
+	Vouchers           []models.Voucher
+	Usage              map[string]repositories.VoucherUsage
+	VoucherData        *VoucherForm
+	IsEdit             bool
+	FormAction         string
+	Errors             map[string]string
+	Categories         []models.Category
+	Products           []models.Product
+	SelectedCategories map[string]bool
+	SelectedProducts   map[string]bool
+	Voucher            *models.Voucher
+	Redemptions        []models.VoucherRedemption
+	Summary            repositories.VoucherUsage
+}
+
+type VoucherForm struct {
+	ID           string
+	Code         string `form:"code" validate:"required,min=3,max=50"`
+	Description  string `form:"description" validate:"max=255"`
+	Type         string `form:"type" validate:"required,oneof=percent fixed"`
+	Value        string `form:"value" validate:"required,numeric"`
+	MinSpend     string `form:"min_spend" validate:"omitempty,numeric"`
+	MaxDiscount  string `form:"max_discount" validate:"omitempty,numeric"`
+	StartsAt     string `form:"starts_at"`
+	EndsAt       string `form:"ends_at"`
+	UsageLimit   string `form:"usage_limit" validate:"omitempty,numeric"`
+	PerUserLimit string `form:"per_user_limit" validate:"omitempty,numeric"`
+	IsActive     bool
+	CategoryIDs  []string
+	ProductIDs   []string
+}
+
+func (h *AdminHandler) GetVouchersPage(w http.ResponseWriter, r *http.Request) {
+	data := &AdminVoucherPageData{}
+	h.populateBaseDataForAdmin(r, data)
+
+	data.Title = "Manajemen Voucher"
+	data.IsAuthPage = true
+	data.IsAdminPage = true
+	data.HideAdminWelcomeMessage = true
+	data.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Voucher", URL: "/admin/vouchers"},
+	}
+
+	vouchers, err := h.voucherRepo.GetAll(r.Context())
+	if err != nil {
+		log.Printf("GetVouchersPage: Gagal mengambil daftar voucher: %v", err)
+		data.Message = "Gagal mengambil daftar voucher."
+		data.MessageStatus = "error"
+	}
+	data.Vouchers = vouchers
+
+	usage, err := h.voucherRepo.GetUsageSummaries(r.Context())
+	if err != nil {
+		log.Printf("GetVouchersPage: Gagal mengambil ringkasan pemakaian voucher: %v", err)
+		usage = map[string]repositories.VoucherUsage{}
+	}
+	data.Usage = usage
+
+	h.render.HTML(w, http.StatusOK, "admin/vouchers/index", data)
+}
+
+func (h *AdminHandler) AddVoucherPage(w http.ResponseWriter, r *http.Request) {
+	data := &AdminVoucherPageData{
+		FormAction:  "/admin/vouchers/add",
+		IsEdit:      false,
+		VoucherData: &VoucherForm{Type: models.VoucherTypePercent, IsActive: true},
+		Errors:      make(map[string]string),
+	}
+	h.renderVoucherForm(w, r, data)
+}
+
+func (h *AdminHandler) AddVoucherPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		log.Printf("AddVoucherPost: Kesalahan parsing form: %v", err)
+		http.Redirect(w, r, "/admin/vouchers/add?status=error&message="+url.QueryEscape("Kesalahan parsing form."), http.StatusSeeOther)
+		return
+	}
+
+	form := voucherFormFromRequest(r)
+	voucher := &models.Voucher{}
+	errs := h.applyVoucherForm(r, &form, voucher)
+	if len(errs) > 0 {
+		h.renderVoucherForm(w, r, &AdminVoucherPageData{
+			FormAction:  "/admin/vouchers/add",
+			IsEdit:      false,
+			VoucherData: &form,
+			Errors:      errs,
+		})
+		return
+	}
+
+	voucher.Categories = categoriesFromIDs(form.CategoryIDs)
+	voucher.Products = productsFromIDs(form.ProductIDs)
+	if err := h.voucherRepo.Create(r.Context(), voucher); err != nil {
+		log.Printf("AddVoucherPost: Gagal membuat voucher: %v", err)
+		http.Redirect(w, r, "/admin/vouchers/add?status=error&message="+url.QueryEscape("Gagal menambahkan voucher: "+err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/vouchers?status=success&message="+url.QueryEscape("Voucher berhasil ditambahkan."), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) EditVoucherPage(w http.ResponseWriter, r *http.Request) {
+	voucherID := mux.Vars(r)["id"]
+
+	voucher, err := h.voucherRepo.FindByID(r.Context(), voucherID)
+	if err != nil || voucher == nil {
+		log.Printf("EditVoucherPage: Voucher %s tidak ditemukan: %v", voucherID, err)
+		http.Redirect(w, r, "/admin/vouchers?status=error&message="+url.QueryEscape("Voucher tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+
+	form := VoucherForm{
+		ID:           voucher.ID,
+		Code:         voucher.Code,
+		Description:  voucher.Description,
+		Type:         voucher.Type,
+		Value:        voucher.Value.String(),
+		MinSpend:     voucher.MinSpend.String(),
+		MaxDiscount:  voucher.MaxDiscount.String(),
+		UsageLimit:   strconv.Itoa(voucher.UsageLimit),
+		PerUserLimit: strconv.Itoa(voucher.PerUserLimit),
+		IsActive:     voucher.IsActive,
+	}
+	if voucher.StartsAt != nil {
+		form.StartsAt = voucher.StartsAt.Local().Format(adminDateTimeLayout)
+	}
+	if voucher.EndsAt != nil {
+		form.EndsAt = voucher.EndsAt.Local().Format(adminDateTimeLayout)
+	}
+	for _, category := range voucher.Categories {
+		form.CategoryIDs = append(form.CategoryIDs, category.ID)
+	}
+	for _, product := range voucher.Products {
+		form.ProductIDs = append(form.ProductIDs, product.ID)
+	}
+
+	h.renderVoucherForm(w, r, &AdminVoucherPageData{
+		FormAction:  fmt.Sprintf("/admin/vouchers/edit/%s", voucherID),
+		IsEdit:      true,
+		VoucherData: &form,
+		Errors:      make(map[string]string),
+	})
+}
+
+func (h *AdminHandler) EditVoucherPost(w http.ResponseWriter, r *http.Request) {
+	voucherID := mux.Vars(r)["id"]
+
+	voucher, err := h.voucherRepo.FindByID(r.Context(), voucherID)
+	if err != nil || voucher == nil {
+		log.Printf("EditVoucherPost: Voucher %s tidak ditemukan untuk pembaruan: %v", voucherID, err)
+		http.Redirect(w, r, "/admin/vouchers?status=error&message="+url.QueryEscape("Voucher tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		log.Printf("EditVoucherPost: Kesalahan parsing form: %v", err)
+		http.Redirect(w, r, fmt.Sprintf("/admin/vouchers/edit/%s?status=error&message=%s", voucherID, url.QueryEscape("Kesalahan parsing form.")), http.StatusSeeOther)
+		return
+	}
+
+	form := voucherFormFromRequest(r)
+	form.ID = voucherID
+	errs := h.applyVoucherForm(r, &form, voucher)
+	if len(errs) > 0 {
+		h.renderVoucherForm(w, r, &AdminVoucherPageData{
+			FormAction:  fmt.Sprintf("/admin/vouchers/edit/%s", voucherID),
+			IsEdit:      true,
+			VoucherData: &form,
+			Errors:      errs,
+		})
+		return
+	}
+
+	if err := h.voucherRepo.Update(r.Context(), voucher, form.CategoryIDs, form.ProductIDs); err != nil {
+		log.Printf("EditVoucherPost: Gagal memperbarui voucher %s: %v", voucherID, err)
+		http.Redirect(w, r, fmt.Sprintf("/admin/vouchers/edit/%s?status=error&message=%s", voucherID, url.QueryEscape("Gagal memperbarui voucher: "+err.Error())), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/vouchers?status=success&message="+url.QueryEscape("Voucher berhasil diperbarui."), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) DeleteVoucherPost(w http.ResponseWriter, r *http.Request) {
+	voucherID := mux.Vars(r)["id"]
+
+	redemptions, err := h.voucherRepo.GetRedemptionsByVoucherID(r.Context(), voucherID)
+	if err != nil {
+		log.Printf("DeleteVoucherPost: Gagal memeriksa pemakaian voucher %s: %v", voucherID, err)
+		http.Redirect(w, r, "/admin/vouchers?status=error&message="+url.QueryEscape("Gagal menghapus voucher."), http.StatusSeeOther)
+		return
+	}
+	if len(redemptions) > 0 {
+		http.Redirect(w, r, "/admin/vouchers?status=error&message="+url.QueryEscape("Voucher yang sudah pernah dipakai tidak dapat dihapus. Nonaktifkan voucher tersebut."), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.voucherRepo.Delete(r.Context(), voucherID); err != nil {
+		log.Printf("DeleteVoucherPost: Gagal menghapus voucher %s: %v", voucherID, err)
+		http.Redirect(w, r, "/admin/vouchers?status=error&message="+url.QueryEscape("Gagal menghapus voucher."), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/vouchers?status=success&message="+url.QueryEscape("Voucher berhasil dihapus."), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) GetVoucherUsagePage(w http.ResponseWriter, r *http.Request) {
+	voucherID := mux.Vars(r)["id"]
+
+	voucher, err := h.voucherRepo.FindByID(r.Context(), voucherID)
+	if err != nil || voucher == nil {
+		log.Printf("GetVoucherUsagePage: Voucher %s tidak ditemukan: %v", voucherID, err)
+		http.Redirect(w, r, "/admin/vouchers?status=error&message="+url.QueryEscape("Voucher tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+
+	data := &AdminVoucherPageData{Voucher: voucher}
+	h.populateBaseDataForAdmin(r, data)
+
+	data.Title = "Pemakaian Voucher " + voucher.Code
+	data.IsAuthPage = true
+	data.IsAdminPage = true
+	data.HideAdminWelcomeMessage = true
+	data.Breadcrumbs = []breadcrumb.Breadcrumb{
+		{Name: "Beranda", URL: "/"},
+		{Name: "Admin", URL: "/admin/dashboard"},
+		{Name: "Voucher", URL: "/admin/vouchers"},
+		{Name: voucher.Code, URL: fmt.Sprintf("/admin/vouchers/%s/usage", voucher.ID)},
+	}
+
+	redemptions, err := h.voucherRepo.GetRedemptionsByVoucherID(r.Context(), voucher.ID)
+	if err != nil {
+		log.Printf("GetVoucherUsagePage: Gagal mengambil pemakaian voucher %s: %v", voucher.ID, err)
+		data.Message = "Gagal mengambil riwayat pemakaian voucher."
+		data.MessageStatus = "error"
+	}
+	data.Redemptions = redemptions
+
+	summary := repositories.VoucherUsage{VoucherID: voucher.ID, TotalDiscount: decimal.Zero}
+	for _, redemption := range redemptions {
+		if redemption.Status == models.VoucherRedemptionActive {
+			summary.ActiveCount++
+			summary.TotalDiscount = summary.TotalDiscount.Add(redemption.DiscountAmount)
+		} else {
+			summary.ReleasedCount++
+		}
+	}
+	data.Summary = summary
+
+	h.render.HTML(w, http.StatusOK, "admin/vouchers/report", data)
+}
+
+func (h *AdminHandler) renderVoucherForm(w http.ResponseWriter, r *http.Request, data *AdminVoucherPageData) {
+	message, messageStatus := "", ""
+	h.populateBaseDataForAdmin(r, data)
+
+	categories, err := h.categoryRepo.GetAll(r.Context())
+	if err != nil {
+		log.Printf("renderVoucherForm: Gagal mengambil daftar kategori: %v", err)
+		message, messageStatus = "Gagal memuat daftar kategori.", "error"
+	}
+	data.Categories = categories
+
+	products, err := h.productRepo.GetProducts(r.Context())
+	if err != nil {
+		log.Printf("renderVoucherForm: Gagal mengambil daftar produk: %v", err)
+		message, messageStatus = "Gagal memuat daftar produk.", "error"
+	}
+	data.Products = products
+
+	data.SelectedCategories = make(map[string]bool, len(data.VoucherData.CategoryIDs))
+	for _, id := range data.VoucherData.CategoryIDs {
+		data.SelectedCategories[id] = true
+	}
+	data.SelectedProducts = make(map[string]bool, len(data.VoucherData.ProductIDs))
+	for _, id := range data.VoucherData.ProductIDs {
+		data.SelectedProducts[id] = true
+	}
+
+	if message != "" {
+		data.Message = message
+		data.MessageStatus = messageStatus
+	}
+	data.IsAuthPage = true
+	data.IsAdminPage = true
+	data.HideAdminWelcomeMessage = true
+	if data.IsEdit {
+		data.Title = "Edit Voucher"
+		data.Breadcrumbs = []breadcrumb.Breadcrumb{
+			{Name: "Beranda", URL: "/"}, {Name: "Admin", URL: "/admin/dashboard"},
+			{Name: "Voucher", URL: "/admin/vouchers"}, {Name: "Edit", URL: data.FormAction},
+		}
+	} else {
+		data.Title = "Tambah Voucher Baru"
+		data.Breadcrumbs = []breadcrumb.Breadcrumb{
+			{Name: "Beranda", URL: "/"}, {Name: "Admin", URL: "/admin/dashboard"},
+			{Name: "Voucher", URL: "/admin/vouchers"}, {Name: "Tambah Baru", URL: "/admin/vouchers/add"},
+		}
+	}
+
+	h.render.HTML(w, http.StatusOK, "admin/vouchers/form", data)
+}
+
+func voucherFormFromRequest(r *http.Request) VoucherForm {
+	return VoucherForm{
+		Code:         services.NormalizeVoucherCode(r.PostFormValue("code")),
+		Description:  r.PostFormValue("description"),
+		Type:         r.PostFormValue("type"),
+		Value:        r.PostFormValue("value"),
+		MinSpend:     r.PostFormValue("min_spend"),
+		MaxDiscount:  r.PostFormValue("max_discount"),
+		StartsAt:     r.PostFormValue("starts_at"),
+		EndsAt:       r.PostFormValue("ends_at"),
+		UsageLimit:   r.PostFormValue("usage_limit"),
+		PerUserLimit: r.PostFormValue("per_user_limit"),
+		IsActive:     r.PostFormValue("is_active") == "on",
+		CategoryIDs:  r.PostForm["category_ids"],
+		ProductIDs:   r.PostForm["product_ids"],
+	}
+}
+
+// applyVoucherForm memvalidasi form lalu menyalin nilainya ke voucher. Mengembalikan error per field
+// dengan key yang sama seperti helpers.FormatValidationErrors.
+func (h *AdminHandler) applyVoucherForm(r *http.Request, form *VoucherForm, voucher *models.Voucher) map[string]string {
+	errs := make(map[string]string)
+	if err := h.validator.Struct(form); err != nil {
+		errs = helpers.FormatValidationErrors(err.(validator.ValidationErrors))
+	}
+
+	existing, err := h.voucherRepo.FindByCode(r.Context(), form.Code)
+	if err != nil {
+		log.Printf("applyVoucherForm: Gagal memeriksa kode voucher %s: %v", form.Code, err)
+	} else if existing != nil && existing.ID != form.ID {
+		errs["code"] = "Kode voucher sudah digunakan."
+	}
+
+	value, _ := decimal.NewFromString(form.Value)
+	if _, ok := errs["value"]; !ok {
+		if !value.IsPositive() {
+			errs["value"] = "Nilai voucher harus lebih dari 0."
+		} else if form.Type == models.VoucherTypePercent && value.GreaterThan(decimal.NewFromInt(100)) {
+			errs["value"] = "Persentase voucher maksimal 100."
+		}
+	}
+	minSpend := decimalOrZero(form.MinSpend)
+	maxDiscount := decimalOrZero(form.MaxDiscount)
+	usageLimit, _ := strconv.Atoi(form.UsageLimit)
+	perUserLimit, _ := strconv.Atoi(form.PerUserLimit)
+
+	startsAt, err := parseAdminDateTime(form.StartsAt)
+	if err != nil {
+		errs["startsat"] = "Format tanggal mulai tidak valid."
+	}
+	endsAt, err := parseAdminDateTime(form.EndsAt)
+	if err != nil {
+		errs["endsat"] = "Format tanggal berakhir tidak valid."
+	}
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		errs["endsat"] = "Tanggal berakhir harus setelah tanggal mulai."
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	voucher.Code = form.Code
+	voucher.Description = form.Description
+	voucher.Type = form.Type
+	voucher.Value = value
+	voucher.MinSpend = minSpend
+	voucher.MaxDiscount = maxDiscount
+	voucher.StartsAt = startsAt
+	voucher.EndsAt = endsAt
+	voucher.UsageLimit = usageLimit
+	voucher.PerUserLimit = perUserLimit
+	voucher.IsActive = form.IsActive
+	voucher.UpdatedAt = time.Now()
+	return nil
+}
+
+func parseAdminDateTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(adminDateTimeLayout, value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func decimalOrZero(value string) decimal.Decimal {
+	d, err := decimal.NewFromString(value)
+	if err != nil || d.IsNegative() {
+		return decimal.Zero
+	}
+	return d
+}
+
+func categoriesFromIDs(ids []string) []models.Category {
+	categories := make([]models.Category, 0, len(ids))
+	for _, id := range ids {
+		categories = append(categories, models.Category{ID: id})
+	}
+	return categories
+}
+
+func productsFromIDs(ids []string) []models.Product {
+	products := make([]models.Product, 0, len(ids))
+	for _, id := range ids {
+		products = append(products, models.Product{ID: id})
+	}
+	return products
+}
@@ -26,16 +26,18 @@ type AuthHandler struct {
 	render       *render.Render
 	userRepo     repositories.UserRepositoryImpl
 	cartRepo     repositories.CartRepositoryImpl
+	cartSvc      *services.CartService
 	sessionStore sessions.SessionStore
 	mailer       *services.Mailer
 	validator    *validator.Validate
 }
 
-func NewAuthHandler(r *render.Render, userRepo repositories.UserRepositoryImpl, cartRepo repositories.CartRepositoryImpl, sessionStore sessions.SessionStore, mailer *services.Mailer, validator *validator.Validate) *AuthHandler {
+func NewAuthHandler(r *render.Render, userRepo repositories.UserRepositoryImpl, cartRepo repositories.CartRepositoryImpl, cartSvc *services.CartService, sessionStore sessions.SessionStore, mailer *services.Mailer, validator *validator.Validate) *AuthHandler {
 	return &AuthHandler{
 		render:       r,
 		userRepo:     userRepo,
 		cartRepo:     cartRepo,
+		cartSvc:      cartSvc,
 		sessionStore: sessionStore,
 		mailer:       mailer,
 		validator:    validator,
@@ -68,6 +70,7 @@ func (h *AuthHandler) LoginGetHandler(w http.ResponseWriter, r *http.Request) {
 		"MessageStatus": r.URL.Query().Get("status"),
 		"Message":       r.URL.Query().Get("message"),
 		"IsAuthPage":    true,
+		"Next":          safeRedirectPath(r.URL.Query().Get("next")),
 	}
 
 	data := helpers.GetBaseData(r, pageSpecificData)
@@ -84,30 +87,33 @@ func (h *AuthHandler) LoginPostHandler(w http.ResponseWriter, r *http.Request) {
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 	rememberMe := r.FormValue("remember_me") == "on"
+	next := safeRedirectPath(r.FormValue("next"))
 
 	user, err := h.userRepo.FindByEmail(r.Context(), email)
 	if err != nil {
 		log.Printf("LoginPostHandler: Error getting user by email '%s': %v", email, err)
-		http.Redirect(w, r, fmt.Sprintf("/login?status=error&message=%s", url.QueryEscape("Terjadi kesalahan server.")), http.StatusSeeOther)
+		http.Redirect(w, r, loginRedirectURL(next, "Terjadi kesalahan server."), http.StatusSeeOther)
 		return
 	}
 	if user == nil {
 		log.Printf("LoginPostHandler: User not found for email: %s", email)
-		http.Redirect(w, r, fmt.Sprintf("/login?status=error&message=%s", url.QueryEscape("Email atau password salah.")), http.StatusSeeOther)
+		http.Redirect(w, r, loginRedirectURL(next, "Email atau password salah."), http.StatusSeeOther)
 		return
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
 		log.Printf("LoginPostHandler: Password mismatch for email: %s", email)
-		http.Redirect(w, r, fmt.Sprintf("/login?status=error&message=%s", url.QueryEscape("Email atau password salah.")), http.StatusSeeOther)
+		http.Redirect(w, r, loginRedirectURL(next, "Email atau password salah."), http.StatusSeeOther)
 		return
 	}
 
+	guestCartID := h.sessionStore.GetCartID(w, r)
+
 	err = h.sessionStore.SetUserID(w, r, user.ID)
 	if err != nil {
 		log.Printf("LoginPostHandler: Error setting user session: %v", err)
-		http.Redirect(w, r, fmt.Sprintf("/login?status=error&message=%s", url.QueryEscape("Gagal membuat sesi login.")), http.StatusSeeOther)
+		http.Redirect(w, r, loginRedirectURL(next, "Gagal membuat sesi login."), http.StatusSeeOther)
 		return
 	}
 
@@ -139,6 +145,11 @@ func (h *AuthHandler) LoginPostHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	welcomeMessage := fmt.Sprintf("Selamat datang, %s!", user.FirstName)
+	if h.mergeGuestCart(r, guestCartID, user.ID) {
+		welcomeMessage += " Jumlah beberapa produk di keranjang disesuaikan dengan stok yang tersedia."
+	}
+
 	userCart, err := h.cartRepo.GetOrCreateCartByUserID(r.Context(), "", user.ID)
 	if err != nil {
 		log.Printf("LoginPostHandler: Failed to get or create cart for user %s: %v", user.ID, err)
@@ -150,7 +161,29 @@ func (h *AuthHandler) LoginPostHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/?status=success&message=%s", url.QueryEscape(fmt.Sprintf("Selamat datang, %s!", user.FirstName))), http.StatusSeeOther)
+	if next != "" {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/?status=success&message=%s", url.QueryEscape(welcomeMessage)), http.StatusSeeOther)
+}
+
+// loginRedirectURL kembali ke halaman login dengan pesan error tanpa kehilangan tujuan setelah login.
+func loginRedirectURL(next, message string) string {
+	target := fmt.Sprintf("/login?status=error&message=%s", url.QueryEscape(message))
+	if next != "" {
+		target += "&next=" + url.QueryEscape(next)
+	}
+	return target
+}
+
+// safeRedirectPath hanya menerima path relatif di situs ini agar parameter next tidak bisa dipakai
+// untuk mengalihkan pengguna ke situs lain.
+func safeRedirectPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return ""
+	}
+	return next
 }
 
 func (h *AuthHandler) RegisterGetHandler(w http.ResponseWriter, r *http.Request) {
@@ -245,11 +278,26 @@ func (h *AuthHandler) RegisterPostHandler(w http.ResponseWriter, r *http.Request
 
 	} else {
 		log.Printf("RegisterPostHandler: Cart %s created for new user %s.", newCart.ID, user.ID)
+		h.mergeGuestCart(r, h.sessionStore.GetCartID(w, r), user.ID)
 	}
 
 	http.Redirect(w, r, fmt.Sprintf("/login?status=success&message=%s", url.QueryEscape("Akun Anda berhasil dibuat! Silakan login.")), http.StatusSeeOther)
 }
 
+// mergeGuestCart menggabungkan keranjang tamu dari sesi ke keranjang user. Kegagalan hanya dicatat agar
+// login/registrasi tetap berhasil; nilai kembalian true berarti ada jumlah yang dipotong karena stok.
+func (h *AuthHandler) mergeGuestCart(r *http.Request, guestCartID, userID string) bool {
+	if guestCartID == "" {
+		return false
+	}
+	capped, err := h.cartSvc.MergeGuestCart(r.Context(), guestCartID, userID)
+	if err != nil {
+		log.Printf("AuthHandler.mergeGuestCart: Gagal menggabungkan keranjang tamu %s ke user %s: %v", guestCartID, userID, err)
+		return false
+	}
+	return capped
+}
+
 func (h *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(helpers.ContextKeyUserID).(string)
 	if ok && userID != "" {
@@ -3,12 +3,14 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/Rakhulsr/go-ecommerce/app/helpers"
 	"github.com/Rakhulsr/go-ecommerce/app/models"
@@ -16,7 +18,8 @@ import (
 	"github.com/Rakhulsr/go-ecommerce/app/repositories"
 	"github.com/Rakhulsr/go-ecommerce/app/services"
 	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
-	"github.com/Rakhulsr/go-ecommerce/app/utils/calc"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/sessions"
+	"github.com/gorilla/mux"
 	"github.com/shopspring/decimal"
 	"github.com/unrolled/render"
 )
@@ -26,17 +29,20 @@ type CalculateShippingCostRequest struct {
 	Destination int    `json:"destination"`
 	Weight      int    `json:"weight"`
 	Courier     string `json:"courier"`
+	AddressID   string `json:"address_id"`
 }
 type KomerceCartHandler struct {
-	productRepo        repositories.ProductRepositoryImpl
-	cartRepo           repositories.CartRepositoryImpl
-	cartItemRepo       repositories.CartItemRepositoryImpl
-	render             *render.Render
-	komerceLocationSvc services.KomerceRajaOngkirClient
-	userRepo           repositories.UserRepositoryImpl
-	addressRepo        repositories.AddressRepository
-	cartSvc            *services.CartService
-	merchantOriginID   int
+	productRepo      repositories.ProductRepositoryImpl
+	cartRepo         repositories.CartRepositoryImpl
+	cartItemRepo     repositories.CartItemRepositoryImpl
+	render           *render.Render
+	shippingQuoteSvc *services.ShippingQuoteService
+	userRepo         repositories.UserRepositoryImpl
+	addressRepo      repositories.AddressRepository
+	cartSvc          *services.CartService
+	recoverySvc      *services.CartRecoveryService
+	sessionStore     sessions.SessionStore
+	merchantOriginID int
 }
 
 func NewKomerceCartHandler(
@@ -44,35 +50,35 @@ func NewKomerceCartHandler(
 	cartRepo repositories.CartRepositoryImpl,
 	render *render.Render,
 	cartItemRepo repositories.CartItemRepositoryImpl,
-	komerceLocationSvc services.KomerceRajaOngkirClient,
+	shippingQuoteSvc *services.ShippingQuoteService,
 	userRepo repositories.UserRepositoryImpl,
 	addressRepo repositories.AddressRepository,
 	cartSvc *services.CartService,
+	recoverySvc *services.CartRecoveryService,
+	sessionStore sessions.SessionStore,
 	merchantOriginID int,
 ) *KomerceCartHandler {
 	return &KomerceCartHandler{
-		productRepo:        productRepo,
-		cartRepo:           cartRepo,
-		cartItemRepo:       cartItemRepo,
-		render:             render,
-		komerceLocationSvc: komerceLocationSvc,
-		userRepo:           userRepo,
-		addressRepo:        addressRepo,
-		cartSvc:            cartSvc,
-		merchantOriginID:   merchantOriginID,
+		productRepo:      productRepo,
+		cartRepo:         cartRepo,
+		cartItemRepo:     cartItemRepo,
+		render:           render,
+		shippingQuoteSvc: shippingQuoteSvc,
+		userRepo:         userRepo,
+		addressRepo:      addressRepo,
+		cartSvc:          cartSvc,
+		recoverySvc:      recoverySvc,
+		sessionStore:     sessionStore,
+		merchantOriginID: merchantOriginID,
 	}
 }
 
 func (h *KomerceCartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID, userOk := ctx.Value(helpers.ContextKeyUserID).(string)
-	if !userOk || userID == "" {
-		log.Printf("KomerceCartHandler.GetCart: UserID not found in context. Rendering empty cart for non-logged in user.")
-		h.renderEmptyCart(w, r, "info", "Keranjang Anda kosong. Mohon login untuk menyimpan keranjang Anda.")
-		return
-	}
+	userID, _ := ctx.Value(helpers.ContextKeyUserID).(string)
+	cartID, _ := ctx.Value(helpers.ContextKeyCartID).(string)
 
-	cart, err := h.cartSvc.GetUserCart(ctx, userID)
+	cart, err := h.cartSvc.GetCart(ctx, cartID, userID)
 	if err != nil {
 		log.Printf("KomerceCartHandler.GetCart: Gagal mengambil data cart untuk user %s: %v", userID, err)
 		http.Error(w, "Gagal mengambil data cart", http.StatusInternalServerError)
@@ -80,6 +86,18 @@ func (h *KomerceCartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if cart == nil || len(cart.CartItems) == 0 {
+		if cart != nil && cart.HasNotices() {
+			// semua item sudah tidak tersedia; tidak ada yang perlu dikonfirmasi sebelum checkout
+			names := make([]string, 0, len(cart.Notices))
+			for _, notice := range cart.Notices {
+				names = append(names, notice.Name)
+			}
+			if _, err := h.cartSvc.AcknowledgeChanges(ctx, cartID, userID); err != nil {
+				log.Printf("KomerceCartHandler.GetCart: Gagal membersihkan item yang tidak tersedia di cart %s: %v", cart.ID, err)
+			}
+			h.renderEmptyCart(w, r, "warning", fmt.Sprintf("Produk berikut sudah tidak tersedia dan dihapus dari keranjang: %s.", strings.Join(names, ", ")))
+			return
+		}
 		log.Printf("KomerceCartHandler.GetCart: Cart for user %s is empty or not found after service call. Rendering empty cart.", userID)
 		h.renderEmptyCart(w, r, "info", "Keranjang Anda kosong.")
 		return
@@ -89,13 +107,13 @@ func (h *KomerceCartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
 	message := r.URL.Query().Get("message")
 
 	var userAddresses []models.Address
-	userWithAddresses, err := h.userRepo.GetUserByIDWithAddresses(ctx, userID)
-	if err != nil {
-		log.Printf("KomerceCartHandler.GetCart: Gagal mengambil user dengan alamat untuk user %s: %v", userID, err)
-	} else if userWithAddresses != nil {
-		userAddresses = userWithAddresses.Address
-	} else {
-		log.Printf("KomerceCartHandler.GetCart: UserID tidak ditemukan di konteks untuk memuat alamat.")
+	if userID != "" {
+		userWithAddresses, err := h.userRepo.GetUserByIDWithAddresses(ctx, userID)
+		if err != nil {
+			log.Printf("KomerceCartHandler.GetCart: Gagal mengambil user dengan alamat untuk user %s: %v", userID, err)
+		} else if userWithAddresses != nil {
+			userAddresses = userWithAddresses.Address
+		}
 	}
 
 	supportedCouriers := []other.Courier{
@@ -138,7 +156,7 @@ func (h *KomerceCartHandler) renderEmptyCart(w http.ResponseWriter, r *http.Requ
 	emptyCart := &models.Cart{
 		BaseTotalPrice:  decimal.Zero,
 		TaxAmount:       decimal.Zero,
-		TaxPercent:      calc.GetTaxPercent(),
+		TaxPercent:      decimal.Zero,
 		DiscountAmount:  decimal.Zero,
 		DiscountPercent: decimal.Zero,
 		GrandTotal:      decimal.Zero,
@@ -206,6 +224,7 @@ func (h *KomerceCartHandler) AddItemCart(w http.ResponseWriter, r *http.Request)
 	}
 
 	productID := r.FormValue("product_id")
+	variantID := r.FormValue("variant_id")
 	qtyStr := r.FormValue("qty")
 	action := r.FormValue("action")
 
@@ -229,16 +248,30 @@ func (h *KomerceCartHandler) AddItemCart(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if product.HasVariants() && variantID == "" {
+		redirectBackWithError(w, r, productID, "Silakan pilih varian produk terlebih dahulu.", "warning", h.productRepo)
+		return
+	}
+
 	cartID, _ := r.Context().Value(helpers.ContextKeyCartID).(string)
-	userID, userOk := r.Context().Value(helpers.ContextKeyUserID).(string)
+	userID, _ := r.Context().Value(helpers.ContextKeyUserID).(string)
 
-	if !userOk || userID == "" {
-		log.Printf("KomerceCartHandler.AddItemCart: UserID not found in context. Redirecting to login.")
-		redirectBackWithError(w, r, productID, "Anda harus login untuk menambahkan produk ke keranjang.", "warning", h.productRepo)
-		return
+	if userID == "" {
+		guestCart, err := h.cartSvc.EnsureGuestCart(r.Context(), cartID)
+		if err != nil {
+			log.Printf("KomerceCartHandler.AddItemCart: Gagal menyiapkan keranjang tamu: %v", err)
+			redirectBackWithError(w, r, productID, "Gagal menyiapkan keranjang. Silakan coba lagi.", "error", h.productRepo)
+			return
+		}
+		if guestCart.ID != cartID {
+			cartID = guestCart.ID
+			if err := h.sessionStore.SetCartID(w, r, cartID); err != nil {
+				log.Printf("KomerceCartHandler.AddItemCart: Gagal menyimpan keranjang tamu %s ke sesi: %v", cartID, err)
+			}
+		}
 	}
 
-	err = h.cartSvc.AddItemToCart(r.Context(), cartID, userID, productID, qty)
+	err = h.cartSvc.AddItemToCart(r.Context(), cartID, userID, productID, variantID, qty)
 	if err != nil {
 		log.Printf("KomerceCartHandler.AddItemCart: Gagal menambahkan item ke keranjang melalui service: %v", err)
 		redirectBackWithError(w, r, productID, fmt.Sprintf("Gagal menambahkan produk ke keranjang: %v", err), "error", h.productRepo)
@@ -256,6 +289,7 @@ func (h *KomerceCartHandler) AddItemCart(w http.ResponseWriter, r *http.Request)
 
 func (h *KomerceCartHandler) UpdateCartItem(w http.ResponseWriter, r *http.Request) {
 	productID := r.FormValue("product_id")
+	variantID := r.FormValue("variant_id")
 	qtyStr := r.FormValue("qty")
 
 	qty, err := strconv.Atoi(qtyStr)
@@ -264,13 +298,14 @@ func (h *KomerceCartHandler) UpdateCartItem(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	userID, userOk := r.Context().Value(helpers.ContextKeyUserID).(string)
-	if !userOk || userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	userID, _ := r.Context().Value(helpers.ContextKeyUserID).(string)
+	cartID, _ := r.Context().Value(helpers.ContextKeyCartID).(string)
+	if userID == "" && cartID == "" {
+		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape("Keranjang Anda sudah kedaluwarsa.")), http.StatusSeeOther)
 		return
 	}
 
-	updatedCart, err := h.cartSvc.UpdateCartItemQty(r.Context(), userID, productID, qty)
+	updatedCart, err := h.cartSvc.UpdateCartItemQty(r.Context(), cartID, userID, productID, variantID, qty)
 	if err != nil {
 		log.Printf("KomerceCartHandler.UpdateCartItem: Gagal memperbarui item keranjang melalui service: %v", err)
 		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape(fmt.Sprintf("Gagal memperbarui item: %v", err))), http.StatusSeeOther)
@@ -287,18 +322,20 @@ func (h *KomerceCartHandler) UpdateCartItem(w http.ResponseWriter, r *http.Reque
 
 func (h *KomerceCartHandler) DeleteCartItem(w http.ResponseWriter, r *http.Request) {
 	productID := r.FormValue("product_id")
+	variantID := r.FormValue("variant_id")
 	if productID == "" {
 		http.Error(w, "Produk tidak valid", http.StatusBadRequest)
 		return
 	}
 
-	userID, userOk := r.Context().Value(helpers.ContextKeyUserID).(string)
-	if !userOk || userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	userID, _ := r.Context().Value(helpers.ContextKeyUserID).(string)
+	cartID, _ := r.Context().Value(helpers.ContextKeyCartID).(string)
+	if userID == "" && cartID == "" {
+		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape("Keranjang Anda sudah kedaluwarsa.")), http.StatusSeeOther)
 		return
 	}
 
-	updatedCart, err := h.cartSvc.RemoveItemFromCart(r.Context(), userID, productID)
+	updatedCart, err := h.cartSvc.RemoveItemFromCart(r.Context(), cartID, userID, productID, variantID)
 	if err != nil {
 		log.Printf("KomerceCartHandler.DeleteCartItem: Gagal menghapus item keranjang melalui service: %v", err)
 		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape(fmt.Sprintf("Gagal menghapus item: %v", err))), http.StatusSeeOther)
@@ -313,6 +350,50 @@ func (h *KomerceCartHandler) DeleteCartItem(w http.ResponseWriter, r *http.Reque
 	http.Redirect(w, r, fmt.Sprintf("/carts?status=success&message=%s", url.QueryEscape("Item keranjang berhasil dihapus!")), http.StatusSeeOther)
 }
 
+func (h *KomerceCartHandler) ApplyVoucherPost(w http.ResponseWriter, r *http.Request) {
+	userID, userOk := r.Context().Value(helpers.ContextKeyUserID).(string)
+	if !userOk || userID == "" {
+		http.Redirect(w, r, fmt.Sprintf("/login?status=error&message=%s", url.QueryEscape("Mohon login untuk menggunakan voucher.")), http.StatusSeeOther)
+		return
+	}
+
+	code := r.FormValue("voucher_code")
+	if services.NormalizeVoucherCode(code) == "" {
+		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape("Kode voucher wajib diisi.")), http.StatusSeeOther)
+		return
+	}
+
+	cart, err := h.cartSvc.ApplyVoucher(r.Context(), userID, code)
+	if err != nil {
+		log.Printf("KomerceCartHandler.ApplyVoucherPost: Gagal memasang voucher %q untuk user %s: %v", code, userID, err)
+		message := "Gagal memasang voucher."
+		if services.IsVoucherRuleError(err) {
+			message = fmt.Sprintf("Voucher tidak dapat digunakan: %v", err)
+		}
+		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape(message)), http.StatusSeeOther)
+		return
+	}
+
+	message := fmt.Sprintf("Voucher %s berhasil dipasang. Anda hemat %s.", cart.VoucherCode, helpers.FormatRupiah(cart.VoucherDiscount.InexactFloat64()))
+	http.Redirect(w, r, fmt.Sprintf("/carts?status=success&message=%s", url.QueryEscape(message)), http.StatusSeeOther)
+}
+
+func (h *KomerceCartHandler) RemoveVoucherPost(w http.ResponseWriter, r *http.Request) {
+	userID, userOk := r.Context().Value(helpers.ContextKeyUserID).(string)
+	if !userOk || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.cartSvc.RemoveVoucher(r.Context(), userID); err != nil {
+		log.Printf("KomerceCartHandler.RemoveVoucherPost: Gagal melepas voucher untuk user %s: %v", userID, err)
+		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape("Gagal melepas voucher.")), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/carts?status=success&message=%s", url.QueryEscape("Voucher berhasil dilepas.")), http.StatusSeeOther)
+}
+
 func (h *KomerceCartHandler) CalculateShippingCost(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID, ok := ctx.Value(helpers.ContextKeyUserID).(string)
@@ -350,12 +431,10 @@ func (h *KomerceCartHandler) CalculateShippingCost(w http.ResponseWriter, r *htt
 		return
 	}
 
-	originID := h.merchantOriginID
-	destinationID := reqBody.Destination
-	weight := reqBody.Weight
+	addressID := reqBody.AddressID
 	courier := reqBody.Courier
 
-	if originID == 0 {
+	if h.merchantOriginID == 0 {
 		log.Println("CalculateShippingCost: Merchant Origin ID is not configured (0).")
 		h.render.JSON(w, http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
@@ -364,32 +443,44 @@ func (h *KomerceCartHandler) CalculateShippingCost(w http.ResponseWriter, r *htt
 		return
 	}
 
-	if destinationID == 0 {
-		log.Println("CalculateShippingCost: Destination ID is missing or invalid (0).")
+	if addressID == "" {
+		log.Println("CalculateShippingCost: Address ID is missing.")
 		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
-			"message": "Destination ID is required and must be a valid number.",
+			"message": "Address is required.",
 		})
 		return
 	}
-	if weight <= 0 {
-		log.Println("CalculateShippingCost: Weight is missing or invalid (<= 0).")
+	if courier == "" {
+		log.Println("CalculateShippingCost: Courier is empty.")
 		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
-			"message": "Weight must be greater than 0.",
+			"message": "Courier is required.",
 		})
 		return
 	}
-	if courier == "" {
-		log.Println("CalculateShippingCost: Courier is empty.")
+
+	address, err := h.addressRepo.FindAddressByID(ctx, addressID)
+	if err != nil || address == nil || address.UserID != userID {
+		log.Printf("CalculateShippingCost: Alamat %s tidak valid untuk user %s: %v", addressID, userID, err)
 		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
-			"message": "Courier is required.",
+			"message": "Alamat pengiriman tidak ditemukan atau tidak valid.",
+		})
+		return
+	}
+
+	cart, err := h.cartRepo.GetCartWithItems(ctx, helpers.GetCartIDFromContext(r))
+	if err != nil || cart == nil || len(cart.CartItems) == 0 {
+		log.Printf("CalculateShippingCost: Keranjang kosong atau tidak ditemukan untuk user %s: %v", userID, err)
+		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "Keranjang belanja Anda kosong.",
 		})
 		return
 	}
 
-	shippingCosts, err := h.komerceLocationSvc.CalculateCost(ctx, originID, destinationID, weight, courier)
+	quotes, err := h.shippingQuoteSvc.CreateQuotes(ctx, cart, address, courier)
 	if err != nil {
 		log.Printf("CalculateShippingCost: Gagal menghitung biaya pengiriman melalui service Komerce: %v", err)
 		h.render.JSON(w, http.StatusInternalServerError, map[string]interface{}{
@@ -401,18 +492,19 @@ func (h *KomerceCartHandler) CalculateShippingCost(w http.ResponseWriter, r *htt
 
 	h.render.JSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
-		"data":    shippingCosts,
+		"data":    quotes,
 	})
 }
 
 func (h *KomerceCartHandler) GetCartCount(w http.ResponseWriter, r *http.Request) {
-	userID, userOk := r.Context().Value(helpers.ContextKeyUserID).(string)
-	if !userOk || userID == "" {
+	userID, _ := r.Context().Value(helpers.ContextKeyUserID).(string)
+	cartID, _ := r.Context().Value(helpers.ContextKeyCartID).(string)
+	if userID == "" && cartID == "" {
 		w.Write([]byte("0"))
 		return
 	}
 
-	cart, err := h.cartSvc.GetUserCart(r.Context(), userID)
+	cart, err := h.cartSvc.GetCart(r.Context(), cartID, userID)
 	if err != nil {
 		log.Printf("GetCartCount: Gagal mengambil cart untuk userID %s: %v", userID, err)
 		w.Write([]byte("0"))
@@ -437,3 +529,47 @@ func redirectBackWithError(w http.ResponseWriter, r *http.Request, productID str
 	}
 	http.Redirect(w, r, fmt.Sprintf("/?status=%s&message=%s", status, url.QueryEscape(msg)), http.StatusSeeOther)
 }
+
+// RecoverCart memulihkan isi keranjang dari tautan email keranjang yang ditinggalkan. Pengguna yang belum
+// login diarahkan ke halaman login lalu dikembalikan ke tautan ini.
+func (h *KomerceCartHandler) RecoverCart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value(helpers.ContextKeyUserID).(string)
+	if userID == "" {
+		http.Redirect(w, r, fmt.Sprintf("/login?status=info&message=%s&next=%s", url.QueryEscape("Silakan login untuk memulihkan keranjang Anda."), url.QueryEscape(r.URL.RequestURI())), http.StatusSeeOther)
+		return
+	}
+
+	query := r.URL.Query()
+	capped, err := h.recoverySvc.Restore(ctx, mux.Vars(r)["id"], query.Get("expires"), query.Get("signature"), userID)
+	if err != nil {
+		log.Printf("RecoverCart: Gagal memulihkan keranjang untuk user %s: %v", userID, err)
+		message := "Gagal memulihkan keranjang. Silakan coba lagi."
+		if errors.Is(err, services.ErrCartRecoveryInvalidLink) || errors.Is(err, services.ErrCartRecoveryExpiredLink) || errors.Is(err, services.ErrCartRecoveryNotOwner) {
+			message = err.Error()
+		}
+		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape(message)), http.StatusSeeOther)
+		return
+	}
+
+	message := "Keranjang Anda berhasil dipulihkan."
+	if capped {
+		message += " Jumlah beberapa produk disesuaikan dengan stok yang tersedia."
+	}
+	http.Redirect(w, r, fmt.Sprintf("/carts?status=success&message=%s", url.QueryEscape(message)), http.StatusSeeOther)
+}
+
+// AcknowledgeChangesPost mengonfirmasi perubahan harga, stok, dan produk yang dihapus di keranjang agar
+// pelanggan bisa melanjutkan checkout.
+func (h *KomerceCartHandler) AcknowledgeChangesPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value(helpers.ContextKeyUserID).(string)
+	cartID, _ := ctx.Value(helpers.ContextKeyCartID).(string)
+
+	if _, err := h.cartSvc.AcknowledgeChanges(ctx, cartID, userID); err != nil {
+		log.Printf("AcknowledgeChangesPost: Gagal mengonfirmasi perubahan keranjang %s: %v", cartID, err)
+		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape("Gagal mengonfirmasi perubahan keranjang. Silakan coba lagi.")), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/carts?status=success&message=%s", url.QueryEscape("Perubahan keranjang sudah dikonfirmasi. Silakan lanjutkan belanja.")), http.StatusSeeOther)
+}
@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
@@ -13,13 +13,17 @@ import (
 	"github.com/Rakhulsr/go-ecommerce/app/models/other"
 	"github.com/Rakhulsr/go-ecommerce/app/repositories"
 	"github.com/Rakhulsr/go-ecommerce/app/services"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/payment"
 	"github.com/Rakhulsr/go-ecommerce/app/utils/sessions"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/unrolled/render"
-	"gorm.io/gorm"
 )
 
+// maxNotificationBodySize membatasi body webhook yang dibaca dan disimpan ke log notifikasi.
+const maxNotificationBodySize = 1 << 20
+
 type KomerceCheckoutHandler struct {
 	render             *render.Render
 	validator          *validator.Validate
@@ -28,12 +32,14 @@ type KomerceCheckoutHandler struct {
 	userRepo           repositories.UserRepositoryImpl
 	orderRepo          repositories.OrderRepository
 	productRepo        repositories.ProductRepositoryImpl
-	db                 *gorm.DB
+	variantRepo        repositories.ProductVariantRepositoryImpl
 	komerceLocationSvc services.KomerceRajaOngkirClient
 	addressRepo        repositories.AddressRepository
 	sessionStore       sessions.SessionStore
 	paymentSvc         services.PaymentService
-	cartItemRepo       repositories.CartItemRepositoryImpl
+	shippingQuoteSvc   *services.ShippingQuoteService
+	cartSvc            *services.CartService
+	stockSvc           *services.StockReservationService
 }
 
 func NewKomerceCheckoutHandler(
@@ -44,12 +50,14 @@ func NewKomerceCheckoutHandler(
 	userRepo repositories.UserRepositoryImpl,
 	orderRepo repositories.OrderRepository,
 	productRepo repositories.ProductRepositoryImpl,
-	db *gorm.DB,
+	variantRepo repositories.ProductVariantRepositoryImpl,
 	komerceLocationSvc services.KomerceRajaOngkirClient,
 	addressRepo repositories.AddressRepository,
 	sessionStore sessions.SessionStore,
 	paymentSvc services.PaymentService,
-	cartItemRepo repositories.CartItemRepositoryImpl,
+	shippingQuoteSvc *services.ShippingQuoteService,
+	cartSvc *services.CartService,
+	stockSvc *services.StockReservationService,
 ) *KomerceCheckoutHandler {
 	return &KomerceCheckoutHandler{
 		render:             render,
@@ -59,12 +67,14 @@ func NewKomerceCheckoutHandler(
 		userRepo:           userRepo,
 		orderRepo:          orderRepo,
 		productRepo:        productRepo,
-		db:                 db,
+		variantRepo:        variantRepo,
 		komerceLocationSvc: komerceLocationSvc,
 		addressRepo:        addressRepo,
 		sessionStore:       sessionStore,
 		paymentSvc:         paymentSvc,
-		cartItemRepo:       cartItemRepo,
+		shippingQuoteSvc:   shippingQuoteSvc,
+		cartSvc:            cartSvc,
+		stockSvc:           stockSvc,
 	}
 }
 
@@ -75,6 +85,9 @@ type CheckoutPageDataKomerce struct {
 	ShippingCost                decimal.Decimal
 	ShippingServiceCode         string
 	ShippingServiceName         string
+	ShippingQuoteID             string
+	CheckoutKey                 string
+	PaymentChannels             []services.PaymentChannelOption
 	FinalTotalPrice             decimal.Decimal
 	FinalTotalPriceForJS        float64
 	Errors                      map[string]string
@@ -166,36 +179,20 @@ func (h *KomerceCheckoutHandler) DisplayCheckoutConfirmation(w http.ResponseWrit
 	}
 
 	addressID := r.PostFormValue("selected_address_id")
-	shippingCostStr := r.PostFormValue("shipping_cost")
-	shippingServiceCode := r.PostFormValue("shipping_service_code")
-	shippingServiceName := r.PostFormValue("shipping_service_name")
-	finalTotalPriceStr := r.PostFormValue("final_total_price")
-
-	if addressID == "" || shippingCostStr == "" || shippingServiceCode == "" || shippingServiceName == "" || finalTotalPriceStr == "" {
-		log.Printf("DisplayCheckoutConfirmation: Data checkout tidak lengkap. AddressID: '%s', ShippingCost: '%s', ServiceCode: '%s', ServiceName: '%s', FinalTotalPrice: '%s'",
-			addressID, shippingCostStr, shippingServiceCode, shippingServiceName, finalTotalPriceStr)
-
-		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s&selected_address_id=%s&shipping_cost=%s&shipping_service_code=%s&shipping_service_name=%s&final_total_price=%s",
-			url.QueryEscape("Data checkout tidak lengkap. Mohon pilih alamat dan opsi pengiriman."),
-			url.QueryEscape(addressID), url.QueryEscape(shippingCostStr), url.QueryEscape(shippingServiceCode), url.QueryEscape(shippingServiceName), url.QueryEscape(finalTotalPriceStr)), http.StatusSeeOther)
-		return
+	shippingQuoteID := r.PostFormValue("shipping_quote_id")
+	courier := r.PostFormValue("courier")
+	backToCart := func(message string) {
+		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s&selected_address_id=%s&courier=%s",
+			url.QueryEscape(message), url.QueryEscape(addressID), url.QueryEscape(courier)), http.StatusSeeOther)
 	}
 
-	shippingCost, err := decimal.NewFromString(shippingCostStr)
-	if err != nil {
-		log.Printf("DisplayCheckoutConfirmation: Invalid shipping cost: %v", err)
-		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape("Biaya pengiriman tidak valid.")), http.StatusSeeOther)
+	if addressID == "" || shippingQuoteID == "" {
+		log.Printf("DisplayCheckoutConfirmation: Data checkout tidak lengkap. AddressID: '%s', ShippingQuoteID: '%s'", addressID, shippingQuoteID)
+		backToCart("Data checkout tidak lengkap. Mohon pilih alamat dan opsi pengiriman.")
 		return
 	}
 
-	finalTotalPrice, err := decimal.NewFromString(finalTotalPriceStr)
-	if err != nil {
-		log.Printf("DisplayCheckoutConfirmation: Invalid final total price: %v", err)
-		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape("Total harga tidak valid.")), http.StatusSeeOther)
-		return
-	}
-
-	cart, err := h.cartRepo.GetCartWithItems(ctx, helpers.GetCartIDFromContext(r))
+	cart, err := h.cartSvc.GetCart(ctx, helpers.GetCartIDFromContext(r), userID)
 	if err != nil || cart == nil || len(cart.CartItems) == 0 {
 		log.Printf("DisplayCheckoutConfirmation: Keranjang kosong atau tidak ditemukan untuk user %s: %v", userID, err)
 		http.Redirect(w, r, fmt.Sprintf("/carts?status=error&message=%s", url.QueryEscape("Keranjang belanja Anda kosong.")), http.StatusSeeOther)
@@ -209,12 +206,23 @@ func (h *KomerceCheckoutHandler) DisplayCheckoutConfirmation(w http.ResponseWrit
 		return
 	}
 
+	quote, err := h.shippingQuoteSvc.ValidateQuote(ctx, shippingQuoteID, cart, selectedAddress)
+	if err != nil {
+		log.Printf("DisplayCheckoutConfirmation: Quote ongkir %s ditolak untuk user %s: %v", shippingQuoteID, userID, err)
+		backToCart(shippingQuoteErrorMessage(err))
+		return
+	}
+	finalTotalPrice := cart.GrandTotal.Add(quote.Cost).Round(2)
+
 	pageData := CheckoutPageDataKomerce{
 		Cart:                 cart,
 		SelectedAddress:      selectedAddress,
-		ShippingCost:         shippingCost,
-		ShippingServiceCode:  shippingServiceCode,
-		ShippingServiceName:  shippingServiceName,
+		ShippingCost:         quote.Cost,
+		ShippingServiceCode:  quote.Code,
+		ShippingServiceName:  quote.ServiceName(),
+		ShippingQuoteID:      quote.ID,
+		CheckoutKey:          uuid.New().String(),
+		PaymentChannels:      services.PaymentChannelOptions(),
 		FinalTotalPrice:      finalTotalPrice,
 		FinalTotalPriceForJS: finalTotalPrice.InexactFloat64(),
 		Errors:               make(map[string]string),
@@ -296,12 +304,12 @@ func (h *KomerceCheckoutHandler) InitiateMidtransTransactionPost(w http.Response
 	cartID := helpers.GetCartIDFromContext(r)
 
 	var reqBody struct {
-		OrderID             string  `json:"order_id"`
-		GrossAmount         float64 `json:"gross_amount"`
-		AddressID           string  `json:"address_id"`
-		ShippingCost        float64 `json:"shipping_cost"`
-		ShippingServiceCode string  `json:"shipping_service_code"`
-		ShippingServiceName string  `json:"shipping_service_name"`
+		OrderID         string  `json:"order_id"`
+		GrossAmount     float64 `json:"gross_amount"`
+		AddressID       string  `json:"address_id"`
+		ShippingQuoteID string  `json:"shipping_quote_id"`
+		IdempotencyKey  string  `json:"idempotency_key"`
+		PaymentChannel  string  `json:"payment_channel"`
 	}
 
 	if err := helpers.DecodeJSONBody(w, r, &reqBody); err != nil {
@@ -311,11 +319,16 @@ func (h *KomerceCheckoutHandler) InitiateMidtransTransactionPost(w http.Response
 	}
 
 	addressID := reqBody.AddressID
-	shippingCost := decimal.NewFromFloat(reqBody.ShippingCost)
-	shippingServiceCode := reqBody.ShippingServiceCode
-	shippingServiceName := reqBody.ShippingServiceName
+	shippingQuoteID := reqBody.ShippingQuoteID
+
+	// submit ulang dengan key yang sama (double-click atau retry setelah timeout) memakai order yang sudah
+	// dibuat, meskipun keranjangnya sudah dilepas dari sesi
+	if order, redirectURL, err := h.checkoutSvc.ResumeCheckout(ctx, userID, reqBody.IdempotencyKey); err != nil || order != nil {
+		h.respondCheckout(w, r, order, redirectURL, err)
+		return
+	}
 
-	if addressID == "" || shippingServiceCode == "" || shippingServiceName == "" || shippingCost.IsZero() {
+	if addressID == "" || shippingQuoteID == "" || reqBody.IdempotencyKey == "" {
 		log.Printf("InitiateMidtransTransactionPost: Data pembayaran tidak lengkap.")
 		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
@@ -324,7 +337,7 @@ func (h *KomerceCheckoutHandler) InitiateMidtransTransactionPost(w http.Response
 		return
 	}
 
-	cart, err := h.cartRepo.GetCartWithItems(ctx, cartID)
+	cart, err := h.cartSvc.GetCart(ctx, cartID, userID)
 	if err != nil || cart == nil || len(cart.CartItems) == 0 {
 		log.Printf("InitiateMidtransTransactionPost: Keranjang kosong atau tidak ditemukan untuk user %s: %v", userID, err)
 		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
@@ -333,6 +346,14 @@ func (h *KomerceCheckoutHandler) InitiateMidtransTransactionPost(w http.Response
 		})
 		return
 	}
+	if cart.HasNotices() {
+		log.Printf("InitiateMidtransTransactionPost: Keranjang %s memiliki %d perubahan yang belum dikonfirmasi", cart.ID, len(cart.Notices))
+		h.render.JSON(w, http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"message": "Harga atau stok beberapa produk di keranjang Anda berubah. Mohon periksa dan konfirmasi perubahan di halaman keranjang.",
+		})
+		return
+	}
 
 	for _, item := range cart.CartItems {
 		product, err := h.productRepo.GetByID(ctx, item.ProductID)
@@ -344,11 +365,33 @@ func (h *KomerceCheckoutHandler) InitiateMidtransTransactionPost(w http.Response
 			})
 			return
 		}
-		if product.Stock < item.Qty {
-			log.Printf("InitiateMidtransTransactionPost: Stok tidak mencukupi untuk produk %s. Stok: %d, Qty: %d", product.Name, product.Stock, item.Qty)
+		var variant *models.ProductVariant
+		if item.VariantID != "" {
+			variant = product.FindVariant(item.VariantID)
+			if variant == nil {
+				log.Printf("InitiateMidtransTransactionPost: Varian %s untuk produk %s tidak ditemukan", item.VariantID, product.Name)
+				h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
+					"success": false,
+					"message": fmt.Sprintf("Varian produk '%s' sudah tidak tersedia.", product.Name),
+				})
+				return
+			}
+		}
+		// stok yang sedang ditahan checkout lain tidak ikut dihitung, sama seperti saat reservasi dibuat
+		availableStock, err := h.stockSvc.AvailableStock(ctx, product, variant)
+		if err != nil {
+			log.Printf("InitiateMidtransTransactionPost: Gagal menghitung stok tersedia produk %s: %v", product.Name, err)
+			h.render.JSON(w, http.StatusInternalServerError, map[string]interface{}{
+				"success": false,
+				"message": "Gagal memeriksa stok produk. Silakan coba lagi.",
+			})
+			return
+		}
+		if availableStock < item.Qty {
+			log.Printf("InitiateMidtransTransactionPost: Stok tidak mencukupi untuk produk %s. Stok: %d, Qty: %d", product.Name, availableStock, item.Qty)
 			h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
 				"success": false,
-				"message": fmt.Sprintf("Stok produk '%s' tidak mencukupi. Sisa stok: %d", product.Name, product.Stock),
+				"message": fmt.Sprintf("Stok produk '%s' tidak mencukupi. Sisa stok: %d", product.Name, availableStock),
 			})
 			return
 		}
@@ -359,11 +402,15 @@ func (h *KomerceCheckoutHandler) InitiateMidtransTransactionPost(w http.Response
 		userID,
 		cartID,
 		addressID,
-		shippingServiceCode,
-		shippingServiceName,
-		shippingCost,
+		shippingQuoteID,
+		reqBody.IdempotencyKey,
+		reqBody.PaymentChannel,
 	)
+	h.respondCheckout(w, r, order, snapRedirectURL, err)
+}
 
+// respondCheckout menulis respons JSON hasil checkout, baik untuk submit pertama maupun submit ulang.
+func (h *KomerceCheckoutHandler) respondCheckout(w http.ResponseWriter, r *http.Request, order *models.Order, snapRedirectURL string, err error) {
 	if err == nil {
 		helpers.ClearCartIDFromSession(w, r, h.sessionStore)
 		log.Printf("InitiateMidtransTransactionPost: Berhasil menginisiasi Midtrans Snap URL: %s untuk OrderID: %s", snapRedirectURL, order.OrderCode)
@@ -374,6 +421,7 @@ func (h *KomerceCheckoutHandler) InitiateMidtransTransactionPost(w http.Response
 		})
 		return
 	}
+	log.Printf("InitiateMidtransTransactionPost: Checkout gagal: %v", err)
 
 	if errors.Is(err, services.ErrInsufficientStock) {
 		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
@@ -383,110 +431,101 @@ func (h *KomerceCheckoutHandler) InitiateMidtransTransactionPost(w http.Response
 		return
 	}
 
-	h.render.JSON(w, http.StatusInternalServerError, map[string]interface{}{
-		"success": false,
-		"message": fmt.Sprintf("Gagal memproses pesanan: %v", err),
-	})
-}
-
-func (h *KomerceCheckoutHandler) MidtransNotificationPost(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	var notificationPayload services.MidtransNotificationPayload
-	err := json.NewDecoder(r.Body).Decode(&notificationPayload)
-	if err != nil {
-		log.Printf("MidtransNotificationPost: Gagal decode JSON body: %v", err)
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+	if errors.Is(err, services.ErrShippingQuoteNotFound) || errors.Is(err, services.ErrShippingQuoteExpired) || errors.Is(err, services.ErrShippingQuoteMismatch) {
+		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": shippingQuoteErrorMessage(err),
+		})
 		return
 	}
 
-	newPaymentStatus, newOrderStatus, shouldReduceStock, shouldClearCart, shouldRefundStock, order, svcErr := h.paymentSvc.ProcessMidtransNotification(ctx, notificationPayload)
-	if svcErr != nil {
-		log.Printf("ERROR: PaymentService failed to process Midtrans notification for OrderID %s: %v", notificationPayload.OrderID, svcErr)
-		http.Error(w, svcErr.Error(), http.StatusInternalServerError)
+	if services.IsVoucherRuleError(err) {
+		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Voucher tidak dapat digunakan: %v. Mohon periksa kembali keranjang Anda.", err),
+		})
 		return
 	}
 
-	if order == nil {
-		log.Printf("WARNING: Order %s not found in database after PaymentService processing.", notificationPayload.OrderID)
-		http.Error(w, "Order not found after status processing", http.StatusNotFound)
+	if errors.Is(err, services.ErrCheckoutKeyInvalid) {
+		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "Sesi checkout tidak valid. Mohon muat ulang halaman checkout.",
+		})
 		return
 	}
 
-	txErr := h.db.Transaction(func(tx *gorm.DB) error {
+	if errors.Is(err, services.ErrPaymentChannelInvalid) {
+		h.render.JSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "Metode pembayaran yang dipilih tidak tersedia. Mohon pilih metode lain.",
+		})
+		return
+	}
 
-		if shouldReduceStock {
+	if errors.Is(err, services.ErrCheckoutInProgress) {
+		h.render.JSON(w, http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"message": "Pesanan Anda sedang diproses. Mohon tunggu sebentar lalu coba lagi.",
+		})
+		return
+	}
 
-			for _, item := range order.OrderItems {
-				product, err := h.productRepo.GetByID(ctx, item.ProductID)
-				if err != nil {
-					log.Printf("WARNING: Failed to get product %s for stock reduction: %v", item.ProductID, err)
+	if errors.Is(err, services.ErrCheckoutCancelled) {
+		h.render.JSON(w, http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"message": "Pesanan dari sesi checkout ini sudah dibatalkan. Mohon kembali ke keranjang dan checkout ulang.",
+		})
+		return
+	}
 
-					return fmt.Errorf("product %s not found during stock reduction: %w", item.ProductID, err)
-				}
-				if product != nil {
-					if product.Stock < item.Qty {
-						log.Printf("CRITICAL: Insufficient stock for product %s (ID: %s) during reduction. Current: %d, Ordered: %d. Rolling back transaction.", product.Name, product.ID, product.Stock, item.Qty)
-						return fmt.Errorf("insufficient stock for product %s. Current: %d, Ordered: %d", product.Name, product.Stock, item.Qty)
-					}
-					if err := h.productRepo.UpdateStock(ctx, tx, product.ID, product.Stock-item.Qty); err != nil {
-						return fmt.Errorf("failed to reduce stock for product %s: %w", product.Name, err)
-					}
-				}
-			}
-		} else if shouldRefundStock {
-
-			for _, item := range order.OrderItems {
-				product, err := h.productRepo.GetByID(ctx, item.ProductID)
-				if err != nil {
-					log.Printf("WARNING: Failed to get product %s for stock refund: %v", item.ProductID, err)
-					continue
-				}
-				if product != nil {
-					if err := h.productRepo.UpdateStock(ctx, tx, product.ID, product.Stock+item.Qty); err != nil {
-						return fmt.Errorf("failed to refund stock for product %s: %w", product.Name, err)
-					}
-					log.Printf("Stock refunded for product %s (ID: %s). New stock: %d", product.Name, product.ID, product.Stock+item.Qty)
-				}
-			}
-		}
+	if errors.Is(err, services.ErrPaymentGatewayUnavailable) {
+		// order sudah tersimpan; tombol bayar boleh ditekan lagi dengan key yang sama
+		h.render.JSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"success": false,
+			"message": "Pesanan Anda sudah tersimpan, tetapi halaman pembayaran belum dapat dibuat. Silakan tekan tombol bayar lagi.",
+		})
+		return
+	}
 
-		if shouldClearCart {
-
-			if order.UserID != "" {
-				cart, err := h.cartRepo.GetCartByUserID(ctx, order.UserID)
-				if err != nil {
-					log.Printf("ERROR: Failed to find cart for user %s associated with order %s for clearing: %v", order.UserID, order.ID, err)
-					return fmt.Errorf("failed to find cart for clearing: %w", err)
-				}
-				if cart != nil {
-
-					if err := h.cartItemRepo.DeleteAllItemsByCartID(ctx, tx, cart.ID); err != nil {
-						return fmt.Errorf("failed to delete cart items for cart %s: %w", cart.ID, err)
-					}
-
-					if err := h.cartRepo.UpdateCartTotalPrice(ctx, tx, cart.ID, decimal.Zero, decimal.Zero, decimal.Zero, decimal.Zero, decimal.Zero, 0); err != nil {
-						return fmt.Errorf("failed to reset cart totals for cart %s: %w", cart.ID, err)
-					}
-
-					helpers.ClearCartIDFromSession(w, r, h.sessionStore)
-				} else {
-					log.Printf("INFO: No active cart found for user %s associated with order %s to clear. Possibly a guest checkout or cart already cleared.", order.UserID, order.OrderCode)
-				}
-			} else {
-				log.Printf("WARNING: UserID not found for Order %s. Cannot clear cart for anonymous user.", order.OrderCode)
-			}
-		}
-		return nil
+	h.render.JSON(w, http.StatusInternalServerError, map[string]interface{}{
+		"success": false,
+		"message": fmt.Sprintf("Gagal memproses pesanan: %v", err),
 	})
+}
 
-	if txErr != nil {
-		log.Printf("ERROR during Midtrans notification (stock/cart ops) transaction for OrderID %s: %v", order.ID, txErr)
+func (h *KomerceCheckoutHandler) MidtransNotificationPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxNotificationBodySize))
+	if err != nil {
+		log.Printf("MidtransNotificationPost: Gagal membaca body: %v", err)
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
 
-		http.Error(w, "Internal server error during stock/cart processing", http.StatusInternalServerError)
+	notification, duplicate, err := h.paymentSvc.ReceiveNotification(ctx, body)
+	if err != nil {
+		log.Printf("ERROR: PaymentService failed to receive payment notification: %v", err)
+		switch {
+		case errors.Is(err, payment.ErrInvalidNotification):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrNotificationInProgress):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if duplicate {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Notification already processed"))
 		return
 	}
 
-	log.Printf("SUCCESS: Order %s and Payment updated to PaymentStatus: %s, OrderStatus: %d. Stock Reduced: %t, Stock Refunded: %t, Cart Cleared: %t", order.ID, newPaymentStatus, newOrderStatus, shouldReduceStock, shouldRefundStock, shouldClearCart)
+	if _, err := h.paymentSvc.ProcessNotification(ctx, notification); err != nil {
+		http.Error(w, "Internal server error during payment processing", http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Notification received and processed"))
@@ -553,3 +592,14 @@ func (h *KomerceCheckoutHandler) CheckoutErrorGet(w http.ResponseWriter, r *http
 
 	h.render.HTML(w, http.StatusOK, "checkout_error", pageData)
 }
+
+func shippingQuoteErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, services.ErrShippingQuoteExpired):
+		return "Ongkos kirim sudah kedaluwarsa. Mohon hitung ulang ongkos kirim."
+	case errors.Is(err, services.ErrShippingQuoteMismatch):
+		return "Keranjang atau alamat berubah setelah ongkos kirim dihitung. Mohon hitung ulang ongkos kirim."
+	default:
+		return "Opsi pengiriman tidak valid. Mohon pilih ulang opsi pengiriman."
+	}
+}
@@ -1,30 +1,67 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 
 	"github.com/Rakhulsr/go-ecommerce/app/helpers"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
 	"github.com/Rakhulsr/go-ecommerce/app/models/other"
 	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/services"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/storage"
 	"github.com/gorilla/mux"
 	"github.com/unrolled/render"
 )
 
+const (
+	PaymentProofKeyPrefix   = "payment-proofs/"
+	paymentProofMaxFileSize = 5 << 20
+)
+
 type OrderHandler struct {
-	render      *render.Render
-	orderRepo   repositories.OrderRepository
-	userRepo    repositories.UserRepositoryImpl
-	paymentRepo *repositories.PaymentRepositoryImpl
+	render            *render.Render
+	orderRepo         repositories.OrderRepository
+	userRepo          repositories.UserRepositoryImpl
+	paymentRepo       *repositories.PaymentRepositoryImpl
+	reviewRepo        repositories.ReviewRepository
+	manualTransferSvc *services.ManualTransferService
+	store             storage.Storage
 }
 
-func NewOrderHandler(render *render.Render, orderRepo repositories.OrderRepository, userRepo repositories.UserRepositoryImpl, paymentRepo repositories.PaymentRepositoryImpl) *OrderHandler {
+func NewOrderHandler(render *render.Render, orderRepo repositories.OrderRepository, userRepo repositories.UserRepositoryImpl, paymentRepo repositories.PaymentRepositoryImpl, reviewRepo repositories.ReviewRepository, manualTransferSvc *services.ManualTransferService, store storage.Storage) *OrderHandler {
 	return &OrderHandler{
-		render:      render,
-		orderRepo:   orderRepo,
-		userRepo:    userRepo,
-		paymentRepo: &paymentRepo,
+		render:            render,
+		orderRepo:         orderRepo,
+		userRepo:          userRepo,
+		paymentRepo:       &paymentRepo,
+		reviewRepo:        reviewRepo,
+		manualTransferSvc: manualTransferSvc,
+		store:             store,
+	}
+}
+
+// OrderPaymentPageData adalah data halaman instruksi pembayaran. ManualTransfer hanya diisi untuk order
+// transfer manual.
+type OrderPaymentPageData struct {
+	other.BasePageData
+	ManualTransfer *services.ManualTransferPayment
+}
+
+func paymentProofErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, services.ErrManualTransferClosed),
+		errors.Is(err, services.ErrManualTransferExpired),
+		errors.Is(err, services.ErrPaymentProofPending),
+		errors.Is(err, services.ErrPaymentProofImageRequired),
+		errors.Is(err, services.ErrPaymentProofSenderRequired):
+		return err.Error()
+	default:
+		return "Gagal mengunggah bukti transfer. Silakan coba lagi."
 	}
 }
 
@@ -115,5 +152,145 @@ func (h *OrderHandler) OrderDetailGet(w http.ResponseWriter, r *http.Request) {
 	pageData.Order = order
 	pageData.Payment = payment
 
+	if order.Status == models.OrderStatusCompleted {
+		reviewed, err := h.reviewRepo.GetReviewedOrderItemIDs(ctx, order.ID)
+		if err != nil {
+			log.Printf("OrderDetailGet: Gagal mengambil status ulasan untuk OrderID %s: %v", order.ID, err)
+		}
+		pageData.ReviewedItems = reviewed
+	}
+
 	h.render.HTML(w, http.StatusOK, "order_detail", pageData)
 }
+
+// PaymentInstructionsGet menampilkan nomor virtual account atau Mandiri bill beserta cara bayarnya untuk order
+// yang dibayar lewat bank transfer. Halaman ini memantau status pembayaran dan kembali ke detail pesanan
+// begitu pembayaran diterima.
+func (h *OrderHandler) PaymentInstructionsGet(w http.ResponseWriter, r *http.Request) {
+	orderCode := mux.Vars(r)["orderCode"]
+	ctx := r.Context()
+
+	order, err := h.orderRepo.FindByCodeWithDetails(ctx, orderCode)
+	if err != nil || order == nil {
+		log.Printf("PaymentInstructionsGet: Pesanan %s tidak ditemukan: %v", orderCode, err)
+		http.Redirect(w, r, "/orders?status=error&message="+url.QueryEscape("Pesanan tidak ditemukan."), http.StatusSeeOther)
+		return
+	}
+	if order.UserID != helpers.GetUserIDFromContext(ctx) {
+		http.Redirect(w, r, "/orders?status=error&message="+url.QueryEscape("Anda tidak memiliki akses ke pesanan ini."), http.StatusSeeOther)
+		return
+	}
+
+	payment, err := h.paymentRepo.FindByOrderID(ctx, order.ID)
+	if err != nil {
+		log.Printf("PaymentInstructionsGet: Gagal mendapatkan pembayaran untuk OrderID %s: %v", order.ID, err)
+	}
+	pageData := &OrderPaymentPageData{}
+	if payment != nil && payment.IsManualTransfer() {
+		// halaman transfer manual tetap bisa dibuka setelah order diproses untuk melihat status bukti transfer
+		pageData.ManualTransfer, err = h.manualTransferSvc.GetPayment(ctx, order.UserID, order.OrderCode)
+		if err != nil {
+			log.Printf("PaymentInstructionsGet: Gagal mengambil transfer manual pesanan %s: %v", order.OrderCode, err)
+			http.Redirect(w, r, "/orders/"+order.OrderCode, http.StatusSeeOther)
+			return
+		}
+	} else if payment == nil || !payment.IsBankTransfer() || order.Status != models.OrderStatusPending {
+		http.Redirect(w, r, "/orders/"+order.OrderCode, http.StatusSeeOther)
+		return
+	}
+
+	baseDataMap := helpers.GetBaseData(r, nil)
+	helpers.PopulateBaseData(&pageData.BasePageData, baseDataMap)
+
+	pageData.Title = "Instruksi Pembayaran #" + order.OrderCode
+	pageData.Order = order
+	pageData.Payment = payment
+
+	h.render.HTML(w, http.StatusOK, "order_payment", pageData)
+}
+
+// PaymentStatusGet mengembalikan status order dan pembayaran untuk dipantau halaman pesanan. Status dibaca dari
+// database yang diperbarui oleh notifikasi gateway, bukan ditanyakan ulang ke gateway.
+func (h *OrderHandler) PaymentStatusGet(w http.ResponseWriter, r *http.Request) {
+	orderCode := mux.Vars(r)["orderCode"]
+	ctx := r.Context()
+
+	order, err := h.orderRepo.FindByCode(ctx, orderCode)
+	if err != nil {
+		log.Printf("PaymentStatusGet: Gagal mendapatkan pesanan %s: %v", orderCode, err)
+		h.render.JSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "Gagal memuat status pesanan.",
+		})
+		return
+	}
+	if order == nil || order.UserID != helpers.GetUserIDFromContext(ctx) {
+		h.render.JSON(w, http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "Pesanan tidak ditemukan.",
+		})
+		return
+	}
+
+	h.render.JSON(w, http.StatusOK, map[string]interface{}{
+		"success":             true,
+		"order_status":        order.Status,
+		"order_status_text":   helpers.OrderStatusText(order.Status),
+		"payment_status":      order.PaymentStatus,
+		"payment_status_text": helpers.PaymentStatusText(order.PaymentStatus),
+		"pending":             order.Status == models.OrderStatusPending,
+	})
+}
+
+// PaymentProofPost menerima foto bukti transfer manual dari pembeli untuk diverifikasi admin.
+func (h *OrderHandler) PaymentProofPost(w http.ResponseWriter, r *http.Request) {
+	orderCode := mux.Vars(r)["orderCode"]
+	userID := helpers.GetUserIDFromContext(r.Context())
+	pageURL := fmt.Sprintf("/orders/%s/payment", url.PathEscape(orderCode))
+
+	if err := r.ParseMultipartForm(paymentProofMaxFileSize + (1 << 20)); err != nil {
+		log.Printf("PaymentProofPost: Gagal parse form: %v", err)
+		http.Redirect(w, r, pageURL+"?status=error&message="+url.QueryEscape("Ukuran foto terlalu besar."), http.StatusSeeOther)
+		return
+	}
+
+	fileHeaders := r.MultipartForm.File["receipt"]
+	if len(fileHeaders) == 0 {
+		http.Redirect(w, r, pageURL+"?status=error&message="+url.QueryEscape(services.ErrPaymentProofImageRequired.Error()), http.StatusSeeOther)
+		return
+	}
+	fileHeader := fileHeaders[0]
+	if fileHeader.Size > paymentProofMaxFileSize {
+		http.Redirect(w, r, pageURL+"?status=error&message="+url.QueryEscape("Bukti transfer harus berupa gambar dengan ukuran maksimal 5MB."), http.StatusSeeOther)
+		return
+	}
+
+	imagePath, err := helpers.SaveUploadedImage(r.Context(), h.store, fileHeader, PaymentProofKeyPrefix)
+	if services.IsImageValidationError(err) {
+		http.Redirect(w, r, pageURL+"?status=error&message="+url.QueryEscape("Bukti transfer tidak valid: "+err.Error()+"."), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		log.Printf("PaymentProofPost: Gagal menyimpan bukti transfer pesanan %s: %v", orderCode, err)
+		http.Redirect(w, r, pageURL+"?status=error&message="+url.QueryEscape("Gagal mengunggah foto."), http.StatusSeeOther)
+		return
+	}
+
+	_, err = h.manualTransferSvc.SubmitProof(r.Context(), userID, orderCode, services.PaymentProofInput{
+		ImagePath:  imagePath,
+		SenderName: r.FormValue("sender_name"),
+		SenderBank: r.FormValue("sender_bank"),
+	})
+	if err != nil {
+		log.Printf("PaymentProofPost: Gagal menyimpan bukti transfer pesanan %s: %v", orderCode, err)
+		helpers.RemoveUploadedFile(context.Background(), h.store, imagePath)
+		if errors.Is(err, services.ErrManualTransferNotFound) {
+			http.Redirect(w, r, "/orders?status=error&message="+url.QueryEscape("Pesanan tidak ditemukan."), http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, pageURL+"?status=error&message="+url.QueryEscape(paymentProofErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, pageURL+"?status=success&message="+url.QueryEscape("Bukti transfer terkirim. Pesanan akan diproses setelah pembayaran diverifikasi admin."), http.StatusSeeOther)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/Rakhulsr/go-ecommerce/app/models"
 	"github.com/Rakhulsr/go-ecommerce/app/models/other"
 	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/services"
 	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
 	"github.com/gorilla/mux"
 	"github.com/unrolled/render"
@@ -18,12 +19,18 @@ type ProductHandler struct {
 	repo         repositories.ProductRepositoryImpl
 	categoryRepo repositories.CategoryRepositoryImpl
 	render       *render.Render
+	stockSvc     *services.StockReservationService
+	searchSvc    *services.ProductSearchService
+	reviewRepo   repositories.ReviewRepository
+	wishlistSvc  *services.WishlistService
 }
 
-func NewProductHandler(p repositories.ProductRepositoryImpl, c repositories.CategoryRepositoryImpl, r *render.Render) *ProductHandler {
-	return &ProductHandler{p, c, r}
+func NewProductHandler(p repositories.ProductRepositoryImpl, c repositories.CategoryRepositoryImpl, r *render.Render, stockSvc *services.StockReservationService, searchSvc *services.ProductSearchService, reviewRepo repositories.ReviewRepository, wishlistSvc *services.WishlistService) *ProductHandler {
+	return &ProductHandler{p, c, r, stockSvc, searchSvc, reviewRepo, wishlistSvc}
 }
 
+const reviewsPerPage = 10
+
 type ProductDetailPageData struct {
 	BaseData      other.BasePageData
 	Product       models.Product
@@ -48,8 +55,7 @@ type ProductListPageData struct {
 
 func (h *ProductHandler) Products(w http.ResponseWriter, r *http.Request) {
 
-	slug := r.URL.Query().Get("category")
-	query := r.URL.Query().Get("q")
+	filter := other.ParseProductFilter(r.URL.Query())
 	pageStr := r.URL.Query().Get("page")
 	page, _ := strconv.Atoi(pageStr)
 	if page < 1 {
@@ -58,29 +64,21 @@ func (h *ProductHandler) Products(w http.ResponseWriter, r *http.Request) {
 	limit := 9
 	offset := (page - 1) * limit
 
-	var (
-		products []models.Product
-		err      error
-		total    int64
-	)
 	breadcrumbs := []breadcrumb.Breadcrumb{
 		{Name: "Home", URL: "/"},
 		{Name: "Products", URL: "/products"},
 	}
 
-	var currentCategory models.Category
-
-	switch {
-	case query != "":
-		products, total, err = h.repo.SearchProductsPaginated(r.Context(), query, limit, offset)
-	case slug != "":
-		products, total, err = h.repo.GetByCategorySlugPaginated(r.Context(), slug, limit, offset)
-	default:
-		products, total, err = h.repo.GetPaginated(r.Context(), limit, offset)
+	userID, _ := r.Context().Value(helpers.ContextKeyUserID).(string)
+	result, err := h.searchSvc.Search(r.Context(), filter, userID, limit, offset, page == 1)
+	if err != nil {
+		http.Error(w, "Gagal mengambil data produk", http.StatusInternalServerError)
+		return
 	}
 
+	facets, err := h.repo.GetProductFacets(r.Context(), result.Filter)
 	if err != nil {
-		http.Error(w, "Gagal mengambil data produk", http.StatusInternalServerError)
+		http.Error(w, "Gagal menghitung filter produk", http.StatusInternalServerError)
 		return
 	}
 
@@ -90,22 +88,47 @@ func (h *ProductHandler) Products(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dataMap := helpers.GetBaseData(r, map[string]interface{}{
-		"title":       "Produk Kami",
-		"products":    products,
-		"categories":  categories,
-		"current":     page,
-		"totalPages":  int((total + int64(limit) - 1) / int64(limit)),
-		"category":    slug,
-		"searchQuery": query,
-		"Breadcrumbs": breadcrumbs,
-		"IsAuthPage":  false,
-	})
+	productIDs := make([]string, 0, len(result.Products))
+	for _, product := range result.Products {
+		productIDs = append(productIDs, product.ID)
+	}
+	ratings, err := h.reviewRepo.GetRatingSummaries(r.Context(), productIDs)
+	if err != nil {
+		log.Printf("Products: Gagal mengambil rating produk: %v", err)
+	}
+	wishlisted, err := h.wishlistSvc.GetProductIDs(r.Context(), userID)
+	if err != nil {
+		log.Printf("Products: Gagal mengambil wishlist user %s: %v", userID, err)
+	}
 
-	if currentCategory.ID != "" {
-		dataMap["currentCategory"] = currentCategory
+	if len(filter.CategorySlugs) == 1 {
+		for _, category := range categories {
+			if category.Slug == filter.CategorySlugs[0] {
+				breadcrumbs = append(breadcrumbs, breadcrumb.Breadcrumb{Name: category.Name, URL: "/products?category=" + category.Slug})
+				break
+			}
+		}
 	}
 
+	dataMap := helpers.GetBaseData(r, map[string]interface{}{
+		"title":          "Produk Kami",
+		"products":       result.Products,
+		"categories":     categories,
+		"current":        page,
+		"totalPages":     int((result.Total + int64(limit) - 1) / int64(limit)),
+		"total":          result.Total,
+		"filter":         result.Filter,
+		"facets":         facets,
+		"ratings":        ratings,
+		"wishlisted":     wishlisted,
+		"currentURL":     r.URL.RequestURI(),
+		"ratingOptions":  other.ProductRatingThresholds,
+		"searchQuery":    filter.Keyword,
+		"correctedQuery": result.CorrectedQuery,
+		"Breadcrumbs":    breadcrumbs,
+		"IsAuthPage":     false,
+	})
+
 	datas := helpers.GetBaseData(r, dataMap)
 
 	_ = h.render.HTML(w, http.StatusOK, "products", datas)
@@ -136,6 +159,10 @@ func (h *ProductHandler) ProductDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.stockSvc.ApplyAvailableStock(r.Context(), product); err != nil {
+		log.Printf("ProductDetail: Gagal menghitung stok tersedia untuk produk %s: %v", product.ID, err)
+	}
+
 	breadcrumbs := []breadcrumb.Breadcrumb{
 		{Name: "Home", URL: "/"},
 		{Name: "Products", URL: "/products"},
@@ -153,11 +180,55 @@ func (h *ProductHandler) ProductDetail(w http.ResponseWriter, r *http.Request) {
 
 	priceFloat, _ := product.Price.Float64()
 
+	ratingSummary, err := h.reviewRepo.GetRatingSummary(r.Context(), product.ID)
+	if err != nil {
+		log.Printf("ProductDetail: Gagal mengambil ringkasan rating produk %s: %v", product.ID, err)
+		ratingSummary = &other.RatingSummary{}
+	}
+
+	reviewRating, _ := strconv.Atoi(r.URL.Query().Get("rating"))
+	if reviewRating < 1 || reviewRating > 5 {
+		reviewRating = 0
+	}
+	reviewPage, _ := strconv.Atoi(r.URL.Query().Get("review_page"))
+	if reviewPage < 1 {
+		reviewPage = 1
+	}
+	reviews, reviewTotal, err := h.reviewRepo.GetApprovedByProductID(r.Context(), product.ID, reviewRating, reviewsPerPage, (reviewPage-1)*reviewsPerPage)
+	if err != nil {
+		log.Printf("ProductDetail: Gagal mengambil ulasan produk %s: %v", product.ID, err)
+	}
+
+	// tombol "Tulis Ulasan" hanya untuk pembeli dengan pesanan selesai yang belum diulas
+	var reviewableItem *models.OrderItem
+	wishlisted := false
+	if userID, _ := r.Context().Value(helpers.ContextKeyUserID).(string); userID != "" {
+		items, err := h.reviewRepo.FindReviewableItems(r.Context(), userID, product.ID)
+		if err != nil {
+			log.Printf("ProductDetail: Gagal memeriksa item yang bisa diulas: %v", err)
+		} else if len(items) > 0 {
+			reviewableItem = &items[0]
+		}
+
+		wishlistIDs, err := h.wishlistSvc.GetProductIDs(r.Context(), userID)
+		if err != nil {
+			log.Printf("ProductDetail: Gagal memeriksa wishlist: %v", err)
+		}
+		wishlisted = wishlistIDs[product.ID]
+	}
+
 	dataMap := map[string]interface{}{
-		"title":       product.Name,
-		"product":     *product,
-		"price":       priceFloat,
-		"Breadcrumbs": breadcrumbs,
+		"title":            product.Name,
+		"product":          *product,
+		"price":            priceFloat,
+		"Breadcrumbs":      breadcrumbs,
+		"ratingSummary":    ratingSummary,
+		"reviews":          reviews,
+		"reviewRating":     reviewRating,
+		"reviewPage":       reviewPage,
+		"reviewTotalPages": int((reviewTotal + reviewsPerPage - 1) / reviewsPerPage),
+		"reviewableItem":   reviewableItem,
+		"wishlisted":       wishlisted,
 	}
 
 	data := helpers.GetBaseData(r, dataMap)
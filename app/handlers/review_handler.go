@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/helpers"
+	"github.com/Rakhulsr/go-ecommerce/app/services"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/storage"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/unrolled/render"
+)
+
+const (
+	ReviewPhotoKeyPrefix   = "reviews/"
+	reviewPhotoMaxFileSize = 2 << 20
+)
+
+type ReviewHandler struct {
+	render    *render.Render
+	validate  *validator.Validate
+	reviewSvc *services.ReviewService
+	store     storage.Storage
+}
+
+func NewReviewHandler(render *render.Render, validate *validator.Validate, reviewSvc *services.ReviewService, store storage.Storage) *ReviewHandler {
+	return &ReviewHandler{
+		render:    render,
+		validate:  validate,
+		reviewSvc: reviewSvc,
+		store:     store,
+	}
+}
+
+type ReviewForm struct {
+	Rating int    `validate:"required,min=1,max=5"`
+	Title  string `validate:"max=255"`
+	Body   string `validate:"max=2000"`
+}
+
+func reviewErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, services.ErrReviewItemNotFound),
+		errors.Is(err, services.ErrReviewNotEligible),
+		errors.Is(err, services.ErrReviewAlreadyExists),
+		errors.Is(err, services.ErrReviewInvalidRating):
+		return err.Error()
+	default:
+		return "Gagal memproses ulasan. Silakan coba lagi."
+	}
+}
+
+func (h *ReviewHandler) ReviewFormGet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderCode := vars["orderCode"]
+	userID := helpers.GetUserIDFromContext(r.Context())
+
+	order, item, err := h.reviewSvc.GetReviewableItem(r.Context(), userID, orderCode, vars["itemID"])
+	if err != nil {
+		if !errors.Is(err, services.ErrReviewItemNotFound) && !errors.Is(err, services.ErrReviewNotEligible) && !errors.Is(err, services.ErrReviewAlreadyExists) {
+			log.Printf("ReviewFormGet: Gagal memeriksa item %s pada pesanan %s: %v", vars["itemID"], orderCode, err)
+		}
+		http.Redirect(w, r, fmt.Sprintf("/orders/%s?status=error&message=%s", url.PathEscape(orderCode), url.QueryEscape(reviewErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+
+	data := helpers.GetBaseData(r, map[string]interface{}{
+		"title":     "Tulis Ulasan",
+		"order":     order,
+		"item":      item,
+		"maxPhotos": services.MaxReviewPhotos,
+		"Breadcrumbs": []breadcrumb.Breadcrumb{
+			{Name: "Home", URL: "/"},
+			{Name: "Pesanan", URL: "/orders"},
+			{Name: "#" + order.OrderCode, URL: "/orders/" + order.OrderCode},
+			{Name: "Tulis Ulasan", URL: r.URL.Path},
+		},
+	})
+
+	_ = h.render.HTML(w, http.StatusOK, "review_form", data)
+}
+
+func (h *ReviewHandler) ReviewPost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderCode := vars["orderCode"]
+	itemID := vars["itemID"]
+	userID := helpers.GetUserIDFromContext(r.Context())
+	formURL := fmt.Sprintf("/orders/%s/items/%s/review", url.PathEscape(orderCode), url.PathEscape(itemID))
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		log.Printf("ReviewPost: Gagal parse form: %v", err)
+		http.Redirect(w, r, formURL+"?status=error&message="+url.QueryEscape("Ukuran foto terlalu besar."), http.StatusSeeOther)
+		return
+	}
+
+	rating, _ := strconv.Atoi(r.FormValue("rating"))
+	form := ReviewForm{
+		Rating: rating,
+		Title:  strings.TrimSpace(r.FormValue("title")),
+		Body:   strings.TrimSpace(r.FormValue("body")),
+	}
+	if err := h.validate.Struct(form); err != nil {
+		http.Redirect(w, r, formURL+"?status=error&message="+url.QueryEscape("Pilih rating 1-5 dan pastikan judul maksimal 255 karakter serta ulasan maksimal 2000 karakter."), http.StatusSeeOther)
+		return
+	}
+
+	if _, _, err := h.reviewSvc.GetReviewableItem(r.Context(), userID, orderCode, itemID); err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/orders/%s?status=error&message=%s", url.PathEscape(orderCode), url.QueryEscape(reviewErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+
+	var photoPaths []string
+	if r.MultipartForm != nil {
+		files := r.MultipartForm.File["photos"]
+		if len(files) > services.MaxReviewPhotos {
+			http.Redirect(w, r, formURL+"?status=error&message="+url.QueryEscape(fmt.Sprintf("Maksimal %d foto per ulasan.", services.MaxReviewPhotos)), http.StatusSeeOther)
+			return
+		}
+		for _, fileHeader := range files {
+			if fileHeader.Size > reviewPhotoMaxFileSize {
+				h.removeReviewPhotos(r.Context(), photoPaths)
+				http.Redirect(w, r, formURL+"?status=error&message="+url.QueryEscape("Foto harus berupa gambar dengan ukuran maksimal 2MB."), http.StatusSeeOther)
+				return
+			}
+			path, err := helpers.SaveUploadedImage(r.Context(), h.store, fileHeader, ReviewPhotoKeyPrefix)
+			if services.IsImageValidationError(err) {
+				h.removeReviewPhotos(r.Context(), photoPaths)
+				http.Redirect(w, r, formURL+"?status=error&message="+url.QueryEscape("Foto ulasan tidak valid: "+err.Error()+"."), http.StatusSeeOther)
+				return
+			}
+			if err != nil {
+				log.Printf("ReviewPost: Gagal menyimpan foto ulasan: %v", err)
+				h.removeReviewPhotos(r.Context(), photoPaths)
+				http.Redirect(w, r, formURL+"?status=error&message="+url.QueryEscape("Gagal mengunggah foto."), http.StatusSeeOther)
+				return
+			}
+			photoPaths = append(photoPaths, path)
+		}
+	}
+
+	_, err := h.reviewSvc.Submit(r.Context(), userID, orderCode, itemID, services.ReviewInput{
+		Rating:     form.Rating,
+		Title:      form.Title,
+		Body:       form.Body,
+		PhotoPaths: photoPaths,
+	})
+	if err != nil {
+		log.Printf("ReviewPost: Gagal menyimpan ulasan item %s: %v", itemID, err)
+		h.removeReviewPhotos(r.Context(), photoPaths)
+		http.Redirect(w, r, fmt.Sprintf("/orders/%s?status=error&message=%s", url.PathEscape(orderCode), url.QueryEscape(reviewErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/orders/%s?status=success&message=%s", url.PathEscape(orderCode), url.QueryEscape("Terima kasih! Ulasan Anda akan tampil setelah dimoderasi.")), http.StatusSeeOther)
+}
+
+func (h *ReviewHandler) removeReviewPhotos(ctx context.Context, paths []string) {
+	for _, path := range paths {
+		helpers.RemoveUploadedFile(ctx, h.store, path)
+	}
+}
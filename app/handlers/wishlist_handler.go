@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/helpers"
+	"github.com/Rakhulsr/go-ecommerce/app/services"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/breadcrumb"
+	"github.com/unrolled/render"
+)
+
+type WishlistHandler struct {
+	render      *render.Render
+	wishlistSvc *services.WishlistService
+}
+
+func NewWishlistHandler(render *render.Render, wishlistSvc *services.WishlistService) *WishlistHandler {
+	return &WishlistHandler{
+		render:      render,
+		wishlistSvc: wishlistSvc,
+	}
+}
+
+func (h *WishlistHandler) WishlistGet(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserIDFromContext(r.Context())
+
+	items, err := h.wishlistSvc.GetItems(r.Context(), userID)
+	if err != nil {
+		log.Printf("WishlistGet: Gagal mengambil wishlist user %s: %v", userID, err)
+	}
+
+	data := helpers.GetBaseData(r, map[string]interface{}{
+		"title": "Wishlist Saya",
+		"items": items,
+		"Breadcrumbs": []breadcrumb.Breadcrumb{
+			{Name: "Home", URL: "/"},
+			{Name: "Wishlist", URL: "/wishlist"},
+		},
+	})
+
+	_ = h.render.HTML(w, http.StatusOK, "wishlist", data)
+}
+
+func (h *WishlistHandler) WishlistAddPost(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserIDFromContext(r.Context())
+	returnURL := wishlistReturnURL(r)
+
+	if _, err := h.wishlistSvc.Add(r.Context(), userID, r.FormValue("product_id")); err != nil {
+		if !errors.Is(err, services.ErrWishlistProductNotFound) {
+			log.Printf("WishlistAddPost: Gagal menambahkan wishlist user %s: %v", userID, err)
+		}
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Gagal menambahkan produk ke wishlist."), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, returnURL+"status=success&message="+url.QueryEscape("Produk disimpan ke wishlist."), http.StatusSeeOther)
+}
+
+func (h *WishlistHandler) WishlistRemovePost(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserIDFromContext(r.Context())
+	returnURL := wishlistReturnURL(r)
+
+	if err := h.wishlistSvc.Remove(r.Context(), userID, r.FormValue("product_id")); err != nil {
+		log.Printf("WishlistRemovePost: Gagal menghapus wishlist user %s: %v", userID, err)
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Gagal menghapus produk dari wishlist."), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, returnURL+"status=success&message="+url.QueryEscape("Produk dihapus dari wishlist."), http.StatusSeeOther)
+}
+
+func (h *WishlistHandler) WishlistAlertsPost(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserIDFromContext(r.Context())
+	returnURL := wishlistReturnURL(r)
+
+	backInStock := r.FormValue("notify_back_in_stock") == "1"
+	priceDrop := r.FormValue("notify_price_drop") == "1"
+	if err := h.wishlistSvc.SetAlerts(r.Context(), userID, r.FormValue("product_id"), backInStock, priceDrop); err != nil {
+		if !errors.Is(err, services.ErrWishlistProductNotFound) {
+			log.Printf("WishlistAlertsPost: Gagal menyimpan notifikasi user %s: %v", userID, err)
+		}
+		http.Redirect(w, r, returnURL+"status=error&message="+url.QueryEscape("Gagal menyimpan pengaturan notifikasi."), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, returnURL+"status=success&message="+url.QueryEscape("Pengaturan notifikasi disimpan."), http.StatusSeeOther)
+}
+
+func (h *WishlistHandler) WishlistMoveToCartPost(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserIDFromContext(r.Context())
+	cartID := helpers.GetCartIDFromContext(r)
+
+	product, err := h.wishlistSvc.MoveToCart(r.Context(), cartID, userID, r.FormValue("product_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWishlistVariantRequired):
+			http.Redirect(w, r, "/products/"+product.Slug+"?status=warning&message="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		case errors.Is(err, services.ErrWishlistProductNotFound):
+			http.Redirect(w, r, "/wishlist?status=error&message="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		default:
+			log.Printf("WishlistMoveToCartPost: Gagal memindahkan produk ke keranjang untuk user %s: %v", userID, err)
+			http.Redirect(w, r, "/wishlist?status=error&message="+url.QueryEscape("Gagal memindahkan produk ke keranjang: "+err.Error()), http.StatusSeeOther)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/carts?status=success&message="+url.QueryEscape(product.Name+" dipindahkan ke keranjang."), http.StatusSeeOther)
+}
+
+// wishlistReturnURL mengembalikan user ke halaman asal (hanya path lokal). Hasilnya selalu diakhiri "?" atau "&".
+func wishlistReturnURL(r *http.Request) string {
+	returnTo := r.FormValue("return_to")
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.HasPrefix(returnTo, "/\\") {
+		returnTo = "/wishlist"
+	}
+
+	parsed, err := url.Parse(returnTo)
+	if err != nil {
+		return "/wishlist?"
+	}
+	query := parsed.Query()
+	query.Del("status")
+	query.Del("message")
+	if encoded := query.Encode(); encoded != "" {
+		return parsed.Path + "?" + encoded + "&"
+	}
+	return parsed.Path + "?"
+}
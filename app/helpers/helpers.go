@@ -398,6 +398,8 @@ func OrderStatusText(status int) string {
 		return "Dibatalkan"
 	case models.OrderStatusRefunded:
 		return "Pengembalian Dana"
+	case models.OrderStatusPartiallyRefunded:
+		return "Dikembalikan Sebagian"
 	case models.OrderStatusFailed:
 		return "Gagal"
 	default:
@@ -417,6 +419,8 @@ func PaymentStatusText(status string) string {
 		return "Dibatalkan"
 	case "Refunded":
 		return "Dikembalikan"
+	case "Partially Refunded":
+		return "Dikembalikan Sebagian"
 	case "settlement":
 		return "Lunas"
 	case "capture":
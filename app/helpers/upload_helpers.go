@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/utils/imaging"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/storage"
+	"github.com/google/uuid"
+)
+
+// SaveUploadedImage menyimpan file upload gambar ke storage dengan key keyPrefix + nama acak dan
+// mengembalikan URL publiknya. Isi file diperiksa dengan imaging.Validate lalu di-encode ulang dengan
+// imaging.Process, dan ekstensi key diambil dari hasil encode, jadi nama file dan Content-Type dari klien
+// tidak pernah menentukan tipe file yang disajikan. Dipakai oleh upload foto ulasan dan bukti transfer.
+func SaveUploadedImage(ctx context.Context, store storage.Storage, fileHeader *multipart.FileHeader, keyPrefix string) (string, error) {
+	if fileHeader.Size > imaging.MaxUploadBytes {
+		return "", imaging.ErrTooLarge
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Printf("SaveUploadedImage: Gagal membuka file yang diunggah: %v", err)
+		return "", fmt.Errorf("gagal membuka file yang diunggah: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, imaging.MaxUploadBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("gagal membaca file yang diunggah: %w", err)
+	}
+	if err := imaging.Validate(data); err != nil {
+		return "", err
+	}
+	result, err := imaging.Process(data)
+	if err != nil {
+		return "", err
+	}
+
+	key := strings.TrimSuffix(keyPrefix, "/") + "/" + uuid.New().String() + result.Ext
+	if err := store.Put(ctx, key, bytes.NewReader(result.Master), int64(len(result.Master)), storage.ContentType(key)); err != nil {
+		log.Printf("SaveUploadedImage: Gagal menyimpan file %s: %v", key, err)
+		return "", fmt.Errorf("gagal menyimpan file yang diunggah: %w", err)
+	}
+
+	return store.URL(key), nil
+}
+
+// RemoveUploadedFile menghapus file hasil SaveUploadedImage berdasarkan URL publiknya.
+func RemoveUploadedFile(ctx context.Context, store storage.Storage, publicURL string) {
+	if err := storage.DeleteURL(ctx, store, publicURL); err != nil {
+		log.Printf("RemoveUploadedFile: Gagal menghapus %s: %v", publicURL, err)
+	}
+}
@@ -9,10 +9,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Rakhulsr/go-ecommerce/app/configs"
 	"github.com/Rakhulsr/go-ecommerce/app/helpers"
 	"github.com/Rakhulsr/go-ecommerce/app/models"
 	"github.com/Rakhulsr/go-ecommerce/app/repositories"
-	"github.com/Rakhulsr/go-ecommerce/app/utils/calc"
 	"github.com/Rakhulsr/go-ecommerce/app/utils/sessions"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -119,18 +119,23 @@ func AuthAndCartSessionMiddleware(userRepo repositories.UserRepositoryImpl, cart
 					}
 
 				}
-			} else {
+			} else if cartIDFromSession := sessionStore.GetCartID(w, r); cartIDFromSession != "" {
 
-				if sessionStore.GetCartID(w, r) != "" {
+				// pengunjung tanpa login memakai keranjang tamu selama belum kedaluwarsa
+				cart, err := cartRepo.GetGuestCart(ctx, cartIDFromSession)
+				if err != nil {
+					log.Printf("AuthAndCartSessionMiddleware: Failed to get guest cart %s: %v", cartIDFromSession, err)
+				} else if cart == nil || time.Since(cart.UpdatedAt) > configs.GetGuestCartTTL() {
 					sessionStore.ClearCartID(w, r)
-					log.Printf("AuthAndCartSessionMiddleware: User not logged in, clearing cart_id from session.")
+					log.Printf("AuthAndCartSessionMiddleware: Guest cart %s not found or expired, clearing cart_id from session.", cartIDFromSession)
+				} else {
+					activeCartID = cart.ID
 				}
-				activeCartID = ""
 			}
 
 			requestPath := r.URL.Path
 			requiresLoginPaths := []string{
-				"/carts", "/carts/add", "/checkout", "/carts/delete", "/profile", "/addresses", "/orders", "/payment", "/shipment",
+				"/checkout", "/profile", "/addresses", "/orders", "/payment", "/shipment",
 			}
 			shouldRedirect := false
 			for _, p := range requiresLoginPaths {
@@ -184,7 +189,7 @@ func CartCountMiddleware(cartRepo repositories.CartRepositoryImpl) func(http.Han
 				return
 			}
 
-			cart.CalculateTotals(calc.GetTaxPercent())
+			cart.CalculateTotals(cart.TaxPercent)
 			count := cart.TotalItems
 			ctx := context.WithValue(r.Context(), helpers.CartCountKey, count)
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -202,36 +207,46 @@ func AuthRequiredMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func ContentSecurityPolicyMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Kebijakan CSP yang diizinkan:
-		// default-src: 'self' mengizinkan sumber daya dari domain yang sama
-		// script-src: 'self' mengizinkan skrip dari domain yang sama
-		//               https://app.sandbox.midtrans.com mengizinkan skrip dari domain Midtrans
-		//               https://cdn.jsdelivr.net (untuk SweetAlert2)
-		//               'unsafe-inline' mungkin diperlukan untuk beberapa inline script (hati-hati)
-		//               'unsafe-eval' diperlukan karena Midtrans Snap menggunakan eval()
-		// connect-src: 'self' mengizinkan koneksi dari domain yang sama
-		//                https://app.sandbox.midtrans.com mengizinkan koneksi ke domain Midtrans
-		//                https://api.sandbox.midtrans.com mengizinkan koneksi ke API Midtrans
-		//                https://snap.midtrans.com (jika ada)
-		//                https://snap.i.b-id-ca-eks-01.gopay.sh (dari log Anda, tambahkan ini juga)
-		// frame-src: https://app.sandbox.midtrans.com mengizinkan iframe dari domain Midtrans
-		// img-src: 'self' data: https://app.sandbox.midtrans.com (gambar Midtrans)
-		// style-src: 'self' 'unsafe-inline'
-		// font-src: 'self' https://cdnjs.cloudflare.com (untuk Font Awesome)
-
-		csp := "default-src 'self';" +
-			"script-src 'self' https://app.sandbox.midtrans.com https://cdn.jsdelivr.net 'unsafe-inline' 'unsafe-eval';" +
-			"connect-src 'self' https://app.sandbox.midtrans.com https://api.sandbox.midtrans.com https://snap.midtrans.com https://snap.i.b-id-ca-eks-01.gopay.sh;" +
-			"frame-src https://app.sandbox.midtrans.com;" +
-			"img-src 'self' data: https://app.sandbox.midtrans.com;" +
-			"style-src 'self' 'unsafe-inline' https://cdnjs.cloudflare.com;" +
-			"font-src 'self' https://cdnjs.cloudflare.com;" +
-			"object-src 'none'; " +
-			"base-uri 'self';"
-
-		w.Header().Set("Content-Security-Policy", csp)
-		next.ServeHTTP(w, r)
-	})
+// ContentSecurityPolicyMiddleware memasang header CSP. imgSources menambah origin img-src, misalnya
+// URL publik bucket S3 tempat gambar produk disimpan.
+func ContentSecurityPolicyMiddleware(imgSources ...string) func(http.Handler) http.Handler {
+	extraImgSrc := ""
+	for _, src := range imgSources {
+		if src != "" {
+			extraImgSrc += " " + src
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Kebijakan CSP yang diizinkan:
+			// default-src: 'self' mengizinkan sumber daya dari domain yang sama
+			// script-src: 'self' mengizinkan skrip dari domain yang sama
+			//               https://app.sandbox.midtrans.com mengizinkan skrip dari domain Midtrans
+			//               https://cdn.jsdelivr.net (untuk SweetAlert2)
+			//               'unsafe-inline' mungkin diperlukan untuk beberapa inline script (hati-hati)
+			//               'unsafe-eval' diperlukan karena Midtrans Snap menggunakan eval()
+			// connect-src: 'self' mengizinkan koneksi dari domain yang sama
+			//                https://app.sandbox.midtrans.com mengizinkan koneksi ke domain Midtrans
+			//                https://api.sandbox.midtrans.com mengizinkan koneksi ke API Midtrans
+			//                https://snap.midtrans.com (jika ada)
+			//                https://snap.i.b-id-ca-eks-01.gopay.sh (dari log Anda, tambahkan ini juga)
+			// frame-src: https://app.sandbox.midtrans.com mengizinkan iframe dari domain Midtrans
+			// img-src: 'self' data: https://app.sandbox.midtrans.com (gambar Midtrans)
+			// style-src: 'self' 'unsafe-inline'
+			// font-src: 'self' https://cdnjs.cloudflare.com (untuk Font Awesome)
+
+			csp := "default-src 'self';" +
+				"script-src 'self' https://app.sandbox.midtrans.com https://cdn.jsdelivr.net 'unsafe-inline' 'unsafe-eval';" +
+				"connect-src 'self' https://app.sandbox.midtrans.com https://api.sandbox.midtrans.com https://snap.midtrans.com https://snap.i.b-id-ca-eks-01.gopay.sh;" +
+				"frame-src https://app.sandbox.midtrans.com;" +
+				"img-src 'self' data: https://app.sandbox.midtrans.com" + extraImgSrc + ";" +
+				"style-src 'self' 'unsafe-inline' https://cdnjs.cloudflare.com;" +
+				"font-src 'self' https://cdnjs.cloudflare.com;" +
+				"object-src 'none'; " +
+				"base-uri 'self';"
+
+			w.Header().Set("Content-Security-Policy", csp)
+			next.ServeHTTP(w, r)
+		})
+	}
 }
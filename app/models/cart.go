@@ -9,13 +9,19 @@ import (
 type Cart struct {
 	ID              string          `gorm:"size:36;not null;uniqueIndex;primary_key"`
 	UserID          string          `gorm:"size:36;index"`
-	User            User            `gorm:"foreignKey:UserID"`
+	User            User            `gorm:"foreignKey:UserID;constraint:-"`
 	CartItems       []CartItem      `gorm:"foreignKey:CartID"`
 	BaseTotalPrice  decimal.Decimal `gorm:"type:decimal(16,2);"`
 	TaxAmount       decimal.Decimal `gorm:"type:decimal(16,2);"`
 	TaxPercent      decimal.Decimal `gorm:"type:decimal(10,2);"`
+	TaxIncluded     bool            `gorm:"not null;default:false"`
 	DiscountAmount  decimal.Decimal `gorm:"type:decimal(16,2);"`
 	DiscountPercent decimal.Decimal `gorm:"type:decimal(10,2);"`
+	VoucherID       string          `gorm:"size:36;index"`
+	VoucherCode     string          `gorm:"size:50"`
+	VoucherDiscount decimal.Decimal `gorm:"type:decimal(16,2);default:0.00"`
+	VoucherMessage  string          `gorm:"-"`
+	Notices         []CartNotice    `gorm:"-"`
 	GrandTotal      decimal.Decimal `gorm:"type:decimal(16,2);"`
 	TotalWeight     decimal.Decimal `gorm:"type:decimal(16,2);default:0.00"`
 	ShippingCost    decimal.Decimal `gorm:"type:decimal(16,2);"`
@@ -24,6 +30,23 @@ type Cart struct {
 	UpdatedAt       time.Time
 }
 
+// IsGuest mengecek apakah keranjang milik pengunjung yang belum login.
+func (c *Cart) IsGuest() bool {
+	return c.UserID == ""
+}
+
+// HasNotices mengecek apakah ada perubahan item yang belum dikonfirmasi pelanggan.
+func (c *Cart) HasNotices() bool {
+	return len(c.Notices) > 0
+}
+
+// ClearVoucher melepas voucher dari keranjang tanpa menghitung ulang total.
+func (c *Cart) ClearVoucher() {
+	c.VoucherID = ""
+	c.VoucherCode = ""
+	c.VoucherDiscount = decimal.Zero
+}
+
 func (c *Cart) CalculateTotals(defaultTaxPercent decimal.Decimal) {
 	c.BaseTotalPrice = decimal.Zero
 	totalWeightDecimal := decimal.Zero
@@ -33,9 +56,7 @@ func (c *Cart) CalculateTotals(defaultTaxPercent decimal.Decimal) {
 
 		c.BaseTotalPrice = c.BaseTotalPrice.Add(item.Subtotal)
 
-		if item.Product != nil {
-			totalWeightDecimal = totalWeightDecimal.Add(item.Product.Weight.Mul(decimal.NewFromInt(int64(item.Qty))))
-		}
+		totalWeightDecimal = totalWeightDecimal.Add(item.UnitWeight().Mul(decimal.NewFromInt(int64(item.Qty))))
 
 		c.TotalItems += item.Qty
 	}
@@ -51,7 +72,7 @@ func (c *Cart) CalculateTotals(defaultTaxPercent decimal.Decimal) {
 
 	c.DiscountAmount = cartLevelDiscountAmount
 
-	priceAfterCartDiscount := c.BaseTotalPrice.Sub(c.DiscountAmount)
+	priceAfterCartDiscount := c.BaseTotalPrice.Sub(c.DiscountAmount).Sub(c.VoucherDiscount)
 	if priceAfterCartDiscount.LessThan(decimal.Zero) {
 		priceAfterCartDiscount = decimal.Zero
 	}
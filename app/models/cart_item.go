@@ -9,17 +9,28 @@ import (
 )
 
 type CartItem struct {
-	ID              string   `gorm:"size:36;not null;uniqueIndex;primary_key" json:"id"`
-	Cart            *Cart    `gorm:"foreignKey:CartID"`
-	CartID          string   `gorm:"size:36;index"`
-	Product         *Product `gorm:"foreignKey:ProductID"`
-	ProductID       string   `gorm:"size:36;index"`
+	ID              string          `gorm:"size:36;not null;uniqueIndex;primary_key" json:"id"`
+	Cart            *Cart           `gorm:"foreignKey:CartID"`
+	CartID          string          `gorm:"size:36;index"`
+	Product         *Product        `gorm:"foreignKey:ProductID"`
+	ProductID       string          `gorm:"size:36;index"`
+	Variant         *ProductVariant `gorm:"foreignKey:VariantID;constraint:-"`
+	VariantID       string          `gorm:"size:36;index"`
 	Qty             int
 	Price           decimal.Decimal `gorm:"type:decimal(16,2);"`
 	DiscountPercent decimal.Decimal `gorm:"type:decimal(10,2);"`
 	DiscountAmount  decimal.Decimal `gorm:"type:decimal(16,2);"`
 	FinalPriceUnit  decimal.Decimal `gorm:"type:decimal(16,2);"`
 	Subtotal        decimal.Decimal `gorm:"type:decimal(16,2);"`
+	// Name adalah nama produk (dan varian) terakhir yang diketahui, dipakai jika produknya sudah dihapus.
+	// AcceptedPrice adalah harga satuan akhir yang terakhir dikonfirmasi pelanggan.
+	Name          string          `gorm:"size:255"`
+	AcceptedPrice decimal.Decimal `gorm:"type:decimal(16,2);not null;default:0"`
+	// Field berikut hasil hitungan TaxService dan tidak disimpan; VoucherEligible diisi saat voucher dievaluasi.
+	VoucherEligible bool            `gorm:"-"`
+	VoucherDiscount decimal.Decimal `gorm:"-"`
+	TaxPercent      decimal.Decimal `gorm:"-"`
+	TaxAmount       decimal.Decimal `gorm:"-"`
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 }
@@ -30,3 +41,31 @@ func (ci *CartItem) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+
+// IsUnavailable mengecek apakah produk atau varian item sudah dihapus sehingga tidak bisa dibeli lagi.
+func (ci *CartItem) IsUnavailable() bool {
+	if ci.Product == nil || ci.Product.ID == "" {
+		return true
+	}
+	return ci.VariantID != "" && (ci.Variant == nil || ci.Variant.ID == "")
+}
+
+func (ci *CartItem) UnitWeight() decimal.Decimal {
+	if ci.Variant != nil {
+		return ci.Variant.Weight
+	}
+	if ci.Product != nil {
+		return ci.Product.Weight
+	}
+	return decimal.Zero
+}
+
+func (ci *CartItem) AvailableStock() int {
+	if ci.Variant != nil {
+		return ci.Variant.Stock
+	}
+	if ci.Product != nil {
+		return ci.Product.Stock
+	}
+	return 0
+}
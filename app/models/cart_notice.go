@@ -0,0 +1,22 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+const (
+	CartNoticePriceChanged = "price_changed"
+	CartNoticeLowStock     = "low_stock"
+	CartNoticeUnavailable  = "unavailable"
+)
+
+// CartNotice adalah perubahan pada item keranjang sejak terakhir dikonfirmasi pelanggan: harga berubah,
+// stok kurang dari jumlah yang diminta, atau produk/varian sudah dihapus. Checkout ditahan sampai
+// pelanggan mengonfirmasi perubahan ini.
+type CartNotice struct {
+	Kind      string
+	ItemID    string
+	Name      string
+	OldPrice  decimal.Decimal
+	NewPrice  decimal.Decimal
+	Qty       int
+	Available int
+}
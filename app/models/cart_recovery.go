@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	CartRecoveryOpen      = "open"
+	CartRecoveryRecovered = "recovered"
+	CartRecoveryClosed    = "closed"
+)
+
+// CartRecovery mencatat satu kejadian keranjang ditinggalkan: isi dan nilai keranjang saat terdeteksi,
+// pengingat yang sudah dikirim, serta order yang akhirnya dibuat pelanggan. Keranjang yang diubah lagi
+// lalu ditinggalkan kembali dicatat sebagai kejadian baru dan kejadian lama ditutup.
+type CartRecovery struct {
+	ID              string             `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	CartID          string             `gorm:"size:36;not null;index"`
+	UserID          string             `gorm:"size:36;not null;index"`
+	User            *User              `gorm:"foreignKey:UserID"`
+	Email           string             `gorm:"size:100;not null"`
+	CartValue       decimal.Decimal    `gorm:"type:decimal(16,2);not null"`
+	TotalItems      int                `gorm:"not null"`
+	Items           []CartRecoveryItem `gorm:"foreignKey:CartRecoveryID"`
+	AbandonedAt     time.Time          `gorm:"not null;index"`
+	Status          string             `gorm:"size:20;not null;index"`
+	RemindersSent   int                `gorm:"not null;default:0"`
+	LastReminderAt  *time.Time
+	RestoredAt      *time.Time
+	RecoveredAt     *time.Time
+	OrderID         string          `gorm:"size:36;index"`
+	RecoveredAmount decimal.Decimal `gorm:"type:decimal(16,2);not null;default:0"`
+	CreatedAt       time.Time       `gorm:"index"`
+	UpdatedAt       time.Time
+}
+
+// CartRecoveryItem adalah salinan satu item keranjang saat ditinggalkan, dipakai untuk isi email dan
+// untuk memulihkan keranjang dari tautan email.
+type CartRecoveryItem struct {
+	ID             string          `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	CartRecoveryID string          `gorm:"size:36;not null;index"`
+	ProductID      string          `gorm:"size:36;not null"`
+	VariantID      string          `gorm:"size:36"`
+	Name           string          `gorm:"size:255;not null"`
+	Qty            int             `gorm:"not null"`
+	Subtotal       decimal.Decimal `gorm:"type:decimal(16,2);not null"`
+}
+
+func (r *CartRecovery) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return
+}
+
+func (i *CartRecoveryItem) BeforeCreate(tx *gorm.DB) (err error) {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return
+}
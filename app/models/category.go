@@ -7,13 +7,15 @@ import (
 )
 
 type Category struct {
-	ID        string `gorm:"size:36;not null;uniqueIndex;primary_key"`
-	Name      string `gorm:"size:100;not null;uniqueIndex"`
-	Slug      string `gorm:"size:100;not null;uniqueIndex"`
-	SectionID string `gorm:"size:36;index"`
-	Section   Section
-	Products  []Product `gorm:"many2many:product_categories;"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID         string `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	Name       string `gorm:"size:100;not null;uniqueIndex"`
+	Slug       string `gorm:"size:100;not null;uniqueIndex"`
+	SectionID  string `gorm:"size:36;index"`
+	Section    Section
+	TaxClassID string    `gorm:"size:36;index"`
+	TaxClass   *TaxClass `gorm:"foreignKey:TaxClassID;constraint:-"`
+	Products   []Product `gorm:"many2many:product_categories;"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
 }
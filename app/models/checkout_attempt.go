@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	CheckoutAttemptPending   = "pending"
+	CheckoutAttemptReady     = "ready"
+	CheckoutAttemptCancelled = "cancelled"
+)
+
+// CheckoutAttempt mencatat satu kali submit checkout berdasarkan idempotency key dari halaman checkout.
+// Order dibuat lebih dulu dengan status pending, lalu token pembayaran dicatat di sini setelah gateway
+// berhasil dipanggil. Submit ulang dengan key yang sama mengembalikan order dan URL pembayaran yang sama.
+type CheckoutAttempt struct {
+	ID              string `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	IdempotencyKey  string `gorm:"size:64;not null;uniqueIndex"`
+	UserID          string `gorm:"size:36;not null;index"`
+	OrderID         string `gorm:"size:36;not null;index"`
+	Status          string `gorm:"size:20;not null;index"`
+	RedirectURL     string `gorm:"type:text"`
+	GatewayAttempts int    `gorm:"not null;default:0"`
+	GatewayLockedAt *time.Time
+	LastError       string `gorm:"type:text"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (a *CheckoutAttempt) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return
+}
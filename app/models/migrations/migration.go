@@ -1,9 +1,12 @@
 package migrations
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -14,6 +17,9 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.Address{},
 		&models.Product{},
 		&models.ProductImage{},
+		&models.ProductOption{},
+		&models.ProductOptionValue{},
+		&models.ProductVariant{},
 		&models.Section{},
 		&models.Category{},
 		&models.Payment{},
@@ -44,6 +50,225 @@ func AutoMigrate(db *gorm.DB) error {
 		log.Printf("Error during OrderCustomer AutoMigrate: %v", err)
 		return err
 	}
+
+	err = db.AutoMigrate(&models.StockReservation{})
+	if err != nil {
+		log.Printf("Error during StockReservation AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.StockMovement{})
+	if err != nil {
+		log.Printf("Error during StockMovement AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.SearchQuery{})
+	if err != nil {
+		log.Printf("Error during SearchQuery AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.Review{}, &models.ReviewPhoto{})
+	if err != nil {
+		log.Printf("Error during Review AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.WishlistItem{})
+	if err != nil {
+		log.Printf("Error during WishlistItem AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.ShippingQuote{})
+	if err != nil {
+		log.Printf("Error during ShippingQuote AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.Voucher{}, &models.VoucherRedemption{})
+	if err != nil {
+		log.Printf("Error during Voucher AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.Promotion{})
+	if err != nil {
+		log.Printf("Error during Promotion AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.TaxClass{}, &models.Setting{})
+	if err != nil {
+		log.Printf("Error during TaxClass AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.CartRecovery{}, &models.CartRecoveryItem{})
+	if err != nil {
+		log.Printf("Error during CartRecovery AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.CheckoutAttempt{})
+	if err != nil {
+		log.Printf("Error during CheckoutAttempt AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.PaymentNotification{})
+	if err != nil {
+		log.Printf("Error during PaymentNotification AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.Refund{}, &models.RefundItem{})
+	if err != nil {
+		log.Printf("Error during Refund AutoMigrate: %v", err)
+		return err
+	}
+
+	err = db.AutoMigrate(&models.PaymentProof{})
+	if err != nil {
+		log.Printf("Error during PaymentProof AutoMigrate: %v", err)
+		return err
+	}
+
+	if err := dropCartUserForeignKey(db); err != nil {
+		log.Printf("Error dropping carts user foreign key: %v", err)
+		return err
+	}
+
+	if err := ensureFullTextIndex(db, "products", "ft_products_search", "name", "description", "sku"); err != nil {
+		log.Printf("Error creating products FULLTEXT index: %v", err)
+		return err
+	}
+
+	if err := backfillOpeningStockMovements(db); err != nil {
+		log.Printf("Error during opening stock movement backfill: %v", err)
+		return err
+	}
+
+	if err := seedDefaultTaxClasses(db); err != nil {
+		log.Printf("Error seeding default tax classes: %v", err)
+		return err
+	}
 	log.Println("✅ All models migrated successfully.")
 	return nil
 }
+
+// backfillOpeningStockMovements mencatat saldo awal untuk produk yang belum punya riwayat stok,
+// agar jumlah pergerakan sama dengan stok saat ledger pertama kali dipasang.
+func backfillOpeningStockMovements(db *gorm.DB) error {
+	var products []models.Product
+	err := db.Preload("Variants").
+		Where("NOT EXISTS (SELECT 1 FROM stock_movements sm WHERE sm.product_id = products.id)").
+		Find(&products).Error
+	if err != nil {
+		return err
+	}
+	if len(products) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		recorded := 0
+		for _, product := range products {
+			running := 0
+			var movements []models.StockMovement
+			for _, variant := range product.Variants {
+				if variant.Stock == 0 {
+					continue
+				}
+				running += variant.Stock
+				movements = append(movements, models.StockMovement{
+					ProductID:    product.ID,
+					VariantID:    variant.ID,
+					Delta:        variant.Stock,
+					ResultingQty: running,
+					VariantQty:   variant.Stock,
+					Reason:       models.StockMovementManual,
+					Note:         "Saldo awal",
+				})
+			}
+			if residual := product.Stock - running; residual != 0 {
+				movements = append(movements, models.StockMovement{
+					ProductID:    product.ID,
+					Delta:        residual,
+					ResultingQty: product.Stock,
+					Reason:       models.StockMovementManual,
+					Note:         "Saldo awal",
+				})
+			}
+			if len(movements) == 0 {
+				continue
+			}
+			if err := tx.Create(&movements).Error; err != nil {
+				return err
+			}
+			recorded++
+		}
+		if recorded > 0 {
+			log.Printf("✅ Opening stock movements recorded for %d product(s).", recorded)
+		}
+		return nil
+	})
+}
+
+// seedDefaultTaxClasses membuat kelas PPN standar 12% (default) dan kelas bebas PPN jika tabel kelas
+// pajak masih kosong, sehingga tarif yang sebelumnya tertanam di kode tetap berlaku.
+func seedDefaultTaxClasses(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.TaxClass{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	classes := []models.TaxClass{
+		{Code: models.TaxClassStandard, Name: "PPN Standar", Rate: decimal.NewFromInt(12), IsDefault: true},
+		{Code: models.TaxClassExempt, Name: "Bebas PPN (Barang Kebutuhan Pokok)", Rate: decimal.Zero},
+	}
+	if err := db.Create(&classes).Error; err != nil {
+		return err
+	}
+	log.Println("✅ Default tax classes created.")
+	return nil
+}
+
+// dropCartUserForeignKey menghapus foreign key carts.user_id yang dibuat AutoMigrate versi lama, karena
+// keranjang tamu disimpan dengan user_id kosong.
+func dropCartUserForeignKey(db *gorm.DB) error {
+	const constraintName = "fk_carts_user"
+	if !db.Migrator().HasConstraint(&models.Cart{}, constraintName) {
+		return nil
+	}
+	if err := db.Migrator().DropConstraint(&models.Cart{}, constraintName); err != nil {
+		return err
+	}
+	log.Printf("✅ Constraint %s dropped.", constraintName)
+	return nil
+}
+
+// ensureFullTextIndex membuat index FULLTEXT jika belum ada. AutoMigrate tidak mendukung FULLTEXT di MySQL.
+func ensureFullTextIndex(db *gorm.DB, table, indexName string, columns ...string) error {
+	var count int64
+	err := db.Raw(
+		"SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+		table, indexName,
+	).Scan(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if err := db.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD FULLTEXT INDEX `%s` (%s)", table, indexName, "`"+strings.Join(columns, "`, `")+"`")).Error; err != nil {
+		return err
+	}
+	log.Printf("✅ FULLTEXT index %s created on %s.", indexName, table)
+	return nil
+}
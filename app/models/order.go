@@ -16,6 +16,8 @@ const (
 	OrderStatusCancelled  = 5
 	OrderStatusRefunded   = 6
 	OrderStatusFailed     = 7
+	// OrderStatusPartiallyRefunded dipakai setelah sebagian nilai order dikembalikan lewat refund admin.
+	OrderStatusPartiallyRefunded = 8
 )
 
 type Order struct {
@@ -29,8 +31,12 @@ type Order struct {
 	BaseTotalPrice       decimal.Decimal `gorm:"type:decimal(16,2);"`
 	TaxAmount            decimal.Decimal `gorm:"type:decimal(16,2);"`
 	TaxPercent           decimal.Decimal `gorm:"type:decimal(10,2);"`
+	TaxIncluded          bool            `gorm:"not null;default:false"`
 	DiscountAmount       decimal.Decimal `gorm:"type:decimal(16,2);"`
 	DiscountPercent      decimal.Decimal `gorm:"type:decimal(10,2);"`
+	VoucherID            string          `gorm:"size:36;index"`
+	VoucherCode          string          `gorm:"size:50"`
+	VoucherDiscount      decimal.Decimal `gorm:"type:decimal(16,2);default:0.00"`
 	ShippingCost         decimal.Decimal `gorm:"type:decimal(16,2);"`
 	GrandTotal           decimal.Decimal `gorm:"type:decimal(16,2);"`
 	ShippingAddress      string          `gorm:"type:text"`
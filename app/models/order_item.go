@@ -16,9 +16,12 @@ type OrderItem struct {
 	Product         Product         `gorm:"foreignKey:ProductID;references:ID"`
 	ProductName     string          `gorm:"type:varchar(255);not null" json:"product_name"`
 	ProductSku      string          `gorm:"type:varchar(100)" json:"product_sku"`
+	VariantID       string          `gorm:"type:varchar(36);index" json:"variant_id"`
+	VariantName     string          `gorm:"type:varchar(255)" json:"variant_name"`
 	Qty             int             `gorm:"not null" json:"qty"`
 	Price           decimal.Decimal `gorm:"type:decimal(16,2);not null" json:"price"`
 	BaseTotal       decimal.Decimal `gorm:"type:decimal(16,2);not null" json:"base_total"`
+	VoucherDiscount decimal.Decimal `gorm:"type:decimal(16,2);not null;default:0" json:"voucher_discount"`
 	TaxAmount       decimal.Decimal `gorm:"type:decimal(16,2);not null" json:"tax_amount"`
 	TaxPercent      decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"tax_percent"`
 	DiscountAmount  decimal.Decimal `gorm:"type:decimal(16,2);not null" json:"discount_amount"`
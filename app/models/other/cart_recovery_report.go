@@ -0,0 +1,22 @@
+package other
+
+import "github.com/shopspring/decimal"
+
+// CartRecoveryReport merangkum keranjang yang ditinggalkan dalam satu periode. Keranjang dihitung
+// terpulihkan jika pemiliknya membuat order setelah keranjang ditinggalkan.
+type CartRecoveryReport struct {
+	AbandonedCarts int64
+	AbandonedValue decimal.Decimal
+	RemindedCarts  int64
+	RemindersSent  int64
+	RecoveredCarts int64
+	RecoveredValue decimal.Decimal
+}
+
+// RecoveryRate mengembalikan persentase keranjang yang terpulihkan.
+func (r CartRecoveryReport) RecoveryRate() decimal.Decimal {
+	if r.AbandonedCarts == 0 {
+		return decimal.Zero
+	}
+	return decimal.NewFromInt(r.RecoveredCarts).Mul(decimal.NewFromInt(100)).Div(decimal.NewFromInt(r.AbandonedCarts)).Round(2)
+}
@@ -0,0 +1,192 @@
+package other
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	ProductSortRelevance   = "relevance"
+	ProductSortNewest      = "newest"
+	ProductSortPriceAsc    = "price_asc"
+	ProductSortPriceDesc   = "price_desc"
+	ProductSortName        = "name"
+	ProductSortBestSelling = "best_selling"
+	ProductSortRating      = "rating"
+)
+
+// ProductRatingThresholds adalah pilihan filter "bintang N ke atas" di sidebar katalog.
+var ProductRatingThresholds = []int{4, 3, 2, 1}
+
+type ProductSortOption struct {
+	Value string
+	Label string
+}
+
+var productSortOptions = []ProductSortOption{
+	{ProductSortRelevance, "Paling Relevan"},
+	{ProductSortNewest, "Terbaru"},
+	{ProductSortBestSelling, "Terlaris"},
+	{ProductSortRating, "Rating Tertinggi"},
+	{ProductSortPriceAsc, "Harga Terendah"},
+	{ProductSortPriceDesc, "Harga Tertinggi"},
+	{ProductSortName, "Nama A-Z"},
+}
+
+// ProductFilter adalah state filter katalog. Semua field dibaca dari dan ditulis kembali ke query string
+// sehingga tautan hasil filter bisa dibagikan. MinPrice/MaxPrice 0 berarti tanpa batas.
+type ProductFilter struct {
+	Keyword       string
+	CategorySlugs []string
+	MinPrice      int64
+	MaxPrice      int64
+	InStock       bool
+	Discounted    bool
+	MinRating     int
+	Sort          string
+}
+
+// ProductFacets menyimpan jumlah produk per nilai filter. Setiap facet dihitung dengan semua filter lain
+// tetap aktif, tetapi tanpa filter facet itu sendiri. CategoryCounts dikunci dengan slug kategori.
+type ProductFacets struct {
+	CategoryCounts  map[string]int64
+	InStockCount    int64
+	DiscountedCount int64
+	// RatingCounts dikunci dengan nilai ProductRatingThresholds.
+	RatingCounts map[int]int64
+	MinPrice     int64
+	MaxPrice     int64
+}
+
+func ParseProductFilter(values url.Values) ProductFilter {
+	keyword := strings.TrimSpace(values.Get("search"))
+	if keyword == "" {
+		// tautan lama masih memakai ?q=
+		keyword = strings.TrimSpace(values.Get("q"))
+	}
+
+	filter := ProductFilter{
+		Keyword:    keyword,
+		MinPrice:   parsePrice(values.Get("min_price")),
+		MaxPrice:   parsePrice(values.Get("max_price")),
+		InStock:    values.Get("in_stock") == "1",
+		Discounted: values.Get("discounted") == "1",
+	}
+	if rating, err := strconv.Atoi(values.Get("min_rating")); err == nil && slices.Contains(ProductRatingThresholds, rating) {
+		filter.MinRating = rating
+	}
+	for _, slug := range values["category"] {
+		if slug = strings.TrimSpace(slug); slug != "" && !slices.Contains(filter.CategorySlugs, slug) {
+			filter.CategorySlugs = append(filter.CategorySlugs, slug)
+		}
+	}
+	if filter.MaxPrice > 0 && filter.MinPrice > filter.MaxPrice {
+		filter.MinPrice, filter.MaxPrice = filter.MaxPrice, filter.MinPrice
+	}
+
+	filter.Sort = values.Get("sort")
+	if !filter.validSort(filter.Sort) {
+		filter.Sort = filter.DefaultSort()
+	}
+	return filter
+}
+
+func parsePrice(value string) int64 {
+	price, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || price < 0 {
+		return 0
+	}
+	return price
+}
+
+func (f ProductFilter) DefaultSort() string {
+	if f.Keyword != "" {
+		return ProductSortRelevance
+	}
+	return ProductSortNewest
+}
+
+func (f ProductFilter) validSort(sort string) bool {
+	for _, option := range f.SortOptions() {
+		if option.Value == sort {
+			return true
+		}
+	}
+	return false
+}
+
+// SortOptions mengembalikan pilihan urutan; "Paling Relevan" hanya tersedia saat ada kata kunci.
+func (f ProductFilter) SortOptions() []ProductSortOption {
+	if f.Keyword != "" {
+		return productSortOptions
+	}
+	return productSortOptions[1:]
+}
+
+func (f ProductFilter) HasCategory(slug string) bool {
+	return slices.Contains(f.CategorySlugs, slug)
+}
+
+// IsFiltered bernilai true jika ada filter selain kata kunci dan urutan.
+func (f ProductFilter) IsFiltered() bool {
+	return len(f.CategorySlugs) > 0 || f.MinPrice > 0 || f.MaxPrice > 0 || f.InStock || f.Discounted || f.MinRating > 0
+}
+
+func (f ProductFilter) Values() url.Values {
+	values := url.Values{}
+	if f.Keyword != "" {
+		values.Set("search", f.Keyword)
+	}
+	for _, slug := range f.CategorySlugs {
+		values.Add("category", slug)
+	}
+	if f.MinPrice > 0 {
+		values.Set("min_price", strconv.FormatInt(f.MinPrice, 10))
+	}
+	if f.MaxPrice > 0 {
+		values.Set("max_price", strconv.FormatInt(f.MaxPrice, 10))
+	}
+	if f.InStock {
+		values.Set("in_stock", "1")
+	}
+	if f.Discounted {
+		values.Set("discounted", "1")
+	}
+	if f.MinRating > 0 {
+		values.Set("min_rating", strconv.Itoa(f.MinRating))
+	}
+	if f.Sort != "" && f.Sort != f.DefaultSort() {
+		values.Set("sort", f.Sort)
+	}
+	return values
+}
+
+func (f ProductFilter) URL() string {
+	if encoded := f.Values().Encode(); encoded != "" {
+		return "/products?" + encoded
+	}
+	return "/products"
+}
+
+func (f ProductFilter) PageURL(page int) string {
+	values := f.Values()
+	if page > 1 {
+		values.Set("page", strconv.Itoa(page))
+	}
+	if encoded := values.Encode(); encoded != "" {
+		return "/products?" + encoded
+	}
+	return "/products"
+}
+
+func (f ProductFilter) SortURL(sort string) string {
+	f.Sort = sort
+	return f.URL()
+}
+
+// ClearURL menghapus semua filter tetapi mempertahankan kata kunci pencarian.
+func (f ProductFilter) ClearURL() string {
+	return ProductFilter{Keyword: f.Keyword}.URL()
+}
@@ -0,0 +1,22 @@
+package other
+
+// RatingSummary merangkum ulasan yang sudah disetujui untuk satu produk.
+// Distribution dikunci dengan nilai bintang 1-5.
+type RatingSummary struct {
+	Average      float64
+	Count        int64
+	Distribution map[int]int64
+}
+
+// Stars mengembalikan jumlah bintang penuh (dibulatkan) untuk ditampilkan.
+func (s RatingSummary) Stars() int {
+	return int(s.Average + 0.5)
+}
+
+// Percent mengembalikan persentase ulasan dengan nilai bintang tertentu, untuk lebar bar distribusi.
+func (s RatingSummary) Percent(stars int) int64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Distribution[stars] * 100 / s.Count
+}
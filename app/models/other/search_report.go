@@ -0,0 +1,10 @@
+package other
+
+import "time"
+
+type SearchQueryStat struct {
+	Query          string
+	Searches       int64
+	AvgResults     float64
+	LastSearchedAt time.Time
+}
@@ -37,4 +37,5 @@ type BasePageData struct {
 	Order                   *models.Order
 	Orders                  []models.Order
 	Payment                 *models.Payment
+	ReviewedItems           map[string]bool
 }
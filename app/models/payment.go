@@ -8,6 +8,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// PaymentTypeManualTransfer adalah transfer manual ke rekening toko yang diverifikasi admin dari bukti transfer.
+const PaymentTypeManualTransfer = "manual_transfer"
+
 type Payment struct {
 	ID          string          `gorm:"size:36;not null;uniqueIndex;primary_key"`
 	Order       Order           `gorm:"foreignKey:OrderID"`
@@ -19,12 +22,17 @@ type Payment struct {
 	Token       string          `gorm:"size:100;index"`
 	Payload     string          `gorm:"type:text"`
 	PaymentType string          `gorm:"size:100"`
-	VaNumber    string          `gorm:"size:100"`
-	BillCode    string          `gorm:"size:100"`
-	BillKey     string          `gorm:"size:100"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	DeletedAt   gorm.DeletedAt
+	// Bank diisi untuk pembayaran bank transfer lewat Core API; kosong berarti pembayaran lewat Snap.
+	Bank      string `gorm:"size:20"`
+	VaNumber  string `gorm:"size:100"`
+	BillCode  string `gorm:"size:100"`
+	BillKey   string `gorm:"size:100"`
+	ExpiresAt *time.Time
+	// UniqueCode ditambahkan ke Amount pada transfer manual agar transfer masuk mudah dicocokkan dengan order.
+	UniqueCode int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt
 }
 
 func (p *Payment) BeforeCreate(tx *gorm.DB) (err error) {
@@ -33,3 +41,12 @@ func (p *Payment) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+
+// IsBankTransfer menandai pembayaran yang nomor VA atau bill key-nya ditampilkan di aplikasi.
+func (p *Payment) IsBankTransfer() bool {
+	return p.Bank != ""
+}
+
+func (p *Payment) IsManualTransfer() bool {
+	return p.PaymentType == PaymentTypeManualTransfer
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	PaymentNotificationReceived  = "received"
+	PaymentNotificationProcessed = "processed"
+	PaymentNotificationFailed    = "failed"
+	PaymentNotificationRejected  = "rejected"
+)
+
+// PaymentNotification menyimpan setiap webhook pembayaran apa adanya beserta hasil verifikasi signature
+// dan hasil pemrosesannya. DedupKey adalah hash body mentah, jadi pengiriman ulang dari gateway tercatat
+// sebagai Deliveries tambahan pada baris yang sama dan tidak diproses dua kali.
+type PaymentNotification struct {
+	ID                string `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	Provider          string `gorm:"size:20;not null"`
+	DedupKey          string `gorm:"size:64;not null;uniqueIndex"`
+	OrderCode         string `gorm:"size:255;index"`
+	TransactionID     string `gorm:"size:100"`
+	TransactionStatus string `gorm:"size:50"`
+	FraudStatus       string `gorm:"size:50"`
+	StatusCode        string `gorm:"size:10"`
+	GrossAmount       string `gorm:"size:50"`
+	RawBody           string `gorm:"type:text"`
+	Verified          bool   `gorm:"not null;default:false"`
+	VerifyError       string `gorm:"type:text"`
+	Outcome           string `gorm:"size:20;not null;index"`
+	OutcomeMessage    string `gorm:"type:text"`
+	Deliveries        int    `gorm:"not null;default:1"`
+	Replays           int    `gorm:"not null;default:0"`
+	ProcessedAt       *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func (n *PaymentNotification) BeforeCreate(tx *gorm.DB) (err error) {
+	if n.ID == "" {
+		n.ID = uuid.New().String()
+	}
+	return
+}
+
+func (n *PaymentNotification) OutcomeLabel() string {
+	switch n.Outcome {
+	case PaymentNotificationProcessed:
+		return "Diproses"
+	case PaymentNotificationFailed:
+		return "Gagal"
+	case PaymentNotificationRejected:
+		return "Ditolak"
+	default:
+		return "Diterima"
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	PaymentProofPending  = "pending"
+	PaymentProofApproved = "approved"
+	PaymentProofRejected = "rejected"
+)
+
+// PaymentProof adalah bukti transfer manual yang diunggah pembeli dan menunggu diverifikasi admin.
+// Bukti yang ditolak tetap disimpan; pembeli boleh mengunggah ulang selama order masih menunggu pembayaran.
+type PaymentProof struct {
+	ID           string   `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	OrderID      string   `gorm:"size:36;not null;index"`
+	Order        *Order   `gorm:"foreignKey:OrderID"`
+	PaymentID    string   `gorm:"size:36;not null;index"`
+	Payment      *Payment `gorm:"foreignKey:PaymentID"`
+	ImagePath    string   `gorm:"size:255;not null"`
+	SenderName   string   `gorm:"size:100"`
+	SenderBank   string   `gorm:"size:50"`
+	Status       string   `gorm:"size:20;not null;index"`
+	RejectReason string   `gorm:"type:text"`
+	ReviewerID   string   `gorm:"size:36"`
+	Reviewer     *User    `gorm:"foreignKey:ReviewerID;constraint:-"`
+	ReviewedAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (p *PaymentProof) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return
+}
+
+func (p *PaymentProof) StatusLabel() string {
+	switch p.Status {
+	case PaymentProofApproved:
+		return "Disetujui"
+	case PaymentProofRejected:
+		return "Ditolak"
+	default:
+		return "Menunggu Verifikasi"
+	}
+}
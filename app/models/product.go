@@ -8,20 +8,25 @@ import (
 )
 
 type Product struct {
-	ID              string          `gorm:"size:36;not null;uniqueIndex;primary_key"`
-	UserID          string          `gorm:"size:36;index"`
-	User            User            `gorm:"foreignKey:UserID"`
-	Name            string          `gorm:"size:255;not null"`
-	Slug            string          `gorm:"size:255;not null;uniqueIndex"`
-	Description     string          `gorm:"type:text"`
-	Sku             string          `gorm:"size:100;uniqueIndex"`
-	Price           decimal.Decimal `gorm:"type:decimal(16,2);not null"`
-	Stock           int             `gorm:"not null"`
-	Weight          decimal.Decimal `gorm:"type:decimal(10,2);not null"`
-	DiscountPercent decimal.Decimal `gorm:"type:decimal(10,2);default:0.00"`
-	DiscountAmount  decimal.Decimal `gorm:"type:decimal(16,2);default:0.00"`
-	Categories      []Category      `gorm:"many2many:product_categories;"`
-	ProductImages   []ProductImage  `gorm:"foreignKey:ProductID"`
+	ID              string           `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	UserID          string           `gorm:"size:36;index"`
+	User            User             `gorm:"foreignKey:UserID"`
+	Name            string           `gorm:"size:255;not null"`
+	Slug            string           `gorm:"size:255;not null;uniqueIndex"`
+	Description     string           `gorm:"type:text"`
+	Sku             string           `gorm:"size:100;uniqueIndex"`
+	Price           decimal.Decimal  `gorm:"type:decimal(16,2);not null"`
+	Stock           int              `gorm:"not null"`
+	Weight          decimal.Decimal  `gorm:"type:decimal(10,2);not null"`
+	DiscountPercent decimal.Decimal  `gorm:"type:decimal(10,2);default:0.00"`
+	DiscountAmount  decimal.Decimal  `gorm:"type:decimal(16,2);default:0.00"`
+	TaxClassID      string           `gorm:"size:36;index"`
+	TaxClass        *TaxClass        `gorm:"foreignKey:TaxClassID;constraint:-"`
+	Categories      []Category       `gorm:"many2many:product_categories;"`
+	ProductImages   []ProductImage   `gorm:"foreignKey:ProductID"`
+	Options         []ProductOption  `gorm:"foreignKey:ProductID"`
+	Variants        []ProductVariant `gorm:"foreignKey:ProductID"`
+	Promotions      []Promotion      `gorm:"-"`
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 	DeletedAt       gorm.DeletedAt `gorm:"index"`
@@ -33,3 +38,59 @@ type ProductCategory struct {
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
+
+func (p *Product) HasVariants() bool {
+	return len(p.Variants) > 0
+}
+
+func (p *Product) FindVariant(variantID string) *ProductVariant {
+	for i := range p.Variants {
+		if p.Variants[i].ID == variantID {
+			return &p.Variants[i]
+		}
+	}
+	return nil
+}
+
+// UnitPricing menghitung harga satu unit produk atau varian: diskon produk (persen didahulukan, lalu
+// nominal) diterapkan lebih dulu, kemudian promosi yang sudah dipasang repository di Promotions.
+func (p *Product) UnitPricing(variant *ProductVariant) (price, discountPerUnit, finalPriceUnit decimal.Decimal) {
+	price = p.Price
+	if variant != nil {
+		price = variant.Price
+	}
+
+	discountPerUnit = decimal.Zero
+	if p.DiscountPercent.GreaterThan(decimal.Zero) {
+		discountPerUnit = price.Mul(p.DiscountPercent.Div(decimal.NewFromInt(100)))
+	} else if p.DiscountAmount.GreaterThan(decimal.Zero) {
+		discountPerUnit = p.DiscountAmount
+	}
+	if discountPerUnit.GreaterThan(price) {
+		discountPerUnit = price
+	}
+	discountPerUnit = discountPerUnit.Add(PromotionDiscount(price.Sub(discountPerUnit), p.Promotions))
+
+	finalPriceUnit = price.Sub(discountPerUnit)
+	if finalPriceUnit.LessThan(decimal.Zero) {
+		finalPriceUnit = decimal.Zero
+	}
+	return price, discountPerUnit, finalPriceUnit
+}
+
+// FinalPrice adalah harga efektif produk tanpa varian. Receiver bernilai agar bisa dipanggil template
+// walaupun produk dikirim sebagai nilai di map data.
+func (p Product) FinalPrice() decimal.Decimal {
+	_, _, finalPriceUnit := p.UnitPricing(nil)
+	return finalPriceUnit
+}
+
+func (p Product) HasDiscount() bool {
+	return p.FinalPrice().LessThan(p.Price)
+}
+
+// VariantFinalPrice adalah harga efektif satu varian, dipakai untuk data-price di halaman produk.
+func (p Product) VariantFinalPrice(variantID string) decimal.Decimal {
+	_, _, finalPriceUnit := p.UnitPricing(p.FindVariant(variantID))
+	return finalPriceUnit
+}
@@ -6,6 +6,7 @@ type ProductImage struct {
 	ID         string `gorm:"size:36;not null;uniqueIndex;primary_key"`
 	Product    Product
 	ProductID  string `gorm:"size:36;index"`
+	VariantID  string `gorm:"size:36;index"`
 	Path       string `gorm:"type:text"`
 	ExtraLarge string `gorm:"type:text"`
 	Large      string `gorm:"type:text"`
@@ -14,3 +15,17 @@ type ProductImage struct {
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
+
+// FilePaths mengembalikan semua path file (master dan ukuran turunan) tanpa duplikat. Gambar lama
+// sebelum pipeline gambar memakai path yang sama untuk semua ukuran.
+func (pi *ProductImage) FilePaths() []string {
+	seen := make(map[string]bool, 5)
+	var paths []string
+	for _, path := range []string{pi.Path, pi.ExtraLarge, pi.Large, pi.Medium, pi.Small} {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type ProductOption struct {
+	ID        string               `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	ProductID string               `gorm:"size:36;index"`
+	Name      string               `gorm:"size:100;not null"`
+	Position  int                  `gorm:"default:0"`
+	Values    []ProductOptionValue `gorm:"foreignKey:OptionID"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ProductOptionValue struct {
+	ID        string `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	OptionID  string `gorm:"size:36;index"`
+	Value     string `gorm:"size:100;not null"`
+	Position  int    `gorm:"default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ProductVariant struct {
+	ID           string               `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	ProductID    string               `gorm:"size:36;index"`
+	Sku          string               `gorm:"size:100;uniqueIndex"`
+	Name         string               `gorm:"size:255;not null"`
+	Price        decimal.Decimal      `gorm:"type:decimal(16,2);not null"`
+	Stock        int                  `gorm:"not null"`
+	Weight       decimal.Decimal      `gorm:"type:decimal(10,2);not null"`
+	Position     int                  `gorm:"default:0"`
+	OptionValues []ProductOptionValue `gorm:"many2many:product_variant_option_values;"`
+	Images       []ProductImage       `gorm:"foreignKey:VariantID;constraint:-"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (o *ProductOption) BeforeCreate(tx *gorm.DB) (err error) {
+	if o.ID == "" {
+		o.ID = uuid.New().String()
+	}
+	return
+}
+
+func (ov *ProductOptionValue) BeforeCreate(tx *gorm.DB) (err error) {
+	if ov.ID == "" {
+		ov.ID = uuid.New().String()
+	}
+	return
+}
+
+func (v *ProductVariant) BeforeCreate(tx *gorm.DB) (err error) {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return
+}
+
+func (v *ProductVariant) OptionLabel() string {
+	if len(v.OptionValues) == 0 {
+		return v.Name
+	}
+	values := make([]string, 0, len(v.OptionValues))
+	for _, ov := range v.OptionValues {
+		values = append(values, ov.Value)
+	}
+	return strings.Join(values, " / ")
+}
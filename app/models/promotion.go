@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	PromotionScopeAll        = "all"
+	PromotionScopeCategories = "categories"
+	PromotionScopeProducts   = "products"
+
+	PromotionTypePercent = "percent"
+	PromotionTypeFixed   = "fixed"
+)
+
+// Promotion adalah potongan harga terjadwal yang dihitung saat produk dibaca, tanpa mengubah baris
+// produk. EndsAt nil berarti promosi berjalan sampai dinonaktifkan. Jika beberapa promosi berlaku
+// untuk satu produk, prioritas tertinggi selalu dipakai dan promosi lain hanya ditambahkan bila
+// semuanya Stackable.
+type Promotion struct {
+	ID         string          `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	Name       string          `gorm:"size:100;not null"`
+	Scope      string          `gorm:"size:20;not null"`
+	Type       string          `gorm:"size:20;not null"`
+	Value      decimal.Decimal `gorm:"type:decimal(16,2);not null"`
+	StartsAt   time.Time       `gorm:"not null;index"`
+	EndsAt     *time.Time      `gorm:"index"`
+	Priority   int             `gorm:"not null;default:0"`
+	Stackable  bool            `gorm:"not null;default:false"`
+	IsActive   bool            `gorm:"not null;default:true"`
+	Categories []Category      `gorm:"many2many:promotion_categories;"`
+	Products   []Product       `gorm:"many2many:promotion_products;"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (p *Promotion) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return
+}
+
+// IsRunningAt mengecek apakah promosi aktif dan berada dalam jadwalnya pada waktu t.
+func (p *Promotion) IsRunningAt(t time.Time) bool {
+	if !p.IsActive || t.Before(p.StartsAt) {
+		return false
+	}
+	return p.EndsAt == nil || t.Before(*p.EndsAt)
+}
+
+// AppliesTo mengecek apakah produk masuk cakupan promosi. categoryIDs adalah kategori produk tersebut.
+func (p *Promotion) AppliesTo(productID string, categoryIDs []string) bool {
+	switch p.Scope {
+	case PromotionScopeAll:
+		return true
+	case PromotionScopeProducts:
+		for _, product := range p.Products {
+			if product.ID == productID {
+				return true
+			}
+		}
+	case PromotionScopeCategories:
+		for _, category := range p.Categories {
+			for _, id := range categoryIDs {
+				if category.ID == id {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// DiscountOn menghitung potongan per unit untuk harga tertentu, tidak pernah melebihi harga itu sendiri.
+func (p *Promotion) DiscountOn(price decimal.Decimal) decimal.Decimal {
+	if !price.IsPositive() {
+		return decimal.Zero
+	}
+	discount := p.Value
+	if p.Type == PromotionTypePercent {
+		discount = price.Mul(p.Value).Div(decimal.NewFromInt(100)).Round(2)
+	}
+	if discount.GreaterThan(price) {
+		discount = price
+	}
+	if discount.IsNegative() {
+		return decimal.Zero
+	}
+	return discount
+}
+
+// PromotionDiscount menjumlahkan potongan dari promosi yang sudah diurutkan berdasarkan prioritas.
+// Promosi pertama selalu dipakai; jika promosi itu Stackable, promosi Stackable berikutnya ikut dihitung
+// dari sisa harga secara berurutan dan promosi yang tidak Stackable dilewati.
+func PromotionDiscount(price decimal.Decimal, promotions []Promotion) decimal.Decimal {
+	total := decimal.Zero
+	remaining := price
+	for i := range promotions {
+		if i > 0 && (!promotions[0].Stackable || !promotions[i].Stackable) {
+			continue
+		}
+		discount := promotions[i].DiscountOn(remaining)
+		total = total.Add(discount)
+		remaining = remaining.Sub(discount)
+	}
+	return total
+}
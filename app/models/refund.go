@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// Refund mencatat satu pengembalian dana yang dimulai admin. Baris dibuat berstatus pending sebelum
+// gateway dipanggil, jadi RefundKey yang sama dipakai ulang jika panggilan gateway perlu diulang.
+type Refund struct {
+	ID            string          `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	OrderID       string          `gorm:"size:36;not null;index"`
+	RefundKey     string          `gorm:"size:64;not null;uniqueIndex"`
+	Amount        decimal.Decimal `gorm:"type:decimal(16,2);not null"`
+	Reason        string          `gorm:"type:text;not null"`
+	Restock       bool            `gorm:"not null;default:false"`
+	Status        string          `gorm:"size:20;not null;index"`
+	GatewayStatus string          `gorm:"size:50"`
+	FailureReason string          `gorm:"type:text"`
+	ActorID       string          `gorm:"size:36;index"`
+	Actor         *User           `gorm:"foreignKey:ActorID"`
+	Items         []RefundItem    `gorm:"foreignKey:RefundID"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// RefundItem adalah jumlah satu item order yang ikut dikembalikan pada sebuah Refund.
+type RefundItem struct {
+	ID          string          `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	RefundID    string          `gorm:"size:36;not null;index"`
+	OrderItemID string          `gorm:"size:255;not null;index"`
+	ProductID   string          `gorm:"size:255;not null"`
+	VariantID   string          `gorm:"size:36"`
+	Name        string          `gorm:"size:255;not null"`
+	Qty         int             `gorm:"not null"`
+	Amount      decimal.Decimal `gorm:"type:decimal(16,2);not null"`
+}
+
+func (r *Refund) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return
+}
+
+func (r *Refund) StatusLabel() string {
+	switch r.Status {
+	case RefundStatusSucceeded:
+		return "Berhasil"
+	case RefundStatusFailed:
+		return "Gagal"
+	default:
+		return "Diproses"
+	}
+}
+
+func (i *RefundItem) BeforeCreate(tx *gorm.DB) (err error) {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusHidden   = "hidden"
+)
+
+// Review hanya bisa dibuat dari OrderItem milik order yang sudah selesai, satu ulasan per item.
+// Ulasan baru menunggu moderasi dan baru tampil di halaman produk setelah disetujui admin.
+type Review struct {
+	ID          string        `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	ProductID   string        `gorm:"size:36;index;not null"`
+	Product     Product       `gorm:"foreignKey:ProductID"`
+	UserID      string        `gorm:"size:36;index;not null"`
+	User        User          `gorm:"foreignKey:UserID"`
+	OrderID     string        `gorm:"size:36;index;not null"`
+	OrderItemID string        `gorm:"size:255;uniqueIndex;not null"`
+	VariantName string        `gorm:"size:255"`
+	Rating      int           `gorm:"not null"`
+	Title       string        `gorm:"size:255"`
+	Body        string        `gorm:"type:text"`
+	Status      string        `gorm:"size:20;index;not null;default:pending"`
+	AdminReply  string        `gorm:"type:text"`
+	RepliedAt   *time.Time    `gorm:"default:null"`
+	Photos      []ReviewPhoto `gorm:"foreignKey:ReviewID"`
+	CreatedAt   time.Time     `gorm:"index"`
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (r *Review) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return
+}
+
+func (r *Review) StatusLabel() string {
+	switch r.Status {
+	case ReviewStatusApproved:
+		return "Ditampilkan"
+	case ReviewStatusHidden:
+		return "Disembunyikan"
+	default:
+		return "Menunggu Moderasi"
+	}
+}
+
+// ReviewerName menyamarkan nama belakang pembeli, misalnya "Budi S.".
+func (r *Review) ReviewerName() string {
+	if r.User.FirstName == "" {
+		return "Pembeli"
+	}
+	if r.User.LastName == "" {
+		return r.User.FirstName
+	}
+	return r.User.FirstName + " " + string([]rune(r.User.LastName)[:1]) + "."
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ReviewPhoto struct {
+	ID        string `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	ReviewID  string `gorm:"size:36;index;not null"`
+	Path      string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+func (p *ReviewPhoto) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return
+}
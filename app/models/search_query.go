@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SearchQuery struct {
+	ID             string `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	Query          string `gorm:"size:255;index;not null"`
+	CorrectedQuery string `gorm:"size:255"`
+	ResultCount    int64  `gorm:"index"`
+	UsedFallback   bool
+	UserID         string    `gorm:"size:36;index"`
+	CreatedAt      time.Time `gorm:"index"`
+}
+
+func (s *SearchQuery) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return
+}
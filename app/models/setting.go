@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+const (
+	SettingPricesIncludeTax = "tax.prices_include_tax"
+)
+
+// Setting menyimpan pengaturan toko yang bisa diubah admin tanpa deploy ulang.
+type Setting struct {
+	Key       string `gorm:"size:100;not null;primary_key"`
+	Value     string `gorm:"type:text"`
+	UpdatedAt time.Time
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// ShippingQuote adalah satu opsi ongkir hasil hitungan Komerce yang disimpan di server. Checkout hanya
+// menerima ID quote, sehingga biaya kirim tidak pernah diambil dari input browser.
+type ShippingQuote struct {
+	ID            string          `gorm:"size:36;not null;uniqueIndex;primary_key" json:"id"`
+	CartID        string          `gorm:"size:36;not null;index" json:"-"`
+	AddressID     string          `gorm:"size:36;not null" json:"-"`
+	OriginID      int             `gorm:"not null" json:"-"`
+	DestinationID string          `gorm:"type:varchar(255);not null" json:"-"`
+	Weight        int             `gorm:"not null" json:"weight"`
+	Courier       string          `gorm:"size:50;not null" json:"courier"`
+	Name          string          `gorm:"size:255" json:"name"`
+	Code          string          `gorm:"size:50;not null" json:"code"`
+	Service       string          `gorm:"size:100;not null" json:"service"`
+	Description   string          `gorm:"size:255" json:"description"`
+	Cost          decimal.Decimal `gorm:"type:decimal(16,2);not null" json:"cost"`
+	Etd           string          `gorm:"size:50" json:"etd"`
+	ExpiresAt     time.Time       `gorm:"index" json:"expires_at"`
+	CreatedAt     time.Time       `json:"-"`
+}
+
+func (q *ShippingQuote) BeforeCreate(tx *gorm.DB) (err error) {
+	if q.ID == "" {
+		q.ID = uuid.New().String()
+	}
+	return
+}
+
+// ServiceName adalah label layanan yang disimpan di order, sama dengan yang tampil di halaman keranjang.
+func (q *ShippingQuote) ServiceName() string {
+	return fmt.Sprintf("%s - %s (%s)", q.Name, q.Service, q.Description)
+}
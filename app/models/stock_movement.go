@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	StockMovementSale   = "sale"
+	StockMovementRefund = "refund"
+	StockMovementManual = "manual"
+	StockMovementImport = "import"
+)
+
+// StockMovement mencatat setiap perubahan stok. ResultingQty adalah stok produk setelah perubahan,
+// VariantQty stok varian setelah perubahan (hanya untuk pergerakan varian).
+type StockMovement struct {
+	ID           string `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	ProductID    string `gorm:"size:36;index"`
+	VariantID    string `gorm:"size:36;index"`
+	Delta        int    `gorm:"not null"`
+	ResultingQty int    `gorm:"not null"`
+	VariantQty   int
+	Reason       string    `gorm:"size:20;index;not null"`
+	Reference    string    `gorm:"size:255;index"`
+	Note         string    `gorm:"size:255"`
+	CreatedAt    time.Time `gorm:"index"`
+}
+
+func (m *StockMovement) BeforeCreate(tx *gorm.DB) (err error) {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return
+}
+
+func (m *StockMovement) ReasonLabel() string {
+	switch m.Reason {
+	case StockMovementSale:
+		return "Penjualan"
+	case StockMovementRefund:
+		return "Pengembalian"
+	case StockMovementManual:
+		return "Penyesuaian Manual"
+	case StockMovementImport:
+		return "Impor"
+	default:
+		return m.Reason
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	StockReservationActive    = "active"
+	StockReservationReleased  = "released"
+	StockReservationConverted = "converted"
+)
+
+type StockReservation struct {
+	ID         string    `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	OrderID    string    `gorm:"size:36;index"`
+	ProductID  string    `gorm:"size:36;index"`
+	VariantID  string    `gorm:"size:36;index"`
+	Qty        int       `gorm:"not null"`
+	Status     string    `gorm:"size:20;index;not null"`
+	ExpiresAt  time.Time `gorm:"index"`
+	ReleasedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (s *StockReservation) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return
+}
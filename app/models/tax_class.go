@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	TaxClassStandard = "standard"
+	TaxClassExempt   = "exempt"
+)
+
+// TaxClass adalah tarif pajak yang dipasang ke produk atau kategori. Produk tanpa kelas pajak mengikuti
+// kelas kategorinya, lalu kelas IsDefault.
+type TaxClass struct {
+	ID        string          `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	Code      string          `gorm:"size:50;not null;uniqueIndex"`
+	Name      string          `gorm:"size:100;not null"`
+	Rate      decimal.Decimal `gorm:"type:decimal(10,2);not null;default:0.00"`
+	IsDefault bool            `gorm:"not null;default:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (t *TaxClass) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return
+}
+
+// TaxOn menghitung pajak untuk nominal tertentu, dibulatkan ke 2 desimal. Jika inclusive, nominal sudah
+// termasuk pajak sehingga pajaknya diambil dari dalam nominal tersebut.
+func (t *TaxClass) TaxOn(amount decimal.Decimal, inclusive bool) decimal.Decimal {
+	if !amount.IsPositive() || !t.Rate.IsPositive() {
+		return decimal.Zero
+	}
+	hundred := decimal.NewFromInt(100)
+	if inclusive {
+		return amount.Mul(t.Rate).Div(hundred.Add(t.Rate)).Round(2)
+	}
+	return amount.Mul(t.Rate).Div(hundred).Round(2)
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	VoucherTypePercent = "percent"
+	VoucherTypeFixed   = "fixed"
+)
+
+// Voucher adalah kode potongan yang dimasukkan pelanggan di keranjang. Nilai 0 pada MaxDiscount,
+// UsageLimit, dan PerUserLimit berarti tanpa batas. Jika Categories atau Products diisi, potongan
+// hanya dihitung dari item yang cocok.
+type Voucher struct {
+	ID           string          `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	Code         string          `gorm:"size:50;not null;uniqueIndex"`
+	Description  string          `gorm:"size:255"`
+	Type         string          `gorm:"size:20;not null"`
+	Value        decimal.Decimal `gorm:"type:decimal(16,2);not null"`
+	MinSpend     decimal.Decimal `gorm:"type:decimal(16,2);not null;default:0"`
+	MaxDiscount  decimal.Decimal `gorm:"type:decimal(16,2);not null;default:0"`
+	StartsAt     *time.Time
+	EndsAt       *time.Time
+	UsageLimit   int        `gorm:"not null;default:0"`
+	PerUserLimit int        `gorm:"not null;default:0"`
+	IsActive     bool       `gorm:"not null;default:true"`
+	Categories   []Category `gorm:"many2many:voucher_categories;"`
+	Products     []Product  `gorm:"many2many:voucher_products;"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (v *Voucher) BeforeCreate(tx *gorm.DB) (err error) {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return
+}
+
+func (v *Voucher) IsRestricted() bool {
+	return len(v.Categories) > 0 || len(v.Products) > 0
+}
+
+// AppliesTo mengecek apakah produk termasuk dalam batasan voucher. categoryIDs adalah kategori produk
+// tersebut.
+func (v *Voucher) AppliesTo(productID string, categoryIDs []string) bool {
+	if !v.IsRestricted() {
+		return true
+	}
+	for _, product := range v.Products {
+		if product.ID == productID {
+			return true
+		}
+	}
+	for _, category := range v.Categories {
+		for _, id := range categoryIDs {
+			if category.ID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DiscountFor menghitung potongan untuk subtotal item yang memenuhi syarat. Potongan tidak pernah
+// melebihi subtotal tersebut maupun MaxDiscount.
+func (v *Voucher) DiscountFor(eligibleSubtotal decimal.Decimal) decimal.Decimal {
+	if !eligibleSubtotal.IsPositive() {
+		return decimal.Zero
+	}
+
+	discount := v.Value
+	if v.Type == VoucherTypePercent {
+		discount = eligibleSubtotal.Mul(v.Value).Div(decimal.NewFromInt(100)).Round(2)
+	}
+	if v.MaxDiscount.IsPositive() && discount.GreaterThan(v.MaxDiscount) {
+		discount = v.MaxDiscount
+	}
+	if discount.GreaterThan(eligibleSubtotal) {
+		discount = eligibleSubtotal
+	}
+	if discount.IsNegative() {
+		return decimal.Zero
+	}
+	return discount
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	VoucherRedemptionActive   = "active"
+	VoucherRedemptionReleased = "released"
+)
+
+// VoucherRedemption mencatat pemakaian voucher oleh satu order. Hanya redemption aktif yang dihitung
+// terhadap batas pemakaian; redemption dilepas saat order dibatalkan atau kedaluwarsa.
+type VoucherRedemption struct {
+	ID             string          `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	VoucherID      string          `gorm:"size:36;not null;index"`
+	Voucher        *Voucher        `gorm:"foreignKey:VoucherID"`
+	UserID         string          `gorm:"size:36;not null;index"`
+	User           *User           `gorm:"foreignKey:UserID"`
+	OrderID        string          `gorm:"size:36;not null;uniqueIndex"`
+	Order          *Order          `gorm:"foreignKey:OrderID"`
+	Code           string          `gorm:"size:50;not null"`
+	DiscountAmount decimal.Decimal `gorm:"type:decimal(16,2);not null"`
+	Status         string          `gorm:"size:20;not null;index"`
+	ReleasedAt     *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (r *VoucherRedemption) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return
+}
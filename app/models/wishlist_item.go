@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WishlistItem menyimpan produk yang disimpan user. Kolom Notify* adalah opt-in notifikasi email.
+type WishlistItem struct {
+	ID                string   `gorm:"size:36;not null;uniqueIndex;primary_key"`
+	UserID            string   `gorm:"size:36;not null;uniqueIndex:idx_wishlist_user_product"`
+	User              User     `gorm:"foreignKey:UserID"`
+	ProductID         string   `gorm:"size:36;not null;uniqueIndex:idx_wishlist_user_product;index"`
+	Product           *Product `gorm:"foreignKey:ProductID"`
+	NotifyBackInStock bool     `gorm:"not null;default:false"`
+	NotifyPriceDrop   bool     `gorm:"not null;default:false"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func (w *WishlistItem) BeforeCreate(tx *gorm.DB) (err error) {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	return
+}
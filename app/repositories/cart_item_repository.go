@@ -14,14 +14,15 @@ type CartItemRepository struct {
 }
 
 type CartItemRepositoryImpl interface {
-	Add(ctx context.Context, item *models.CartItem) error
-	Update(ctx context.Context, item *models.CartItem) error
+	Add(ctx context.Context, tx *gorm.DB, item *models.CartItem) error
+	Update(ctx context.Context, tx *gorm.DB, item *models.CartItem) error
 	Delete(ctx context.Context, cartItemID string) error
 	GetByID(ctx context.Context, id string) (*models.CartItem, error)
 	GetByCartID(ctx context.Context, cartID string) ([]models.CartItem, error)
 	GetCartAndProduct(ctx context.Context, cartID, productID string) (*models.CartItem, error)
 	ClearCartItems(ctx context.Context, tx *gorm.DB, cartID string) error
 	GetByCartIDAndProductID(ctx context.Context, cartID, productID string) (*models.CartItem, error)
+	GetByCartIDProductAndVariant(ctx context.Context, tx *gorm.DB, cartID, productID, variantID string) (*models.CartItem, error)
 	DeleteAllItemsByCartID(ctx context.Context, tx *gorm.DB, cartID string) error
 }
 
@@ -29,12 +30,12 @@ func NewCartItemRepository(db *gorm.DB) CartItemRepositoryImpl {
 	return &CartItemRepository{db}
 }
 
-func (r *CartItemRepository) Add(ctx context.Context, item *models.CartItem) error {
-	return r.DB.WithContext(ctx).Create(item).Error
+func (r *CartItemRepository) Add(ctx context.Context, tx *gorm.DB, item *models.CartItem) error {
+	return r.dbOrTx(tx).WithContext(ctx).Create(item).Error
 }
 
-func (r *CartItemRepository) Update(ctx context.Context, item *models.CartItem) error {
-	return r.DB.WithContext(ctx).Save(item).Error
+func (r *CartItemRepository) Update(ctx context.Context, tx *gorm.DB, item *models.CartItem) error {
+	return r.dbOrTx(tx).WithContext(ctx).Save(item).Error
 }
 
 func (r *CartItemRepository) Delete(ctx context.Context, cartItemID string) error {
@@ -85,13 +86,19 @@ func (r *CartItemRepository) GetByCartIDAndProductID(ctx context.Context, cartID
 	return &cartItem, nil
 }
 
-func (r *CartItemRepository) DeleteAllItemsByCartID(ctx context.Context, tx *gorm.DB, cartID string) error {
-	dbInstance := r.DB
-	if tx != nil {
-		dbInstance = tx
+func (r *CartItemRepository) GetByCartIDProductAndVariant(ctx context.Context, tx *gorm.DB, cartID, productID, variantID string) (*models.CartItem, error) {
+	var cartItem models.CartItem
+	if err := r.dbOrTx(tx).WithContext(ctx).Where("cart_id = ? AND product_id = ? AND variant_id = ?", cartID, productID, variantID).First(&cartItem).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get cart item by cart ID, product ID and variant ID: %w", err)
 	}
+	return &cartItem, nil
+}
 
-	result := dbInstance.WithContext(ctx).Where("cart_id = ?", cartID).Delete(&models.CartItem{})
+func (r *CartItemRepository) DeleteAllItemsByCartID(ctx context.Context, tx *gorm.DB, cartID string) error {
+	result := r.dbOrTx(tx).WithContext(ctx).Where("cart_id = ?", cartID).Delete(&models.CartItem{})
 	if result.Error != nil {
 		log.Printf("ERROR: Failed to delete cart items for CartID %s: %v", cartID, result.Error)
 		return fmt.Errorf("failed to delete cart items: %w", result.Error)
@@ -99,3 +106,11 @@ func (r *CartItemRepository) DeleteAllItemsByCartID(ctx context.Context, tx *gor
 
 	return nil
 }
+
+// dbOrTx memakai tx jika diberikan, selain itu koneksi repository.
+func (r *CartItemRepository) dbOrTx(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.DB
+}
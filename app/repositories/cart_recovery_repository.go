@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/models/other"
+	"gorm.io/gorm"
+)
+
+type CartRecoveryRepository interface {
+	FindAbandonedCarts(ctx context.Context, updatedAfter, updatedBefore time.Time, maxReminders, limit int) ([]models.Cart, error)
+	Create(ctx context.Context, recovery *models.CartRecovery) error
+	Update(ctx context.Context, recovery *models.CartRecovery) error
+	FindByID(ctx context.Context, id string) (*models.CartRecovery, error)
+	GetLatestByCartID(ctx context.Context, cartID string) (*models.CartRecovery, error)
+	GetOpen(ctx context.Context) ([]models.CartRecovery, error)
+	FindFirstPaidOrderSince(ctx context.Context, userID string, since time.Time) (*models.Order, error)
+	GetReport(ctx context.Context, since time.Time) (other.CartRecoveryReport, error)
+	GetRecent(ctx context.Context, since time.Time, limit int) ([]models.CartRecovery, error)
+}
+
+type cartRecoveryRepository struct {
+	db *gorm.DB
+}
+
+func NewCartRecoveryRepository(db *gorm.DB) CartRecoveryRepository {
+	return &cartRecoveryRepository{db: db}
+}
+
+// FindAbandonedCarts mengambil keranjang user yang masih berisi item, terakhir diubah di antara
+// updatedAfter dan updatedBefore, dan pemiliknya belum membuat order sejak keranjang terakhir diubah.
+// Keranjang yang kejadiannya untuk updated_at yang sama sudah selesai (ditutup, pulih, pengingatnya sudah
+// maxReminders, atau tanpa email) dilewati sebelum LIMIT agar tidak menghabiskan jatah batch setiap kali
+// worker berjalan.
+func (r *cartRecoveryRepository) FindAbandonedCarts(ctx context.Context, updatedAfter, updatedBefore time.Time, maxReminders, limit int) ([]models.Cart, error) {
+	var carts []models.Cart
+	err := r.db.WithContext(ctx).
+		Preload("User").
+		Preload("CartItems.Product").
+		Preload("CartItems.Variant").
+		Where("carts.user_id <> ?", "").
+		Where("carts.updated_at > ? AND carts.updated_at < ?", updatedAfter, updatedBefore).
+		Where("EXISTS (SELECT 1 FROM cart_items ci WHERE ci.cart_id = carts.id)").
+		Where("NOT EXISTS (SELECT 1 FROM orders o WHERE o.user_id = carts.user_id AND o.created_at >= carts.updated_at)").
+		Where("NOT EXISTS (SELECT 1 FROM cart_recoveries cr WHERE cr.cart_id = carts.id AND cr.abandoned_at = carts.updated_at AND (cr.status <> ? OR cr.reminders_sent >= ? OR cr.email = ''))",
+			models.CartRecoveryOpen, maxReminders).
+		Order("carts.updated_at ASC").
+		Limit(limit).
+		Find(&carts).Error
+	if err != nil {
+		log.Printf("CartRecoveryRepository.FindAbandonedCarts: Error finding abandoned carts: %v", err)
+		return nil, fmt.Errorf("gagal mencari keranjang yang ditinggalkan: %w", err)
+	}
+	return carts, nil
+}
+
+func (r *cartRecoveryRepository) Create(ctx context.Context, recovery *models.CartRecovery) error {
+	if err := r.db.WithContext(ctx).Omit("User").Create(recovery).Error; err != nil {
+		log.Printf("CartRecoveryRepository.Create: Error creating recovery for cart %s: %v", recovery.CartID, err)
+		return fmt.Errorf("gagal menyimpan keranjang yang ditinggalkan: %w", err)
+	}
+	return nil
+}
+
+func (r *cartRecoveryRepository) Update(ctx context.Context, recovery *models.CartRecovery) error {
+	if err := r.db.WithContext(ctx).Omit("User", "Items").Save(recovery).Error; err != nil {
+		log.Printf("CartRecoveryRepository.Update: Error updating recovery %s: %v", recovery.ID, err)
+		return fmt.Errorf("gagal memperbarui keranjang yang ditinggalkan: %w", err)
+	}
+	return nil
+}
+
+func (r *cartRecoveryRepository) FindByID(ctx context.Context, id string) (*models.CartRecovery, error) {
+	var recovery models.CartRecovery
+	err := r.db.WithContext(ctx).Preload("Items").First(&recovery, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil keranjang yang ditinggalkan: %w", err)
+	}
+	return &recovery, nil
+}
+
+func (r *cartRecoveryRepository) GetLatestByCartID(ctx context.Context, cartID string) (*models.CartRecovery, error) {
+	var recovery models.CartRecovery
+	err := r.db.WithContext(ctx).Preload("Items").Where("cart_id = ?", cartID).Order("abandoned_at DESC").First(&recovery).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil keranjang yang ditinggalkan: %w", err)
+	}
+	return &recovery, nil
+}
+
+func (r *cartRecoveryRepository) GetOpen(ctx context.Context) ([]models.CartRecovery, error) {
+	var recoveries []models.CartRecovery
+	if err := r.db.WithContext(ctx).Where("status = ?", models.CartRecoveryOpen).Find(&recoveries).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil keranjang yang ditinggalkan: %w", err)
+	}
+	return recoveries, nil
+}
+
+// FindFirstPaidOrderSince mengambil order pertama user yang dibuat sejak waktu tertentu dan sudah dibayar.
+// Order yang masih menunggu pembayaran atau dibatalkan tidak dihitung sebagai keranjang yang pulih.
+func (r *cartRecoveryRepository) FindFirstPaidOrderSince(ctx context.Context, userID string, since time.Time) (*models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND created_at >= ? AND status IN ?", userID, since, soldOrderStatuses).
+		Order("created_at ASC").
+		First(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil order user: %w", err)
+	}
+	return &order, nil
+}
+
+// GetReport merangkum kejadian keranjang ditinggalkan yang terdeteksi sejak waktu tertentu.
+func (r *cartRecoveryRepository) GetReport(ctx context.Context, since time.Time) (other.CartRecoveryReport, error) {
+	var report other.CartRecoveryReport
+	err := r.db.WithContext(ctx).Model(&models.CartRecovery{}).
+		Select(`COUNT(*) AS abandoned_carts,
+			COALESCE(SUM(cart_value), 0) AS abandoned_value,
+			COALESCE(SUM(CASE WHEN reminders_sent > 0 THEN 1 ELSE 0 END), 0) AS reminded_carts,
+			COALESCE(SUM(reminders_sent), 0) AS reminders_sent,
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) AS recovered_carts,
+			COALESCE(SUM(CASE WHEN status = ? THEN recovered_amount ELSE 0 END), 0) AS recovered_value`,
+			models.CartRecoveryRecovered, models.CartRecoveryRecovered).
+		Where("abandoned_at >= ?", since).
+		Scan(&report).Error
+	if err != nil {
+		log.Printf("CartRecoveryRepository.GetReport: Error aggregating cart recoveries: %v", err)
+		return report, fmt.Errorf("gagal mengambil laporan keranjang yang ditinggalkan: %w", err)
+	}
+	return report, nil
+}
+
+func (r *cartRecoveryRepository) GetRecent(ctx context.Context, since time.Time, limit int) ([]models.CartRecovery, error) {
+	var recoveries []models.CartRecovery
+	err := r.db.WithContext(ctx).
+		Preload("User").
+		Where("abandoned_at >= ?", since).
+		Order("abandoned_at DESC").
+		Limit(limit).
+		Find(&recoveries).Error
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil daftar keranjang yang ditinggalkan: %w", err)
+	}
+	return recoveries, nil
+}
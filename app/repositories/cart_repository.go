@@ -8,10 +8,10 @@ import (
 	"time"
 
 	"github.com/Rakhulsr/go-ecommerce/app/models"
-	"github.com/Rakhulsr/go-ecommerce/app/utils/calc"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CartRepositoryImpl interface {
@@ -19,7 +19,8 @@ type CartRepositoryImpl interface {
 	GetByID(ctx context.Context, id string) (*models.Cart, error)
 	GetOrCreateCartByUserID(ctx context.Context, cartID, userID string) (*models.Cart, error)
 	GetCartByUserID(ctx context.Context, userID string) (*models.Cart, error)
-	UpdateCartSummary(ctx context.Context, cartID string) error
+	GetGuestCart(ctx context.Context, cartID string) (*models.Cart, error)
+	DeleteGuestCartsBefore(ctx context.Context, before time.Time) (int64, error)
 	GetCartItemCount(ctx context.Context, cartID string) (int, error)
 	UpdateCart(ctx context.Context, cart *models.Cart) error
 	DeleteCart(ctx context.Context, db *gorm.DB, cartID string) error
@@ -45,6 +46,7 @@ func (r *cartRepository) GetCartWithItems(ctx context.Context, cartID string) (*
 	err := r.db.WithContext(ctx).
 		Preload("CartItems.Product.ProductImages").
 		Preload("CartItems.Product").
+		Preload("CartItems.Variant").
 		Preload("CartItems").
 		Where("id = ?", cartID).
 		First(&cart).Error
@@ -78,30 +80,6 @@ func (r *cartRepository) AddCart(ctx context.Context, cart *models.Cart) (*model
 	return cart, nil
 }
 
-func (r *cartRepository) UpdateCartSummary(ctx context.Context, cartID string) error {
-	cart, err := r.GetCartWithItems(ctx, cartID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("UpdateCartSummary: Keranjang dengan ID %s tidak ditemukan. Mungkin sudah dihapus.", cartID)
-			return nil
-		}
-		return fmt.Errorf("gagal mengambil keranjang dengan item untuk ringkasan: %w", err)
-	}
-	if cart == nil {
-		log.Printf("UpdateCartSummary: Keranjang dengan ID %s kosong setelah diambil. Mungkin sudah dihapus.", cartID)
-		return nil
-	}
-
-	cart.CalculateTotals(calc.GetTaxPercent())
-
-	err = r.UpdateCart(ctx, cart)
-	if err != nil {
-		return fmt.Errorf("gagal memperbarui ringkasan keranjang: %w", err)
-	}
-
-	return nil
-}
-
 func (r *cartRepository) UpdateCartTotalPrice(ctx context.Context, tx *gorm.DB, cartID string, baseTotalPrice, taxAmount, taxPercent, discountAmount, discountPercent decimal.Decimal, totalItems int) error {
 
 	grandTotal := baseTotalPrice.Add(taxAmount).Sub(discountAmount)
@@ -115,6 +93,9 @@ func (r *cartRepository) UpdateCartTotalPrice(ctx context.Context, tx *gorm.DB,
 		"grand_total":      grandTotal,
 		"total_items":      totalItems,
 		"shipping_cost":    decimal.Zero,
+		"voucher_id":       "",
+		"voucher_code":     "",
+		"voucher_discount": decimal.Zero,
 		"updated_at":       time.Now(),
 	}).Error
 }
@@ -133,6 +114,9 @@ func (r *cartRepository) ResetCartTotals(ctx context.Context, tx *gorm.DB, cartI
 		"shipping_service":      "",
 		"shipping_service_code": "",
 		"shipping_service_name": "",
+		"voucher_id":            "",
+		"voucher_code":          "",
+		"voucher_discount":      decimal.Zero,
 		"updated_at":            time.Now(),
 	}).Error
 }
@@ -174,10 +158,22 @@ func (r *cartRepository) CreateCartForUser(ctx context.Context, userID string) (
 	return newCart, nil
 }
 
+// UpdateCart menyimpan kolom keranjang dan item-itemnya saja. Produk dan varian yang ikut dimuat tidak pernah
+// ditulis dari sini karena stoknya hanya boleh diubah lewat jalur stok.
 func (r *cartRepository) UpdateCart(ctx context.Context, cart *models.Cart) error {
 	cart.UpdatedAt = time.Now()
 
-	err := r.db.WithContext(ctx).Session(&gorm.Session{FullSaveAssociations: true}).Save(cart).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(cart).Error; err != nil {
+			return err
+		}
+		for i := range cart.CartItems {
+			if err := tx.Omit(clause.Associations).Save(&cart.CartItems[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		log.Printf("CartRepository.UpdateCart: Error updating cart %s: %v", cart.ID, err)
 		return fmt.Errorf("failed to update cart: %w", err)
@@ -188,7 +184,7 @@ func (r *cartRepository) UpdateCart(ctx context.Context, cart *models.Cart) erro
 func (r *cartRepository) GetAllCarts(ctx context.Context) ([]models.Cart, error) {
 	var carts []models.Cart
 
-	if err := r.db.WithContext(ctx).Preload("CartItems.Product.ProductImages").Preload("CartItems.Product").Find(&carts).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("CartItems.Product.ProductImages").Preload("CartItems.Product").Preload("CartItems.Variant").Find(&carts).Error; err != nil {
 		log.Printf("CartRepository.GetAllCarts: Error getting all carts: %v", err)
 		return nil, fmt.Errorf("failed to get all carts: %w", err)
 	}
@@ -260,6 +256,7 @@ func (r *cartRepository) GetByUserIDWithItems(ctx context.Context, userID string
 	if err := r.db.WithContext(ctx).
 		Preload("CartItems.Product.ProductImages").
 		Preload("CartItems.Product").
+		Preload("CartItems.Variant").
 		Where("user_id = ?", userID).
 		First(&cart).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -269,3 +266,48 @@ func (r *cartRepository) GetByUserIDWithItems(ctx context.Context, userID string
 	}
 	return &cart, nil
 }
+
+// GetGuestCart mengambil keranjang tamu (tanpa user) berdasarkan ID. Keranjang milik user tidak dikembalikan.
+func (r *cartRepository) GetGuestCart(ctx context.Context, cartID string) (*models.Cart, error) {
+	if cartID == "" {
+		return nil, nil
+	}
+	var cart models.Cart
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", cartID, "").First(&cart).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil keranjang tamu: %w", err)
+	}
+	return &cart, nil
+}
+
+// DeleteGuestCartsBefore menghapus keranjang tamu beserta itemnya yang terakhir diubah sebelum waktu
+// tertentu, dan mengembalikan jumlah keranjang yang dihapus.
+func (r *cartRepository) DeleteGuestCartsBefore(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var cartIDs []string
+		if err := tx.Model(&models.Cart{}).Where("user_id = ? AND updated_at < ?", "", before).Pluck("id", &cartIDs).Error; err != nil {
+			return err
+		}
+		if len(cartIDs) == 0 {
+			return nil
+		}
+		if err := tx.Where("cart_id IN ?", cartIDs).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id IN ?", cartIDs).Delete(&models.Cart{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		log.Printf("CartRepository.DeleteGuestCartsBefore: Error deleting guest carts: %v", err)
+		return 0, fmt.Errorf("gagal menghapus keranjang tamu kedaluwarsa: %w", err)
+	}
+	return deleted, nil
+}
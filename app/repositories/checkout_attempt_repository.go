@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"gorm.io/gorm"
+)
+
+type CheckoutAttemptRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, attempt *models.CheckoutAttempt) error
+	FindByKey(ctx context.Context, key string) (*models.CheckoutAttempt, error)
+	ClaimGateway(ctx context.Context, id string, staleBefore time.Time) (bool, error)
+	ReleaseGateway(ctx context.Context, id, lastError string) error
+	MarkReady(ctx context.Context, tx *gorm.DB, id, redirectURL string) error
+	MarkCancelled(ctx context.Context, tx *gorm.DB, id, reason string) error
+	FindStuck(ctx context.Context, before time.Time, limit int) ([]models.CheckoutAttempt, error)
+	FindByOrderID(ctx context.Context, orderID string) (*models.CheckoutAttempt, error)
+}
+
+type checkoutAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewCheckoutAttemptRepository(db *gorm.DB) CheckoutAttemptRepository {
+	return &checkoutAttemptRepository{db}
+}
+
+func (r *checkoutAttemptRepository) Create(ctx context.Context, tx *gorm.DB, attempt *models.CheckoutAttempt) error {
+	if err := tx.WithContext(ctx).Create(attempt).Error; err != nil {
+		return fmt.Errorf("gagal menyimpan percobaan checkout: %w", err)
+	}
+	return nil
+}
+
+func (r *checkoutAttemptRepository) FindByKey(ctx context.Context, key string) (*models.CheckoutAttempt, error) {
+	var attempt models.CheckoutAttempt
+	err := r.db.WithContext(ctx).Where("idempotency_key = ?", key).First(&attempt).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil percobaan checkout: %w", err)
+	}
+	return &attempt, nil
+}
+
+// ClaimGateway mengunci percobaan yang masih pending untuk satu pemanggilan gateway. Kunci yang diambil
+// sebelum staleBefore dianggap milik proses yang sudah mati dan boleh diambil alih. Nilai false berarti
+// percobaan sedang diproses di tempat lain atau sudah tidak pending.
+func (r *checkoutAttemptRepository) ClaimGateway(ctx context.Context, id string, staleBefore time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.CheckoutAttempt{}).
+		Where("id = ? AND status = ?", id, models.CheckoutAttemptPending).
+		Where("gateway_locked_at IS NULL OR gateway_locked_at < ?", staleBefore).
+		Updates(map[string]interface{}{
+			"gateway_locked_at": time.Now(),
+			"gateway_attempts":  gorm.Expr("gateway_attempts + 1"),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("gagal mengunci percobaan checkout: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseGateway melepas kunci setelah pemanggilan gateway gagal agar bisa dicoba lagi.
+func (r *checkoutAttemptRepository) ReleaseGateway(ctx context.Context, id, lastError string) error {
+	err := r.db.WithContext(ctx).Model(&models.CheckoutAttempt{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"gateway_locked_at": nil, "last_error": lastError}).Error
+	if err != nil {
+		return fmt.Errorf("gagal melepas kunci percobaan checkout: %w", err)
+	}
+	return nil
+}
+
+func (r *checkoutAttemptRepository) MarkReady(ctx context.Context, tx *gorm.DB, id, redirectURL string) error {
+	err := tx.WithContext(ctx).Model(&models.CheckoutAttempt{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":            models.CheckoutAttemptReady,
+			"redirect_url":      redirectURL,
+			"gateway_locked_at": nil,
+			"last_error":        "",
+		}).Error
+	if err != nil {
+		return fmt.Errorf("gagal mencatat token pembayaran checkout: %w", err)
+	}
+	return nil
+}
+
+func (r *checkoutAttemptRepository) MarkCancelled(ctx context.Context, tx *gorm.DB, id, reason string) error {
+	err := tx.WithContext(ctx).Model(&models.CheckoutAttempt{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":            models.CheckoutAttemptCancelled,
+			"gateway_locked_at": nil,
+			"last_error":        reason,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("gagal membatalkan percobaan checkout: %w", err)
+	}
+	return nil
+}
+
+// FindStuck mengambil percobaan yang masih pending sejak sebelum waktu tertentu dan tidak sedang dikunci.
+func (r *checkoutAttemptRepository) FindStuck(ctx context.Context, before time.Time, limit int) ([]models.CheckoutAttempt, error) {
+	var attempts []models.CheckoutAttempt
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND created_at < ?", models.CheckoutAttemptPending, before).
+		Where("gateway_locked_at IS NULL OR gateway_locked_at < ?", before).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&attempts).Error
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil percobaan checkout yang tertahan: %w", err)
+	}
+	return attempts, nil
+}
+
+// FindByOrderID mengambil percobaan checkout terakhir yang membuat order; order lama bisa tidak memilikinya.
+func (r *checkoutAttemptRepository) FindByOrderID(ctx context.Context, orderID string) (*models.CheckoutAttempt, error) {
+	var attempt models.CheckoutAttempt
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at DESC").First(&attempt).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil percobaan checkout order %s: %w", orderID, err)
+	}
+	return &attempt, nil
+}
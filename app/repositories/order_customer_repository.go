@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"errors"
 
 	"github.com/Rakhulsr/go-ecommerce/app/models"
 	"gorm.io/gorm"
@@ -9,6 +10,7 @@ import (
 
 type OrderCustomerRepository interface {
 	Create(ctx context.Context, db *gorm.DB, customer *models.OrderCustomer) error
+	FindByOrderID(ctx context.Context, orderID string) (*models.OrderCustomer, error)
 }
 
 type OrderCustomerRepositoryImpl struct {
@@ -22,3 +24,15 @@ func NewOrderCustomerRepository(db *gorm.DB) OrderCustomerRepository {
 func (r *OrderCustomerRepositoryImpl) Create(ctx context.Context, db *gorm.DB, customer *models.OrderCustomer) error {
 	return db.WithContext(ctx).Create(customer).Error
 }
+
+func (r *OrderCustomerRepositoryImpl) FindByOrderID(ctx context.Context, orderID string) (*models.OrderCustomer, error) {
+	var customer models.OrderCustomer
+	err := r.DB.WithContext(ctx).Where("order_id = ?", orderID).First(&customer).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &customer, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Rakhulsr/go-ecommerce/app/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderRepository interface {
@@ -25,6 +26,7 @@ type OrderRepository interface {
 	UpdateMidtransDetails(ctx context.Context, db *gorm.DB, orderID, transactionToken, paymentURL string) error
 	GetOrderByIDWithRelations(ctx context.Context, orderID string) (*models.Order, error)
 	FindByCodeWithDetails(ctx context.Context, orderCode string) (*models.Order, error)
+	LockByCode(ctx context.Context, tx *gorm.DB, orderCode string) (*models.Order, error)
 	FindByUserID(ctx context.Context, userID string) ([]models.Order, error)
 
 	GetOrderCount(ctx context.Context) (int64, error)
@@ -157,6 +159,24 @@ func (r *gormOrderRepository) FindByCodeWithDetails(ctx context.Context, orderCo
 	return &order, nil
 }
 
+// LockByCode mengunci baris order (SELECT ... FOR UPDATE) di dalam tx beserta item-itemnya, supaya
+// perubahan status pembayaran untuk order yang sama diproses bergantian.
+func (r *gormOrderRepository) LockByCode(ctx context.Context, tx *gorm.DB, orderCode string) (*models.Order, error) {
+	var order models.Order
+	err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("OrderItems").
+		Where("order_code = ?", orderCode).
+		First(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengunci order %s: %w", orderCode, err)
+	}
+	return &order, nil
+}
+
 func (r *gormOrderRepository) FindByUserID(ctx context.Context, userID string) ([]models.Order, error) {
 	var orders []models.Order
 
@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"gorm.io/gorm"
+)
+
+type PaymentNotificationRepository interface {
+	Create(ctx context.Context, notification *models.PaymentNotification) error
+	FindByID(ctx context.Context, id string) (*models.PaymentNotification, error)
+	FindByDedupKey(ctx context.Context, key string) (*models.PaymentNotification, error)
+	RecordDelivery(ctx context.Context, id string) error
+	Claim(ctx context.Context, id string, staleBefore time.Time) (bool, error)
+	ClaimReplay(ctx context.Context, id string, staleBefore time.Time) (bool, error)
+	MarkOutcome(ctx context.Context, tx *gorm.DB, id, outcome, message string) error
+	GetForAdmin(ctx context.Context, outcome, orderCode string, limit, offset int) ([]models.PaymentNotification, int64, error)
+	CountByOutcome(ctx context.Context) (map[string]int64, error)
+}
+
+type paymentNotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentNotificationRepository(db *gorm.DB) PaymentNotificationRepository {
+	return &paymentNotificationRepository{db: db}
+}
+
+func (r *paymentNotificationRepository) Create(ctx context.Context, notification *models.PaymentNotification) error {
+	if err := r.db.WithContext(ctx).Create(notification).Error; err != nil {
+		return fmt.Errorf("gagal menyimpan notifikasi pembayaran: %w", err)
+	}
+	return nil
+}
+
+func (r *paymentNotificationRepository) FindByID(ctx context.Context, id string) (*models.PaymentNotification, error) {
+	var notification models.PaymentNotification
+	err := r.db.WithContext(ctx).First(&notification, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil notifikasi pembayaran: %w", err)
+	}
+	return &notification, nil
+}
+
+func (r *paymentNotificationRepository) FindByDedupKey(ctx context.Context, key string) (*models.PaymentNotification, error) {
+	var notification models.PaymentNotification
+	err := r.db.WithContext(ctx).First(&notification, "dedup_key = ?", key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil notifikasi pembayaran: %w", err)
+	}
+	return &notification, nil
+}
+
+func (r *paymentNotificationRepository) RecordDelivery(ctx context.Context, id string) error {
+	err := r.db.WithContext(ctx).Model(&models.PaymentNotification{}).Where("id = ?", id).
+		Update("deliveries", gorm.Expr("deliveries + 1")).Error
+	if err != nil {
+		return fmt.Errorf("gagal mencatat pengiriman ulang notifikasi pembayaran: %w", err)
+	}
+	return nil
+}
+
+// Claim mengambil notifikasi terverifikasi yang gagal diproses, atau yang tertahan di status received
+// sejak sebelum staleBefore, untuk diproses ulang. Nilai false berarti notifikasi sudah selesai atau
+// sedang diproses request lain.
+func (r *paymentNotificationRepository) Claim(ctx context.Context, id string, staleBefore time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.PaymentNotification{}).
+		Where("id = ? AND verified = ?", id, true).
+		Where("outcome = ? OR (outcome = ? AND updated_at < ?)", models.PaymentNotificationFailed, models.PaymentNotificationReceived, staleBefore).
+		Updates(map[string]interface{}{
+			"outcome":    models.PaymentNotificationReceived,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("gagal mengunci notifikasi pembayaran: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ClaimReplay sama dengan Claim tetapi juga mengambil notifikasi yang sudah diproses, untuk replay admin.
+func (r *paymentNotificationRepository) ClaimReplay(ctx context.Context, id string, staleBefore time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.PaymentNotification{}).
+		Where("id = ? AND verified = ?", id, true).
+		Where("outcome <> ? OR updated_at < ?", models.PaymentNotificationReceived, staleBefore).
+		Updates(map[string]interface{}{
+			"outcome":    models.PaymentNotificationReceived,
+			"replays":    gorm.Expr("replays + 1"),
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("gagal mengunci notifikasi pembayaran: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *paymentNotificationRepository) MarkOutcome(ctx context.Context, tx *gorm.DB, id, outcome, message string) error {
+	err := tx.WithContext(ctx).Model(&models.PaymentNotification{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"outcome":         outcome,
+			"outcome_message": message,
+			"processed_at":    time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("gagal mencatat hasil notifikasi pembayaran: %w", err)
+	}
+	return nil
+}
+
+func (r *paymentNotificationRepository) GetForAdmin(ctx context.Context, outcome, orderCode string, limit, offset int) ([]models.PaymentNotification, int64, error) {
+	var (
+		notifications []models.PaymentNotification
+		total         int64
+	)
+
+	query := r.db.WithContext(ctx).Model(&models.PaymentNotification{})
+	if outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+	if orderCode != "" {
+		query = query.Where("order_code = ?", orderCode)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung notifikasi pembayaran: %w", err)
+	}
+
+	if err := query.Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&notifications).Error; err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil notifikasi pembayaran: %w", err)
+	}
+	return notifications, total, nil
+}
+
+func (r *paymentNotificationRepository) CountByOutcome(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Outcome string
+		Total   int64
+	}
+	if err := r.db.WithContext(ctx).Model(&models.PaymentNotification{}).
+		Select("outcome, COUNT(*) AS total").
+		Group("outcome").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("gagal menghitung notifikasi pembayaran: %w", err)
+	}
+
+	result := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		result[row.Outcome] = row.Total
+	}
+	return result, nil
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"gorm.io/gorm"
+)
+
+type PaymentProofRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, proof *models.PaymentProof) error
+	FindByID(ctx context.Context, id string) (*models.PaymentProof, error)
+	FindByOrderID(ctx context.Context, orderID string) ([]models.PaymentProof, error)
+	HasPending(ctx context.Context, tx *gorm.DB, orderID string) (bool, error)
+	MarkReviewed(ctx context.Context, tx *gorm.DB, id, status, rejectReason, reviewerID string) (bool, error)
+	GetForAdmin(ctx context.Context, status, orderCode string, limit, offset int) ([]models.PaymentProof, int64, error)
+	CountByStatus(ctx context.Context) (map[string]int64, error)
+	GetPaginated(ctx context.Context, limit, offset int) ([]models.PaymentProof, error)
+	UpdateImagePath(ctx context.Context, id, path string) error
+}
+
+type paymentProofRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentProofRepository(db *gorm.DB) PaymentProofRepository {
+	return &paymentProofRepository{db}
+}
+
+func (r *paymentProofRepository) Create(ctx context.Context, tx *gorm.DB, proof *models.PaymentProof) error {
+	if err := tx.WithContext(ctx).Create(proof).Error; err != nil {
+		return fmt.Errorf("gagal menyimpan bukti transfer: %w", err)
+	}
+	return nil
+}
+
+func (r *paymentProofRepository) FindByID(ctx context.Context, id string) (*models.PaymentProof, error) {
+	var proof models.PaymentProof
+	err := r.db.WithContext(ctx).Preload("Order").Preload("Payment").First(&proof, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil bukti transfer: %w", err)
+	}
+	return &proof, nil
+}
+
+func (r *paymentProofRepository) FindByOrderID(ctx context.Context, orderID string) ([]models.PaymentProof, error) {
+	var proofs []models.PaymentProof
+	if err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at DESC").Find(&proofs).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil bukti transfer order %s: %w", orderID, err)
+	}
+	return proofs, nil
+}
+
+func (r *paymentProofRepository) HasPending(ctx context.Context, tx *gorm.DB, orderID string) (bool, error) {
+	var count int64
+	err := tx.WithContext(ctx).Model(&models.PaymentProof{}).
+		Where("order_id = ? AND status = ?", orderID, models.PaymentProofPending).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("gagal memeriksa bukti transfer order %s: %w", orderID, err)
+	}
+	return count > 0, nil
+}
+
+// MarkReviewed mengubah bukti yang masih pending menjadi disetujui atau ditolak. Nilai false berarti bukti
+// sudah lebih dulu diverifikasi admin lain.
+func (r *paymentProofRepository) MarkReviewed(ctx context.Context, tx *gorm.DB, id, status, rejectReason, reviewerID string) (bool, error) {
+	result := tx.WithContext(ctx).Model(&models.PaymentProof{}).
+		Where("id = ? AND status = ?", id, models.PaymentProofPending).
+		Updates(map[string]interface{}{
+			"status":        status,
+			"reject_reason": rejectReason,
+			"reviewer_id":   reviewerID,
+			"reviewed_at":   time.Now(),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("gagal memperbarui bukti transfer: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *paymentProofRepository) GetForAdmin(ctx context.Context, status, orderCode string, limit, offset int) ([]models.PaymentProof, int64, error) {
+	var (
+		proofs []models.PaymentProof
+		total  int64
+	)
+
+	query := r.db.WithContext(ctx).Model(&models.PaymentProof{})
+	if status != "" {
+		query = query.Where("payment_proofs.status = ?", status)
+	}
+	if orderCode != "" {
+		query = query.Joins("JOIN orders ON orders.id = payment_proofs.order_id").Where("orders.order_code = ?", orderCode)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung bukti transfer: %w", err)
+	}
+
+	// antrean pending diurutkan dari yang paling lama menunggu
+	sort := "payment_proofs.created_at DESC"
+	if status == models.PaymentProofPending {
+		sort = "payment_proofs.created_at ASC"
+	}
+	if err := query.Preload("Order").Preload("Payment").Preload("Reviewer").
+		Order(sort).
+		Limit(limit).
+		Offset(offset).
+		Find(&proofs).Error; err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil bukti transfer: %w", err)
+	}
+	return proofs, total, nil
+}
+
+func (r *paymentProofRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Total  int64
+	}
+	if err := r.db.WithContext(ctx).Model(&models.PaymentProof{}).
+		Select("status, COUNT(*) AS total").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("gagal menghitung bukti transfer: %w", err)
+	}
+
+	result := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		result[row.Status] = row.Total
+	}
+	return result, nil
+}
+
+func (r *paymentProofRepository) GetPaginated(ctx context.Context, limit, offset int) ([]models.PaymentProof, error) {
+	var proofs []models.PaymentProof
+	if err := r.db.WithContext(ctx).Order("created_at ASC, id ASC").Limit(limit).Offset(offset).Find(&proofs).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil bukti transfer: %w", err)
+	}
+	return proofs, nil
+}
+
+func (r *paymentProofRepository) UpdateImagePath(ctx context.Context, id, path string) error {
+	if err := r.db.WithContext(ctx).Model(&models.PaymentProof{}).Where("id = ?", id).Update("image_path", path).Error; err != nil {
+		return fmt.Errorf("gagal memperbarui path bukti transfer: %w", err)
+	}
+	return nil
+}
@@ -2,17 +2,26 @@ package repositories
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type PaymentRepository interface {
 	Create(ctx context.Context, tx *gorm.DB, payment *models.Payment) error
 	FindByOrderID(ctx context.Context, orderID string) (*models.Payment, error)
+	FindByOrderIDTx(ctx context.Context, tx *gorm.DB, orderID string) (*models.Payment, error)
 	UpdatePaymentStatusTx(ctx context.Context, tx *gorm.DB, paymentID string, status string) error
 	UpdatePaymentStatus(ctx context.Context, paymentID string, status string) error
+	UpdateTokenTx(ctx context.Context, tx *gorm.DB, orderID, token string) error
+	UpdateBankTransferTx(ctx context.Context, tx *gorm.DB, orderID, token, vaNumber, billCode, billKey string, expiresAt time.Time) error
+	PendingAmountExistsTx(ctx context.Context, tx *gorm.DB, paymentType string, amount decimal.Decimal) (bool, error)
+	FindExpiredPending(ctx context.Context, paymentType string, now time.Time, limit int) ([]models.Payment, error)
+	FindExpiredGatewayPending(ctx context.Context, expiredBefore, createdBefore time.Time, limit int) ([]models.Payment, error)
 }
 
 type PaymentRepositoryImpl struct {
@@ -50,6 +59,15 @@ func (r *PaymentRepositoryImpl) FindByOrderID(ctx context.Context, orderID strin
 	return &payment, nil
 }
 
+func (r *PaymentRepositoryImpl) FindByOrderIDTx(ctx context.Context, tx *gorm.DB, orderID string) (*models.Payment, error) {
+	var payment models.Payment
+	err := tx.WithContext(ctx).Where("order_id = ?", orderID).First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
 func (r *PaymentRepositoryImpl) UpdateStatus(ctx context.Context, orderID, status string) error {
 	return r.DB.WithContext(ctx).
 		Model(&models.Payment{}).
@@ -64,3 +82,64 @@ func (r *PaymentRepositoryImpl) UpdatePaymentStatus(ctx context.Context, payment
 func (r *PaymentRepositoryImpl) UpdatePaymentStatusTx(ctx context.Context, tx *gorm.DB, paymentID string, status string) error {
 	return tx.WithContext(ctx).Model(&models.Payment{}).Where("id = ?", paymentID).Update("status", status).Error
 }
+
+func (r *PaymentRepositoryImpl) UpdateTokenTx(ctx context.Context, tx *gorm.DB, orderID, token string) error {
+	return tx.WithContext(ctx).Model(&models.Payment{}).Where("order_id = ?", orderID).Update("token", token).Error
+}
+
+// UpdateBankTransferTx mencatat nomor pembayaran hasil charge bank transfer beserta batas waktunya.
+func (r *PaymentRepositoryImpl) UpdateBankTransferTx(ctx context.Context, tx *gorm.DB, orderID, token, vaNumber, billCode, billKey string, expiresAt time.Time) error {
+	return tx.WithContext(ctx).Model(&models.Payment{}).Where("order_id = ?", orderID).Updates(map[string]interface{}{
+		"token":      token,
+		"va_number":  vaNumber,
+		"bill_code":  billCode,
+		"bill_key":   billKey,
+		"expires_at": expiresAt,
+	}).Error
+}
+
+// PendingAmountExistsTx memeriksa apakah sudah ada pembayaran pending dengan jenis dan nominal yang sama,
+// dipakai untuk memilih kode unik transfer manual.
+func (r *PaymentRepositoryImpl) PendingAmountExistsTx(ctx context.Context, tx *gorm.DB, paymentType string, amount decimal.Decimal) (bool, error) {
+	var count int64
+	err := tx.WithContext(ctx).Model(&models.Payment{}).
+		Where("payment_type = ? AND status = ? AND amount = ?", paymentType, "Pending", amount).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("gagal memeriksa nominal pembayaran: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *PaymentRepositoryImpl) FindExpiredPending(ctx context.Context, paymentType string, now time.Time, limit int) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.DB.WithContext(ctx).
+		Where("payment_type = ? AND status = ? AND expires_at <= ?", paymentType, "Pending", now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Preload("Order").
+		Find(&payments).Error
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil pembayaran kedaluwarsa: %w", err)
+	}
+	return payments, nil
+}
+
+// FindExpiredGatewayPending mengambil pembayaran gateway (selain transfer manual) yang order-nya masih pending
+// setelah batas waktunya: ExpiresAt untuk bank transfer, atau waktu dibuat untuk Snap yang batasnya mengikuti
+// reservasi stok.
+func (r *PaymentRepositoryImpl) FindExpiredGatewayPending(ctx context.Context, expiredBefore, createdBefore time.Time, limit int) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.DB.WithContext(ctx).
+		Joins("JOIN orders ON orders.id = payments.order_id AND orders.status = ?", models.OrderStatusPending).
+		Where("payments.payment_type <> ? AND payments.status = ?", models.PaymentTypeManualTransfer, "Pending").
+		Where("(payments.expires_at IS NOT NULL AND payments.expires_at <= ?) OR (payments.expires_at IS NULL AND payments.created_at <= ?)", expiredBefore, createdBefore).
+		Order("payments.created_at ASC").
+		Limit(limit).
+		Preload("Order").
+		Find(&payments).Error
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil pembayaran gateway kedaluwarsa: %w", err)
+	}
+	return payments, nil
+}
@@ -0,0 +1,193 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/models/other"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Query builder katalog. Setiap filter adalah gorm scope yang berdiri sendiri sehingga bisa digabung
+// bebas, dan facet bisa dihitung dengan melewati satu filter tertentu.
+
+const (
+	productFacetCategory   = "category"
+	productFacetPrice      = "price"
+	productFacetInStock    = "in_stock"
+	productFacetDiscounted = "discounted"
+	productFacetRating     = "rating"
+)
+
+const productSearchMatch = "MATCH(products.name, products.description, products.sku) AGAINST (? IN BOOLEAN MODE)"
+
+// productBasePrice mengikuti Product.UnitPricing untuk diskon produk: persen didahulukan, lalu nominal.
+const productBasePrice = "GREATEST(CASE" +
+	" WHEN products.discount_percent > 0 THEN products.price - (products.price * products.discount_percent / 100)" +
+	" WHEN products.discount_amount > 0 THEN products.price - products.discount_amount" +
+	" ELSE products.price END, 0)"
+
+// productPromotionRunningSQL adalah syarat promosi dengan alias %[1]s sedang berjalan dan mencakup produk, sama
+// seperti PromotionRepository.GetRunning lalu Promotion.AppliesTo.
+const productPromotionRunningSQL = "%[1]s.is_active = ? AND %[1]s.starts_at <= ? AND (%[1]s.ends_at IS NULL OR %[1]s.ends_at > ?) AND (%[1]s.scope = ?" +
+	" OR (%[1]s.scope = ? AND EXISTS (SELECT 1 FROM promotion_products pp WHERE pp.promotion_id = %[1]s.id AND pp.product_id = products.id))" +
+	" OR (%[1]s.scope = ? AND EXISTS (SELECT 1 FROM promotion_categories pmc JOIN product_categories ppc ON ppc.category_id = pmc.category_id" +
+	" WHERE pmc.promotion_id = %[1]s.id AND ppc.product_id = products.id)))"
+
+func productPromotionRunning(alias string, now time.Time) clause.Expr {
+	return clause.Expr{
+		SQL:  fmt.Sprintf(productPromotionRunningSQL, alias),
+		Vars: []interface{}{true, now, now, models.PromotionScopeAll, models.PromotionScopeProducts, models.PromotionScopeCategories},
+	}
+}
+
+// productEffectivePrice adalah harga setelah diskon produk dan promosi berjalan, dipakai untuk filter dan urutan
+// harga. Promosi yang dihitung mengikuti models.PromotionDiscount: promosi dengan prioritas tertinggi, ditambah
+// promosi Stackable lain jika promosi teratas juga Stackable. Untuk tumpukan campuran persen dan nominal,
+// persen dihitung lebih dulu sehingga hasilnya bisa sedikit berbeda dari urutan prioritas di UnitPricing.
+func productEffectivePrice(now time.Time) clause.Expr {
+	top := func(column string) clause.Expr {
+		return clause.Expr{
+			SQL:  "(SELECT t." + column + " FROM promotions t WHERE ? ORDER BY t.priority DESC, t.created_at ASC LIMIT 1)",
+			Vars: []interface{}{productPromotionRunning("t", now)},
+		}
+	}
+	applied := clause.Expr{
+		SQL:  "? AND (s.id = ? OR (s.stackable AND ?))",
+		Vars: []interface{}{productPromotionRunning("s", now), top("id"), top("stackable")},
+	}
+	return clause.Expr{
+		SQL: "GREATEST(" + productBasePrice +
+			" * COALESCE((SELECT CASE WHEN MIN(1 - s.value / 100) <= 0 THEN 0 ELSE EXP(SUM(LN(1 - s.value / 100))) END" +
+			" FROM promotions s WHERE s.type = ? AND ?), 1)" +
+			" - COALESCE((SELECT SUM(s.value) FROM promotions s WHERE s.type = ? AND ?), 0), 0)",
+		Vars: []interface{}{models.PromotionTypePercent, applied, models.PromotionTypeFixed, applied},
+	}
+}
+
+const productSoldQty = "(SELECT COALESCE(SUM(oi.qty), 0) FROM order_items oi JOIN orders o ON o.id = oi.order_id" +
+	" WHERE oi.product_id = products.id AND oi.deleted_at IS NULL AND o.deleted_at IS NULL AND o.status IN ?)"
+
+// productAvgRating dan productReviewCount hanya menghitung ulasan yang sudah disetujui moderator.
+const productAvgRating = "COALESCE((SELECT AVG(rv.rating) FROM reviews rv WHERE rv.product_id = products.id AND rv.status = ? AND rv.deleted_at IS NULL), 0)"
+
+const productReviewCount = "(SELECT COUNT(*) FROM reviews rv WHERE rv.product_id = products.id AND rv.status = ? AND rv.deleted_at IS NULL)"
+
+// soldOrderStatuses adalah status order yang sudah dibayar dan dihitung sebagai penjualan.
+var soldOrderStatuses = []int{models.OrderStatusProcessing, models.OrderStatusShipped, models.OrderStatusCompleted}
+
+// productFilterScopes menyusun scope untuk semua filter aktif kecuali facet yang disebut di skip.
+func productFilterScopes(filter other.ProductFilter, skip string) []func(*gorm.DB) *gorm.DB {
+	var scopes []func(*gorm.DB) *gorm.DB
+	if filter.Keyword != "" {
+		scopes = append(scopes, productKeywordScope(filter.Keyword))
+	}
+	if len(filter.CategorySlugs) > 0 && skip != productFacetCategory {
+		scopes = append(scopes, productCategoryScope(filter.CategorySlugs))
+	}
+	if (filter.MinPrice > 0 || filter.MaxPrice > 0) && skip != productFacetPrice {
+		scopes = append(scopes, productPriceScope(filter.MinPrice, filter.MaxPrice))
+	}
+	if filter.InStock && skip != productFacetInStock {
+		scopes = append(scopes, productInStockScope)
+	}
+	if filter.Discounted && skip != productFacetDiscounted {
+		scopes = append(scopes, productDiscountedScope)
+	}
+	if filter.MinRating > 0 && skip != productFacetRating {
+		scopes = append(scopes, productRatingScope(filter.MinRating))
+	}
+	return scopes
+}
+
+// productKeywordScope memakai index FULLTEXT (name, description, sku). SKU produk maupun varian
+// yang sama persis selalu ikut walaupun tidak lolos MATCH.
+func productKeywordScope(keyword string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		booleanQuery := buildBooleanSearchQuery(keyword)
+		if booleanQuery == "" {
+			return db.Where("products.sku = ?", keyword)
+		}
+		return db.Where(
+			productSearchMatch+" OR products.sku = ? OR EXISTS (SELECT 1 FROM product_variants pv WHERE pv.product_id = products.id AND pv.sku = ?)",
+			booleanQuery, keyword, keyword,
+		)
+	}
+}
+
+// productCategoryScope memakai EXISTS agar produk dengan beberapa kategori tidak muncul ganda.
+func productCategoryScope(slugs []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("EXISTS (SELECT 1 FROM product_categories pc JOIN categories c ON c.id = pc.category_id WHERE pc.product_id = products.id AND c.slug IN ?)", slugs)
+	}
+}
+
+func productPriceScope(minPrice, maxPrice int64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		price := productEffectivePrice(time.Now())
+		if minPrice > 0 {
+			db = db.Where("? >= ?", price, minPrice)
+		}
+		if maxPrice > 0 {
+			db = db.Where("? <= ?", price, maxPrice)
+		}
+		return db
+	}
+}
+
+// productInStockScope memperhitungkan hold checkout yang masih aktif, sama seperti StockReservationService.
+func productInStockScope(db *gorm.DB) *gorm.DB {
+	return db.Where(
+		"products.stock > COALESCE((SELECT SUM(sr.qty) FROM stock_reservations sr WHERE sr.product_id = products.id AND sr.status = ? AND sr.expires_at > ?), 0)",
+		models.StockReservationActive, time.Now(),
+	)
+}
+
+func productDiscountedScope(db *gorm.DB) *gorm.DB {
+	return db.Where("(products.discount_percent > 0 OR products.discount_amount > 0 OR EXISTS (SELECT 1 FROM promotions pr WHERE ?))",
+		productPromotionRunning("pr", time.Now()))
+}
+
+func productRatingScope(minRating int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(productAvgRating+" >= ?", models.ReviewStatusApproved, minRating)
+	}
+}
+
+// productSortScope menambahkan ORDER BY sesuai filter.Sort. Urutan terakhir selalu created_at DESC
+// supaya paginasi stabil.
+func productSortScope(filter other.ProductFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch filter.Sort {
+		case other.ProductSortRelevance:
+			booleanQuery := buildBooleanSearchQuery(filter.Keyword)
+			if booleanQuery == "" {
+				break
+			}
+			relevance := "(" + productSearchMatch + ")" +
+				" + (CASE WHEN LOWER(products.name) LIKE ? THEN 5 ELSE 0 END)" +
+				" + (CASE WHEN products.sku = ? THEN 10 ELSE 0 END)"
+			db = db.Select("products.*, "+relevance+" AS relevance", booleanQuery, "%"+strings.ToLower(filter.Keyword)+"%", filter.Keyword).
+				Order("relevance DESC")
+		case other.ProductSortPriceAsc:
+			db = db.Select("products.*, ? AS effective_price", productEffectivePrice(time.Now())).
+				Order("effective_price ASC")
+		case other.ProductSortPriceDesc:
+			db = db.Select("products.*, ? AS effective_price", productEffectivePrice(time.Now())).
+				Order("effective_price DESC")
+		case other.ProductSortName:
+			db = db.Order("products.name ASC")
+		case other.ProductSortRating:
+			db = db.Select("products.*, "+productAvgRating+" AS avg_rating, "+productReviewCount+" AS review_count", models.ReviewStatusApproved, models.ReviewStatusApproved).
+				Order("avg_rating DESC").
+				Order("review_count DESC")
+		case other.ProductSortBestSelling:
+			db = db.Select("products.*, "+productSoldQty+" AS sold_qty", soldOrderStatuses).
+				Order("sold_qty DESC")
+		}
+		return db.Order("products.created_at DESC")
+	}
+}
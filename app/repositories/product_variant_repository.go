@@ -0,0 +1,277 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/storage"
+	"gorm.io/gorm"
+)
+
+type ProductVariantRepositoryImpl interface {
+	GetByID(ctx context.Context, id string) (*models.ProductVariant, error)
+	GetByProductID(ctx context.Context, productID string) ([]models.ProductVariant, error)
+	IsSKUExists(ctx context.Context, sku, excludeVariantID string) (bool, error)
+	SyncVariants(ctx context.Context, productID string, options []models.ProductOption, variants []models.ProductVariant, reference string) error
+}
+
+type productVariantRepository struct {
+	db    *gorm.DB
+	store storage.Storage
+}
+
+func NewProductVariantRepository(db *gorm.DB, store storage.Storage) ProductVariantRepositoryImpl {
+	return &productVariantRepository{db: db, store: store}
+}
+
+func (r *productVariantRepository) GetByID(ctx context.Context, id string) (*models.ProductVariant, error) {
+	var variant models.ProductVariant
+	if err := r.db.WithContext(ctx).
+		Preload("OptionValues").
+		Preload("Images").
+		Where("id = ?", id).
+		First(&variant).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil varian produk: %w", err)
+	}
+	return &variant, nil
+}
+
+func (r *productVariantRepository) GetByProductID(ctx context.Context, productID string) ([]models.ProductVariant, error) {
+	var variants []models.ProductVariant
+	if err := r.db.WithContext(ctx).
+		Preload("OptionValues").
+		Preload("Images").
+		Where("product_id = ?", productID).
+		Order("position ASC").
+		Find(&variants).Error; err != nil {
+		log.Printf("ProductVariantRepository.GetByProductID: Error getting variants for product %s: %v", productID, err)
+		return nil, fmt.Errorf("gagal mengambil varian produk: %w", err)
+	}
+	return variants, nil
+}
+
+func (r *productVariantRepository) IsSKUExists(ctx context.Context, sku, excludeVariantID string) (bool, error) {
+	var count int64
+	query := r.db.WithContext(ctx).Model(&models.ProductVariant{}).Where("sku = ?", sku)
+	if excludeVariantID != "" {
+		query = query.Where("id <> ?", excludeVariantID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// SyncVariants mengganti seluruh opsi produk dan menyimpan varian yang dikirim.
+// Varian yang tidak ada di daftar akan dihapus. OptionValues tiap varian dicocokkan
+// dengan opsi berdasarkan urutan opsi dan nilainya. Perubahan stok varian dicatat sebagai
+// penyesuaian manual dengan reference yang diberikan.
+func (r *productVariantRepository) SyncVariants(ctx context.Context, productID string, options []models.ProductOption, variants []models.ProductVariant, reference string) error {
+	var removedImages []models.ProductImage
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existingVariants []models.ProductVariant
+		if err := tx.Select("id", "stock").Where("product_id = ?", productID).Find(&existingVariants).Error; err != nil {
+			return fmt.Errorf("gagal mengambil varian lama: %w", err)
+		}
+		existingVariantIDs := make([]string, 0, len(existingVariants))
+		existingStock := make(map[string]int, len(existingVariants))
+		for _, v := range existingVariants {
+			existingVariantIDs = append(existingVariantIDs, v.ID)
+			existingStock[v.ID] = v.Stock
+		}
+
+		if len(existingVariantIDs) > 0 {
+			if err := tx.Exec("DELETE FROM product_variant_option_values WHERE product_variant_id IN (?)", existingVariantIDs).Error; err != nil {
+				return fmt.Errorf("gagal menghapus relasi opsi varian lama: %w", err)
+			}
+		}
+
+		var existingOptionIDs []string
+		if err := tx.Model(&models.ProductOption{}).Where("product_id = ?", productID).Pluck("id", &existingOptionIDs).Error; err != nil {
+			return fmt.Errorf("gagal mengambil opsi lama: %w", err)
+		}
+		if len(existingOptionIDs) > 0 {
+			if err := tx.Where("option_id IN (?)", existingOptionIDs).Delete(&models.ProductOptionValue{}).Error; err != nil {
+				return fmt.Errorf("gagal menghapus nilai opsi lama: %w", err)
+			}
+			if err := tx.Where("id IN (?)", existingOptionIDs).Delete(&models.ProductOption{}).Error; err != nil {
+				return fmt.Errorf("gagal menghapus opsi lama: %w", err)
+			}
+		}
+
+		valueIndex := make(map[string]models.ProductOptionValue)
+		for i := range options {
+			options[i].ID = ""
+			options[i].ProductID = productID
+			options[i].Position = i
+			for j := range options[i].Values {
+				options[i].Values[j].ID = ""
+				options[i].Values[j].Position = j
+			}
+			if err := tx.Create(&options[i]).Error; err != nil {
+				return fmt.Errorf("gagal menyimpan opsi %s: %w", options[i].Name, err)
+			}
+			for _, value := range options[i].Values {
+				valueIndex[fmt.Sprintf("%d:%s", i, value.Value)] = value
+			}
+		}
+
+		keptIDs := make([]string, 0, len(variants))
+		for i := range variants {
+			variant := &variants[i]
+			variant.ProductID = productID
+			variant.Position = i
+
+			var linkedValues []models.ProductOptionValue
+			for optionPos, ov := range variant.OptionValues {
+				if value, ok := valueIndex[fmt.Sprintf("%d:%s", optionPos, ov.Value)]; ok {
+					linkedValues = append(linkedValues, value)
+				}
+			}
+			variant.OptionValues = nil
+			images := variant.Images
+			variant.Images = nil
+
+			// stok ditulis lewat ledger, bukan lewat Create/Save
+			targetStock := variant.Stock
+			currentStock := 0
+			if variant.ID == "" {
+				variant.Stock = 0
+				if err := tx.Omit("OptionValues", "Images").Create(variant).Error; err != nil {
+					return fmt.Errorf("gagal membuat varian %s: %w", variant.Sku, err)
+				}
+			} else {
+				currentStock = existingStock[variant.ID]
+				variant.UpdatedAt = time.Now()
+				if err := tx.Omit("OptionValues", "Images", "CreatedAt", "Stock").Save(variant).Error; err != nil {
+					return fmt.Errorf("gagal memperbarui varian %s: %w", variant.Sku, err)
+				}
+			}
+			keptIDs = append(keptIDs, variant.ID)
+
+			if delta := targetStock - currentStock; delta != 0 {
+				if _, err := applyStockAdjustment(ctx, tx, StockAdjustment{
+					ProductID: productID,
+					VariantID: variant.ID,
+					Delta:     delta,
+					Reason:    models.StockMovementManual,
+					Reference: reference,
+					Note:      "Perubahan stok varian " + variant.Name,
+				}); err != nil {
+					return err
+				}
+			}
+			variant.Stock = targetStock
+
+			if len(linkedValues) > 0 {
+				if err := tx.Model(variant).Association("OptionValues").Append(linkedValues); err != nil {
+					return fmt.Errorf("gagal menghubungkan opsi ke varian %s: %w", variant.Sku, err)
+				}
+			}
+			variant.OptionValues = linkedValues
+
+			if len(images) > 0 {
+				if err := r.deleteVariantImages(tx, []string{variant.ID}, &removedImages); err != nil {
+					return err
+				}
+				for j := range images {
+					images[j].ID = ""
+					images[j].ProductID = productID
+					images[j].VariantID = variant.ID
+				}
+				if err := tx.Create(&images).Error; err != nil {
+					return fmt.Errorf("gagal menyimpan gambar varian %s: %w", variant.Sku, err)
+				}
+			}
+			variant.Images = images
+		}
+
+		removeQuery := tx.Where("product_id = ?", productID)
+		if len(keptIDs) > 0 {
+			removeQuery = removeQuery.Where("id NOT IN (?)", keptIDs)
+		}
+		var removedIDs []string
+		if err := removeQuery.Model(&models.ProductVariant{}).Pluck("id", &removedIDs).Error; err != nil {
+			return fmt.Errorf("gagal mengambil varian yang dihapus: %w", err)
+		}
+		if len(removedIDs) > 0 {
+			for _, id := range removedIDs {
+				if existingStock[id] == 0 {
+					continue
+				}
+				if _, err := applyStockAdjustment(ctx, tx, StockAdjustment{
+					ProductID: productID,
+					VariantID: id,
+					Delta:     -existingStock[id],
+					Reason:    models.StockMovementManual,
+					Reference: reference,
+					Note:      "Varian dihapus",
+				}); err != nil {
+					return err
+				}
+			}
+			if err := r.deleteVariantImages(tx, removedIDs, &removedImages); err != nil {
+				return err
+			}
+			if err := tx.Where("id IN (?)", removedIDs).Delete(&models.ProductVariant{}).Error; err != nil {
+				return fmt.Errorf("gagal menghapus varian lama: %w", err)
+			}
+			log.Printf("ProductVariantRepository.SyncVariants: %d varian dihapus untuk produk %s", len(removedIDs), productID)
+		}
+
+		if len(variants) > 0 {
+			totalStock := 0
+			for _, v := range variants {
+				totalStock += v.Stock
+			}
+			// sisa stok level produk (misal stok sebelum produk memiliki varian) disesuaikan agar sama dengan total varian
+			productStock, err := lockedStock(ctx, tx, productID, "")
+			if err != nil {
+				return err
+			}
+			if delta := totalStock - productStock; delta != 0 {
+				if _, err := applyStockAdjustment(ctx, tx, StockAdjustment{
+					ProductID: productID,
+					Delta:     delta,
+					Reason:    models.StockMovementManual,
+					Reference: reference,
+					Note:      "Penyesuaian stok produk ke total stok varian",
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	removeImageFiles(ctx, r.store, "ProductVariantRepository.SyncVariants", removedImages...)
+	return nil
+}
+
+// deleteVariantImages menghapus record gambar varian; file-nya dikumpulkan ke removed dan baru
+// dihapus dari storage setelah transaksi berhasil.
+func (r *productVariantRepository) deleteVariantImages(tx *gorm.DB, variantIDs []string, removed *[]models.ProductImage) error {
+	var images []models.ProductImage
+	if err := tx.Where("variant_id IN (?)", variantIDs).Find(&images).Error; err != nil {
+		return fmt.Errorf("gagal mengambil gambar varian: %w", err)
+	}
+	if len(images) == 0 {
+		return nil
+	}
+	*removed = append(*removed, images...)
+
+	if err := tx.Where("variant_id IN (?)", variantIDs).Delete(&models.ProductImage{}).Error; err != nil {
+		return fmt.Errorf("gagal menghapus gambar varian: %w", err)
+	}
+	return nil
+}
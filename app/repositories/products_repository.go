@@ -4,14 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"math"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/models/other"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/storage"
 	"github.com/google/uuid"
-	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	_ "gorm.io/gorm/clause"
 )
@@ -20,30 +21,62 @@ type ProductRepositoryImpl interface {
 	GetProducts(ctx context.Context) ([]models.Product, error)
 	GetByCategorySlug(ctx context.Context, slug string) ([]models.Product, error)
 	GetPaginated(ctx context.Context, limit, offset int) ([]models.Product, int64, error)
-	GetByCategorySlugPaginated(ctx context.Context, slug string, limit, offset int) ([]models.Product, int64, error)
 	GetBySlug(ctx context.Context, slug string) (*models.Product, error)
 	GetFeaturedProducts(ctx context.Context, limit int) ([]models.Product, error)
-	SearchProductsPaginated(ctx context.Context, keyword string, limit, offset int) ([]models.Product, int64, error)
+	FindProducts(ctx context.Context, filter other.ProductFilter, limit, offset int) ([]models.Product, int64, error)
+	GetProductFacets(ctx context.Context, filter other.ProductFilter) (*other.ProductFacets, error)
+	GetSearchVocabulary(ctx context.Context) ([]string, error)
 	GetByID(ctx context.Context, id string) (*models.Product, error)
 	GetProductCount(ctx context.Context) (int64, error)
 
 	CreateProduct(ctx context.Context, product *models.Product) error
 	UpdateProduct(ctx context.Context, product *models.Product) error
 	DeleteProduct(ctx context.Context, id string) error
-	UpdateProductDiscount(ctx context.Context, productID string, discountPercent decimal.Decimal, discountAmount decimal.Decimal) error
 	IsSKUExists(ctx context.Context, sku string) (bool, error)
-	DecrementStock(ctx context.Context, tx *gorm.DB, productID string, quantity int) error
 	UpdateProductTx(ctx context.Context, tx *gorm.DB, product *models.Product) error
-	UpdateStock(ctx context.Context, tx *gorm.DB, productID string, newStock int) error
 	DeleteProductImage(ctx context.Context, imageID string) error
+	GetProductImagesPaginated(ctx context.Context, limit, offset int) ([]models.ProductImage, error)
+	UpdateProductImageFiles(ctx context.Context, image *models.ProductImage) error
+	GetBySKU(ctx context.Context, sku string) (*models.Product, error)
+	GetAllForExport(ctx context.Context) ([]models.Product, error)
+	UpsertProducts(ctx context.Context, items []ProductUpsert, reference string) error
+}
+
+// ProductUpsert adalah satu produk hasil import. Stock, Categories dan Images bernilai nil berarti
+// tidak diubah; Images berisi daftar akhir gambar level produk sehingga gambar lama yang tidak ada
+// di daftar ikut dihapus.
+type ProductUpsert struct {
+	IsNew      bool
+	Product    *models.Product
+	Stock      *int
+	Categories []models.Category
+	Images     []models.ProductImage
 }
 
 type productRepository struct {
-	db *gorm.DB
+	db         *gorm.DB
+	store      storage.Storage
+	promotions PromotionRepository
+}
+
+func NewProductRepository(db *gorm.DB, store storage.Storage) ProductRepositoryImpl {
+	return &productRepository{db: db, store: store, promotions: NewPromotionRepository(db)}
 }
 
-func NewProductRepository(db *gorm.DB) ProductRepositoryImpl {
-	return &productRepository{db}
+// applyPromotions memasang promosi yang sedang berjalan ke produk hasil baca katalog. Kegagalan hanya
+// dicatat supaya katalog tetap tampil dengan harga tanpa promosi.
+func (p *productRepository) applyPromotions(ctx context.Context, products ...*models.Product) {
+	if err := p.promotions.ApplyToProducts(ctx, time.Now(), products...); err != nil {
+		log.Printf("ProductRepository.applyPromotions: Error applying promotions: %v", err)
+	}
+}
+
+func (p *productRepository) applyPromotionsToList(ctx context.Context, products []models.Product) {
+	refs := make([]*models.Product, len(products))
+	for i := range products {
+		refs[i] = &products[i]
+	}
+	p.applyPromotions(ctx, refs...)
 }
 
 func (p *productRepository) GetProducts(ctx context.Context) ([]models.Product, error) {
@@ -52,6 +85,7 @@ func (p *productRepository) GetProducts(ctx context.Context) ([]models.Product,
 		log.Printf("ProductRepository.GetProducts: Error getting products: %v", err)
 		return nil, err
 	}
+	p.applyPromotionsToList(ctx, products)
 	return products, nil
 }
 
@@ -61,6 +95,11 @@ func (p *productRepository) GetByID(ctx context.Context, id string) (*models.Pro
 		Model(&models.Product{}).
 		Preload("Categories").
 		Preload("ProductImages").
+		Preload("Options", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Options.Values", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Variants.OptionValues").
+		Preload("Variants.Images").
 		Where("id = ?", id).
 		First(&product).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -69,6 +108,7 @@ func (p *productRepository) GetByID(ctx context.Context, id string) (*models.Pro
 		}
 		return nil, err
 	}
+	p.applyPromotions(ctx, &product)
 	return &product, nil
 }
 
@@ -78,6 +118,11 @@ func (p *productRepository) GetBySlug(ctx context.Context, slug string) (*models
 		Model(&models.Product{}).
 		Preload("Categories").
 		Preload("ProductImages").
+		Preload("Options", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Options.Values", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Variants.OptionValues").
+		Preload("Variants.Images").
 		Where("slug = ?", slug).
 		First(&product).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -87,6 +132,7 @@ func (p *productRepository) GetBySlug(ctx context.Context, slug string) (*models
 
 		return nil, err
 	}
+	p.applyPromotions(ctx, &product)
 	return &product, nil
 }
 
@@ -101,8 +147,10 @@ func (p *productRepository) GetByCategorySlug(ctx context.Context, slug string)
 		Find(&products).Error
 	if err != nil {
 		log.Printf("ProductRepository.GetByCategorySlug: Error getting products by category slug %s: %v", slug, err)
+		return products, err
 	}
-	return products, err
+	p.applyPromotionsToList(ctx, products)
+	return products, nil
 }
 
 func (p *productRepository) GetPaginated(ctx context.Context, limit, offset int) ([]models.Product, int64, error) {
@@ -123,41 +171,11 @@ func (p *productRepository) GetPaginated(ctx context.Context, limit, offset int)
 		Find(&products).Error
 	if err != nil {
 		log.Printf("ProductRepository.GetPaginated: Error getting paginated products: %v", err)
+		return products, total, err
 	}
 
-	return products, total, err
-}
-
-func (p *productRepository) GetByCategorySlugPaginated(ctx context.Context, slug string, limit, offset int) ([]models.Product, int64, error) {
-	var products []models.Product
-	var total int64
-
-	err := p.db.WithContext(ctx).
-		Joins("JOIN product_categories pc ON pc.product_id = products.id").
-		Joins("JOIN categories c ON c.id = pc.category_id").
-		Where("c.slug = ?", slug).
-		Model(&models.Product{}).
-		Count(&total).Error
-	if err != nil {
-		log.Printf("ProductRepository.GetByCategorySlugPaginated: Error counting products by category slug %s: %v", slug, err)
-		return nil, 0, err
-	}
-
-	err = p.db.WithContext(ctx).
-		Joins("JOIN product_categories pc ON pc.product_id = products.id").
-		Joins("JOIN categories c ON c.id = pc.category_id").
-		Where("c.slug = ?", slug).
-		Preload("Categories").
-		Preload("ProductImages").
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&products).Error
-	if err != nil {
-		log.Printf("ProductRepository.GetByCategorySlugPaginated: Error getting paginated products by category slug %s: %v", slug, err)
-	}
-
-	return products, total, err
+	p.applyPromotionsToList(ctx, products)
+	return products, total, nil
 }
 
 func (p *productRepository) GetFeaturedProducts(ctx context.Context, limit int) ([]models.Product, error) {
@@ -170,36 +188,135 @@ func (p *productRepository) GetFeaturedProducts(ctx context.Context, limit int)
 		Find(&products).Error
 	if err != nil {
 		log.Printf("ProductRepository.GetFeaturedProducts: Error getting featured products: %v", err)
+		return products, err
 	}
-	return products, err
+	p.applyPromotionsToList(ctx, products)
+	return products, nil
 }
 
-func (p *productRepository) SearchProductsPaginated(ctx context.Context, keyword string, limit, offset int) ([]models.Product, int64, error) {
+// FindProducts adalah satu-satunya jalur listing katalog: kata kunci, kategori, rentang harga, stok,
+// diskon dan urutan digabung lewat scope di product_query.go.
+func (p *productRepository) FindProducts(ctx context.Context, filter other.ProductFilter, limit, offset int) ([]models.Product, int64, error) {
 	var products []models.Product
 	var total int64
-	searchKeyword := "%" + strings.ToLower(keyword) + "%"
 
 	if err := p.db.WithContext(ctx).
 		Model(&models.Product{}).
-		Where("LOWER(name) LIKE ?", searchKeyword).
+		Scopes(productFilterScopes(filter, "")...).
 		Count(&total).Error; err != nil {
-		log.Printf("ProductRepository.SearchProductsPaginated: Error counting search results for keyword '%s': %v", keyword, err)
+		log.Printf("ProductRepository.FindProducts: Error counting products for filter %+v: %v", filter, err)
 		return nil, 0, err
 	}
+	if total == 0 {
+		return products, 0, nil
+	}
 
 	err := p.db.WithContext(ctx).
+		Scopes(productFilterScopes(filter, "")...).
+		Scopes(productSortScope(filter)).
 		Preload("ProductImages").
 		Preload("Categories").
-		Where("LOWER(name) LIKE ?", searchKeyword).
-		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&products).Error
 	if err != nil {
-		log.Printf("ProductRepository.SearchProductsPaginated: Error getting paginated search results for keyword '%s': %v", keyword, err)
+		log.Printf("ProductRepository.FindProducts: Error getting products for filter %+v: %v", filter, err)
+		return products, total, err
+	}
+
+	p.applyPromotionsToList(ctx, products)
+	return products, total, nil
+}
+
+// GetProductFacets menghitung jumlah produk untuk setiap nilai filter di sidebar katalog.
+func (p *productRepository) GetProductFacets(ctx context.Context, filter other.ProductFilter) (*other.ProductFacets, error) {
+	facets := &other.ProductFacets{CategoryCounts: make(map[string]int64)}
+	base := func(skip string) *gorm.DB {
+		return p.db.WithContext(ctx).Model(&models.Product{}).Scopes(productFilterScopes(filter, skip)...)
+	}
+
+	var categoryRows []struct {
+		Slug  string
+		Total int64
+	}
+	if err := base(productFacetCategory).
+		Joins("JOIN product_categories pcf ON pcf.product_id = products.id").
+		Joins("JOIN categories cf ON cf.id = pcf.category_id").
+		Select("cf.slug AS slug, COUNT(DISTINCT products.id) AS total").
+		Group("cf.slug").
+		Scan(&categoryRows).Error; err != nil {
+		log.Printf("ProductRepository.GetProductFacets: Error counting category facets: %v", err)
+		return nil, fmt.Errorf("gagal menghitung facet kategori: %w", err)
+	}
+	for _, row := range categoryRows {
+		facets.CategoryCounts[row.Slug] = row.Total
 	}
 
-	return products, total, err
+	if err := base(productFacetInStock).Scopes(productInStockScope).Count(&facets.InStockCount).Error; err != nil {
+		log.Printf("ProductRepository.GetProductFacets: Error counting in-stock facet: %v", err)
+		return nil, fmt.Errorf("gagal menghitung facet stok: %w", err)
+	}
+
+	if err := base(productFacetDiscounted).Scopes(productDiscountedScope).Count(&facets.DiscountedCount).Error; err != nil {
+		log.Printf("ProductRepository.GetProductFacets: Error counting discounted facet: %v", err)
+		return nil, fmt.Errorf("gagal menghitung facet diskon: %w", err)
+	}
+
+	facets.RatingCounts = make(map[int]int64, len(other.ProductRatingThresholds))
+	for _, threshold := range other.ProductRatingThresholds {
+		var count int64
+		if err := base(productFacetRating).Scopes(productRatingScope(threshold)).Count(&count).Error; err != nil {
+			log.Printf("ProductRepository.GetProductFacets: Error counting rating facet %d: %v", threshold, err)
+			return nil, fmt.Errorf("gagal menghitung facet rating: %w", err)
+		}
+		facets.RatingCounts[threshold] = count
+	}
+
+	var bounds struct {
+		MinPrice float64
+		MaxPrice float64
+	}
+	price := productEffectivePrice(time.Now())
+	if err := base(productFacetPrice).
+		Select("COALESCE(MIN(?), 0) AS min_price, COALESCE(MAX(?), 0) AS max_price", price, price).
+		Scan(&bounds).Error; err != nil {
+		log.Printf("ProductRepository.GetProductFacets: Error getting price bounds: %v", err)
+		return nil, fmt.Errorf("gagal menghitung rentang harga: %w", err)
+	}
+	facets.MinPrice = int64(math.Floor(bounds.MinPrice))
+	facets.MaxPrice = int64(math.Ceil(bounds.MaxPrice))
+
+	return facets, nil
+}
+
+// buildBooleanSearchQuery mengubah input bebas menjadi query BOOLEAN MODE: operator dibuang dan
+// setiap kata dijadikan prefix match (kata*), sehingga "kuc" tetap menemukan "kucing".
+func buildBooleanSearchQuery(keyword string) string {
+	terms := strings.FieldsFunc(strings.ToLower(keyword), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, term := range terms {
+		terms[i] = term + "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+// GetSearchVocabulary mengembalikan nama dan SKU semua produk, dipakai sebagai kamus koreksi typo.
+func (p *productRepository) GetSearchVocabulary(ctx context.Context) ([]string, error) {
+	var rows []struct {
+		Name string
+		Sku  string
+	}
+	if err := p.db.WithContext(ctx).Model(&models.Product{}).Select("name", "sku").Scan(&rows).Error; err != nil {
+		log.Printf("ProductRepository.GetSearchVocabulary: Error getting vocabulary: %v", err)
+		return nil, err
+	}
+
+	vocabulary := make([]string, 0, len(rows)*2)
+	for _, row := range rows {
+		vocabulary = append(vocabulary, row.Name, row.Sku)
+	}
+	return vocabulary, nil
 }
 
 func (p *productRepository) CreateProduct(ctx context.Context, product *models.Product) error {
@@ -208,7 +325,7 @@ func (p *productRepository) CreateProduct(ctx context.Context, product *models.P
 
 	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 
-		if err := tx.Omit("Categories").Create(product).Error; err != nil {
+		if err := tx.Omit("Categories", "Options", "Variants").Create(product).Error; err != nil {
 			log.Printf("ProductRepository.CreateProduct: Error creating product in DB (omitting categories): %v", err)
 			return fmt.Errorf("failed to create product: %w", err)
 		}
@@ -263,7 +380,8 @@ func (r *productRepository) UpdateProduct(ctx context.Context, product *models.P
 		}
 	}()
 
-	if err := tx.Omit("Categories", "ProductImages").Save(product).Error; err != nil {
+	// stok hanya diubah lewat StockMovementRepository
+	if err := tx.Omit("Categories", "ProductImages", "Options", "Variants", "Stock").Save(product).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("gagal memperbarui data produk dasar: %w", err)
 	}
@@ -298,7 +416,7 @@ func (r *productRepository) UpdateProduct(ctx context.Context, product *models.P
 	}
 
 	var existingDBImages []models.ProductImage
-	if err := tx.Where("product_id = ?", product.ID).Find(&existingDBImages).Error; err != nil {
+	if err := tx.Where("product_id = ? AND (variant_id = '' OR variant_id IS NULL)", product.ID).Find(&existingDBImages).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("gagal mengambil gambar produk yang sudah ada dari DB: %w", err)
 	}
@@ -321,21 +439,6 @@ func (r *productRepository) UpdateProduct(ctx context.Context, product *models.P
 		var idsToDelete []string
 		for _, img := range imagesToDeleteFromDB {
 			idsToDelete = append(idsToDelete, img.ID)
-
-			if img.Path != "" {
-
-				fullPath := filepath.Join("static", img.Path)
-				if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-					log.Printf("UpdateProduct [INFO]: Gagal menghapus file fisik %s (tidak ditemukan), mungkin sudah dihapus.", fullPath)
-				} else if err := os.Remove(fullPath); err != nil {
-					log.Printf("UpdateProduct [ERROR]: Gagal menghapus file fisik %s: %v", fullPath, err)
-
-				} else {
-					log.Printf("UpdateProduct [INFO]: Berhasil menghapus file fisik: %s", fullPath)
-				}
-			} else {
-				log.Printf("UpdateProduct [WARNING]: Path gambar kosong untuk gambar ID %s, tidak dapat menghapus file fisik.", img.ID)
-			}
 		}
 
 		if err := tx.Where("id IN (?)", idsToDelete).Delete(&models.ProductImage{}).Error; err != nil {
@@ -386,6 +489,9 @@ func (r *productRepository) UpdateProduct(ctx context.Context, product *models.P
 	}
 	log.Printf("UpdateProduct [INFO]: Transaksi update produk berhasil di-commit untuk ID: %s", product.ID)
 
+	// file baru dihapus setelah commit agar rollback tidak meninggalkan record tanpa file
+	removeImageFiles(ctx, r.store, "UpdateProduct", imagesToDeleteFromDB...)
+
 	return nil
 }
 
@@ -403,17 +509,6 @@ func (r *productRepository) DeleteProduct(ctx context.Context, id string) error
 	var images []models.ProductImage
 	if err := tx.Where("product_id = ?", id).Find(&images).Error; err != nil {
 		log.Printf("DeleteProduct: Gagal mengambil gambar produk untuk ID %s (mungkin sudah dihapus): %v", id, err)
-	} else {
-		for _, img := range images {
-			fullPath := filepath.Join(".", img.Path)
-			if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-				log.Printf("DeleteProduct: File gambar tidak ditemukan, mungkin sudah dihapus: %s", fullPath)
-			} else if err := os.Remove(fullPath); err != nil {
-				log.Printf("DeleteProduct: Gagal menghapus file fisik gambar %s: %v", fullPath, err)
-			} else {
-				log.Printf("DeleteProduct: Berhasil menghapus file fisik gambar: %s", fullPath)
-			}
-		}
 	}
 
 	if err := tx.Where("product_id = ?", id).Delete(&models.ProductCategory{}).Error; err != nil {
@@ -422,6 +517,38 @@ func (r *productRepository) DeleteProduct(ctx context.Context, id string) error
 	}
 	log.Printf("DeleteProduct: Asosiasi kategori dihapus dari tabel join untuk produk ID: %s", id)
 
+	var variantIDs []string
+	if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", id).Pluck("id", &variantIDs).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("gagal mengambil varian produk: %w", err)
+	}
+	if len(variantIDs) > 0 {
+		if err := tx.Exec("DELETE FROM product_variant_option_values WHERE product_variant_id IN (?)", variantIDs).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("gagal menghapus relasi opsi varian: %w", err)
+		}
+		if err := tx.Where("id IN (?)", variantIDs).Delete(&models.ProductVariant{}).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("gagal menghapus varian produk: %w", err)
+		}
+	}
+
+	var optionIDs []string
+	if err := tx.Model(&models.ProductOption{}).Where("product_id = ?", id).Pluck("id", &optionIDs).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("gagal mengambil opsi produk: %w", err)
+	}
+	if len(optionIDs) > 0 {
+		if err := tx.Where("option_id IN (?)", optionIDs).Delete(&models.ProductOptionValue{}).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("gagal menghapus nilai opsi produk: %w", err)
+		}
+		if err := tx.Where("id IN (?)", optionIDs).Delete(&models.ProductOption{}).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("gagal menghapus opsi produk: %w", err)
+		}
+	}
+
 	if err := tx.Where("product_id = ?", id).Delete(&models.ProductImage{}).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("gagal menghapus gambar produk dari database: %w", err)
@@ -439,18 +566,9 @@ func (r *productRepository) DeleteProduct(ctx context.Context, id string) error
 	}
 	log.Printf("DeleteProduct: Transaksi committed untuk produk ID: %s", id)
 
-	return nil
-}
+	removeImageFiles(ctx, r.store, "DeleteProduct", images...)
 
-func (r *productRepository) UpdateProductDiscount(ctx context.Context, productID string, discountPercent decimal.Decimal, discountAmount decimal.Decimal) error {
-	return r.db.WithContext(ctx).
-		Model(&models.Product{}).
-		Where("id = ?", productID).
-		Updates(map[string]interface{}{
-			"discount_percent": discountPercent,
-			"discount_amount":  discountAmount,
-			"updated_at":       time.Now(),
-		}).Error
+	return nil
 }
 
 func (p *productRepository) IsSKUExists(ctx context.Context, sku string) (bool, error) {
@@ -462,25 +580,8 @@ func (p *productRepository) IsSKUExists(ctx context.Context, sku string) (bool,
 	return count > 0, nil
 }
 
-func (r *productRepository) DecrementStock(ctx context.Context, tx *gorm.DB, productID string, quantity int) error {
-
-	result := tx.WithContext(ctx).Model(&models.Product{}).Where("id = ?", productID).
-		Update("stock", gorm.Expr("stock - ?", quantity))
-	if result.Error != nil {
-		return fmt.Errorf("failed to decrement stock for product %s: %w", productID, result.Error)
-	}
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("no product found with ID %s to decrement stock", productID)
-	}
-	return nil
-}
-
 func (r *productRepository) UpdateProductTx(ctx context.Context, tx *gorm.DB, product *models.Product) error {
-	return tx.WithContext(ctx).Save(product).Error
-}
-
-func (r *productRepository) UpdateStock(ctx context.Context, tx *gorm.DB, productID string, newStock int) error {
-	return tx.WithContext(ctx).Model(&models.Product{}).Where("id = ?", productID).Update("stock", newStock).Error
+	return tx.WithContext(ctx).Omit("Categories", "ProductImages", "Options", "Variants", "Stock").Save(product).Error
 }
 
 func (r *productRepository) GetProductCount(ctx context.Context) (int64, error) {
@@ -503,10 +604,187 @@ func (r *productRepository) DeleteProductImage(ctx context.Context, imageID stri
 		return fmt.Errorf("gagal menghapus record gambar dari database: %w", err)
 	}
 
-	fullPath := filepath.Join(".", productImage.Path)
-	if err := os.Remove(fullPath); err != nil {
-		log.Printf("DeleteProductImage: Gagal menghapus file fisik %s: %v", fullPath, err)
+	removeImageFiles(ctx, r.store, "DeleteProductImage", productImage)
+	return nil
+}
+
+// removeImageFiles menghapus master dan semua ukuran gambar dari storage. Kegagalan hanya dicatat
+// karena record database sudah terhapus.
+func removeImageFiles(ctx context.Context, store storage.Storage, caller string, images ...models.ProductImage) {
+	for _, img := range images {
+		for _, path := range img.FilePaths() {
+			if err := storage.DeleteURL(ctx, store, path); err != nil {
+				log.Printf("%s: Gagal menghapus file %s: %v", caller, path, err)
+			}
+		}
+	}
+}
+
+func (r *productRepository) GetProductImagesPaginated(ctx context.Context, limit, offset int) ([]models.ProductImage, error) {
+	var images []models.ProductImage
+	if err := r.db.WithContext(ctx).Order("created_at ASC, id ASC").Limit(limit).Offset(offset).Find(&images).Error; err != nil {
+		log.Printf("ProductRepository.GetProductImagesPaginated: %v", err)
+		return nil, fmt.Errorf("gagal mengambil gambar produk: %w", err)
+	}
+	return images, nil
+}
 
+// UpdateProductImageFiles hanya menyimpan kolom path gambar, dipakai setelah ukuran gambar dibuat ulang.
+func (r *productRepository) UpdateProductImageFiles(ctx context.Context, image *models.ProductImage) error {
+	if err := r.db.WithContext(ctx).Model(&models.ProductImage{}).Where("id = ?", image.ID).
+		Updates(map[string]interface{}{
+			"path":        image.Path,
+			"extra_large": image.ExtraLarge,
+			"large":       image.Large,
+			"medium":      image.Medium,
+			"small":       image.Small,
+		}).Error; err != nil {
+		log.Printf("ProductRepository.UpdateProductImageFiles: Error updating image %s: %v", image.ID, err)
+		return fmt.Errorf("gagal memperbarui path gambar produk: %w", err)
 	}
 	return nil
 }
+
+// GetBySKU mengambil produk beserta kategori, gambar level produk dan varian; nil jika tidak ada.
+func (r *productRepository) GetBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	var product models.Product
+	err := r.db.WithContext(ctx).
+		Preload("Categories").
+		Preload("ProductImages", "variant_id = '' OR variant_id IS NULL").
+		Preload("Variants").
+		Where("sku = ?", sku).
+		First(&product).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Printf("ProductRepository.GetBySKU: Error getting product %s: %v", sku, err)
+		return nil, fmt.Errorf("gagal mengambil produk dengan SKU %s: %w", sku, err)
+	}
+	return &product, nil
+}
+
+func (r *productRepository) GetAllForExport(ctx context.Context) ([]models.Product, error) {
+	var products []models.Product
+	if err := r.db.WithContext(ctx).
+		Preload("Categories").
+		Preload("ProductImages", func(db *gorm.DB) *gorm.DB {
+			return db.Where("variant_id = '' OR variant_id IS NULL").Order("created_at ASC")
+		}).
+		Order("name ASC").
+		Find(&products).Error; err != nil {
+		log.Printf("ProductRepository.GetAllForExport: %v", err)
+		return nil, fmt.Errorf("gagal mengambil produk untuk export: %w", err)
+	}
+	return products, nil
+}
+
+// UpsertProducts menyimpan seluruh hasil import dalam satu transaksi. Perubahan stok dicatat sebagai
+// StockMovement dengan reason import; file gambar yang tidak lagi dipakai dihapus setelah commit.
+func (r *productRepository) UpsertProducts(ctx context.Context, items []ProductUpsert, reference string) error {
+	var removedImages []models.ProductImage
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, item := range items {
+			product := item.Product
+			if item.IsNew {
+				product.Stock = 0
+				product.CreatedAt = now
+				product.UpdatedAt = now
+				if err := tx.Omit("Categories", "ProductImages", "Options", "Variants").Create(product).Error; err != nil {
+					return fmt.Errorf("gagal membuat produk %s: %w", product.Sku, err)
+				}
+			} else {
+				if err := tx.Model(&models.Product{}).Where("id = ?", product.ID).Updates(map[string]interface{}{
+					"name":             product.Name,
+					"description":      product.Description,
+					"price":            product.Price,
+					"weight":           product.Weight,
+					"discount_percent": product.DiscountPercent,
+					"discount_amount":  product.DiscountAmount,
+					"updated_at":       now,
+				}).Error; err != nil {
+					return fmt.Errorf("gagal memperbarui produk %s: %w", product.Sku, err)
+				}
+			}
+
+			if item.Categories != nil {
+				if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductCategory{}).Error; err != nil {
+					return fmt.Errorf("gagal menghapus kategori produk %s: %w", product.Sku, err)
+				}
+				for _, category := range item.Categories {
+					if err := tx.Create(&models.ProductCategory{ProductID: product.ID, CategoryID: category.ID, CreatedAt: now, UpdatedAt: now}).Error; err != nil {
+						return fmt.Errorf("gagal menambahkan kategori %s ke produk %s: %w", category.Slug, product.Sku, err)
+					}
+				}
+			}
+
+			if item.Images != nil {
+				kept := make(map[string]bool, len(item.Images))
+				for _, img := range item.Images {
+					if img.ID != "" {
+						kept[img.ID] = true
+					}
+				}
+				var existing []models.ProductImage
+				if err := tx.Where("product_id = ? AND (variant_id = '' OR variant_id IS NULL)", product.ID).Find(&existing).Error; err != nil {
+					return fmt.Errorf("gagal mengambil gambar produk %s: %w", product.Sku, err)
+				}
+				var removedIDs []string
+				for _, img := range existing {
+					if !kept[img.ID] {
+						removedIDs = append(removedIDs, img.ID)
+						removedImages = append(removedImages, img)
+					}
+				}
+				if len(removedIDs) > 0 {
+					if err := tx.Where("id IN (?)", removedIDs).Delete(&models.ProductImage{}).Error; err != nil {
+						return fmt.Errorf("gagal menghapus gambar lama produk %s: %w", product.Sku, err)
+					}
+				}
+				for _, img := range item.Images {
+					if img.ID != "" {
+						continue
+					}
+					img.ID = uuid.New().String()
+					img.ProductID = product.ID
+					img.CreatedAt = now
+					img.UpdatedAt = now
+					if err := tx.Create(&img).Error; err != nil {
+						return fmt.Errorf("gagal menyimpan gambar produk %s: %w", product.Sku, err)
+					}
+				}
+			}
+
+			if item.Stock != nil {
+				current, err := lockedStock(ctx, tx, product.ID, "")
+				if err != nil {
+					return err
+				}
+				if delta := *item.Stock - current; delta != 0 {
+					note := "Perubahan stok dari import produk"
+					if item.IsNew {
+						note = "Stok awal dari import produk"
+					}
+					if _, err := applyStockAdjustment(ctx, tx, StockAdjustment{
+						ProductID: product.ID,
+						Delta:     delta,
+						Reason:    models.StockMovementImport,
+						Reference: reference,
+						Note:      note,
+					}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ProductRepository.UpsertProducts: %v", err)
+		return err
+	}
+
+	removeImageFiles(ctx, r.store, "UpsertProducts", removedImages...)
+	return nil
+}
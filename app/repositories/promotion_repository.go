@@ -0,0 +1,192 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"gorm.io/gorm"
+)
+
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *models.Promotion) error
+	Update(ctx context.Context, promotion *models.Promotion, categoryIDs, productIDs []string) error
+	Delete(ctx context.Context, id string) error
+	FindByID(ctx context.Context, id string) (*models.Promotion, error)
+	GetAll(ctx context.Context) ([]models.Promotion, error)
+	GetRunning(ctx context.Context, at time.Time) ([]models.Promotion, error)
+	GetScheduledBetween(ctx context.Context, from, to time.Time) ([]models.Promotion, error)
+	ApplyToProducts(ctx context.Context, at time.Time, products ...*models.Product) error
+}
+
+type promotionRepository struct {
+	db *gorm.DB
+}
+
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return &promotionRepository{db}
+}
+
+func (r *promotionRepository) Create(ctx context.Context, promotion *models.Promotion) error {
+	if err := r.db.WithContext(ctx).Omit("Categories.*", "Products.*").Create(promotion).Error; err != nil {
+		log.Printf("PromotionRepository.Create: Error creating promotion %s: %v", promotion.Name, err)
+		return fmt.Errorf("gagal membuat promosi: %w", err)
+	}
+	return nil
+}
+
+// Update menyimpan kolom promosi dan mengganti seluruh kategori dan produk cakupannya.
+func (r *promotionRepository) Update(ctx context.Context, promotion *models.Promotion, categoryIDs, productIDs []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Categories", "Products").Save(promotion).Error; err != nil {
+			log.Printf("PromotionRepository.Update: Error updating promotion %s: %v", promotion.ID, err)
+			return fmt.Errorf("gagal memperbarui promosi: %w", err)
+		}
+
+		categories := make([]models.Category, 0, len(categoryIDs))
+		for _, id := range categoryIDs {
+			categories = append(categories, models.Category{ID: id})
+		}
+		if err := tx.Model(promotion).Association("Categories").Replace(categories); err != nil {
+			return fmt.Errorf("gagal memperbarui kategori promosi: %w", err)
+		}
+
+		products := make([]models.Product, 0, len(productIDs))
+		for _, id := range productIDs {
+			products = append(products, models.Product{ID: id})
+		}
+		if err := tx.Model(promotion).Association("Products").Replace(products); err != nil {
+			return fmt.Errorf("gagal memperbarui produk promosi: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *promotionRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		promotion := &models.Promotion{ID: id}
+		if err := tx.Model(promotion).Association("Categories").Clear(); err != nil {
+			return fmt.Errorf("gagal menghapus kategori promosi: %w", err)
+		}
+		if err := tx.Model(promotion).Association("Products").Clear(); err != nil {
+			return fmt.Errorf("gagal menghapus produk promosi: %w", err)
+		}
+		if err := tx.Where("id = ?", id).Delete(&models.Promotion{}).Error; err != nil {
+			log.Printf("PromotionRepository.Delete: Error deleting promotion %s: %v", id, err)
+			return fmt.Errorf("gagal menghapus promosi: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *promotionRepository) FindByID(ctx context.Context, id string) (*models.Promotion, error) {
+	var promotion models.Promotion
+	err := r.db.WithContext(ctx).Preload("Categories").Preload("Products").Where("id = ?", id).First(&promotion).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil promosi: %w", err)
+	}
+	return &promotion, nil
+}
+
+func (r *promotionRepository) GetAll(ctx context.Context) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	if err := r.db.WithContext(ctx).
+		Preload("Categories").
+		Preload("Products").
+		Order("starts_at DESC").
+		Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil daftar promosi: %w", err)
+	}
+	return promotions, nil
+}
+
+// GetRunning mengembalikan promosi yang berjalan pada waktu at, diurutkan dari prioritas tertinggi.
+// Urutan ini yang dipakai models.PromotionDiscount untuk aturan stacking.
+func (r *promotionRepository) GetRunning(ctx context.Context, at time.Time) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	if err := r.db.WithContext(ctx).
+		Preload("Categories").
+		Preload("Products").
+		Where("is_active = ? AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", true, at, at).
+		Order("priority DESC").
+		Order("created_at ASC").
+		Find(&promotions).Error; err != nil {
+		log.Printf("PromotionRepository.GetRunning: Error getting running promotions: %v", err)
+		return nil, fmt.Errorf("gagal mengambil promosi yang berjalan: %w", err)
+	}
+	return promotions, nil
+}
+
+// GetScheduledBetween mengembalikan promosi aktif yang mulai atau berakhir dalam rentang (from, to].
+func (r *promotionRepository) GetScheduledBetween(ctx context.Context, from, to time.Time) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	if err := r.db.WithContext(ctx).
+		Preload("Categories").
+		Preload("Products").
+		Where("is_active = ?", true).
+		Where("(starts_at > ? AND starts_at <= ?) OR (ends_at > ? AND ends_at <= ?)", from, to, from, to).
+		Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil jadwal promosi: %w", err)
+	}
+	return promotions, nil
+}
+
+// ApplyToProducts mengisi Promotions setiap produk dengan promosi yang berlaku pada waktu at. Baris
+// produk tidak diubah; harga efektif dihitung dari field ini oleh Product.UnitPricing.
+func (r *promotionRepository) ApplyToProducts(ctx context.Context, at time.Time, products ...*models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	running, err := r.GetRunning(ctx, at)
+	if err != nil {
+		return err
+	}
+
+	var categoryIDs map[string][]string
+	for _, promotion := range running {
+		if promotion.Scope == models.PromotionScopeCategories {
+			productIDs := make([]string, 0, len(products))
+			for _, product := range products {
+				productIDs = append(productIDs, product.ID)
+			}
+			if categoryIDs, err = productCategoryIDs(r.db.WithContext(ctx), productIDs); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	for _, product := range products {
+		product.Promotions = nil
+		for _, promotion := range running {
+			if promotion.AppliesTo(product.ID, categoryIDs[product.ID]) {
+				product.Promotions = append(product.Promotions, promotion)
+			}
+		}
+	}
+	return nil
+}
+
+// productCategoryIDs mengembalikan ID kategori setiap produk, dikelompokkan per ID produk.
+func productCategoryIDs(db *gorm.DB, productIDs []string) (map[string][]string, error) {
+	result := make(map[string][]string)
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []models.ProductCategory
+	if err := db.Where("product_id IN ?", productIDs).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil kategori produk: %w", err)
+	}
+	for _, row := range rows {
+		result[row.ProductID] = append(result[row.ProductID], row.CategoryID)
+	}
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type RefundRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, refund *models.Refund) error
+	FindByID(ctx context.Context, tx *gorm.DB, id string) (*models.Refund, error)
+	FindByOrderID(ctx context.Context, orderID string) ([]models.Refund, error)
+	MarkStatus(ctx context.Context, tx *gorm.DB, id, status, gatewayStatus, failureReason string) error
+	SumAmount(ctx context.Context, tx *gorm.DB, orderID string, statuses ...string) (decimal.Decimal, error)
+	RefundedQtyByItem(ctx context.Context, tx *gorm.DB, orderID string) (map[string]int, error)
+}
+
+type refundRepository struct {
+	db *gorm.DB
+}
+
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+func (r *refundRepository) Create(ctx context.Context, tx *gorm.DB, refund *models.Refund) error {
+	if err := tx.WithContext(ctx).Create(refund).Error; err != nil {
+		return fmt.Errorf("gagal menyimpan refund: %w", err)
+	}
+	return nil
+}
+
+func (r *refundRepository) FindByID(ctx context.Context, tx *gorm.DB, id string) (*models.Refund, error) {
+	var refund models.Refund
+	err := tx.WithContext(ctx).Preload("Items").First(&refund, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil refund: %w", err)
+	}
+	return &refund, nil
+}
+
+func (r *refundRepository) FindByOrderID(ctx context.Context, orderID string) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := r.db.WithContext(ctx).
+		Preload("Items").
+		Preload("Actor").
+		Where("order_id = ?", orderID).
+		Order("created_at DESC").
+		Find(&refunds).Error
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil riwayat refund: %w", err)
+	}
+	return refunds, nil
+}
+
+func (r *refundRepository) MarkStatus(ctx context.Context, tx *gorm.DB, id, status, gatewayStatus, failureReason string) error {
+	err := tx.WithContext(ctx).Model(&models.Refund{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":         status,
+		"gateway_status": gatewayStatus,
+		"failure_reason": failureReason,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("gagal memperbarui status refund: %w", err)
+	}
+	return nil
+}
+
+// SumAmount menjumlahkan nilai refund order dengan salah satu status yang diberikan.
+func (r *refundRepository) SumAmount(ctx context.Context, tx *gorm.DB, orderID string, statuses ...string) (decimal.Decimal, error) {
+	var total decimal.Decimal
+	err := tx.WithContext(ctx).Model(&models.Refund{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("order_id = ? AND status IN ?", orderID, statuses).
+		Scan(&total).Error
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("gagal menghitung total refund: %w", err)
+	}
+	return total, nil
+}
+
+// RefundedQtyByItem menghitung jumlah yang sudah atau sedang direfund per item order. Refund yang gagal
+// tidak dihitung.
+func (r *refundRepository) RefundedQtyByItem(ctx context.Context, tx *gorm.DB, orderID string) (map[string]int, error) {
+	var rows []struct {
+		OrderItemID string
+		Qty         int
+	}
+	err := tx.WithContext(ctx).Model(&models.RefundItem{}).
+		Select("refund_items.order_item_id, SUM(refund_items.qty) AS qty").
+		Joins("JOIN refunds ON refunds.id = refund_items.refund_id").
+		Where("refunds.order_id = ? AND refunds.status IN ?", orderID, []string{models.RefundStatusPending, models.RefundStatusSucceeded}).
+		Group("refund_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("gagal menghitung jumlah item yang direfund: %w", err)
+	}
+	refunded := make(map[string]int, len(rows))
+	for _, row := range rows {
+		refunded[row.OrderItemID] = row.Qty
+	}
+	return refunded, nil
+}
@@ -0,0 +1,267 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/models/other"
+	"gorm.io/gorm"
+)
+
+type ReviewRepository interface {
+	Create(ctx context.Context, review *models.Review) error
+	FindByID(ctx context.Context, id string) (*models.Review, error)
+	ExistsForOrderItem(ctx context.Context, orderItemID string) (bool, error)
+	GetReviewedOrderItemIDs(ctx context.Context, orderID string) (map[string]bool, error)
+	FindReviewableItems(ctx context.Context, userID, productID string) ([]models.OrderItem, error)
+	GetApprovedByProductID(ctx context.Context, productID string, rating, limit, offset int) ([]models.Review, int64, error)
+	GetRatingSummary(ctx context.Context, productID string) (*other.RatingSummary, error)
+	GetRatingSummaries(ctx context.Context, productIDs []string) (map[string]other.RatingSummary, error)
+	GetForModeration(ctx context.Context, status string, limit, offset int) ([]models.Review, int64, error)
+	CountByStatus(ctx context.Context) (map[string]int64, error)
+	UpdateStatus(ctx context.Context, id, status string) error
+	Reply(ctx context.Context, id, reply string) error
+	GetPhotosPaginated(ctx context.Context, limit, offset int) ([]models.ReviewPhoto, error)
+	UpdatePhotoPath(ctx context.Context, id, path string) error
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{db}
+}
+
+func (r *reviewRepository) Create(ctx context.Context, review *models.Review) error {
+	if err := r.db.WithContext(ctx).Create(review).Error; err != nil {
+		log.Printf("ReviewRepository.Create: Error creating review for order item %s: %v", review.OrderItemID, err)
+		return fmt.Errorf("gagal menyimpan ulasan: %w", err)
+	}
+	return nil
+}
+
+func (r *reviewRepository) FindByID(ctx context.Context, id string) (*models.Review, error) {
+	var review models.Review
+	err := r.db.WithContext(ctx).
+		Preload("Product").
+		Preload("User").
+		Preload("Photos").
+		First(&review, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		log.Printf("ReviewRepository.FindByID: Error getting review %s: %v", id, err)
+		return nil, fmt.Errorf("gagal mengambil ulasan: %w", err)
+	}
+	return &review, nil
+}
+
+func (r *reviewRepository) ExistsForOrderItem(ctx context.Context, orderItemID string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.Review{}).Where("order_item_id = ?", orderItemID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("gagal memeriksa ulasan order item %s: %w", orderItemID, err)
+	}
+	return count > 0, nil
+}
+
+func (r *reviewRepository) GetReviewedOrderItemIDs(ctx context.Context, orderID string) (map[string]bool, error) {
+	var ids []string
+	if err := r.db.WithContext(ctx).Model(&models.Review{}).Where("order_id = ?", orderID).Pluck("order_item_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil ulasan order %s: %w", orderID, err)
+	}
+
+	result := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// FindReviewableItems mengembalikan item dari order selesai milik user untuk produk ini yang belum diulas.
+func (r *reviewRepository) FindReviewableItems(ctx context.Context, userID, productID string) ([]models.OrderItem, error) {
+	var items []models.OrderItem
+	err := r.db.WithContext(ctx).
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Preload("Order").
+		Where("orders.user_id = ? AND orders.status = ? AND order_items.product_id = ?", userID, models.OrderStatusCompleted, productID).
+		Where("NOT EXISTS (SELECT 1 FROM reviews rv WHERE rv.order_item_id = order_items.id AND rv.deleted_at IS NULL)").
+		Order("orders.order_date DESC").
+		Find(&items).Error
+	if err != nil {
+		log.Printf("ReviewRepository.FindReviewableItems: Error for user %s product %s: %v", userID, productID, err)
+		return nil, fmt.Errorf("gagal mengambil item yang bisa diulas: %w", err)
+	}
+	return items, nil
+}
+
+// GetApprovedByProductID mengambil ulasan yang tampil di halaman produk. rating 0 berarti semua bintang.
+func (r *reviewRepository) GetApprovedByProductID(ctx context.Context, productID string, rating, limit, offset int) ([]models.Review, int64, error) {
+	var (
+		reviews []models.Review
+		total   int64
+	)
+
+	query := r.db.WithContext(ctx).Model(&models.Review{}).
+		Where("product_id = ? AND status = ?", productID, models.ReviewStatusApproved)
+	if rating > 0 {
+		query = query.Where("rating = ?", rating)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung ulasan: %w", err)
+	}
+
+	if err := query.Preload("User").Preload("Photos").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&reviews).Error; err != nil {
+		log.Printf("ReviewRepository.GetApprovedByProductID: Error getting reviews for product %s: %v", productID, err)
+		return nil, 0, fmt.Errorf("gagal mengambil ulasan: %w", err)
+	}
+	return reviews, total, nil
+}
+
+func (r *reviewRepository) GetRatingSummary(ctx context.Context, productID string) (*other.RatingSummary, error) {
+	var rows []struct {
+		Rating int
+		Total  int64
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Review{}).
+		Select("rating, COUNT(*) AS total").
+		Where("product_id = ? AND status = ?", productID, models.ReviewStatusApproved).
+		Group("rating").
+		Scan(&rows).Error; err != nil {
+		log.Printf("ReviewRepository.GetRatingSummary: Error for product %s: %v", productID, err)
+		return nil, fmt.Errorf("gagal menghitung rating: %w", err)
+	}
+
+	summary := &other.RatingSummary{Distribution: make(map[int]int64, 5)}
+	var sum int64
+	for _, row := range rows {
+		summary.Distribution[row.Rating] = row.Total
+		summary.Count += row.Total
+		sum += int64(row.Rating) * row.Total
+	}
+	if summary.Count > 0 {
+		summary.Average = float64(sum) / float64(summary.Count)
+	}
+	return summary, nil
+}
+
+// GetRatingSummaries menghitung rata-rata dan jumlah ulasan untuk banyak produk sekaligus (tanpa distribusi).
+func (r *reviewRepository) GetRatingSummaries(ctx context.Context, productIDs []string) (map[string]other.RatingSummary, error) {
+	result := make(map[string]other.RatingSummary, len(productIDs))
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		ProductID string
+		Average   float64
+		Total     int64
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Review{}).
+		Select("product_id, AVG(rating) AS average, COUNT(*) AS total").
+		Where("product_id IN ? AND status = ?", productIDs, models.ReviewStatusApproved).
+		Group("product_id").
+		Scan(&rows).Error; err != nil {
+		log.Printf("ReviewRepository.GetRatingSummaries: %v", err)
+		return nil, fmt.Errorf("gagal menghitung rating produk: %w", err)
+	}
+
+	for _, row := range rows {
+		result[row.ProductID] = other.RatingSummary{Average: row.Average, Count: row.Total}
+	}
+	return result, nil
+}
+
+// GetForModeration mengambil antrean moderasi. status kosong berarti semua status.
+func (r *reviewRepository) GetForModeration(ctx context.Context, status string, limit, offset int) ([]models.Review, int64, error) {
+	var (
+		reviews []models.Review
+		total   int64
+	)
+
+	query := r.db.WithContext(ctx).Model(&models.Review{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung ulasan: %w", err)
+	}
+
+	if err := query.Preload("Product").Preload("User").Preload("Photos").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&reviews).Error; err != nil {
+		log.Printf("ReviewRepository.GetForModeration: Error getting reviews with status '%s': %v", status, err)
+		return nil, 0, fmt.Errorf("gagal mengambil ulasan: %w", err)
+	}
+	return reviews, total, nil
+}
+
+func (r *reviewRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Total  int64
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Review{}).
+		Select("status, COUNT(*) AS total").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("gagal menghitung status ulasan: %w", err)
+	}
+
+	result := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		result[row.Status] = row.Total
+	}
+	return result, nil
+}
+
+func (r *reviewRepository) UpdateStatus(ctx context.Context, id, status string) error {
+	if err := r.db.WithContext(ctx).Model(&models.Review{}).Where("id = ?", id).Update("status", status).Error; err != nil {
+		log.Printf("ReviewRepository.UpdateStatus: Error updating review %s to %s: %v", id, status, err)
+		return fmt.Errorf("gagal memperbarui status ulasan: %w", err)
+	}
+	return nil
+}
+
+// Reply menyimpan balasan admin. Balasan kosong menghapus balasan sebelumnya.
+func (r *reviewRepository) Reply(ctx context.Context, id, reply string) error {
+	var repliedAt *time.Time
+	if reply != "" {
+		now := time.Now()
+		repliedAt = &now
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Review{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"admin_reply": reply, "replied_at": repliedAt}).Error; err != nil {
+		log.Printf("ReviewRepository.Reply: Error replying review %s: %v", id, err)
+		return fmt.Errorf("gagal menyimpan balasan ulasan: %w", err)
+	}
+	return nil
+}
+
+func (r *reviewRepository) GetPhotosPaginated(ctx context.Context, limit, offset int) ([]models.ReviewPhoto, error) {
+	var photos []models.ReviewPhoto
+	if err := r.db.WithContext(ctx).Order("created_at ASC, id ASC").Limit(limit).Offset(offset).Find(&photos).Error; err != nil {
+		log.Printf("ReviewRepository.GetPhotosPaginated: %v", err)
+		return nil, fmt.Errorf("gagal mengambil foto ulasan: %w", err)
+	}
+	return photos, nil
+}
+
+func (r *reviewRepository) UpdatePhotoPath(ctx context.Context, id, path string) error {
+	if err := r.db.WithContext(ctx).Model(&models.ReviewPhoto{}).Where("id = ?", id).Update("path", path).Error; err != nil {
+		log.Printf("ReviewRepository.UpdatePhotoPath: Error updating photo %s: %v", id, err)
+		return fmt.Errorf("gagal memperbarui path foto ulasan: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/models/other"
+	"gorm.io/gorm"
+)
+
+type SearchQueryRepository interface {
+	Create(ctx context.Context, query *models.SearchQuery) error
+	GetPopular(ctx context.Context, since time.Time, limit int) ([]other.SearchQueryStat, error)
+	GetZeroResult(ctx context.Context, since time.Time, limit int) ([]other.SearchQueryStat, error)
+}
+
+type searchQueryRepository struct {
+	db *gorm.DB
+}
+
+func NewSearchQueryRepository(db *gorm.DB) SearchQueryRepository {
+	return &searchQueryRepository{db}
+}
+
+func (r *searchQueryRepository) Create(ctx context.Context, query *models.SearchQuery) error {
+	if err := r.db.WithContext(ctx).Create(query).Error; err != nil {
+		log.Printf("SearchQueryRepository.Create: Error saving search query '%s': %v", query.Query, err)
+		return fmt.Errorf("gagal menyimpan log pencarian: %w", err)
+	}
+	return nil
+}
+
+func (r *searchQueryRepository) GetPopular(ctx context.Context, since time.Time, limit int) ([]other.SearchQueryStat, error) {
+	return r.aggregate(r.db.WithContext(ctx).Where("created_at >= ?", since), limit)
+}
+
+// GetZeroResult mengembalikan query yang tidak menemukan produk sama sekali, termasuk setelah koreksi typo.
+func (r *searchQueryRepository) GetZeroResult(ctx context.Context, since time.Time, limit int) ([]other.SearchQueryStat, error) {
+	return r.aggregate(r.db.WithContext(ctx).Where("created_at >= ? AND result_count = 0", since), limit)
+}
+
+func (r *searchQueryRepository) aggregate(query *gorm.DB, limit int) ([]other.SearchQueryStat, error) {
+	var stats []other.SearchQueryStat
+	err := query.Model(&models.SearchQuery{}).
+		Select("query, COUNT(*) AS searches, AVG(result_count) AS avg_results, MAX(created_at) AS last_searched_at").
+		Group("query").
+		Order("searches DESC").
+		Order("last_searched_at DESC").
+		Limit(limit).
+		Scan(&stats).Error
+	if err != nil {
+		log.Printf("SearchQueryRepository.aggregate: Error aggregating search queries: %v", err)
+		return nil, fmt.Errorf("gagal mengambil laporan pencarian: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SettingRepository interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string) error
+}
+
+type settingRepository struct {
+	db *gorm.DB
+}
+
+func NewSettingRepository(db *gorm.DB) SettingRepository {
+	return &settingRepository{db}
+}
+
+// Get mengembalikan nilai pengaturan. ok bernilai false jika pengaturan belum pernah disimpan.
+func (r *settingRepository) Get(ctx context.Context, key string) (string, bool, error) {
+	var setting models.Setting
+	err := r.db.WithContext(ctx).Where("`key` = ?", key).First(&setting).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("gagal mengambil pengaturan %s: %w", key, err)
+	}
+	return setting.Value, true, nil
+}
+
+func (r *settingRepository) Set(ctx context.Context, key, value string) error {
+	setting := models.Setting{Key: key, Value: value, UpdatedAt: time.Now()}
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error
+	if err != nil {
+		log.Printf("SettingRepository.Set: Error saving setting %s: %v", key, err)
+		return fmt.Errorf("gagal menyimpan pengaturan %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"gorm.io/gorm"
+)
+
+type ShippingQuoteRepository interface {
+	CreateBatch(ctx context.Context, quotes []models.ShippingQuote) error
+	FindByID(ctx context.Context, id string) (*models.ShippingQuote, error)
+	DeleteExpiredBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type shippingQuoteRepository struct {
+	db *gorm.DB
+}
+
+func NewShippingQuoteRepository(db *gorm.DB) ShippingQuoteRepository {
+	return &shippingQuoteRepository{db}
+}
+
+func (r *shippingQuoteRepository) CreateBatch(ctx context.Context, quotes []models.ShippingQuote) error {
+	if len(quotes) == 0 {
+		return nil
+	}
+	if err := r.db.WithContext(ctx).Create(&quotes).Error; err != nil {
+		log.Printf("ShippingQuoteRepository.CreateBatch: Error creating %d quotes: %v", len(quotes), err)
+		return fmt.Errorf("gagal menyimpan quote ongkir: %w", err)
+	}
+	return nil
+}
+
+func (r *shippingQuoteRepository) FindByID(ctx context.Context, id string) (*models.ShippingQuote, error) {
+	var quote models.ShippingQuote
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&quote).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil quote ongkir: %w", err)
+	}
+	return &quote, nil
+}
+
+// DeleteExpiredBefore menghapus quote yang kedaluwarsa sebelum waktu tertentu.
+func (r *shippingQuoteRepository) DeleteExpiredBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", before).Delete(&models.ShippingQuote{})
+	if result.Error != nil {
+		log.Printf("ShippingQuoteRepository.DeleteExpiredBefore: %v", result.Error)
+		return 0, fmt.Errorf("gagal menghapus quote ongkir kedaluwarsa: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNegativeStock = errors.New("stok tidak boleh kurang dari nol")
+
+type StockAdjustment struct {
+	ProductID string
+	VariantID string
+	Delta     int
+	Reason    string
+	Reference string
+	Note      string
+}
+
+// StockMovementRepository adalah satu-satunya jalur untuk mengubah stok produk maupun varian.
+// Setiap perubahan dicatat sebagai StockMovement pada transaksi yang sama.
+type StockMovementRepository interface {
+	Adjust(ctx context.Context, tx *gorm.DB, adj StockAdjustment) (*models.StockMovement, error)
+	SetStock(ctx context.Context, productID, variantID string, newQty int, reason, reference, note string) (*models.StockMovement, error)
+	GetByProductIDPaginated(ctx context.Context, productID string, limit, offset int) ([]models.StockMovement, int64, error)
+	SumByProductID(ctx context.Context, productID string) (int, error)
+	SumByVariant(ctx context.Context, productID string) (map[string]int, error)
+}
+
+type stockMovementRepository struct {
+	db *gorm.DB
+}
+
+func NewStockMovementRepository(db *gorm.DB) StockMovementRepository {
+	return &stockMovementRepository{db}
+}
+
+func (r *stockMovementRepository) Adjust(ctx context.Context, tx *gorm.DB, adj StockAdjustment) (*models.StockMovement, error) {
+	return applyStockAdjustment(ctx, tx, adj)
+}
+
+// SetStock mengubah stok ke nilai absolut dalam transaksi sendiri. Mengembalikan nil jika stok tidak berubah.
+func (r *stockMovementRepository) SetStock(ctx context.Context, productID, variantID string, newQty int, reason, reference, note string) (*models.StockMovement, error) {
+	var movement *models.StockMovement
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := lockedStock(ctx, tx, productID, variantID)
+		if err != nil {
+			return err
+		}
+		if current == newQty {
+			return nil
+		}
+		movement, err = applyStockAdjustment(ctx, tx, StockAdjustment{
+			ProductID: productID,
+			VariantID: variantID,
+			Delta:     newQty - current,
+			Reason:    reason,
+			Reference: reference,
+			Note:      note,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (r *stockMovementRepository) GetByProductIDPaginated(ctx context.Context, productID string, limit, offset int) ([]models.StockMovement, int64, error) {
+	var (
+		movements []models.StockMovement
+		total     int64
+	)
+
+	query := r.db.WithContext(ctx).Model(&models.StockMovement{}).Where("product_id = ?", productID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung riwayat stok: %w", err)
+	}
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&movements).Error; err != nil {
+		log.Printf("StockMovementRepository.GetByProductIDPaginated: Error getting movements for product %s: %v", productID, err)
+		return nil, 0, fmt.Errorf("gagal mengambil riwayat stok: %w", err)
+	}
+	return movements, total, nil
+}
+
+func (r *stockMovementRepository) SumByProductID(ctx context.Context, productID string) (int, error) {
+	var sum int64
+	if err := r.db.WithContext(ctx).Model(&models.StockMovement{}).
+		Where("product_id = ?", productID).
+		Select("COALESCE(SUM(delta), 0)").
+		Scan(&sum).Error; err != nil {
+		return 0, fmt.Errorf("gagal menjumlahkan pergerakan stok: %w", err)
+	}
+	return int(sum), nil
+}
+
+func (r *stockMovementRepository) SumByVariant(ctx context.Context, productID string) (map[string]int, error) {
+	var rows []struct {
+		VariantID string
+		Total     int
+	}
+	if err := r.db.WithContext(ctx).Model(&models.StockMovement{}).
+		Select("variant_id, COALESCE(SUM(delta), 0) AS total").
+		Where("product_id = ? AND variant_id <> ''", productID).
+		Group("variant_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("gagal menjumlahkan pergerakan stok varian: %w", err)
+	}
+
+	result := make(map[string]int, len(rows))
+	for _, row := range rows {
+		result[row.VariantID] = row.Total
+	}
+	return result, nil
+}
+
+func lockedStock(ctx context.Context, tx *gorm.DB, productID, variantID string) (int, error) {
+	if variantID != "" {
+		var variant models.ProductVariant
+		if err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "stock").
+			Where("id = ? AND product_id = ?", variantID, productID).
+			First(&variant).Error; err != nil {
+			return 0, fmt.Errorf("gagal mengunci varian %s: %w", variantID, err)
+		}
+		return variant.Stock, nil
+	}
+
+	var product models.Product
+	if err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "stock").
+		Where("id = ?", productID).
+		First(&product).Error; err != nil {
+		return 0, fmt.Errorf("gagal mengunci produk %s: %w", productID, err)
+	}
+	return product.Stock, nil
+}
+
+// applyStockAdjustment menambah delta ke stok produk (dan varian bila ada) lalu mencatat pergerakannya.
+// Stok produk bervarian adalah jumlah stok variannya, sehingga delta varian juga berlaku ke produk.
+func applyStockAdjustment(ctx context.Context, tx *gorm.DB, adj StockAdjustment) (*models.StockMovement, error) {
+	productStock, err := lockedStock(ctx, tx, adj.ProductID, "")
+	if err != nil {
+		return nil, err
+	}
+	newProductStock := productStock + adj.Delta
+	if newProductStock < 0 {
+		return nil, fmt.Errorf("%w: produk %s (stok %d, perubahan %d)", ErrNegativeStock, adj.ProductID, productStock, adj.Delta)
+	}
+
+	movement := &models.StockMovement{
+		ProductID:    adj.ProductID,
+		VariantID:    adj.VariantID,
+		Delta:        adj.Delta,
+		ResultingQty: newProductStock,
+		Reason:       adj.Reason,
+		Reference:    adj.Reference,
+		Note:         adj.Note,
+	}
+
+	if adj.VariantID != "" {
+		variantStock, err := lockedStock(ctx, tx, adj.ProductID, adj.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		newVariantStock := variantStock + adj.Delta
+		if newVariantStock < 0 {
+			return nil, fmt.Errorf("%w: varian %s (stok %d, perubahan %d)", ErrNegativeStock, adj.VariantID, variantStock, adj.Delta)
+		}
+		if err := tx.WithContext(ctx).Model(&models.ProductVariant{}).Where("id = ?", adj.VariantID).Update("stock", newVariantStock).Error; err != nil {
+			return nil, fmt.Errorf("gagal memperbarui stok varian %s: %w", adj.VariantID, err)
+		}
+		movement.VariantQty = newVariantStock
+	}
+
+	if err := tx.WithContext(ctx).Model(&models.Product{}).Where("id = ?", adj.ProductID).Update("stock", newProductStock).Error; err != nil {
+		return nil, fmt.Errorf("gagal memperbarui stok produk %s: %w", adj.ProductID, err)
+	}
+
+	if err := tx.WithContext(ctx).Create(movement).Error; err != nil {
+		return nil, fmt.Errorf("gagal mencatat pergerakan stok: %w", err)
+	}
+	return movement, nil
+}
@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrReservationInsufficientStock = errors.New("stok tersedia tidak mencukupi untuk reservasi")
+
+type StockReservationRepository interface {
+	Reserve(ctx context.Context, tx *gorm.DB, orderID, productID, variantID string, qty int, ttl time.Duration) (int, error)
+	GetActiveQty(ctx context.Context, productID, variantID string) (int, error)
+	GetActiveQtyByVariant(ctx context.Context, productID string) (map[string]int, error)
+	FindActiveByOrderID(ctx context.Context, tx *gorm.DB, orderID string) ([]models.StockReservation, error)
+	ReleaseByOrderID(ctx context.Context, tx *gorm.DB, orderID string) (int64, error)
+	ConvertByOrderID(ctx context.Context, tx *gorm.DB, orderID string) (int64, error)
+	ExtendByOrderID(ctx context.Context, tx *gorm.DB, orderID string, expiresAt time.Time) (int64, error)
+	ReleaseExpired(ctx context.Context) (int64, error)
+}
+
+type stockReservationRepository struct {
+	db *gorm.DB
+}
+
+func NewStockReservationRepository(db *gorm.DB) StockReservationRepository {
+	return &stockReservationRepository{db}
+}
+
+func activeReservationScope(db *gorm.DB) *gorm.DB {
+	return db.Where("status = ? AND expires_at > ?", models.StockReservationActive, time.Now())
+}
+
+// Reserve mengunci baris produk (dan varian) lalu membuat hold jika stok dikurangi hold aktif masih cukup.
+// Mengembalikan stok tersedia sebelum reservasi dibuat.
+func (r *stockReservationRepository) Reserve(ctx context.Context, tx *gorm.DB, orderID, productID, variantID string, qty int, ttl time.Duration) (int, error) {
+	var product models.Product
+	if err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "stock").
+		Where("id = ?", productID).
+		First(&product).Error; err != nil {
+		return 0, fmt.Errorf("gagal mengunci produk %s: %w", productID, err)
+	}
+
+	stock := product.Stock
+	if variantID != "" {
+		var variant models.ProductVariant
+		if err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "stock").
+			Where("id = ? AND product_id = ?", variantID, productID).
+			First(&variant).Error; err != nil {
+			return 0, fmt.Errorf("gagal mengunci varian %s: %w", variantID, err)
+		}
+		stock = variant.Stock
+	}
+
+	held, err := r.sumActive(ctx, tx, productID, variantID)
+	if err != nil {
+		return 0, err
+	}
+
+	available := stock - held
+	if available < qty {
+		return available, ErrReservationInsufficientStock
+	}
+
+	reservation := &models.StockReservation{
+		OrderID:   orderID,
+		ProductID: productID,
+		VariantID: variantID,
+		Qty:       qty,
+		Status:    models.StockReservationActive,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := tx.WithContext(ctx).Create(reservation).Error; err != nil {
+		return available, fmt.Errorf("gagal membuat reservasi stok: %w", err)
+	}
+	return available, nil
+}
+
+func (r *stockReservationRepository) sumActive(ctx context.Context, db *gorm.DB, productID, variantID string) (int, error) {
+	var held int64
+	query := db.WithContext(ctx).Model(&models.StockReservation{}).
+		Scopes(activeReservationScope).
+		Where("product_id = ?", productID)
+	if variantID != "" {
+		query = query.Where("variant_id = ?", variantID)
+	}
+	if err := query.Select("COALESCE(SUM(qty), 0)").Scan(&held).Error; err != nil {
+		return 0, fmt.Errorf("gagal menghitung reservasi aktif: %w", err)
+	}
+	return int(held), nil
+}
+
+// GetActiveQty menghitung jumlah unit yang sedang ditahan. variantID kosong berarti seluruh produk.
+func (r *stockReservationRepository) GetActiveQty(ctx context.Context, productID, variantID string) (int, error) {
+	return r.sumActive(ctx, r.db, productID, variantID)
+}
+
+func (r *stockReservationRepository) GetActiveQtyByVariant(ctx context.Context, productID string) (map[string]int, error) {
+	var rows []struct {
+		VariantID string
+		Qty       int
+	}
+	if err := r.db.WithContext(ctx).Model(&models.StockReservation{}).
+		Scopes(activeReservationScope).
+		Select("variant_id, COALESCE(SUM(qty), 0) AS qty").
+		Where("product_id = ? AND variant_id <> ''", productID).
+		Group("variant_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("gagal menghitung reservasi varian: %w", err)
+	}
+
+	result := make(map[string]int, len(rows))
+	for _, row := range rows {
+		result[row.VariantID] = row.Qty
+	}
+	return result, nil
+}
+
+func (r *stockReservationRepository) FindActiveByOrderID(ctx context.Context, tx *gorm.DB, orderID string) ([]models.StockReservation, error) {
+	var reservations []models.StockReservation
+	if err := tx.WithContext(ctx).
+		Where("order_id = ? AND status = ?", orderID, models.StockReservationActive).
+		Find(&reservations).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil reservasi order %s: %w", orderID, err)
+	}
+	return reservations, nil
+}
+
+func (r *stockReservationRepository) ReleaseByOrderID(ctx context.Context, tx *gorm.DB, orderID string) (int64, error) {
+	now := time.Now()
+	result := tx.WithContext(ctx).Model(&models.StockReservation{}).
+		Where("order_id = ? AND status = ?", orderID, models.StockReservationActive).
+		Updates(map[string]interface{}{"status": models.StockReservationReleased, "released_at": now})
+	if result.Error != nil {
+		return 0, fmt.Errorf("gagal melepas reservasi order %s: %w", orderID, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ConvertByOrderID menandai hold sebagai potongan permanen. Pengurangan stok dilakukan oleh pemanggil di transaksi yang sama.
+func (r *stockReservationRepository) ConvertByOrderID(ctx context.Context, tx *gorm.DB, orderID string) (int64, error) {
+	result := tx.WithContext(ctx).Model(&models.StockReservation{}).
+		Where("order_id = ? AND status = ?", orderID, models.StockReservationActive).
+		Update("status", models.StockReservationConverted)
+	if result.Error != nil {
+		return 0, fmt.Errorf("gagal mengonversi reservasi order %s: %w", orderID, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ExtendByOrderID memperpanjang hold order yang masih aktif. Hold yang sudah kedaluwarsa tidak dihidupkan lagi
+// karena stoknya mungkin sudah direservasi order lain.
+func (r *stockReservationRepository) ExtendByOrderID(ctx context.Context, tx *gorm.DB, orderID string, expiresAt time.Time) (int64, error) {
+	result := tx.WithContext(ctx).Model(&models.StockReservation{}).
+		Scopes(activeReservationScope).
+		Where("order_id = ?", orderID).
+		Update("expires_at", expiresAt)
+	if result.Error != nil {
+		return 0, fmt.Errorf("gagal memperpanjang reservasi order %s: %w", orderID, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *stockReservationRepository) ReleaseExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&models.StockReservation{}).
+		Where("status = ? AND expires_at <= ?", models.StockReservationActive, now).
+		Updates(map[string]interface{}{"status": models.StockReservationReleased, "released_at": now})
+	if result.Error != nil {
+		log.Printf("StockReservationRepository.ReleaseExpired: %v", result.Error)
+		return 0, fmt.Errorf("gagal melepas reservasi kedaluwarsa: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
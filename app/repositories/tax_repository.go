@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"gorm.io/gorm"
+)
+
+type TaxRepository interface {
+	Create(ctx context.Context, class *models.TaxClass) error
+	Update(ctx context.Context, class *models.TaxClass) error
+	Delete(ctx context.Context, id string) error
+	FindByID(ctx context.Context, id string) (*models.TaxClass, error)
+	FindByCode(ctx context.Context, code string) (*models.TaxClass, error)
+	GetAll(ctx context.Context) ([]models.TaxClass, error)
+	GetDefault(ctx context.Context) (*models.TaxClass, error)
+	ClassesForProducts(ctx context.Context, productIDs []string) (map[string]models.TaxClass, error)
+}
+
+type taxRepository struct {
+	db *gorm.DB
+}
+
+func NewTaxRepository(db *gorm.DB) TaxRepository {
+	return &taxRepository{db}
+}
+
+func (r *taxRepository) Create(ctx context.Context, class *models.TaxClass) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(class).Error; err != nil {
+			log.Printf("TaxRepository.Create: Error creating tax class %s: %v", class.Code, err)
+			return fmt.Errorf("gagal membuat kelas pajak: %w", err)
+		}
+		return clearOtherDefaults(tx, class)
+	})
+}
+
+func (r *taxRepository) Update(ctx context.Context, class *models.TaxClass) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(class).Error; err != nil {
+			log.Printf("TaxRepository.Update: Error updating tax class %s: %v", class.ID, err)
+			return fmt.Errorf("gagal memperbarui kelas pajak: %w", err)
+		}
+		return clearOtherDefaults(tx, class)
+	})
+}
+
+// clearOtherDefaults memastikan hanya satu kelas pajak yang menjadi default.
+func clearOtherDefaults(tx *gorm.DB, class *models.TaxClass) error {
+	if !class.IsDefault {
+		return nil
+	}
+	if err := tx.Model(&models.TaxClass{}).Where("id <> ? AND is_default = ?", class.ID, true).Update("is_default", false).Error; err != nil {
+		return fmt.Errorf("gagal memperbarui kelas pajak default: %w", err)
+	}
+	return nil
+}
+
+// Delete menghapus kelas pajak dan melepasnya dari produk dan kategori, yang kemudian kembali ke kelas default.
+func (r *taxRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Product{}).Where("tax_class_id = ?", id).Update("tax_class_id", "").Error; err != nil {
+			return fmt.Errorf("gagal melepas kelas pajak dari produk: %w", err)
+		}
+		if err := tx.Model(&models.Category{}).Where("tax_class_id = ?", id).Update("tax_class_id", "").Error; err != nil {
+			return fmt.Errorf("gagal melepas kelas pajak dari kategori: %w", err)
+		}
+		if err := tx.Where("id = ?", id).Delete(&models.TaxClass{}).Error; err != nil {
+			log.Printf("TaxRepository.Delete: Error deleting tax class %s: %v", id, err)
+			return fmt.Errorf("gagal menghapus kelas pajak: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *taxRepository) FindByID(ctx context.Context, id string) (*models.TaxClass, error) {
+	return r.findOne(ctx, "id = ?", id)
+}
+
+func (r *taxRepository) FindByCode(ctx context.Context, code string) (*models.TaxClass, error) {
+	return r.findOne(ctx, "code = ?", code)
+}
+
+func (r *taxRepository) GetDefault(ctx context.Context) (*models.TaxClass, error) {
+	return r.findOne(ctx, "is_default = ?", true)
+}
+
+func (r *taxRepository) findOne(ctx context.Context, query string, args ...interface{}) (*models.TaxClass, error) {
+	var class models.TaxClass
+	err := r.db.WithContext(ctx).Where(query, args...).First(&class).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil kelas pajak: %w", err)
+	}
+	return &class, nil
+}
+
+func (r *taxRepository) GetAll(ctx context.Context) ([]models.TaxClass, error) {
+	var classes []models.TaxClass
+	if err := r.db.WithContext(ctx).Order("is_default DESC").Order("name ASC").Find(&classes).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil daftar kelas pajak: %w", err)
+	}
+	return classes, nil
+}
+
+// ClassesForProducts menentukan kelas pajak setiap produk: kelas milik produk, lalu kelas kategorinya,
+// lalu kelas default. Jika kategori produk punya kelas berbeda, tarif terendah yang dipakai agar barang
+// bebas PPN yang juga masuk kategori umum tidak ikut dikenai pajak. Tanpa kelas default, tarifnya nol.
+func (r *taxRepository) ClassesForProducts(ctx context.Context, productIDs []string) (map[string]models.TaxClass, error) {
+	result := make(map[string]models.TaxClass, len(productIDs))
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+	db := r.db.WithContext(ctx)
+
+	classes, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.TaxClass, len(classes))
+	var fallback models.TaxClass
+	for _, class := range classes {
+		byID[class.ID] = class
+		if class.IsDefault {
+			fallback = class
+		}
+	}
+
+	var products []models.Product
+	if err := db.Unscoped().Select("id", "tax_class_id").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		log.Printf("TaxRepository.ClassesForProducts: Error getting product tax classes: %v", err)
+		return nil, fmt.Errorf("gagal mengambil kelas pajak produk: %w", err)
+	}
+
+	var categoryRows []struct {
+		ProductID  string
+		TaxClassID string
+	}
+	if err := db.Table("product_categories").
+		Select("product_categories.product_id, categories.tax_class_id").
+		Joins("JOIN categories ON categories.id = product_categories.category_id").
+		Where("product_categories.product_id IN ? AND categories.tax_class_id <> ''", productIDs).
+		Scan(&categoryRows).Error; err != nil {
+		log.Printf("TaxRepository.ClassesForProducts: Error getting category tax classes: %v", err)
+		return nil, fmt.Errorf("gagal mengambil kelas pajak kategori: %w", err)
+	}
+	categoryClasses := make(map[string][]models.TaxClass)
+	for _, row := range categoryRows {
+		if class, ok := byID[row.TaxClassID]; ok {
+			categoryClasses[row.ProductID] = append(categoryClasses[row.ProductID], class)
+		}
+	}
+
+	for _, id := range productIDs {
+		result[id] = fallback
+	}
+	for _, product := range products {
+		if class, ok := byID[product.TaxClassID]; ok {
+			result[product.ID] = class
+			continue
+		}
+		candidates := categoryClasses[product.ID]
+		if len(candidates) == 0 {
+			continue
+		}
+		lowest := candidates[0]
+		for _, class := range candidates[1:] {
+			if class.Rate.LessThan(lowest.Rate) {
+				lowest = class
+			}
+		}
+		result[product.ID] = lowest
+	}
+	return result, nil
+}
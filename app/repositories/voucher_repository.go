@@ -0,0 +1,246 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrVoucherUsageLimitReached = errors.New("kuota pemakaian voucher sudah habis")
+	ErrVoucherUserLimitReached  = errors.New("batas pemakaian voucher untuk akun ini sudah tercapai")
+)
+
+// VoucherUsage adalah ringkasan pemakaian satu voucher untuk laporan admin.
+type VoucherUsage struct {
+	VoucherID     string
+	ActiveCount   int64
+	ReleasedCount int64
+	TotalDiscount decimal.Decimal
+}
+
+type VoucherRepository interface {
+	Create(ctx context.Context, voucher *models.Voucher) error
+	Update(ctx context.Context, voucher *models.Voucher, categoryIDs, productIDs []string) error
+	Delete(ctx context.Context, id string) error
+	FindByID(ctx context.Context, id string) (*models.Voucher, error)
+	FindByCode(ctx context.Context, code string) (*models.Voucher, error)
+	GetAll(ctx context.Context) ([]models.Voucher, error)
+	CategoryIDsByProductIDs(ctx context.Context, productIDs []string) (map[string][]string, error)
+	CountActiveRedemptions(ctx context.Context, voucherID, userID string) (total int64, byUser int64, err error)
+	Redeem(ctx context.Context, tx *gorm.DB, redemption *models.VoucherRedemption, usageLimit, perUserLimit int) error
+	ReleaseByOrderID(ctx context.Context, tx *gorm.DB, orderID string) (int64, error)
+	GetUsageSummaries(ctx context.Context) (map[string]VoucherUsage, error)
+	GetRedemptionsByVoucherID(ctx context.Context, voucherID string) ([]models.VoucherRedemption, error)
+}
+
+type voucherRepository struct {
+	db *gorm.DB
+}
+
+func NewVoucherRepository(db *gorm.DB) VoucherRepository {
+	return &voucherRepository{db}
+}
+
+func (r *voucherRepository) Create(ctx context.Context, voucher *models.Voucher) error {
+	if err := r.db.WithContext(ctx).Omit("Categories.*", "Products.*").Create(voucher).Error; err != nil {
+		log.Printf("VoucherRepository.Create: Error creating voucher %s: %v", voucher.Code, err)
+		return fmt.Errorf("gagal membuat voucher: %w", err)
+	}
+	return nil
+}
+
+// Update menyimpan kolom voucher dan mengganti seluruh batasan kategori dan produknya.
+func (r *voucherRepository) Update(ctx context.Context, voucher *models.Voucher, categoryIDs, productIDs []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Categories", "Products").Save(voucher).Error; err != nil {
+			log.Printf("VoucherRepository.Update: Error updating voucher %s: %v", voucher.ID, err)
+			return fmt.Errorf("gagal memperbarui voucher: %w", err)
+		}
+
+		categories := make([]models.Category, 0, len(categoryIDs))
+		for _, id := range categoryIDs {
+			categories = append(categories, models.Category{ID: id})
+		}
+		if err := tx.Model(voucher).Association("Categories").Replace(categories); err != nil {
+			return fmt.Errorf("gagal memperbarui kategori voucher: %w", err)
+		}
+
+		products := make([]models.Product, 0, len(productIDs))
+		for _, id := range productIDs {
+			products = append(products, models.Product{ID: id})
+		}
+		if err := tx.Model(voucher).Association("Products").Replace(products); err != nil {
+			return fmt.Errorf("gagal memperbarui produk voucher: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *voucherRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		voucher := &models.Voucher{ID: id}
+		if err := tx.Model(voucher).Association("Categories").Clear(); err != nil {
+			return fmt.Errorf("gagal menghapus kategori voucher: %w", err)
+		}
+		if err := tx.Model(voucher).Association("Products").Clear(); err != nil {
+			return fmt.Errorf("gagal menghapus produk voucher: %w", err)
+		}
+		if err := tx.Where("id = ?", id).Delete(&models.Voucher{}).Error; err != nil {
+			log.Printf("VoucherRepository.Delete: Error deleting voucher %s: %v", id, err)
+			return fmt.Errorf("gagal menghapus voucher: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *voucherRepository) FindByID(ctx context.Context, id string) (*models.Voucher, error) {
+	var voucher models.Voucher
+	err := r.db.WithContext(ctx).Preload("Categories").Preload("Products").Where("id = ?", id).First(&voucher).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil voucher: %w", err)
+	}
+	return &voucher, nil
+}
+
+func (r *voucherRepository) FindByCode(ctx context.Context, code string) (*models.Voucher, error) {
+	var voucher models.Voucher
+	err := r.db.WithContext(ctx).Preload("Categories").Preload("Products").Where("code = ?", code).First(&voucher).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil voucher berdasarkan kode: %w", err)
+	}
+	return &voucher, nil
+}
+
+func (r *voucherRepository) GetAll(ctx context.Context) ([]models.Voucher, error) {
+	var vouchers []models.Voucher
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&vouchers).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil daftar voucher: %w", err)
+	}
+	return vouchers, nil
+}
+
+// CategoryIDsByProductIDs mengembalikan ID kategori setiap produk, dikelompokkan per ID produk.
+func (r *voucherRepository) CategoryIDsByProductIDs(ctx context.Context, productIDs []string) (map[string][]string, error) {
+	return productCategoryIDs(r.db.WithContext(ctx), productIDs)
+}
+
+// CountActiveRedemptions menghitung redemption aktif sebuah voucher, total dan untuk satu user.
+func (r *voucherRepository) CountActiveRedemptions(ctx context.Context, voucherID, userID string) (int64, int64, error) {
+	return countActiveRedemptions(r.db.WithContext(ctx), voucherID, userID)
+}
+
+func countActiveRedemptions(db *gorm.DB, voucherID, userID string) (int64, int64, error) {
+	var total, byUser int64
+	if err := db.Model(&models.VoucherRedemption{}).
+		Where("voucher_id = ? AND status = ?", voucherID, models.VoucherRedemptionActive).
+		Count(&total).Error; err != nil {
+		return 0, 0, fmt.Errorf("gagal menghitung pemakaian voucher: %w", err)
+	}
+	if err := db.Model(&models.VoucherRedemption{}).
+		Where("voucher_id = ? AND user_id = ? AND status = ?", voucherID, userID, models.VoucherRedemptionActive).
+		Count(&byUser).Error; err != nil {
+		return 0, 0, fmt.Errorf("gagal menghitung pemakaian voucher user: %w", err)
+	}
+	return total, byUser, nil
+}
+
+// Redeem mengunci baris voucher lalu mencatat redemption jika batas pemakaian total dan per user belum
+// tercapai. Harus dipanggil di dalam transaksi checkout.
+func (r *voucherRepository) Redeem(ctx context.Context, tx *gorm.DB, redemption *models.VoucherRedemption, usageLimit, perUserLimit int) error {
+	var voucher models.Voucher
+	if err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("id = ?", redemption.VoucherID).
+		First(&voucher).Error; err != nil {
+		return fmt.Errorf("gagal mengunci voucher %s: %w", redemption.VoucherID, err)
+	}
+
+	total, byUser, err := countActiveRedemptions(tx.WithContext(ctx), redemption.VoucherID, redemption.UserID)
+	if err != nil {
+		return err
+	}
+	if usageLimit > 0 && total >= int64(usageLimit) {
+		return ErrVoucherUsageLimitReached
+	}
+	if perUserLimit > 0 && byUser >= int64(perUserLimit) {
+		return ErrVoucherUserLimitReached
+	}
+
+	redemption.Status = models.VoucherRedemptionActive
+	if err := tx.WithContext(ctx).Create(redemption).Error; err != nil {
+		log.Printf("VoucherRepository.Redeem: Error creating redemption for order %s: %v", redemption.OrderID, err)
+		return fmt.Errorf("gagal mencatat pemakaian voucher: %w", err)
+	}
+	return nil
+}
+
+// ReleaseByOrderID melepas redemption aktif milik order sehingga kuota voucher kembali tersedia.
+func (r *voucherRepository) ReleaseByOrderID(ctx context.Context, tx *gorm.DB, orderID string) (int64, error) {
+	now := time.Now()
+	result := tx.WithContext(ctx).Model(&models.VoucherRedemption{}).
+		Where("order_id = ? AND status = ?", orderID, models.VoucherRedemptionActive).
+		Updates(map[string]interface{}{
+			"status":      models.VoucherRedemptionReleased,
+			"released_at": &now,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		log.Printf("VoucherRepository.ReleaseByOrderID: Error releasing redemption for order %s: %v", orderID, result.Error)
+		return 0, fmt.Errorf("gagal melepas pemakaian voucher: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *voucherRepository) GetUsageSummaries(ctx context.Context) (map[string]VoucherUsage, error) {
+	var rows []struct {
+		VoucherID     string
+		ActiveCount   int64
+		ReleasedCount int64
+		TotalDiscount decimal.Decimal
+	}
+	err := r.db.WithContext(ctx).Model(&models.VoucherRedemption{}).
+		Select("voucher_id, "+
+			"SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS active_count, "+
+			"SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS released_count, "+
+			"COALESCE(SUM(CASE WHEN status = ? THEN discount_amount ELSE 0 END), 0) AS total_discount",
+			models.VoucherRedemptionActive, models.VoucherRedemptionReleased, models.VoucherRedemptionActive).
+		Group("voucher_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil ringkasan pemakaian voucher: %w", err)
+	}
+
+	result := make(map[string]VoucherUsage, len(rows))
+	for _, row := range rows {
+		result[row.VoucherID] = VoucherUsage(row)
+	}
+	return result, nil
+}
+
+func (r *voucherRepository) GetRedemptionsByVoucherID(ctx context.Context, voucherID string) ([]models.VoucherRedemption, error) {
+	var redemptions []models.VoucherRedemption
+	err := r.db.WithContext(ctx).
+		Preload("User").
+		Preload("Order").
+		Where("voucher_id = ?", voucherID).
+		Order("created_at DESC").
+		Find(&redemptions).Error
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil riwayat pemakaian voucher: %w", err)
+	}
+	return redemptions, nil
+}
@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WishlistRepository interface {
+	Add(ctx context.Context, userID, productID string) error
+	Remove(ctx context.Context, userID, productID string) error
+	FindByUserAndProduct(ctx context.Context, userID, productID string) (*models.WishlistItem, error)
+	GetByUserID(ctx context.Context, userID string) ([]models.WishlistItem, error)
+	GetProductIDsByUser(ctx context.Context, userID string) (map[string]bool, error)
+	SetAlerts(ctx context.Context, userID, productID string, backInStock, priceDrop bool) error
+	GetBackInStockSubscribers(ctx context.Context, productID string) ([]models.WishlistItem, error)
+	GetPriceDropSubscribers(ctx context.Context, productID string) ([]models.WishlistItem, error)
+	GetWatchedProductIDs(ctx context.Context) (map[string]bool, error)
+}
+
+type wishlistRepository struct {
+	db *gorm.DB
+}
+
+func NewWishlistRepository(db *gorm.DB) WishlistRepository {
+	return &wishlistRepository{db}
+}
+
+// Add bersifat idempoten: produk yang sudah ada di wishlist tidak ditambahkan ulang.
+func (r *wishlistRepository) Add(ctx context.Context, userID, productID string) error {
+	item := &models.WishlistItem{UserID: userID, ProductID: productID}
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(item).Error; err != nil {
+		log.Printf("WishlistRepository.Add: Error adding product %s for user %s: %v", productID, userID, err)
+		return fmt.Errorf("gagal menambahkan produk ke wishlist: %w", err)
+	}
+	return nil
+}
+
+func (r *wishlistRepository) Remove(ctx context.Context, userID, productID string) error {
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND product_id = ?", userID, productID).Delete(&models.WishlistItem{}).Error; err != nil {
+		log.Printf("WishlistRepository.Remove: Error removing product %s for user %s: %v", productID, userID, err)
+		return fmt.Errorf("gagal menghapus produk dari wishlist: %w", err)
+	}
+	return nil
+}
+
+func (r *wishlistRepository) FindByUserAndProduct(ctx context.Context, userID, productID string) (*models.WishlistItem, error) {
+	var item models.WishlistItem
+	err := r.db.WithContext(ctx).Where("user_id = ? AND product_id = ?", userID, productID).First(&item).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gagal mengambil wishlist: %w", err)
+	}
+	return &item, nil
+}
+
+// GetByUserID mengambil wishlist user beserta produknya. Produk yang sudah dihapus tidak ikut.
+func (r *wishlistRepository) GetByUserID(ctx context.Context, userID string) ([]models.WishlistItem, error) {
+	var items []models.WishlistItem
+	err := r.db.WithContext(ctx).
+		Joins("JOIN products ON products.id = wishlist_items.product_id AND products.deleted_at IS NULL").
+		Preload("Product.ProductImages").
+		Preload("Product.Variants").
+		Where("wishlist_items.user_id = ?", userID).
+		Order("wishlist_items.created_at DESC").
+		Find(&items).Error
+	if err != nil {
+		log.Printf("WishlistRepository.GetByUserID: Error getting wishlist for user %s: %v", userID, err)
+		return nil, fmt.Errorf("gagal mengambil wishlist: %w", err)
+	}
+	return items, nil
+}
+
+func (r *wishlistRepository) GetProductIDsByUser(ctx context.Context, userID string) (map[string]bool, error) {
+	var ids []string
+	if err := r.db.WithContext(ctx).Model(&models.WishlistItem{}).Where("user_id = ?", userID).Pluck("product_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil wishlist: %w", err)
+	}
+
+	result := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+func (r *wishlistRepository) SetAlerts(ctx context.Context, userID, productID string, backInStock, priceDrop bool) error {
+	if err := r.db.WithContext(ctx).Model(&models.WishlistItem{}).
+		Where("user_id = ? AND product_id = ?", userID, productID).
+		Updates(map[string]interface{}{"notify_back_in_stock": backInStock, "notify_price_drop": priceDrop}).Error; err != nil {
+		log.Printf("WishlistRepository.SetAlerts: Error updating alerts for product %s user %s: %v", productID, userID, err)
+		return fmt.Errorf("gagal menyimpan pengaturan notifikasi: %w", err)
+	}
+	return nil
+}
+
+func (r *wishlistRepository) GetBackInStockSubscribers(ctx context.Context, productID string) ([]models.WishlistItem, error) {
+	return r.getSubscribers(ctx, productID, "notify_back_in_stock")
+}
+
+func (r *wishlistRepository) GetPriceDropSubscribers(ctx context.Context, productID string) ([]models.WishlistItem, error) {
+	return r.getSubscribers(ctx, productID, "notify_price_drop")
+}
+
+func (r *wishlistRepository) GetWatchedProductIDs(ctx context.Context) (map[string]bool, error) {
+	var ids []string
+	if err := r.db.WithContext(ctx).Model(&models.WishlistItem{}).
+		Where("notify_back_in_stock = ? OR notify_price_drop = ?", true, true).
+		Distinct().
+		Pluck("product_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil produk yang dipantau: %w", err)
+	}
+
+	result := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+func (r *wishlistRepository) getSubscribers(ctx context.Context, productID, column string) ([]models.WishlistItem, error) {
+	var items []models.WishlistItem
+	if err := r.db.WithContext(ctx).
+		Preload("User").
+		Where("product_id = ? AND "+column+" = ?", productID, true).
+		Find(&items).Error; err != nil {
+		log.Printf("WishlistRepository.getSubscribers: Error getting %s subscribers for product %s: %v", column, productID, err)
+		return nil, fmt.Errorf("gagal mengambil pelanggan notifikasi: %w", err)
+	}
+	return items, nil
+}
@@ -1,9 +1,11 @@
 package routes
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Rakhulsr/go-ecommerce/app/configs"
 	"github.com/Rakhulsr/go-ecommerce/app/handlers"
@@ -11,15 +13,16 @@ import (
 	"github.com/Rakhulsr/go-ecommerce/app/middlewares"
 	"github.com/Rakhulsr/go-ecommerce/app/repositories"
 	"github.com/Rakhulsr/go-ecommerce/app/services"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/payment"
 	"github.com/Rakhulsr/go-ecommerce/app/utils/renderer"
 	"github.com/Rakhulsr/go-ecommerce/app/utils/sessions"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/storage"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
 )
 
 func NewRouter(db *gorm.DB) *mux.Router {
-	configs.InitMidtransClient()
 	env := configs.LoadEnv()
 
 	router := mux.NewRouter()
@@ -33,7 +36,18 @@ func NewRouter(db *gorm.DB) *mux.Router {
 
 	sessionStore := sessions.NewCookieSessionStore(sessionKeys.AuthKey, sessionKeys.EncKey)
 
-	productRepo := repositories.NewProductRepository(db)
+	paymentProvider, err := payment.New(payment.ConfigFromEnv(env))
+	if err != nil {
+		log.Fatalf("Failed to initialize payment provider: %v", err)
+	}
+
+	store, err := storage.New(context.Background(), storage.ConfigFromEnv(env))
+	if err != nil {
+		log.Fatalf("Failed to initialize upload storage: %v", err)
+	}
+
+	productRepo := repositories.NewProductRepository(db, store)
+	productVariantRepo := repositories.NewProductVariantRepository(db, store)
 	categoryRepo := repositories.NewCategoryRepository(db)
 	cartItemRepo := repositories.NewCartItemRepository(db)
 	cartRepo := repositories.NewCartRepository(db, cartItemRepo)
@@ -44,9 +58,32 @@ func NewRouter(db *gorm.DB) *mux.Router {
 	orderItemRepo := repositories.NewOrderItemRepository(db)
 	orderCustomerRepo := repositories.NewOrderCustomerRepository(db)
 	paymentRepo := repositories.NewPaymentRepository(db)
-
-	cartSvc := services.NewCartService(cartRepo, cartItemRepo, productRepo, db)
+	stockReservationRepo := repositories.NewStockReservationRepository(db)
+	stockMovementRepo := repositories.NewStockMovementRepository(db)
+	searchQueryRepo := repositories.NewSearchQueryRepository(db)
+	reviewRepo := repositories.NewReviewRepository(db)
+	wishlistRepo := repositories.NewWishlistRepository(db)
+	shippingQuoteRepo := repositories.NewShippingQuoteRepository(db)
+	voucherRepo := repositories.NewVoucherRepository(db)
+	promotionRepo := repositories.NewPromotionRepository(db)
+	taxRepo := repositories.NewTaxRepository(db)
+	settingRepo := repositories.NewSettingRepository(db)
+	cartRecoveryRepo := repositories.NewCartRecoveryRepository(db)
+
+	stockReservationSvc := services.NewStockReservationService(stockReservationRepo)
+	stockReservationSvc.StartExpiryWorker(context.Background(), time.Minute)
+
+	voucherSvc := services.NewVoucherService(voucherRepo, db)
+	taxSvc := services.NewTaxService(taxRepo, settingRepo)
+	cartSvc := services.NewCartService(cartRepo, cartItemRepo, productRepo, promotionRepo, stockReservationSvc, voucherSvc, taxSvc, db)
+	cartSvc.StartGuestCartCleanupWorker(context.Background(), configs.GetGuestCartTTL(), time.Hour)
+	productSearchSvc := services.NewProductSearchService(productRepo, searchQueryRepo)
+	reviewSvc := services.NewReviewService(reviewRepo, orderRepo)
+	productImageSvc := services.NewProductImageService(productRepo, store)
 	komerceShippingSvc := services.NewKomerceRajaOngkirClient(env.API_ONGKIR_KEY_KOMERCE)
+	originID, _ := strconv.Atoi(env.API_ONGKIR_ORIGIN)
+	shippingQuoteSvc := services.NewShippingQuoteService(shippingQuoteRepo, komerceShippingSvc, originID)
+	shippingQuoteSvc.StartCleanupWorker(context.Background(), time.Hour)
 
 	emailConfig := services.Config{
 		Host:     env.EmailHost,
@@ -56,20 +93,36 @@ func NewRouter(db *gorm.DB) *mux.Router {
 		From:     env.EmailFrom,
 	}
 	mailer := services.NewMailer(emailConfig)
+	wishlistSvc := services.NewWishlistService(wishlistRepo, productRepo, cartSvc, mailer, env.APP_URL)
+	wishlistSvc.StartAlertWorker(context.Background())
+	wishlistSvc.StartPromotionAlertWorker(context.Background(), promotionRepo, time.Minute)
+	productImportSvc := services.NewProductImportService(productRepo, productVariantRepo, categoryRepo, productImageSvc, wishlistSvc, store)
+	cartRecoverySvc := services.NewCartRecoveryService(cartRecoveryRepo, cartSvc, mailer, env.APP_URL, sessionKeys.AuthKey, configs.GetAbandonedCartConfig())
+	cartRecoverySvc.StartWorker(context.Background(), time.Hour)
 	validate := validator.New()
 
-	checkoutSvc := services.NewCheckoutService(db, cartRepo, cartItemRepo, productRepo, userRepo, addressRepo, orderRepo, orderItemRepo, orderCustomerRepo, paymentRepo)
-	paymentSvc := services.NewPaymentService(orderRepo, paymentRepo, db)
-	originID, _ := strconv.Atoi(env.API_ONGKIR_ORIGIN)
-
-	productHandler := handlers.NewProductHandler(productRepo, categoryRepo, render)
+	checkoutAttemptRepo := repositories.NewCheckoutAttemptRepository(db)
+	paymentNotificationRepo := repositories.NewPaymentNotificationRepository(db)
+	refundRepo := repositories.NewRefundRepository(db)
+	paymentSvc := services.NewPaymentService(orderRepo, paymentRepo, stockReservationRepo, voucherSvc, db, paymentProvider, paymentNotificationRepo, productRepo, stockMovementRepo, cartRepo, cartItemRepo, refundRepo, wishlistSvc)
+	checkoutSvc := services.NewCheckoutService(db, cartRepo, cartItemRepo, productRepo, productVariantRepo, userRepo, addressRepo, orderRepo, orderItemRepo, orderCustomerRepo, paymentRepo, stockReservationRepo, shippingQuoteSvc, voucherSvc, promotionRepo, taxSvc, checkoutAttemptRepo, paymentProvider, paymentSvc)
+	checkoutSvc.StartRecoveryWorker(context.Background(), time.Minute)
+	checkoutSvc.StartExpiryWorker(context.Background(), 5*time.Minute)
+	refundSvc := services.NewRefundService(db, orderRepo, paymentRepo, refundRepo, orderCustomerRepo, productRepo, stockMovementRepo, wishlistSvc, paymentProvider, mailer)
+	paymentProofRepo := repositories.NewPaymentProofRepository(db)
+	manualTransferSvc := services.NewManualTransferService(db, orderRepo, paymentRepo, paymentProofRepo, stockReservationRepo, paymentSvc)
+	manualTransferSvc.StartExpiryWorker(context.Background(), 5*time.Minute)
+
+	productHandler := handlers.NewProductHandler(productRepo, categoryRepo, render, stockReservationSvc, productSearchSvc, reviewRepo, wishlistSvc)
 	homeHandler := handlers.NewHomeHandler(render, categoryRepo, productRepo)
-	komerceCartHandler := handlers.NewKomerceCartHandler(productRepo, cartRepo, render, cartItemRepo, komerceShippingSvc, userRepo, addressRepo, cartSvc, originID)
-	authHandler := handlers.NewAuthHandler(render, userRepo, cartRepo, sessionStore, mailer, validate)
+	komerceCartHandler := handlers.NewKomerceCartHandler(productRepo, cartRepo, render, cartItemRepo, shippingQuoteSvc, userRepo, addressRepo, cartSvc, cartRecoverySvc, sessionStore, originID)
+	authHandler := handlers.NewAuthHandler(render, userRepo, cartRepo, cartSvc, sessionStore, mailer, validate)
 	komerceAddressHandler := handlers.NewKomerceAddressHandler(render, addressRepo, userRepo, komerceShippingSvc, validate)
-	adminHandler := admin.NewAdminHandler(adminRender, validate, productRepo, categoryRepo, sectionRepo, userRepo, cartRepo, cartItemRepo, *cartSvc, orderRepo)
-	komerceCheckoutHandler := handlers.NewKomerceCheckoutHandler(render, validate, checkoutSvc, cartRepo, userRepo, orderRepo, productRepo, db, komerceShippingSvc, addressRepo, sessionStore, *paymentSvc, cartItemRepo)
-	orderHandler := handlers.NewOrderHandler(render, orderRepo, userRepo, paymentRepo)
+	adminHandler := admin.NewAdminHandler(adminRender, validate, productRepo, productVariantRepo, stockMovementRepo, productImageSvc, productImportSvc, searchQueryRepo, reviewRepo, categoryRepo, sectionRepo, userRepo, cartRepo, cartItemRepo, *cartSvc, wishlistSvc, orderRepo, voucherRepo, voucherSvc, promotionRepo, taxRepo, taxSvc, cartRecoveryRepo, paymentNotificationRepo, paymentSvc, refundSvc, paymentProofRepo, manualTransferSvc)
+	komerceCheckoutHandler := handlers.NewKomerceCheckoutHandler(render, validate, checkoutSvc, cartRepo, userRepo, orderRepo, productRepo, productVariantRepo, komerceShippingSvc, addressRepo, sessionStore, *paymentSvc, shippingQuoteSvc, cartSvc, stockReservationSvc)
+	orderHandler := handlers.NewOrderHandler(render, orderRepo, userRepo, paymentRepo, reviewRepo, manualTransferSvc, store)
+	reviewHandler := handlers.NewReviewHandler(render, validate, reviewSvc, store)
+	wishlistHandler := handlers.NewWishlistHandler(render, wishlistSvc)
 
 	// router.PathPrefix("/css/").Handler(http.StripPrefix("/css/", http.FileServer(http.Dir("static/assets/css"))))
 	// router.PathPrefix("/js/").Handler(http.StripPrefix("/js/", http.FileServer(http.Dir("static/assets/js"))))
@@ -79,7 +132,7 @@ func NewRouter(db *gorm.DB) *mux.Router {
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(staticFileDir))))
 
 	router.Use(mux.MiddlewareFunc(middlewares.MethodOverrideMiddleware))
-	router.Use(mux.MiddlewareFunc((middlewares.ContentSecurityPolicyMiddleware)))
+	router.Use(mux.MiddlewareFunc(middlewares.ContentSecurityPolicyMiddleware(storage.Origin(store))))
 	router.Use(mux.MiddlewareFunc(middlewares.AuthAndCartSessionMiddleware(userRepo, cartRepo, sessionStore)))
 	router.Use(mux.MiddlewareFunc(middlewares.CartCountMiddleware(cartRepo)))
 
@@ -93,6 +146,10 @@ func NewRouter(db *gorm.DB) *mux.Router {
 	router.HandleFunc("/carts/add", komerceCartHandler.AddItemCart).Methods("POST")
 	router.HandleFunc("/carts/update", komerceCartHandler.UpdateCartItem).Methods("POST")
 	router.HandleFunc("/carts/delete", komerceCartHandler.DeleteCartItem).Methods("POST", "DELETE")
+	router.HandleFunc("/carts/voucher/apply", komerceCartHandler.ApplyVoucherPost).Methods("POST")
+	router.HandleFunc("/carts/voucher/remove", komerceCartHandler.RemoveVoucherPost).Methods("POST")
+	router.HandleFunc("/carts/recover/{id}", komerceCartHandler.RecoverCart).Methods("GET")
+	router.HandleFunc("/carts/acknowledge", komerceCartHandler.AcknowledgeChangesPost).Methods("POST")
 
 	router.HandleFunc("/login", authHandler.LoginGetHandler).Methods("GET")
 	router.HandleFunc("/login", authHandler.LoginPostHandler).Methods("POST")
@@ -137,13 +194,26 @@ func NewRouter(db *gorm.DB) *mux.Router {
 
 	authenticated.HandleFunc("/orders", orderHandler.OrderListGet).Methods("GET")
 	authenticated.HandleFunc("/orders/{orderCode}", orderHandler.OrderDetailGet).Methods("GET")
-
-	router.HandleFunc("/midtrans-notification", komerceCheckoutHandler.MidtransNotificationPost).Methods("POST")
+	authenticated.HandleFunc("/orders/{orderCode}/payment", orderHandler.PaymentInstructionsGet).Methods("GET")
+	authenticated.HandleFunc("/orders/{orderCode}/payment-status", orderHandler.PaymentStatusGet).Methods("GET")
+	authenticated.HandleFunc("/orders/{orderCode}/payment/proof", orderHandler.PaymentProofPost).Methods("POST")
+	authenticated.HandleFunc("/orders/{orderCode}/items/{itemID}/review", reviewHandler.ReviewFormGet).Methods("GET")
+	authenticated.HandleFunc("/orders/{orderCode}/items/{itemID}/review", reviewHandler.ReviewPost).Methods("POST")
+
+	authenticated.HandleFunc("/wishlist", wishlistHandler.WishlistGet).Methods("GET")
+	authenticated.HandleFunc("/wishlist/add", wishlistHandler.WishlistAddPost).Methods("POST")
+	authenticated.HandleFunc("/wishlist/remove", wishlistHandler.WishlistRemovePost).Methods("POST")
+	authenticated.HandleFunc("/wishlist/alerts", wishlistHandler.WishlistAlertsPost).Methods("POST")
+	authenticated.HandleFunc("/wishlist/move-to-cart", wishlistHandler.WishlistMoveToCartPost).Methods("POST")
+
+	router.HandleFunc(payment.DefaultNotificationPath, komerceCheckoutHandler.MidtransNotificationPost).Methods("POST")
+	if fakeGateway, ok := paymentProvider.(*payment.FakeProvider); ok {
+		router.PathPrefix(payment.FakeGatewayPath).Handler(fakeGateway)
+	}
 
 	adminRouter := router.PathPrefix("/admin").Subrouter()
 	adminRouter.Use(mux.MiddlewareFunc(middlewares.AuthRequiredMiddleware))
 	adminRouter.Use(mux.MiddlewareFunc(middlewares.AdminAuthMiddleware(userRepo)))
-	adminRouter.HandleFunc("/dashboard/apply-discount", adminHandler.ApplyGlobalDiscountPost).Methods("POST")
 
 	adminRouter.HandleFunc("/dashboard", adminHandler.GetDashboard).Methods("GET")
 	adminRouter.HandleFunc("/products", adminHandler.GetProductsPage).Methods("GET")
@@ -152,6 +222,33 @@ func NewRouter(db *gorm.DB) *mux.Router {
 	adminRouter.HandleFunc("/products/edit/{id}", adminHandler.EditProductPage).Methods("GET")
 	adminRouter.HandleFunc("/products/edit/{id}", adminHandler.EditProductPost).Methods("POST", "PUT")
 	adminRouter.HandleFunc("/products/delete/{id}", adminHandler.DeleteProductPost).Methods("POST", "DELETE")
+	adminRouter.HandleFunc("/products/import", adminHandler.ImportProductsPage).Methods("GET")
+	adminRouter.HandleFunc("/products/import", adminHandler.ImportProductsPreviewPost).Methods("POST")
+	adminRouter.HandleFunc("/products/import/apply", adminHandler.ImportProductsApplyPost).Methods("POST")
+	adminRouter.HandleFunc("/products/export", adminHandler.ExportProducts).Methods("GET")
+	adminRouter.HandleFunc("/products/{id}/stock-movements", adminHandler.GetStockMovementsPage).Methods("GET")
+
+	adminRouter.HandleFunc("/vouchers", adminHandler.GetVouchersPage).Methods("GET")
+	adminRouter.HandleFunc("/vouchers/add", adminHandler.AddVoucherPage).Methods("GET")
+	adminRouter.HandleFunc("/vouchers/add", adminHandler.AddVoucherPost).Methods("POST")
+	adminRouter.HandleFunc("/vouchers/edit/{id}", adminHandler.EditVoucherPage).Methods("GET")
+	adminRouter.HandleFunc("/vouchers/edit/{id}", adminHandler.EditVoucherPost).Methods("POST", "PUT")
+	adminRouter.HandleFunc("/vouchers/delete/{id}", adminHandler.DeleteVoucherPost).Methods("POST", "DELETE")
+	adminRouter.HandleFunc("/vouchers/{id}/usage", adminHandler.GetVoucherUsagePage).Methods("GET")
+
+	adminRouter.HandleFunc("/promotions", adminHandler.GetPromotionsPage).Methods("GET")
+	adminRouter.HandleFunc("/promotions/add", adminHandler.AddPromotionPage).Methods("GET")
+	adminRouter.HandleFunc("/promotions/add", adminHandler.AddPromotionPost).Methods("POST")
+	adminRouter.HandleFunc("/promotions/edit/{id}", adminHandler.EditPromotionPage).Methods("GET")
+	adminRouter.HandleFunc("/promotions/edit/{id}", adminHandler.EditPromotionPost).Methods("POST", "PUT")
+	adminRouter.HandleFunc("/promotions/delete/{id}", adminHandler.DeletePromotionPost).Methods("POST", "DELETE")
+	adminRouter.HandleFunc("/taxes", adminHandler.GetTaxClassesPage).Methods("GET")
+	adminRouter.HandleFunc("/taxes/settings", adminHandler.UpdateTaxSettingsPost).Methods("POST")
+	adminRouter.HandleFunc("/taxes/add", adminHandler.AddTaxClassPage).Methods("GET")
+	adminRouter.HandleFunc("/taxes/add", adminHandler.AddTaxClassPost).Methods("POST")
+	adminRouter.HandleFunc("/taxes/edit/{id}", adminHandler.EditTaxClassPage).Methods("GET")
+	adminRouter.HandleFunc("/taxes/edit/{id}", adminHandler.EditTaxClassPost).Methods("POST", "PUT")
+	adminRouter.HandleFunc("/taxes/delete/{id}", adminHandler.DeleteTaxClassPost).Methods("POST", "DELETE")
 
 	adminRouter.HandleFunc("/categories", adminHandler.GetCategoriesPage).Methods("GET")
 	adminRouter.HandleFunc("/categories/add", adminHandler.AddCategoryPage).Methods("GET")
@@ -169,6 +266,20 @@ func NewRouter(db *gorm.DB) *mux.Router {
 	adminRouter.HandleFunc("/products/{product_id}/images/{image_id}", adminHandler.DeleteProductImage).Methods("DELETE")
 
 	adminRouter.HandleFunc("/orders", adminHandler.GetOrdersPage).Methods("GET")
+	adminRouter.HandleFunc("/search-reports", adminHandler.GetSearchReportsPage).Methods("GET")
+	adminRouter.HandleFunc("/abandoned-carts", adminHandler.GetAbandonedCartsPage).Methods("GET")
+	adminRouter.HandleFunc("/reviews", adminHandler.GetReviewsPage).Methods("GET")
+	adminRouter.HandleFunc("/reviews/{id}/approve", adminHandler.ApproveReviewPost).Methods("POST")
+	adminRouter.HandleFunc("/reviews/{id}/hide", adminHandler.HideReviewPost).Methods("POST")
+	adminRouter.HandleFunc("/reviews/{id}/reply", adminHandler.ReplyReviewPost).Methods("POST")
 	adminRouter.HandleFunc("/orders/update-status", adminHandler.UpdateOrderStatusPost).Methods("POST", "PUT")
+	adminRouter.HandleFunc("/orders/{orderCode}", adminHandler.GetOrderDetailPage).Methods("GET")
+	adminRouter.HandleFunc("/orders/{orderCode}/refund", adminHandler.RefundOrderPost).Methods("POST")
+	adminRouter.HandleFunc("/orders/{orderCode}/refunds/{refundID}/retry", adminHandler.RetryRefundPost).Methods("POST")
+	adminRouter.HandleFunc("/payment-notifications", adminHandler.GetPaymentNotificationsPage).Methods("GET")
+	adminRouter.HandleFunc("/payment-notifications/{id}/replay", adminHandler.ReplayPaymentNotificationPost).Methods("POST")
+	adminRouter.HandleFunc("/payment-proofs", adminHandler.GetPaymentProofsPage).Methods("GET")
+	adminRouter.HandleFunc("/payment-proofs/{id}/approve", adminHandler.ApprovePaymentProofPost).Methods("POST")
+	adminRouter.HandleFunc("/payment-proofs/{id}/reject", adminHandler.RejectPaymentProofPost).Methods("POST")
 	return router
 }
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/configs"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// abandonedCartLookback membatasi keranjang lama yang masih dianggap layak diingatkan.
+	abandonedCartLookback = 30 * 24 * time.Hour
+	abandonedCartBatch    = 200
+	// cartRecoveryLinkTTL adalah masa berlaku tautan pemulihan di email.
+	cartRecoveryLinkTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrCartRecoveryInvalidLink = errors.New("tautan pemulihan keranjang tidak valid")
+	ErrCartRecoveryExpiredLink = errors.New("tautan pemulihan keranjang sudah kedaluwarsa")
+	ErrCartRecoveryNotOwner    = errors.New("tautan pemulihan ini milik akun lain")
+)
+
+// CartRecoveryService mendeteksi keranjang user yang ditinggalkan, mengirim email pengingat berisi tautan
+// bertanda tangan untuk memulihkan keranjang, dan mencatat keranjang yang akhirnya menjadi order.
+type CartRecoveryService struct {
+	recoveryRepo repositories.CartRecoveryRepository
+	cartSvc      *CartService
+	mailer       *Mailer
+	appURL       string
+	signingKey   []byte
+	cfg          configs.AbandonedCartConfig
+}
+
+func NewCartRecoveryService(
+	recoveryRepo repositories.CartRecoveryRepository,
+	cartSvc *CartService,
+	mailer *Mailer,
+	appURL string,
+	signingKey []byte,
+	cfg configs.AbandonedCartConfig,
+) *CartRecoveryService {
+	return &CartRecoveryService{
+		recoveryRepo: recoveryRepo,
+		cartSvc:      cartSvc,
+		mailer:       mailer,
+		appURL:       strings.TrimRight(appURL, "/"),
+		signingKey:   signingKey,
+		cfg:          cfg,
+	}
+}
+
+// StartWorker menjalankan ProcessAbandonedCarts setiap interval sampai ctx dibatalkan.
+func (s *CartRecoveryService) StartWorker(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.ProcessAbandonedCarts(ctx); err != nil {
+					log.Printf("CartRecoveryService: Gagal memproses keranjang yang ditinggalkan: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// ProcessAbandonedCarts menandai kejadian yang sudah menjadi order, mencatat keranjang yang baru
+// ditinggalkan, lalu mengirim pengingat yang sudah jatuh tempo.
+func (s *CartRecoveryService) ProcessAbandonedCarts(ctx context.Context) error {
+	if err := s.markRecovered(ctx); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	carts, err := s.recoveryRepo.FindAbandonedCarts(ctx, now.Add(-abandonedCartLookback), now.Add(-s.cfg.AbandonAfter), s.cfg.MaxReminders, abandonedCartBatch)
+	if err != nil {
+		return err
+	}
+
+	for i := range carts {
+		cart := &carts[i]
+		recovery, err := s.trackAbandonedCart(ctx, cart)
+		if err != nil {
+			log.Printf("CartRecoveryService: Gagal mencatat keranjang %s: %v", cart.ID, err)
+			continue
+		}
+		if recovery == nil || !s.reminderDue(recovery, now) {
+			continue
+		}
+		if err := s.sendReminder(ctx, recovery, cart, now); err != nil {
+			log.Printf("CartRecoveryService: Gagal mengirim pengingat keranjang %s ke %s: %v", cart.ID, recovery.Email, err)
+		}
+	}
+	return nil
+}
+
+// markRecovered menandai kejadian terbuka yang pemiliknya sudah membayar order sejak keranjang ditinggalkan,
+// dan menutup kejadian yang sudah melewati abandonedCartLookback tanpa order yang dibayar.
+func (s *CartRecoveryService) markRecovered(ctx context.Context) error {
+	open, err := s.recoveryRepo.GetOpen(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range open {
+		recovery := &open[i]
+		order, err := s.recoveryRepo.FindFirstPaidOrderSince(ctx, recovery.UserID, recovery.AbandonedAt)
+		if err != nil {
+			log.Printf("CartRecoveryService: Gagal memeriksa order untuk keranjang %s: %v", recovery.CartID, err)
+			continue
+		}
+		if order == nil {
+			if time.Since(recovery.AbandonedAt) > abandonedCartLookback {
+				recovery.Status = models.CartRecoveryClosed
+				if err := s.recoveryRepo.Update(ctx, recovery); err != nil {
+					log.Printf("CartRecoveryService: Gagal menutup keranjang %s: %v", recovery.CartID, err)
+				}
+			}
+			continue
+		}
+		recoveredAt := order.CreatedAt
+		recovery.Status = models.CartRecoveryRecovered
+		recovery.RecoveredAt = &recoveredAt
+		recovery.OrderID = order.ID
+		recovery.RecoveredAmount = order.GrandTotal
+		if err := s.recoveryRepo.Update(ctx, recovery); err != nil {
+			log.Printf("CartRecoveryService: Gagal menandai keranjang %s sebagai pulih: %v", recovery.CartID, err)
+		}
+	}
+	return nil
+}
+
+// trackAbandonedCart mengembalikan kejadian terbuka untuk keranjang. Jika keranjang diubah sejak kejadian
+// terakhir dicatat, kejadian lama ditutup dan kejadian baru dibuat dari isi keranjang saat ini. Nil berarti
+// kejadian untuk keranjang ini sudah selesai.
+func (s *CartRecoveryService) trackAbandonedCart(ctx context.Context, cart *models.Cart) (*models.CartRecovery, error) {
+	latest, err := s.recoveryRepo.GetLatestByCartID(ctx, cart.ID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.AbandonedAt.Equal(cart.UpdatedAt) {
+		if latest.Status != models.CartRecoveryOpen {
+			return nil, nil
+		}
+		return latest, nil
+	}
+	if latest != nil && latest.Status == models.CartRecoveryOpen {
+		latest.Status = models.CartRecoveryClosed
+		if err := s.recoveryRepo.Update(ctx, latest); err != nil {
+			return nil, err
+		}
+	}
+
+	recovery := &models.CartRecovery{
+		CartID:      cart.ID,
+		UserID:      cart.UserID,
+		Email:       cart.User.Email,
+		AbandonedAt: cart.UpdatedAt,
+		Status:      models.CartRecoveryOpen,
+	}
+	cartValue := decimal.Zero
+	for _, item := range cart.CartItems {
+		if item.IsUnavailable() {
+			continue
+		}
+		name := item.Product.Name
+		if item.Variant != nil {
+			name = fmt.Sprintf("%s (%s)", name, item.Variant.Name)
+		}
+		recovery.Items = append(recovery.Items, models.CartRecoveryItem{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Name:      name,
+			Qty:       item.Qty,
+			Subtotal:  item.Subtotal,
+		})
+		recovery.TotalItems += item.Qty
+		cartValue = cartValue.Add(item.Subtotal)
+	}
+	recovery.CartValue = cartValue
+	if cart.GrandTotal.IsPositive() {
+		recovery.CartValue = cart.GrandTotal
+	}
+
+	if err := s.recoveryRepo.Create(ctx, recovery); err != nil {
+		return nil, err
+	}
+	return recovery, nil
+}
+
+func (s *CartRecoveryService) reminderDue(recovery *models.CartRecovery, now time.Time) bool {
+	if recovery.Email == "" || recovery.RemindersSent >= s.cfg.MaxReminders {
+		return false
+	}
+	if recovery.LastReminderAt == nil {
+		return true
+	}
+	return now.Sub(*recovery.LastReminderAt) >= s.cfg.ReminderInterval
+}
+
+func (s *CartRecoveryService) sendReminder(ctx context.Context, recovery *models.CartRecovery, cart *models.Cart, now time.Time) error {
+	body := BuildAbandonedCartEmailBody(cart.User.FirstName, recovery.Items, recovery.CartValue, s.RecoveryURL(recovery.ID, now.Add(cartRecoveryLinkTTL)))
+	if err := s.mailer.SendHTMLEmail(recovery.Email, "Keranjang Anda masih menunggu", body); err != nil {
+		return err
+	}
+	recovery.RemindersSent++
+	recovery.LastReminderAt = &now
+	return s.recoveryRepo.Update(ctx, recovery)
+}
+
+// RecoveryURL membuat tautan pemulihan bertanda tangan yang berlaku sampai expiresAt.
+func (s *CartRecoveryService) RecoveryURL(recoveryID string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return fmt.Sprintf("%s/carts/recover/%s?expires=%s&signature=%s", s.appURL, recoveryID, expires, s.sign(recoveryID, expires))
+}
+
+func (s *CartRecoveryService) sign(recoveryID, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(recoveryID + "|" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyLink memeriksa tanda tangan dan masa berlaku tautan pemulihan.
+func (s *CartRecoveryService) VerifyLink(recoveryID, expires, signature string) error {
+	expected := s.sign(recoveryID, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrCartRecoveryInvalidLink
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrCartRecoveryInvalidLink
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrCartRecoveryExpiredLink
+	}
+	return nil
+}
+
+// Restore mengembalikan isi keranjang dari tautan email ke keranjang userID. Nilai kembalian true berarti
+// ada jumlah yang dipotong karena stok.
+func (s *CartRecoveryService) Restore(ctx context.Context, recoveryID, expires, signature, userID string) (bool, error) {
+	if err := s.VerifyLink(recoveryID, expires, signature); err != nil {
+		return false, err
+	}
+	recovery, err := s.recoveryRepo.FindByID(ctx, recoveryID)
+	if err != nil {
+		return false, err
+	}
+	if recovery == nil {
+		return false, ErrCartRecoveryInvalidLink
+	}
+	if recovery.UserID != userID {
+		return false, ErrCartRecoveryNotOwner
+	}
+
+	capped, err := s.cartSvc.RestoreItems(ctx, userID, recovery.Items)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	recovery.RestoredAt = &now
+	if err := s.recoveryRepo.Update(ctx, recovery); err != nil {
+		log.Printf("CartRecoveryService: Gagal mencatat pemulihan keranjang %s: %v", recovery.CartID, err)
+	}
+	return capped, nil
+}
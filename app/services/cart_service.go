@@ -2,24 +2,27 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/Rakhulsr/go-ecommerce/app/configs"
 	"github.com/Rakhulsr/go-ecommerce/app/models"
 	"github.com/Rakhulsr/go-ecommerce/app/repositories"
-	"github.com/Rakhulsr/go-ecommerce/app/utils/calc"
+	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
-const (
-	DefaultTaxPercent = 12.00
-)
-
 type CartService struct {
 	cartRepo     repositories.CartRepositoryImpl
 	cartItemRepo repositories.CartItemRepositoryImpl
 	productRepo  repositories.ProductRepositoryImpl
+	promoRepo    repositories.PromotionRepository
+	stockSvc     *StockReservationService
+	voucherSvc   *VoucherService
+	taxSvc       *TaxService
 	db           *gorm.DB
 }
 
@@ -27,34 +30,57 @@ func NewCartService(
 	cartRepo repositories.CartRepositoryImpl,
 	cartItemRepo repositories.CartItemRepositoryImpl,
 	productRepo repositories.ProductRepositoryImpl,
+	promoRepo repositories.PromotionRepository,
+	stockSvc *StockReservationService,
+	voucherSvc *VoucherService,
+	taxSvc *TaxService,
 	db *gorm.DB,
 ) *CartService {
 	return &CartService{
 		cartRepo:     cartRepo,
 		cartItemRepo: cartItemRepo,
 		productRepo:  productRepo,
+		promoRepo:    promoRepo,
+		stockSvc:     stockSvc,
+		voucherSvc:   voucherSvc,
+		taxSvc:       taxSvc,
 		db:           db,
 	}
 }
 
 func (s *CartService) GetUserCart(ctx context.Context, userID string) (*models.Cart, error) {
-	cart, err := s.cartRepo.GetOrCreateCartByUserID(ctx, "", userID)
-	if err != nil {
-		return nil, fmt.Errorf("gagal mendapatkan atau membuat keranjang pengguna: %w", err)
+	return s.GetCart(ctx, "", userID)
+}
+
+// GetCart mengambil keranjang user beserta itemnya dan menghitung ulang totalnya. Jika userID kosong,
+// yang diambil adalah keranjang tamu cartID; hasilnya nil jika pengunjung belum punya keranjang.
+func (s *CartService) GetCart(ctx context.Context, cartID, userID string) (*models.Cart, error) {
+	var cart *models.Cart
+	var err error
+	if userID != "" {
+		cart, err = s.cartRepo.GetOrCreateCartByUserID(ctx, "", userID)
+		if err != nil {
+			return nil, fmt.Errorf("gagal mendapatkan atau membuat keranjang pengguna: %w", err)
+		}
+	} else {
+		cart, err = s.cartRepo.GetGuestCart(ctx, cartID)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if cart == nil {
 		return nil, nil
 	}
 
-	detailedCart, err := s.cartRepo.GetCartWithItems(ctx, cart.ID)
-	if err != nil {
+	detailedCart, unavailable, err := s.loadCart(ctx, cart.ID)
+	if err != nil || detailedCart == nil {
 
 		return &models.Cart{
 			ID:             cart.ID,
-			UserID:         userID,
+			UserID:         cart.UserID,
 			BaseTotalPrice: decimal.Zero,
 			TaxAmount:      decimal.Zero,
-			TaxPercent:     calc.GetTaxPercent(),
+			TaxPercent:     decimal.Zero,
 			DiscountAmount: decimal.Zero,
 			GrandTotal:     decimal.Zero,
 			TotalWeight:    decimal.Zero,
@@ -64,25 +90,46 @@ func (s *CartService) GetUserCart(ctx context.Context, userID string) (*models.C
 		}, nil
 	}
 
-	filteredCartItems := []models.CartItem{}
+	for _, item := range unavailable {
+		name := item.Name
+		if name == "" {
+			name = "Produk tanpa nama"
+		}
+		detailedCart.Notices = append(detailedCart.Notices, models.CartNotice{
+			Kind:   models.CartNoticeUnavailable,
+			ItemID: item.ID,
+			Name:   name,
+			Qty:    item.Qty,
+		})
+	}
+
 	shouldUpdateCart := false
-	for _, item := range detailedCart.CartItems {
-		if item.Product != nil && item.Product.ID != "" {
+	snapshots := make([]models.CartItem, len(detailedCart.CartItems))
+	copy(snapshots, detailedCart.CartItems)
 
-			filteredCartItems = append(filteredCartItems, item)
-		} else {
-			err := s.cartItemRepo.Delete(ctx, item.ID)
-			if err != nil {
-				log.Printf("GetUserCart: Gagal menghapus CartItem '%s' (produk hilang): %v", item.ID, err)
-			}
+	s.applyPromotions(ctx, detailedCart)
+	s.voucherSvc.RefreshCartVoucher(ctx, detailedCart)
+	s.CalculateCartTotals(ctx, detailedCart)
+
+	for i, item := range detailedCart.CartItems {
+		if item.Name != snapshots[i].Name || !item.AcceptedPrice.Equal(snapshots[i].AcceptedPrice) {
 			shouldUpdateCart = true
 		}
+		if !item.AcceptedPrice.Equal(item.FinalPriceUnit) {
+			detailedCart.Notices = append(detailedCart.Notices, models.CartNotice{
+				Kind:     models.CartNoticePriceChanged,
+				ItemID:   item.ID,
+				Name:     item.Name,
+				OldPrice: item.AcceptedPrice,
+				NewPrice: item.FinalPriceUnit,
+				Qty:      item.Qty,
+			})
+		}
 	}
-	detailedCart.CartItems = filteredCartItems
-
-	s.CalculateCartTotals(detailedCart)
 
-	if shouldUpdateCart || !detailedCart.GrandTotal.Equal(cart.GrandTotal) || detailedCart.TotalItems != cart.TotalItems {
+	if shouldUpdateCart || !detailedCart.GrandTotal.Equal(cart.GrandTotal) || detailedCart.TotalItems != cart.TotalItems ||
+		detailedCart.VoucherCode != cart.VoucherCode || !detailedCart.VoucherDiscount.Equal(cart.VoucherDiscount) ||
+		!detailedCart.TaxAmount.Equal(cart.TaxAmount) || detailedCart.TaxIncluded != cart.TaxIncluded {
 
 		if err := s.cartRepo.UpdateCart(ctx, detailedCart); err != nil {
 			log.Printf("GetUserCart: Gagal memperbarui cart %s di DB setelah kalkulasi ulang: %v", detailedCart.ID, err)
@@ -90,31 +137,60 @@ func (s *CartService) GetUserCart(ctx context.Context, userID string) (*models.C
 		}
 	}
 
+	// stok tersedia hanya untuk tampilan dan notifikasi, dihitung setelah keranjang disimpan
+	for i := range detailedCart.CartItems {
+		item := &detailedCart.CartItems[i]
+		if err := s.applyItemAvailableStock(ctx, item); err != nil {
+			log.Printf("GetUserCart: Gagal menghitung stok tersedia untuk item '%s': %v", item.ID, err)
+			continue
+		}
+		if available := item.AvailableStock(); available < item.Qty {
+			detailedCart.Notices = append(detailedCart.Notices, models.CartNotice{
+				Kind:      models.CartNoticeLowStock,
+				ItemID:    item.ID,
+				Name:      item.Name,
+				Qty:       item.Qty,
+				Available: max(available, 0),
+			})
+		}
+	}
+
 	return detailedCart, nil
 }
 
-func (s *CartService) AddItemToCart(ctx context.Context, cartID, userID, productID string, qty int) error {
+func (s *CartService) AddItemToCart(ctx context.Context, cartID, userID, productID, variantID string, qty int) error {
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		product, err := s.productRepo.GetByID(ctx, productID)
 		if err != nil || product == nil {
 			return fmt.Errorf("product not found or error getting product: %w", err)
 		}
 
-		if product.Stock < qty {
-			return fmt.Errorf("not enough stock for product '%s'. Available: %d, Requested: %d", product.Name, product.Stock, qty)
+		variant, err := resolveVariant(product, variantID)
+		if err != nil {
+			return err
 		}
 
-		cart, err := s.cartRepo.GetCartByUserID(ctx, userID)
+		availableStock, err := s.stockSvc.AvailableStock(ctx, product, variant)
+		if err != nil {
+			return fmt.Errorf("failed to get available stock: %w", err)
+		}
+
+		if availableStock < qty {
+			return fmt.Errorf("not enough stock for product '%s'. Available: %d, Requested: %d", itemDisplayName(product, variant), availableStock, qty)
+		}
+
+		cart, err := s.findCart(ctx, cartID, userID)
 		if err != nil {
 			return fmt.Errorf("failed to get user cart: %w", err)
 		}
 
 		if cart == nil {
 			cart = &models.Cart{
+				ID:             uuid.New().String(),
 				UserID:         userID,
 				BaseTotalPrice: decimal.Zero,
 				TaxAmount:      decimal.Zero,
-				TaxPercent:     calc.GetTaxPercent(),
+				TaxPercent:     decimal.Zero,
 				DiscountAmount: decimal.Zero,
 				GrandTotal:     decimal.Zero,
 				TotalWeight:    decimal.Zero,
@@ -127,20 +203,9 @@ func (s *CartService) AddItemToCart(ctx context.Context, cartID, userID, product
 			}
 		}
 
-		finalPriceUnit := product.Price
-		discountAmountPerUnit := decimal.Zero
-		if product.DiscountPercent.GreaterThan(decimal.Zero) {
-			discountAmountPerUnit = product.Price.Mul(product.DiscountPercent.Div(decimal.NewFromInt(100)))
-			finalPriceUnit = product.Price.Sub(discountAmountPerUnit)
-		} else if product.DiscountAmount.GreaterThan(decimal.Zero) {
-			discountAmountPerUnit = product.DiscountAmount
-			finalPriceUnit = product.Price.Sub(discountAmountPerUnit)
-		}
-		if finalPriceUnit.LessThan(decimal.Zero) {
-			finalPriceUnit = decimal.Zero
-		}
+		unitPrice, discountAmountPerUnit, finalPriceUnit := product.UnitPricing(variant)
 
-		cartItem, err := s.cartItemRepo.GetByCartIDAndProductID(ctx, cart.ID, productID)
+		cartItem, err := s.cartItemRepo.GetByCartIDProductAndVariant(ctx, nil, cart.ID, productID, variantID)
 		if err != nil {
 			return fmt.Errorf("failed to get cart item: %w", err)
 		}
@@ -149,34 +214,35 @@ func (s *CartService) AddItemToCart(ctx context.Context, cartID, userID, product
 			cartItem = &models.CartItem{
 				CartID:          cart.ID,
 				ProductID:       productID,
+				VariantID:       variantID,
 				Qty:             qty,
-				Price:           product.Price,
+				Price:           unitPrice,
 				DiscountPercent: product.DiscountPercent,
 				DiscountAmount:  discountAmountPerUnit,
 				FinalPriceUnit:  finalPriceUnit,
 				Subtotal:        finalPriceUnit.Mul(decimal.NewFromInt(int64(qty))),
 			}
-			if err := s.cartItemRepo.Add(ctx, cartItem); err != nil {
+			if err := s.cartItemRepo.Add(ctx, nil, cartItem); err != nil {
 				return fmt.Errorf("failed to create cart item: %w", err)
 			}
 		} else {
 			newQty := cartItem.Qty + qty
-			if product.Stock < newQty {
-				return fmt.Errorf("not enough stock to add more for product '%s'. Max allowed: %d", product.Name, product.Stock)
+			if availableStock < newQty {
+				return fmt.Errorf("not enough stock to add more for product '%s'. Max allowed: %d", itemDisplayName(product, variant), availableStock)
 			}
 			cartItem.Qty = newQty
 
-			cartItem.Price = product.Price
+			cartItem.Price = unitPrice
 			cartItem.DiscountPercent = product.DiscountPercent
 			cartItem.DiscountAmount = discountAmountPerUnit
 			cartItem.FinalPriceUnit = finalPriceUnit
 			cartItem.Subtotal = finalPriceUnit.Mul(decimal.NewFromInt(int64(newQty)))
-			if err := s.cartItemRepo.Update(ctx, cartItem); err != nil {
+			if err := s.cartItemRepo.Update(ctx, nil, cartItem); err != nil {
 				return fmt.Errorf("failed to update cart item: %w", err)
 			}
 		}
 
-		updatedCartWithItems, err := s.cartRepo.GetCartWithItems(ctx, cart.ID)
+		updatedCartWithItems, _, err := s.loadCart(ctx, cart.ID)
 		if err != nil {
 			log.Printf("AddItemToCart: Gagal memuat ulang cart setelah menambah/memperbarui item: %v", err)
 			return fmt.Errorf("failed to reload cart for total calculation: %w", err)
@@ -185,7 +251,9 @@ func (s *CartService) AddItemToCart(ctx context.Context, cartID, userID, product
 			return fmt.Errorf("reloaded cart is nil after item addition/update")
 		}
 
-		s.CalculateCartTotals(updatedCartWithItems)
+		s.applyPromotions(ctx, updatedCartWithItems)
+		s.voucherSvc.RefreshCartVoucher(ctx, updatedCartWithItems)
+		s.CalculateCartTotals(ctx, updatedCartWithItems)
 
 		if err := s.cartRepo.UpdateCart(ctx, updatedCartWithItems); err != nil {
 			log.Printf("AddItemToCart: Gagal memperbarui total keranjang setelah menambah item: %v", err)
@@ -195,10 +263,10 @@ func (s *CartService) AddItemToCart(ctx context.Context, cartID, userID, product
 	})
 }
 
-func (s *CartService) UpdateCartItemQty(ctx context.Context, userID, productID string, newQty int) (*models.Cart, error) {
+func (s *CartService) UpdateCartItemQty(ctx context.Context, cartID, userID, productID, variantID string, newQty int) (*models.Cart, error) {
 	var finalCart *models.Cart
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		cart, err := s.cartRepo.GetCartByUserID(ctx, userID)
+		cart, err := s.findCart(ctx, cartID, userID)
 		if err != nil {
 			return fmt.Errorf("failed to get user cart: %w", err)
 		}
@@ -211,7 +279,12 @@ func (s *CartService) UpdateCartItemQty(ctx context.Context, userID, productID s
 			return fmt.Errorf("product not found or error getting product: %w", err)
 		}
 
-		cartItem, err := s.cartItemRepo.GetByCartIDAndProductID(ctx, cart.ID, productID)
+		variant, err := resolveVariant(product, variantID)
+		if err != nil {
+			return err
+		}
+
+		cartItem, err := s.cartItemRepo.GetByCartIDProductAndVariant(ctx, nil, cart.ID, productID, variantID)
 		if err != nil {
 			return fmt.Errorf("failed to get cart item: %w", err)
 		}
@@ -224,35 +297,28 @@ func (s *CartService) UpdateCartItemQty(ctx context.Context, userID, productID s
 				return fmt.Errorf("failed to delete cart item: %w", err)
 			}
 		} else {
-			if product.Stock < newQty {
-				return fmt.Errorf("not enough stock for product '%s'. Available: %d, Requested: %d", product.Name, product.Stock, newQty)
-			}
-
-			finalPriceUnit := product.Price
-			discountAmountPerUnit := decimal.Zero
-			if product.DiscountPercent.GreaterThan(decimal.Zero) {
-				discountAmountPerUnit = product.Price.Mul(product.DiscountPercent.Div(decimal.NewFromInt(100)))
-				finalPriceUnit = product.Price.Sub(discountAmountPerUnit)
-			} else if product.DiscountAmount.GreaterThan(decimal.Zero) {
-				discountAmountPerUnit = product.DiscountAmount
-				finalPriceUnit = product.Price.Sub(discountAmountPerUnit)
+			availableStock, err := s.stockSvc.AvailableStock(ctx, product, variant)
+			if err != nil {
+				return fmt.Errorf("failed to get available stock: %w", err)
 			}
-			if finalPriceUnit.LessThan(decimal.Zero) {
-				finalPriceUnit = decimal.Zero
+			if availableStock < newQty {
+				return fmt.Errorf("not enough stock for product '%s'. Available: %d, Requested: %d", itemDisplayName(product, variant), availableStock, newQty)
 			}
 
+			unitPrice, discountAmountPerUnit, finalPriceUnit := product.UnitPricing(variant)
+
 			cartItem.Qty = newQty
-			cartItem.Price = product.Price
+			cartItem.Price = unitPrice
 			cartItem.DiscountPercent = product.DiscountPercent
 			cartItem.DiscountAmount = discountAmountPerUnit
 			cartItem.FinalPriceUnit = finalPriceUnit
 			cartItem.Subtotal = finalPriceUnit.Mul(decimal.NewFromInt(int64(newQty)))
-			if err := s.cartItemRepo.Update(ctx, cartItem); err != nil {
+			if err := s.cartItemRepo.Update(ctx, nil, cartItem); err != nil {
 				return fmt.Errorf("failed to update cart item: %w", err)
 			}
 		}
 
-		updatedCart, err := s.cartRepo.GetCartWithItems(ctx, cart.ID)
+		updatedCart, _, err := s.loadCart(ctx, cart.ID)
 		if err != nil {
 			log.Printf("UpdateCartItemQty: Gagal memuat ulang cart setelah mengubah item: %v", err)
 			return fmt.Errorf("failed to reload cart for total calculation: %w", err)
@@ -260,14 +326,16 @@ func (s *CartService) UpdateCartItemQty(ctx context.Context, userID, productID s
 		if updatedCart == nil {
 
 			updatedCart = &models.Cart{
-				ID: cart.ID, UserID: userID, CartItems: []models.CartItem{},
-				BaseTotalPrice: decimal.Zero, TaxAmount: decimal.Zero, TaxPercent: calc.GetTaxPercent(),
+				ID: cart.ID, UserID: cart.UserID, CartItems: []models.CartItem{},
+				BaseTotalPrice: decimal.Zero, TaxAmount: decimal.Zero, TaxPercent: decimal.Zero,
 				DiscountAmount: decimal.Zero, GrandTotal: decimal.Zero, TotalWeight: decimal.Zero,
 				ShippingCost: decimal.Zero, TotalItems: 0,
 			}
 		}
 
-		s.CalculateCartTotals(updatedCart)
+		s.applyPromotions(ctx, updatedCart)
+		s.voucherSvc.RefreshCartVoucher(ctx, updatedCart)
+		s.CalculateCartTotals(ctx, updatedCart)
 		if err := s.cartRepo.UpdateCart(ctx, updatedCart); err != nil {
 			log.Printf("UpdateCartItemQty: Gagal memperbarui total keranjang setelah mengubah item: %v", err)
 			return fmt.Errorf("failed to update cart totals: %w", err)
@@ -278,10 +346,10 @@ func (s *CartService) UpdateCartItemQty(ctx context.Context, userID, productID s
 	return finalCart, err
 }
 
-func (s *CartService) RemoveItemFromCart(ctx context.Context, userID, productID string) (*models.Cart, error) {
+func (s *CartService) RemoveItemFromCart(ctx context.Context, cartID, userID, productID, variantID string) (*models.Cart, error) {
 	var finalCart *models.Cart
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		cart, err := s.cartRepo.GetCartByUserID(ctx, userID)
+		cart, err := s.findCart(ctx, cartID, userID)
 		if err != nil {
 			return fmt.Errorf("failed to get user cart: %w", err)
 		}
@@ -289,7 +357,7 @@ func (s *CartService) RemoveItemFromCart(ctx context.Context, userID, productID
 			return nil
 		}
 
-		cartItem, err := s.cartItemRepo.GetByCartIDAndProductID(ctx, cart.ID, productID)
+		cartItem, err := s.cartItemRepo.GetByCartIDProductAndVariant(ctx, nil, cart.ID, productID, variantID)
 		if err != nil {
 			return fmt.Errorf("failed to get cart item: %w", err)
 		}
@@ -301,7 +369,7 @@ func (s *CartService) RemoveItemFromCart(ctx context.Context, userID, productID
 			return fmt.Errorf("failed to delete cart item: %w", err)
 		}
 
-		updatedCart, err := s.cartRepo.GetCartWithItems(ctx, cart.ID)
+		updatedCart, _, err := s.loadCart(ctx, cart.ID)
 		if err != nil {
 			log.Printf("RemoveItemFromCart: Gagal memuat ulang cart setelah menghapus item: %v", err)
 			return fmt.Errorf("failed to reload cart for total calculation: %w", err)
@@ -309,14 +377,16 @@ func (s *CartService) RemoveItemFromCart(ctx context.Context, userID, productID
 		if updatedCart == nil {
 
 			updatedCart = &models.Cart{
-				ID: cart.ID, UserID: userID, CartItems: []models.CartItem{},
-				BaseTotalPrice: decimal.Zero, TaxAmount: decimal.Zero, TaxPercent: calc.GetTaxPercent(),
+				ID: cart.ID, UserID: cart.UserID, CartItems: []models.CartItem{},
+				BaseTotalPrice: decimal.Zero, TaxAmount: decimal.Zero, TaxPercent: decimal.Zero,
 				DiscountAmount: decimal.Zero, GrandTotal: decimal.Zero, TotalWeight: decimal.Zero,
 				ShippingCost: decimal.Zero, TotalItems: 0,
 			}
 		}
 
-		s.CalculateCartTotals(updatedCart)
+		s.applyPromotions(ctx, updatedCart)
+		s.voucherSvc.RefreshCartVoucher(ctx, updatedCart)
+		s.CalculateCartTotals(ctx, updatedCart)
 		if err := s.cartRepo.UpdateCart(ctx, updatedCart); err != nil {
 			log.Printf("RemoveItemFromCart: Gagal memperbarui total keranjang setelah menghapus item: %v", err)
 			return fmt.Errorf("failed to update cart totals after removing item: %w", err)
@@ -350,7 +420,370 @@ func (s *CartService) ClearUserCart(ctx context.Context, userID string) error {
 	})
 }
 
-func (s *CartService) CalculateCartTotals(cart *models.Cart) {
+// EnsureGuestCart mengembalikan keranjang tamu cartID, atau membuat keranjang tamu baru jika cartID kosong,
+// sudah kedaluwarsa, atau bukan keranjang tamu.
+func (s *CartService) EnsureGuestCart(ctx context.Context, cartID string) (*models.Cart, error) {
+	cart, err := s.cartRepo.GetGuestCart(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+	if cart != nil && time.Since(cart.UpdatedAt) <= configs.GetGuestCartTTL() {
+		return cart, nil
+	}
+
+	cart, err = s.cartRepo.AddCart(ctx, &models.Cart{
+		ID:             uuid.New().String(),
+		BaseTotalPrice: decimal.Zero,
+		TaxAmount:      decimal.Zero,
+		TaxPercent:     decimal.Zero,
+		DiscountAmount: decimal.Zero,
+		GrandTotal:     decimal.Zero,
+		TotalWeight:    decimal.Zero,
+		ShippingCost:   decimal.Zero,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat keranjang tamu: %w", err)
+	}
+	return cart, nil
+}
+
+// MergeGuestCart memindahkan item keranjang tamu ke keranjang user setelah login atau registrasi. Jumlah
+// item yang sama dijumlahkan lalu dibatasi stok tersedia, kemudian keranjang tamu dihapus dan total
+// keranjang user dihitung ulang. Nilai kembalian true berarti ada jumlah yang dipotong karena stok.
+func (s *CartService) MergeGuestCart(ctx context.Context, guestCartID, userID string) (bool, error) {
+	guestCart, err := s.cartRepo.GetGuestCart(ctx, guestCartID)
+	if err != nil {
+		return false, err
+	}
+	if guestCart == nil {
+		return false, nil
+	}
+	guestCart, err = s.cartRepo.GetCartWithItems(ctx, guestCart.ID)
+	if err != nil {
+		return false, fmt.Errorf("gagal memuat item keranjang tamu: %w", err)
+	}
+	if guestCart == nil {
+		return false, nil
+	}
+
+	userCart, err := s.cartRepo.GetOrCreateCartByUserID(ctx, "", userID)
+	if err != nil {
+		return false, fmt.Errorf("gagal mendapatkan atau membuat keranjang pengguna: %w", err)
+	}
+
+	lines := make([]cartLine, 0, len(guestCart.CartItems))
+	for _, item := range guestCart.CartItems {
+		lines = append(lines, cartLine{ProductID: item.ProductID, VariantID: item.VariantID, Qty: item.Qty})
+	}
+
+	var capped bool
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		capped, err = s.addLinesCapped(ctx, tx, userCart.ID, lines, true)
+		if err != nil {
+			return err
+		}
+		if err := s.cartItemRepo.DeleteAllItemsByCartID(ctx, tx, guestCart.ID); err != nil {
+			return err
+		}
+		if err := s.cartRepo.DeleteCart(ctx, tx, guestCart.ID); err != nil {
+			return fmt.Errorf("gagal menghapus keranjang tamu: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.recalculateCart(ctx, userCart.ID); err != nil {
+		log.Printf("MergeGuestCart: Gagal memperbarui total keranjang %s setelah penggabungan: %v", userCart.ID, err)
+		return capped, err
+	}
+	return capped, nil
+}
+
+// RestoreItems memastikan keranjang user kembali berisi item yang diberikan, misalnya dari tautan email
+// keranjang yang ditinggalkan. Item yang jumlahnya di keranjang sudah sama atau lebih banyak tidak diubah,
+// sehingga tautan yang dibuka berkali-kali tidak menggandakan jumlah. Nilai kembalian true berarti ada
+// jumlah yang dipotong karena stok.
+func (s *CartService) RestoreItems(ctx context.Context, userID string, items []models.CartRecoveryItem) (bool, error) {
+	cart, err := s.cartRepo.GetOrCreateCartByUserID(ctx, "", userID)
+	if err != nil {
+		return false, fmt.Errorf("gagal mendapatkan atau membuat keranjang pengguna: %w", err)
+	}
+
+	lines := make([]cartLine, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, cartLine{ProductID: item.ProductID, VariantID: item.VariantID, Qty: item.Qty})
+	}
+
+	capped, err := s.addLinesCapped(ctx, nil, cart.ID, lines, false)
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.recalculateCart(ctx, cart.ID); err != nil {
+		log.Printf("RestoreItems: Gagal memperbarui total keranjang %s setelah pemulihan: %v", cart.ID, err)
+		return capped, err
+	}
+	return capped, nil
+}
+
+// cartLine adalah item yang akan dimasukkan ke keranjang lain, tanpa harga karena harga selalu dihitung ulang.
+type cartLine struct {
+	ProductID string
+	VariantID string
+	Qty       int
+}
+
+// addLinesCapped memasukkan lines ke keranjang cartID. Jika sum bernilai true, jumlah ditambahkan ke item yang
+// sudah ada; jika tidak, jumlah item dinaikkan menjadi jumlah line. Hasilnya dibatasi stok tersedia dan
+// produk/varian yang sudah tidak ada dilewati. Item keranjang dibaca dan disimpan lewat tx jika diberikan.
+// Nilai kembalian true berarti ada jumlah yang dipotong.
+func (s *CartService) addLinesCapped(ctx context.Context, tx *gorm.DB, cartID string, lines []cartLine, sum bool) (bool, error) {
+	capped := false
+	for _, line := range lines {
+		product, err := s.productRepo.GetByID(ctx, line.ProductID)
+		if err != nil {
+			return false, fmt.Errorf("product not found or error getting product: %w", err)
+		}
+		if product == nil {
+			continue
+		}
+		variant, err := resolveVariant(product, line.VariantID)
+		if err != nil {
+			log.Printf("CartService.addLinesCapped: Melewati produk '%s': %v", line.ProductID, err)
+			continue
+		}
+
+		availableStock, err := s.stockSvc.AvailableStock(ctx, product, variant)
+		if err != nil {
+			return false, fmt.Errorf("failed to get available stock: %w", err)
+		}
+
+		cartItem, err := s.cartItemRepo.GetByCartIDProductAndVariant(ctx, tx, cartID, product.ID, line.VariantID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get cart item: %w", err)
+		}
+		currentQty := 0
+		if cartItem != nil {
+			currentQty = cartItem.Qty
+		}
+
+		newQty := max(currentQty, line.Qty)
+		if sum {
+			newQty = currentQty + line.Qty
+		}
+		if newQty > availableStock {
+			newQty = availableStock
+			capped = true
+		}
+		if newQty <= currentQty {
+			continue
+		}
+
+		unitPrice, discountAmountPerUnit, finalPriceUnit := product.UnitPricing(variant)
+		if cartItem == nil {
+			cartItem = &models.CartItem{
+				CartID:    cartID,
+				ProductID: product.ID,
+				VariantID: line.VariantID,
+			}
+		}
+		cartItem.Qty = newQty
+		cartItem.Price = unitPrice
+		cartItem.DiscountPercent = product.DiscountPercent
+		cartItem.DiscountAmount = discountAmountPerUnit
+		cartItem.FinalPriceUnit = finalPriceUnit
+		cartItem.Subtotal = finalPriceUnit.Mul(decimal.NewFromInt(int64(newQty)))
+
+		if cartItem.ID == "" {
+			err = s.cartItemRepo.Add(ctx, tx, cartItem)
+		} else {
+			err = s.cartItemRepo.Update(ctx, tx, cartItem)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to save cart item: %w", err)
+		}
+	}
+	return capped, nil
+}
+
+// recalculateCart memuat ulang keranjang beserta itemnya lalu menghitung dan menyimpan totalnya.
+func (s *CartService) recalculateCart(ctx context.Context, cartID string) error {
+	cart, _, err := s.loadCart(ctx, cartID)
+	if err != nil {
+		return fmt.Errorf("failed to reload cart for total calculation: %w", err)
+	}
+	if cart == nil {
+		return nil
+	}
+	s.applyPromotions(ctx, cart)
+	s.voucherSvc.RefreshCartVoucher(ctx, cart)
+	s.CalculateCartTotals(ctx, cart)
+	if err := s.cartRepo.UpdateCart(ctx, cart); err != nil {
+		return fmt.Errorf("failed to update cart totals: %w", err)
+	}
+	return nil
+}
+
+// AcknowledgeChanges mengonfirmasi seluruh perubahan keranjang yang ditampilkan ke pelanggan: item yang produk
+// atau variannya sudah dihapus dibuang, jumlah item dipotong sesuai stok tersedia, dan harga saat ini menjadi
+// harga yang disetujui. Keranjang yang dikembalikan sudah dihitung ulang.
+func (s *CartService) AcknowledgeChanges(ctx context.Context, cartID, userID string) (*models.Cart, error) {
+	cart, err := s.GetCart(ctx, cartID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cart == nil || !cart.HasNotices() {
+		return cart, nil
+	}
+
+	for _, notice := range cart.Notices {
+		item, err := s.cartItemRepo.GetByID(ctx, notice.ItemID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("gagal mengambil item keranjang: %w", err)
+		}
+
+		switch {
+		case notice.Kind == models.CartNoticeUnavailable,
+			notice.Kind == models.CartNoticeLowStock && notice.Available <= 0:
+			err = s.cartItemRepo.Delete(ctx, item.ID)
+		case notice.Kind == models.CartNoticeLowStock:
+			item.Qty = notice.Available
+			item.Subtotal = item.FinalPriceUnit.Mul(decimal.NewFromInt(int64(item.Qty)))
+			err = s.cartItemRepo.Update(ctx, nil, item)
+		case notice.Kind == models.CartNoticePriceChanged:
+			item.AcceptedPrice = notice.NewPrice
+			err = s.cartItemRepo.Update(ctx, nil, item)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("gagal memperbarui item keranjang: %w", err)
+		}
+	}
+
+	return s.GetCart(ctx, cartID, userID)
+}
+
+// StartGuestCartCleanupWorker menghapus keranjang tamu yang tidak diubah lebih lama dari ttl secara
+// berkala sampai ctx dibatalkan.
+func (s *CartService) StartGuestCartCleanupWorker(ctx context.Context, ttl, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := s.cartRepo.DeleteGuestCartsBefore(ctx, time.Now().Add(-ttl))
+				if err != nil {
+					log.Printf("CartService: gagal menghapus keranjang tamu kedaluwarsa: %v", err)
+					continue
+				}
+				if deleted > 0 {
+					log.Printf("CartService: %d keranjang tamu kedaluwarsa dihapus", deleted)
+				}
+			}
+		}
+	}()
+}
+
+// loadCart memuat keranjang beserta itemnya. Item yang produk atau variannya sudah dihapus dipisahkan agar
+// tidak ikut dihitung; item itu tetap tersimpan sampai pelanggan mengonfirmasi perubahan keranjang.
+func (s *CartService) loadCart(ctx context.Context, cartID string) (*models.Cart, []models.CartItem, error) {
+	cart, err := s.cartRepo.GetCartWithItems(ctx, cartID)
+	if err != nil || cart == nil {
+		return cart, nil, err
+	}
+	items := make([]models.CartItem, 0, len(cart.CartItems))
+	var unavailable []models.CartItem
+	for _, item := range cart.CartItems {
+		if item.IsUnavailable() {
+			unavailable = append(unavailable, item)
+			continue
+		}
+		items = append(items, item)
+	}
+	cart.CartItems = items
+	return cart, unavailable, nil
+}
+
+// findCart mengambil keranjang milik userID, atau keranjang tamu cartID jika userID kosong.
+func (s *CartService) findCart(ctx context.Context, cartID, userID string) (*models.Cart, error) {
+	if userID != "" {
+		return s.cartRepo.GetCartByUserID(ctx, userID)
+	}
+	return s.cartRepo.GetGuestCart(ctx, cartID)
+}
+
+// ApplyVoucher memasang kode voucher ke keranjang user jika seluruh syaratnya terpenuhi.
+func (s *CartService) ApplyVoucher(ctx context.Context, userID, code string) (*models.Cart, error) {
+	cart, err := s.cartRepo.GetCartByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mendapatkan keranjang user: %w", err)
+	}
+	if cart == nil {
+		return nil, errors.New("keranjang Anda masih kosong")
+	}
+	detailedCart, _, err := s.loadCart(ctx, cart.ID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal memuat item keranjang: %w", err)
+	}
+	if detailedCart == nil || len(detailedCart.CartItems) == 0 {
+		return nil, errors.New("keranjang Anda masih kosong")
+	}
+
+	s.applyPromotions(ctx, detailedCart)
+	voucher, discount, err := s.voucherSvc.Evaluate(ctx, detailedCart, code)
+	if err != nil {
+		return nil, err
+	}
+	detailedCart.VoucherID = voucher.ID
+	detailedCart.VoucherCode = voucher.Code
+	detailedCart.VoucherDiscount = discount
+
+	s.CalculateCartTotals(ctx, detailedCart)
+	if err := s.cartRepo.UpdateCart(ctx, detailedCart); err != nil {
+		log.Printf("ApplyVoucher: Gagal menyimpan voucher %s ke cart %s: %v", voucher.Code, detailedCart.ID, err)
+		return nil, fmt.Errorf("gagal menyimpan voucher ke keranjang: %w", err)
+	}
+	return detailedCart, nil
+}
+
+// RemoveVoucher melepas voucher dari keranjang user dan menghitung ulang totalnya.
+func (s *CartService) RemoveVoucher(ctx context.Context, userID string) error {
+	cart, err := s.cartRepo.GetCartByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("gagal mendapatkan keranjang user: %w", err)
+	}
+	if cart == nil || cart.VoucherCode == "" {
+		return nil
+	}
+	detailedCart, _, err := s.loadCart(ctx, cart.ID)
+	if err != nil {
+		return fmt.Errorf("gagal memuat item keranjang: %w", err)
+	}
+	if detailedCart == nil {
+		return nil
+	}
+
+	detailedCart.ClearVoucher()
+	s.applyPromotions(ctx, detailedCart)
+	s.CalculateCartTotals(ctx, detailedCart)
+	if err := s.cartRepo.UpdateCart(ctx, detailedCart); err != nil {
+		log.Printf("RemoveVoucher: Gagal melepas voucher dari cart %s: %v", detailedCart.ID, err)
+		return fmt.Errorf("gagal melepas voucher dari keranjang: %w", err)
+	}
+	return nil
+}
+
+// CalculateCartTotals menghitung ulang harga setiap item dari produknya lalu total keranjang. Pajak
+// dihitung per item oleh TaxService setelah potongan voucher. Item baru mendapat AcceptedPrice dari harga
+// saat ini; perubahan harga berikutnya harus dikonfirmasi lewat AcknowledgeChanges.
+func (s *CartService) CalculateCartTotals(ctx context.Context, cart *models.Cart) {
 	if cart == nil {
 		return
 	}
@@ -360,30 +793,21 @@ func (s *CartService) CalculateCartTotals(cart *models.Cart) {
 	totalItems := 0
 	totalDiscountAmount := decimal.Zero
 
-	for _, item := range cart.CartItems {
+	for i := range cart.CartItems {
+		item := &cart.CartItems[i]
 
-		productPrice := item.Product.Price
-		productWeight := item.Product.Weight
-
-		finalPriceUnit := productPrice
-		discountAmountPerUnit := decimal.Zero
-		if item.Product.DiscountPercent.GreaterThan(decimal.Zero) {
-			discountAmountPerUnit = productPrice.Mul(item.Product.DiscountPercent.Div(decimal.NewFromInt(100)))
-			finalPriceUnit = productPrice.Sub(discountAmountPerUnit)
-		} else if item.Product.DiscountAmount.GreaterThan(decimal.Zero) {
-			discountAmountPerUnit = item.Product.DiscountAmount
-			finalPriceUnit = productPrice.Sub(discountAmountPerUnit)
-		}
-
-		if finalPriceUnit.LessThan(decimal.Zero) {
-			finalPriceUnit = decimal.Zero
-		}
+		productPrice, discountAmountPerUnit, finalPriceUnit := item.Product.UnitPricing(item.Variant)
+		productWeight := item.UnitWeight()
 
 		item.Price = productPrice
 		item.DiscountPercent = item.Product.DiscountPercent
 		item.DiscountAmount = discountAmountPerUnit
 		item.FinalPriceUnit = finalPriceUnit
 		item.Subtotal = finalPriceUnit.Mul(decimal.NewFromInt(int64(item.Qty)))
+		item.Name = itemDisplayName(item.Product, item.Variant)
+		if item.AcceptedPrice.IsZero() {
+			item.AcceptedPrice = finalPriceUnit
+		}
 
 		baseTotalPrice = baseTotalPrice.Add(item.Subtotal)
 		totalWeight = totalWeight.Add(productWeight.Mul(decimal.NewFromInt(int64(item.Qty))))
@@ -396,10 +820,65 @@ func (s *CartService) CalculateCartTotals(cart *models.Cart) {
 	cart.TotalWeight = totalWeight
 	cart.TotalItems = totalItems
 
-	taxPercent := calc.GetTaxPercent()
-	cart.TaxPercent = taxPercent
-	cart.TaxAmount = baseTotalPrice.Mul(taxPercent.Div(decimal.NewFromInt(100)))
+	if err := s.taxSvc.ApplyToCart(ctx, cart); err != nil {
+		log.Printf("CartService.CalculateCartTotals: Gagal menghitung pajak untuk cart %s: %v", cart.ID, err)
+	}
+}
 
-	cart.GrandTotal = baseTotalPrice.Add(cart.TaxAmount)
+// applyPromotions memasang promosi yang sedang berjalan ke produk setiap item. Produk item dimuat lewat
+// preload keranjang sehingga belum melewati ProductRepository.
+func (s *CartService) applyPromotions(ctx context.Context, cart *models.Cart) {
+	if err := applyCartPromotions(ctx, s.promoRepo, cart); err != nil {
+		log.Printf("CartService.applyPromotions: Gagal memasang promosi untuk cart %s: %v", cart.ID, err)
+	}
+}
+
+func applyCartPromotions(ctx context.Context, promoRepo repositories.PromotionRepository, cart *models.Cart) error {
+	products := make([]*models.Product, 0, len(cart.CartItems))
+	for i := range cart.CartItems {
+		if cart.CartItems[i].Product != nil {
+			products = append(products, cart.CartItems[i].Product)
+		}
+	}
+	return promoRepo.ApplyToProducts(ctx, time.Now(), products...)
+}
 
+// applyItemAvailableStock mengganti stok produk/varian pada item dengan stok tersedia (stok dikurangi hold aktif)
+// agar AvailableStock di halaman keranjang sesuai dengan yang bisa dibeli.
+func (s *CartService) applyItemAvailableStock(ctx context.Context, item *models.CartItem) error {
+	var variant *models.ProductVariant
+	if item.Variant != nil && item.Variant.ID != "" {
+		variant = item.Variant
+	}
+	available, err := s.stockSvc.AvailableStock(ctx, item.Product, variant)
+	if err != nil {
+		return err
+	}
+	if variant != nil {
+		variant.Stock = available
+	} else {
+		item.Product.Stock = available
+	}
+	return nil
+}
+
+func resolveVariant(product *models.Product, variantID string) (*models.ProductVariant, error) {
+	if variantID == "" {
+		if product.HasVariants() {
+			return nil, fmt.Errorf("please select a variant for product '%s'", product.Name)
+		}
+		return nil, nil
+	}
+	variant := product.FindVariant(variantID)
+	if variant == nil {
+		return nil, fmt.Errorf("variant %s not found for product '%s'", variantID, product.Name)
+	}
+	return variant, nil
+}
+
+func itemDisplayName(product *models.Product, variant *models.ProductVariant) string {
+	if variant == nil {
+		return product.Name
+	}
+	return fmt.Sprintf("%s (%s)", product.Name, variant.Name)
 }
@@ -5,32 +5,92 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"strings"
 	"time"
 
 	"github.com/Rakhulsr/go-ecommerce/app/configs"
 	"github.com/Rakhulsr/go-ecommerce/app/models"
 	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/payment"
 	"github.com/google/uuid"
-	"github.com/midtrans/midtrans-go"
-	"github.com/midtrans/midtrans-go/snap"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
-var ErrInsufficientStock = errors.New("insufficient product stock")
+const (
+	// checkoutGatewayLease adalah batas waktu satu pemanggilan gateway. Kunci yang lebih lama dianggap milik
+	// proses yang sudah mati, dan percobaan yang tertahan lebih lama dari ini diambil alih worker pemulihan.
+	checkoutGatewayLease  = 2 * time.Minute
+	checkoutRecoveryBatch = 100
+	// checkoutExpiryGrace adalah waktu tunggu notifikasi expire dari gateway untuk transaksi yang masih
+	// berstatus pending di gateway walaupun batas waktunya sudah lewat.
+	checkoutExpiryGrace = 30 * time.Minute
+
+	// PaymentChannelSnap memakai halaman pembayaran Snap yang menampilkan semua metode dari gateway.
+	PaymentChannelSnap = "snap"
+	// PaymentChannelManualTransfer membuat order tanpa gateway; pembeli transfer ke rekening toko lalu
+	// mengunggah bukti yang diverifikasi admin.
+	PaymentChannelManualTransfer = models.PaymentTypeManualTransfer
+
+	// manualTransferMaxUniqueCode adalah batas atas kode unik yang ditambahkan ke nominal transfer manual.
+	manualTransferMaxUniqueCode = 999
+)
+
+// PaymentChannelOption adalah pilihan metode pembayaran di halaman checkout.
+type PaymentChannelOption struct {
+	Code  string
+	Label string
+}
+
+// PaymentChannelOptions mengembalikan Snap diikuti bank transfer yang nomornya ditampilkan di aplikasi, lalu
+// transfer manual jika rekening toko sudah diatur.
+func PaymentChannelOptions() []PaymentChannelOption {
+	options := []PaymentChannelOption{{Code: PaymentChannelSnap, Label: "Semua Metode (Kartu, E-Wallet, dll.)"}}
+	for _, bank := range payment.BankTransferBanks {
+		options = append(options, PaymentChannelOption{Code: bank, Label: payment.BankLabel(bank)})
+	}
+	if manual := configs.GetManualTransferConfig(); manual.Enabled() {
+		options = append(options, PaymentChannelOption{Code: PaymentChannelManualTransfer, Label: manualTransferLabel(manual)})
+	}
+	return options
+}
+
+func manualTransferLabel(manual configs.ManualTransferConfig) string {
+	return "Transfer Manual " + manual.Bank
+}
+
+var (
+	ErrInsufficientStock         = errors.New("insufficient product stock")
+	ErrCheckoutKeyInvalid        = errors.New("sesi checkout tidak valid, mohon muat ulang halaman checkout")
+	ErrCheckoutInProgress        = errors.New("pesanan Anda sedang diproses, mohon tunggu sebentar")
+	ErrCheckoutCancelled         = errors.New("pesanan dari sesi checkout ini sudah dibatalkan, mohon checkout ulang")
+	ErrPaymentGatewayUnavailable = errors.New("halaman pembayaran belum dapat dibuat, silakan coba lagi")
+	ErrPaymentChannelInvalid     = errors.New("metode pembayaran tidak tersedia")
+
+	errCheckoutKeyTaken = errors.New("idempotency key sudah dipakai")
+)
 
 type CheckoutService struct {
 	db                *gorm.DB
 	cartRepo          repositories.CartRepositoryImpl
 	cartItemRepo      repositories.CartItemRepositoryImpl
 	productRepo       repositories.ProductRepositoryImpl
+	variantRepo       repositories.ProductVariantRepositoryImpl
 	userRepo          repositories.UserRepositoryImpl
 	addressRepo       repositories.AddressRepository
 	orderRepo         repositories.OrderRepository
 	orderItemRepo     repositories.OrderItemRepository
 	orderCustomerRepo repositories.OrderCustomerRepository
 	paymentRepo       repositories.PaymentRepositoryImpl
+	reservationRepo   repositories.StockReservationRepository
+	shippingQuoteSvc  *ShippingQuoteService
+	voucherSvc        *VoucherService
+	promoRepo         repositories.PromotionRepository
+	taxSvc            *TaxService
+	attemptRepo       repositories.CheckoutAttemptRepository
+	provider          payment.PaymentProvider
+	paymentSvc        *PaymentService
 }
 
 func NewCheckoutService(
@@ -38,32 +98,123 @@ func NewCheckoutService(
 	cartRepo repositories.CartRepositoryImpl,
 	cartItemRepo repositories.CartItemRepositoryImpl,
 	productRepo repositories.ProductRepositoryImpl,
+	variantRepo repositories.ProductVariantRepositoryImpl,
 	userRepo repositories.UserRepositoryImpl,
 	addressRepo repositories.AddressRepository,
 	orderRepo repositories.OrderRepository,
 	orderItemRepo repositories.OrderItemRepository,
 	orderCustomerRepo repositories.OrderCustomerRepository,
 	paymentRepo repositories.PaymentRepositoryImpl,
+	reservationRepo repositories.StockReservationRepository,
+	shippingQuoteSvc *ShippingQuoteService,
+	voucherSvc *VoucherService,
+	promoRepo repositories.PromotionRepository,
+	taxSvc *TaxService,
+	attemptRepo repositories.CheckoutAttemptRepository,
+	provider payment.PaymentProvider,
+	paymentSvc *PaymentService,
 ) *CheckoutService {
 	return &CheckoutService{
 		db:                db,
 		cartRepo:          cartRepo,
 		cartItemRepo:      cartItemRepo,
 		productRepo:       productRepo,
+		variantRepo:       variantRepo,
 		userRepo:          userRepo,
 		addressRepo:       addressRepo,
 		orderRepo:         orderRepo,
 		orderItemRepo:     orderItemRepo,
 		orderCustomerRepo: orderCustomerRepo,
 		paymentRepo:       paymentRepo,
+		reservationRepo:   reservationRepo,
+		shippingQuoteSvc:  shippingQuoteSvc,
+		voucherSvc:        voucherSvc,
+		promoRepo:         promoRepo,
+		taxSvc:            taxSvc,
+		attemptRepo:       attemptRepo,
+		provider:          provider,
+		paymentSvc:        paymentSvc,
+	}
+}
+
+// ProcessFullCheckout membuat order dari keranjang lalu meminta halaman pembayaran ke gateway. Ongkir
+// diambil dari quote yang disimpan server dan divalidasi ulang terhadap keranjang dan alamat saat ini.
+//
+// Checkout dijalankan dalam langkah terpisah: order pending dan idempotency key disimpan dan di-commit lebih
+// dulu, baru gateway dipanggil, lalu tokennya dicatat. Submit ulang dengan key yang sama tidak membuat order
+// baru, melainkan mengembalikan order dan URL pembayaran yang sudah ada.
+//
+// paymentChannel kosong atau "snap" memakai halaman Snap; kode bank (lihat payment.BankTransferBanks) langsung
+// membuat virtual account lewat Core API dan pembeli diarahkan ke halaman instruksi pembayaran di aplikasi.
+// PaymentChannelManualTransfer tidak memanggil gateway sama sekali.
+func (s *CheckoutService) ProcessFullCheckout(ctx context.Context, userID, cartID, addressID, shippingQuoteID, idempotencyKey, paymentChannel string) (*models.Order, string, error) {
+	if idempotencyKey == "" {
+		return nil, "", ErrCheckoutKeyInvalid
+	}
+	channel := ""
+	if paymentChannel != "" && paymentChannel != PaymentChannelSnap {
+		manualEnabled := configs.GetManualTransferConfig().Enabled()
+		if !payment.IsBankTransferBank(paymentChannel) && !(paymentChannel == PaymentChannelManualTransfer && manualEnabled) {
+			return nil, "", ErrPaymentChannelInvalid
+		}
+		channel = paymentChannel
+	}
+	if order, redirectURL, err := s.ResumeCheckout(ctx, userID, idempotencyKey); err != nil || order != nil {
+		return order, redirectURL, err
+	}
+
+	attempt, err := s.createPendingOrder(ctx, userID, cartID, addressID, shippingQuoteID, idempotencyKey, channel)
+	if errors.Is(err, errCheckoutKeyTaken) {
+		// submit lain dengan key yang sama sudah lebih dulu membuat order
+		return s.ResumeCheckout(ctx, userID, idempotencyKey)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return s.requestPayment(ctx, attempt)
+}
+
+// ResumeCheckout mengembalikan order dan URL pembayaran untuk idempotency key yang sudah pernah dipakai.
+// Jika token pembayaran belum tercatat, gateway dipanggil ulang. Order nil tanpa error berarti key belum
+// pernah dipakai.
+func (s *CheckoutService) ResumeCheckout(ctx context.Context, userID, idempotencyKey string) (*models.Order, string, error) {
+	if idempotencyKey == "" {
+		return nil, "", nil
+	}
+	attempt, err := s.attemptRepo.FindByKey(ctx, idempotencyKey)
+	if err != nil {
+		return nil, "", err
 	}
+	if attempt == nil {
+		return nil, "", nil
+	}
+	if attempt.UserID != userID {
+		return nil, "", ErrCheckoutKeyInvalid
+	}
+
+	switch attempt.Status {
+	case models.CheckoutAttemptReady:
+		order, err := s.orderRepo.GetByID(ctx, attempt.OrderID)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get order %s: %w", attempt.OrderID, err)
+		}
+		if order == nil {
+			return nil, "", ErrCheckoutCancelled
+		}
+		return order, attempt.RedirectURL, nil
+	case models.CheckoutAttemptCancelled:
+		return nil, "", ErrCheckoutCancelled
+	}
+	return s.requestPayment(ctx, attempt)
 }
 
-func (s *CheckoutService) ProcessFullCheckout(ctx context.Context, userID, cartID, addressID, shippingServiceCode, shippingServiceName string, shippingCost decimal.Decimal) (*models.Order, string, error) {
+// createPendingOrder menyimpan order pending beserta item, reservasi stok, data pelanggan, catatan
+// pembayaran tanpa token dan idempotency key dalam satu transaksi. Gateway belum dipanggil di sini.
+func (s *CheckoutService) createPendingOrder(ctx context.Context, userID, cartID, addressID, shippingQuoteID, idempotencyKey, channel string) (*models.CheckoutAttempt, error) {
 
 	tx := s.db.WithContext(ctx).Begin()
 	if tx.Error != nil {
-		return nil, "", fmt.Errorf("failed to begin transaction: %w", tx.Error)
+		return nil, fmt.Errorf("failed to begin transaction: %w", tx.Error)
 	}
 
 	defer func() {
@@ -76,78 +227,152 @@ func (s *CheckoutService) ProcessFullCheckout(ctx context.Context, userID, cartI
 		}
 	}()
 
+	// key disimpan paling awal: submit kedua dengan key yang sama tertahan di unique index sampai transaksi
+	// ini selesai, lalu gagal dan mengambil order yang sudah dibuat
+	attempt := &models.CheckoutAttempt{
+		IdempotencyKey: idempotencyKey,
+		UserID:         userID,
+		OrderID:        uuid.New().String(),
+		Status:         models.CheckoutAttemptPending,
+	}
+	if err := s.attemptRepo.Create(ctx, tx, attempt); err != nil {
+		tx.Rollback()
+		if existing, findErr := s.attemptRepo.FindByKey(ctx, idempotencyKey); findErr == nil && existing != nil {
+			return nil, errCheckoutKeyTaken
+		}
+		return nil, err
+	}
+
 	cart, err := s.cartRepo.GetCartWithItems(ctx, cartID)
 	if err != nil {
 		tx.Rollback()
-		return nil, "", fmt.Errorf("failed to get cart with items: %w", err)
+		return nil, fmt.Errorf("failed to get cart with items: %w", err)
 	}
 	if cart == nil || len(cart.CartItems) == 0 {
 		tx.Rollback()
-		return nil, "", errors.New("cart is empty or not found")
+		return nil, errors.New("cart is empty or not found")
+	}
+	// potongan voucher dihitung ulang dari harga efektif, jadi promosi harus dipasang seperti di keranjang
+	if err := applyCartPromotions(ctx, s.promoRepo, cart); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to apply promotions to cart: %w", err)
+	}
+	if err := s.voucherSvc.MarkEligibleItems(ctx, cart); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load cart voucher: %w", err)
+	}
+	// pajak dihitung ulang per item agar jumlah pajak item sama persis dengan pajak order
+	if err := s.taxSvc.ApplyToCart(ctx, cart); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to calculate cart tax: %w", err)
 	}
 
 	user, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
 		tx.Rollback()
-		return nil, "", fmt.Errorf("failed to get user: %w", err)
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	if user == nil {
 		tx.Rollback()
-		return nil, "", errors.New("user not found")
+		return nil, errors.New("user not found")
 	}
 
 	address, err := s.addressRepo.FindAddressByID(ctx, addressID)
 	if err != nil {
 		tx.Rollback()
-		return nil, "", fmt.Errorf("failed to get address: %w", err)
+		return nil, fmt.Errorf("failed to get address: %w", err)
 	}
-	if address == nil {
+	if address == nil || address.UserID != userID {
 		tx.Rollback()
-		return nil, "", errors.New("address not found")
+		return nil, errors.New("address not found")
 	}
 
+	quote, err := s.shippingQuoteSvc.ValidateQuote(ctx, shippingQuoteID, cart, address)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	shippingCost := quote.Cost
+	shippingServiceCode := quote.Code
+	shippingServiceName := quote.ServiceName()
+
+	// nilai per item dibulatkan ke rupiah dan dialokasikan di sini supaya OrderItem yang disimpan sama
+	// persis dengan item yang dikirim ke gateway pembayaran
+	lines := allocateOrderLines(cart)
+	baseTotal := decimal.Zero
+	voucherDiscount := decimal.Zero
+	taxAmount := decimal.Zero
+	itemsTotal := decimal.Zero
+	for _, line := range lines {
+		baseTotal = baseTotal.Add(line.BaseTotal)
+		voucherDiscount = voucherDiscount.Add(line.VoucherDiscount)
+		taxAmount = taxAmount.Add(line.TaxAmount)
+		itemsTotal = itemsTotal.Add(line.GrandTotal)
+	}
+	shippingCost = shippingCost.Round(0)
+
 	orderItems := []models.OrderItem{}
 
-	for _, cartItem := range cart.CartItems {
+	for i, cartItem := range cart.CartItems {
 		product, err := s.productRepo.GetByID(ctx, cartItem.ProductID)
 		if err != nil {
 			tx.Rollback()
-			return nil, "", fmt.Errorf("failed to get product %s: %w", cartItem.ProductID, err)
+			return nil, fmt.Errorf("failed to get product %s: %w", cartItem.ProductID, err)
 		}
 		if product == nil {
 			tx.Rollback()
-			return nil, "", fmt.Errorf("product %s not found", cartItem.ProductID)
+			return nil, fmt.Errorf("product %s not found", cartItem.ProductID)
 		}
 
-		if product.Stock < cartItem.Qty {
-			tx.Rollback()
-			return nil, "", fmt.Errorf("%w: product '%s' has insufficient stock. Available: %d, Requested: %d", ErrInsufficientStock, product.Name, product.Stock, cartItem.Qty)
+		productSku := product.Sku
+		variantName := ""
+		if cartItem.VariantID != "" {
+			variant, err := s.variantRepo.GetByID(ctx, cartItem.VariantID)
+			if err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to get variant %s: %w", cartItem.VariantID, err)
+			}
+			if variant == nil || variant.ProductID != product.ID {
+				tx.Rollback()
+				return nil, fmt.Errorf("variant %s not found for product %s", cartItem.VariantID, product.ID)
+			}
+			productSku = variant.Sku
+			variantName = variant.Name
 		}
 
 		orderItems = append(orderItems, models.OrderItem{
 			ProductID:       product.ID,
 			ProductName:     product.Name,
+			ProductSku:      productSku,
+			VariantID:       cartItem.VariantID,
+			VariantName:     variantName,
 			Qty:             cartItem.Qty,
 			Price:           cartItem.Price,
-			BaseTotal:       cartItem.Subtotal,
-			TaxAmount:       decimal.Zero,
-			TaxPercent:      decimal.Zero,
+			BaseTotal:       lines[i].BaseTotal,
+			VoucherDiscount: lines[i].VoucherDiscount,
+			TaxAmount:       lines[i].TaxAmount,
+			TaxPercent:      cartItem.TaxPercent,
 			DiscountAmount:  cartItem.DiscountAmount,
 			DiscountPercent: cartItem.DiscountPercent,
-			GrandTotal:      cartItem.Subtotal,
+			GrandTotal:      lines[i].GrandTotal,
 		})
 	}
 
 	orderCode := fmt.Sprintf("INV-%s-%s", time.Now().Format("20060102"), uuid.New().String()[:8])
 	order := &models.Order{
+		ID:                  attempt.OrderID,
 		UserID:              userID,
 		OrderCode:           orderCode,
-		BaseTotalPrice:      cart.BaseTotalPrice,
+		BaseTotalPrice:      baseTotal,
 		DiscountAmount:      cart.DiscountAmount,
+		VoucherID:           cart.VoucherID,
+		VoucherCode:         cart.VoucherCode,
+		VoucherDiscount:     voucherDiscount,
 		TaxPercent:          cart.TaxPercent,
-		TaxAmount:           cart.TaxAmount,
+		TaxAmount:           taxAmount,
+		TaxIncluded:         cart.TaxIncluded,
 		ShippingCost:        shippingCost,
-		GrandTotal:          cart.GrandTotal.Add(shippingCost).Round(2),
+		GrandTotal:          itemsTotal.Add(shippingCost),
 		OrderDate:           time.Now(),
 		Status:              models.OrderStatusPending,
 		PaymentStatus:       "Pending",
@@ -160,7 +385,12 @@ func (s *CheckoutService) ProcessFullCheckout(ctx context.Context, userID, cartI
 
 	if err := s.orderRepo.Create(ctx, tx, order); err != nil {
 		tx.Rollback()
-		return nil, "", fmt.Errorf("failed to create order: %w", err)
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	if err := s.voucherSvc.RedeemForOrder(ctx, tx, cart, order); err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
 	for i := range orderItems {
@@ -168,7 +398,28 @@ func (s *CheckoutService) ProcessFullCheckout(ctx context.Context, userID, cartI
 	}
 	if err := s.orderItemRepo.BulkCreate(ctx, tx, orderItems); err != nil {
 		tx.Rollback()
-		return nil, "", fmt.Errorf("failed to create order items: %w", err)
+		return nil, fmt.Errorf("failed to create order items: %w", err)
+	}
+
+	// transfer manual butuh waktu lebih lama, jadi stok ditahan sampai batas waktu pengiriman bukti transfer
+	reservationTTL := configs.GetStockReservationTTL()
+	manual := configs.GetManualTransferConfig()
+	if channel == PaymentChannelManualTransfer {
+		reservationTTL = manual.TTL
+	}
+	for _, item := range orderItems {
+		available, err := s.reservationRepo.Reserve(ctx, tx, order.ID, item.ProductID, item.VariantID, item.Qty, reservationTTL)
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, repositories.ErrReservationInsufficientStock) {
+				itemName := item.ProductName
+				if item.VariantName != "" {
+					itemName = fmt.Sprintf("%s (%s)", item.ProductName, item.VariantName)
+				}
+				return nil, fmt.Errorf("%w: product '%s' has insufficient stock. Available: %d, Requested: %d", ErrInsufficientStock, itemName, max(available, 0), item.Qty)
+			}
+			return nil, fmt.Errorf("failed to reserve stock for product %s: %w", item.ProductID, err)
+		}
 	}
 
 	nameParts := strings.Fields(user.FirstName + " " + user.LastName)
@@ -194,137 +445,505 @@ func (s *CheckoutService) ProcessFullCheckout(ctx context.Context, userID, cartI
 	}
 	if err := s.orderCustomerRepo.Create(ctx, tx, orderCustomer); err != nil {
 		tx.Rollback()
-		return nil, "", fmt.Errorf("failed to create order customer: %w", err)
+		return nil, fmt.Errorf("failed to create order customer: %w", err)
 	}
 
 	newPayment := &models.Payment{
 		OrderID:     order.ID,
 		Number:      order.OrderCode,
 		Amount:      order.GrandTotal,
-		Method:      "Midtrans Snap",
+		Method:      payment.MethodLabel(s.provider.Name()),
 		Status:      "Pending",
 		PaymentType: "Snap",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
+	switch {
+	case channel == PaymentChannelManualTransfer:
+		uniqueCode, err := s.manualTransferUniqueCode(ctx, tx, order.GrandTotal)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		expiresAt := time.Now().Add(manual.TTL)
+		newPayment.Method = manualTransferLabel(manual)
+		newPayment.PaymentType = models.PaymentTypeManualTransfer
+		newPayment.UniqueCode = uniqueCode
+		newPayment.Amount = order.GrandTotal.Add(decimal.NewFromInt(int64(uniqueCode)))
+		newPayment.ExpiresAt = &expiresAt
+	case channel != "":
+		newPayment.Bank = channel
+		newPayment.Method = payment.BankLabel(channel)
+		newPayment.PaymentType = payment.BankPaymentType(channel)
+	}
+	if err := s.paymentRepo.Create(ctx, tx, newPayment); err != nil {
+		tx.Rollback()
+		log.Printf("ERROR: Failed to create payment record for OrderID %s: %v", order.ID, err)
+		return nil, fmt.Errorf("failed to create payment record: %w", err)
+	}
 
-	snapClient := configs.GetMidtransSnapClient()
-	var midtransItemDetails []midtrans.ItemDetails
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("ERROR: Failed to commit pending order %s: %v", order.OrderCode, err)
+		return nil, fmt.Errorf("failed to commit database transaction: %w", err)
+	}
 
-	for _, item := range orderItems {
-		itemName := item.ProductName
-		if len(itemName) > 50 {
-			itemName = itemName[:50]
+	log.Printf("SUCCESS: Pending order %s created for checkout key %s", order.OrderCode, idempotencyKey)
+	return attempt, nil
+}
+
+// requestPayment memanggil gateway untuk order yang sudah tersimpan lalu mencatat tokennya. Pemanggilan
+// dikunci per percobaan sehingga double-submit tidak memanggil gateway dua kali untuk order yang sama.
+func (s *CheckoutService) requestPayment(ctx context.Context, attempt *models.CheckoutAttempt) (*models.Order, string, error) {
+	claimed, err := s.attemptRepo.ClaimGateway(ctx, attempt.ID, time.Now().Add(-checkoutGatewayLease))
+	if err != nil {
+		return nil, "", err
+	}
+	if !claimed {
+		return nil, "", ErrCheckoutInProgress
+	}
+
+	order, err := s.orderRepo.GetOrderByIDWithRelations(ctx, attempt.OrderID)
+	if err != nil {
+		s.releaseGateway(ctx, attempt, err)
+		return nil, "", err
+	}
+	if order == nil || order.Status != models.OrderStatusPending {
+		return s.closeAttempt(ctx, attempt, order)
+	}
+
+	paymentRecord, err := s.paymentRepo.FindByOrderID(ctx, order.ID)
+	if err != nil {
+		s.releaseGateway(ctx, attempt, err)
+		return nil, "", err
+	}
+	if paymentRecord != nil && paymentRecord.IsManualTransfer() {
+		return s.finishManualTransfer(ctx, attempt, order)
+	}
+
+	// percobaan sebelumnya bisa saja berhasil di gateway walaupun hasilnya tidak sampai tercatat (timeout atau
+	// gagal menyimpan token); order_id yang sama akan ditolak gateway, jadi transaksi yang ada dipakai
+	if attempt.GatewayAttempts > 0 {
+		existing, err := s.provider.CheckStatus(ctx, order.OrderCode)
+		if err != nil && !errors.Is(err, payment.ErrTransactionNotFound) {
+			log.Printf("%s CheckStatus Error for OrderCode %s: %v", s.provider.Name(), order.OrderCode, err)
+			s.releaseGateway(ctx, attempt, err)
+			return nil, "", fmt.Errorf("%w: %v", ErrPaymentGatewayUnavailable, err)
+		}
+		if existing != nil {
+			return s.adoptGatewayTransaction(ctx, attempt, order, paymentRecord, existing)
 		}
-		priceForMidtrans := item.GrandTotal.Round(0).IntPart()
-		midtransItemDetails = append(midtransItemDetails, midtrans.ItemDetails{
-			ID:    item.ProductID,
-			Name:  itemName,
-			Price: int64(priceForMidtrans),
-			Qty:   int32(item.Qty),
-		})
 	}
 
-	shippingItemName := fmt.Sprintf("Biaya Pengiriman (%s - %s)", order.ShippingServiceCode, order.ShippingServiceName)
-	if len(shippingItemName) > 50 {
-		shippingItemName = shippingItemName[:50]
+	// transaksi gateway kedaluwarsa bersamaan dengan reservasi stok order, termasuk saat dicoba ulang
+	remaining := time.Until(order.CreatedAt.Add(configs.GetStockReservationTTL()))
+	if remaining < time.Minute {
+		if err := s.cancelPendingOrder(ctx, attempt, order, "reservasi stok habis sebelum token pembayaran dibuat"); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrCheckoutCancelled
 	}
-	shippingCostForMidtrans := order.ShippingCost.Round(0).IntPart()
-	midtransItemDetails = append(midtransItemDetails, midtrans.ItemDetails{
-		ID:    "SHIPPING_FEE",
-		Name:  shippingItemName,
-		Price: int64(shippingCostForMidtrans),
-		Qty:   1,
+
+	if paymentRecord != nil && paymentRecord.IsBankTransfer() {
+		return s.requestBankTransfer(ctx, attempt, order, paymentRecord.Bank, remaining)
+	}
+
+	gatewayTxn, err := s.provider.CreateTransaction(ctx, s.paymentRequest(order, remaining))
+	if err != nil {
+		log.Printf("%s CreateTransaction Error for OrderCode %s: %v", s.provider.Name(), order.OrderCode, err)
+		s.releaseGateway(ctx, attempt, err)
+		return nil, "", fmt.Errorf("%w: %v", ErrPaymentGatewayUnavailable, err)
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.paymentRepo.UpdateTokenTx(ctx, tx, order.ID, gatewayTxn.Token); err != nil {
+			return fmt.Errorf("failed to record payment token: %w", err)
+		}
+		if err := s.orderRepo.UpdateMidtransDetails(ctx, tx, order.ID, gatewayTxn.Token, gatewayTxn.RedirectURL); err != nil {
+			return fmt.Errorf("failed to record payment URL: %w", err)
+		}
+		return s.attemptRepo.MarkReady(ctx, tx, attempt.ID, gatewayTxn.RedirectURL)
 	})
+	if err != nil {
+		// transaksi di gateway sudah ada, jadi pembeli tetap diarahkan ke halaman pembayaran; kunci dibiarkan
+		// sampai kedaluwarsa lalu worker pemulihan mengambil transaksinya lewat CheckStatus
+		log.Printf("ERROR: Failed to record payment token for OrderCode %s: %v", order.OrderCode, err)
+	}
+
+	log.Printf("SUCCESS: %s transaction initiated for order %s. Redirect URL: %s", s.provider.Name(), order.OrderCode, gatewayTxn.RedirectURL)
+	order.MidtransTransactionID = gatewayTxn.Token
+	order.MidtransPaymentURL = gatewayTxn.RedirectURL
+	return order, gatewayTxn.RedirectURL, nil
+}
 
-	initialItemsTotal := decimal.Zero
-	for _, item := range midtransItemDetails {
-		initialItemsTotal = initialItemsTotal.Add(decimal.NewFromInt(item.Price).Mul(decimal.NewFromInt32(item.Qty)))
+// requestBankTransfer membuat virtual account (atau Mandiri bill) lewat Core API dan mencatat nomornya.
+// Pembeli diarahkan ke halaman instruksi pembayaran milik toko, bukan ke halaman gateway.
+func (s *CheckoutService) requestBankTransfer(ctx context.Context, attempt *models.CheckoutAttempt, order *models.Order, bank string, expiry time.Duration) (*models.Order, string, error) {
+	charge, err := s.provider.ChargeBankTransfer(ctx, s.paymentRequest(order, expiry), bank)
+	if err != nil {
+		log.Printf("%s ChargeBankTransfer Error for OrderCode %s: %v", s.provider.Name(), order.OrderCode, err)
+		s.releaseGateway(ctx, attempt, err)
+		return nil, "", fmt.Errorf("%w: %v", ErrPaymentGatewayUnavailable, err)
 	}
-	targetGrossAmount := order.GrandTotal.Round(0)
-	difference := targetGrossAmount.Sub(initialItemsTotal)
 
-	if difference.Abs().GreaterThan(decimal.NewFromFloat(0.01)) {
-		midtransItemDetails = append(midtransItemDetails, midtrans.ItemDetails{
-			ID:    "ADJUSTMENT",
-			Name:  "Penyesuaian Total Harga",
-			Price: difference.IntPart(),
-			Qty:   1,
-		})
+	redirectURL := configs.GetAppBaseURL() + "/orders/" + order.OrderCode + "/payment"
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.paymentRepo.UpdateBankTransferTx(ctx, tx, order.ID, charge.TransactionID, charge.VaNumber, charge.BillerCode, charge.BillKey, charge.ExpiresAt); err != nil {
+			return fmt.Errorf("failed to record virtual account: %w", err)
+		}
+		if err := s.orderRepo.UpdateMidtransDetails(ctx, tx, order.ID, charge.TransactionID, redirectURL); err != nil {
+			return fmt.Errorf("failed to record payment URL: %w", err)
+		}
+		return s.attemptRepo.MarkReady(ctx, tx, attempt.ID, redirectURL)
+	})
+	if err != nil {
+		// nomor VA sudah terbit di gateway tetapi belum tercatat, sehingga halaman instruksi belum bisa
+		// menampilkannya; kunci dibiarkan sampai kedaluwarsa lalu worker pemulihan mengambilnya lewat CheckStatus
+		log.Printf("ERROR: Failed to record virtual account for OrderCode %s: %v", order.OrderCode, err)
+		return nil, "", fmt.Errorf("%w: %v", ErrPaymentGatewayUnavailable, err)
 	}
-	grossAmountForMidtrans := order.GrandTotal.Round(0).IntPart()
-
-	custDetails := &midtrans.CustomerDetails{
-		FName: user.FirstName,
-		LName: user.LastName,
-		Email: user.Email,
-		Phone: user.Phone,
-		BillAddr: &midtrans.CustomerAddress{
-			FName:       address.Name,
-			Address:     address.Address1,
-			City:        address.LocationName,
-			Postcode:    address.PostCode,
-			Phone:       address.Phone,
-			CountryCode: "IDN",
-		},
-		ShipAddr: &midtrans.CustomerAddress{
-			FName:       address.Name,
-			Address:     address.Address1,
-			City:        address.LocationName,
-			Postcode:    address.PostCode,
-			Phone:       address.Phone,
-			CountryCode: "IDN",
-		},
+
+	log.Printf("SUCCESS: %s %s charge created for order %s", s.provider.Name(), payment.BankLabel(bank), order.OrderCode)
+	order.MidtransTransactionID = charge.TransactionID
+	order.MidtransPaymentURL = redirectURL
+	return order, redirectURL, nil
+}
+
+// adoptGatewayTransaction mencatat transaksi yang ternyata sudah ada di gateway. Transaksi yang masih menunggu
+// pembayaran dipakai apa adanya; status lain (dibayar, ditolak, kedaluwarsa) dijalankan lewat PaymentService
+// seperti notifikasi, sehingga order yang sudah dibayar tidak pernah dibatalkan oleh pemulihan checkout.
+func (s *CheckoutService) adoptGatewayTransaction(ctx context.Context, attempt *models.CheckoutAttempt, order *models.Order, paymentRecord *models.Payment, status *payment.Status) (*models.Order, string, error) {
+	// token Snap tidak bisa dibaca ulang dari gateway; tanpa URL tercatat pembeli diarahkan ke detail pesanan
+	redirectURL := order.MidtransPaymentURL
+	if redirectURL == "" {
+		redirectURL = configs.GetAppBaseURL() + "/orders/" + order.OrderCode
+	}
+	bankTransfer := paymentRecord != nil && paymentRecord.IsBankTransfer() && (status.VaNumber != "" || status.BillKey != "")
+	if bankTransfer {
+		redirectURL = configs.GetAppBaseURL() + "/orders/" + order.OrderCode + "/payment"
+	}
+	token := order.MidtransTransactionID
+	if token == "" {
+		token = status.TransactionID
 	}
 
-	snapReq := &snap.Request{
-		TransactionDetails: midtrans.TransactionDetails{
-			OrderID:  order.OrderCode,
-			GrossAmt: int64(grossAmountForMidtrans),
-		},
-		Items:           &midtransItemDetails,
-		CustomerDetail:  custDetails,
-		EnabledPayments: snap.AllSnapPaymentType,
-		Callbacks: &snap.Callbacks{
-			Finish: configs.GetAppBaseURL() + "/checkout/finish?order_code=" + order.OrderCode,
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if bankTransfer {
+			expiresAt := order.CreatedAt.Add(configs.GetStockReservationTTL())
+			if paymentRecord.ExpiresAt != nil {
+				expiresAt = *paymentRecord.ExpiresAt
+			}
+			if err := s.paymentRepo.UpdateBankTransferTx(ctx, tx, order.ID, status.TransactionID, status.VaNumber, status.BillerCode, status.BillKey, expiresAt); err != nil {
+				return fmt.Errorf("failed to record virtual account: %w", err)
+			}
+		}
+		return s.orderRepo.UpdateMidtransDetails(ctx, tx, order.ID, token, redirectURL)
+	})
+	if err != nil {
+		s.releaseGateway(ctx, attempt, err)
+		return nil, "", err
+	}
+	order.MidtransTransactionID = token
+	order.MidtransPaymentURL = redirectURL
+	log.Printf("INFO: Existing %s transaction for order %s recovered with status %s", s.provider.Name(), order.OrderCode, status.TransactionStatus)
+
+	if status.TransactionStatus != payment.StatusPending {
+		result, err := s.paymentSvc.ApplyGatewayStatus(ctx, status)
+		if err != nil {
+			s.releaseGateway(ctx, attempt, err)
+			return nil, "", err
+		}
+		if result.Order.Status != models.OrderStatusPending {
+			result.Order.MidtransPaymentURL = redirectURL
+			return s.closeAttempt(ctx, attempt, result.Order)
+		}
+	}
+
+	if err := s.attemptRepo.MarkReady(ctx, s.db, attempt.ID, redirectURL); err != nil {
+		return nil, "", err
+	}
+	return order, redirectURL, nil
+}
+
+// finishManualTransfer menandai checkout transfer manual selesai tanpa memanggil gateway; pembeli diarahkan ke
+// halaman rekening tujuan dan unggah bukti transfer.
+func (s *CheckoutService) finishManualTransfer(ctx context.Context, attempt *models.CheckoutAttempt, order *models.Order) (*models.Order, string, error) {
+	redirectURL := configs.GetAppBaseURL() + "/orders/" + order.OrderCode + "/payment"
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.orderRepo.UpdateMidtransDetails(ctx, tx, order.ID, "", redirectURL); err != nil {
+			return fmt.Errorf("failed to record payment URL: %w", err)
+		}
+		return s.attemptRepo.MarkReady(ctx, tx, attempt.ID, redirectURL)
+	})
+	if err != nil {
+		s.releaseGateway(ctx, attempt, err)
+		return nil, "", err
+	}
+
+	log.Printf("SUCCESS: Manual transfer order %s awaiting payment proof", order.OrderCode)
+	order.MidtransPaymentURL = redirectURL
+	return order, redirectURL, nil
+}
+
+// manualTransferUniqueCode memilih kode unik 1-999 sehingga nominal transfer tidak sama dengan transfer manual
+// lain yang masih menunggu. Jika semua percobaan bentrok, kode terakhir tetap dipakai; admin masih bisa
+// mencocokkan lewat bukti transfer.
+func (s *CheckoutService) manualTransferUniqueCode(ctx context.Context, tx *gorm.DB, total decimal.Decimal) (int, error) {
+	code := 0
+	for i := 0; i < 20; i++ {
+		code = rand.Intn(manualTransferMaxUniqueCode) + 1
+		taken, err := s.paymentRepo.PendingAmountExistsTx(ctx, tx, models.PaymentTypeManualTransfer, total.Add(decimal.NewFromInt(int64(code))))
+		if err != nil {
+			return 0, err
+		}
+		if !taken {
+			break
+		}
+	}
+	return code, nil
+}
+
+func (s *CheckoutService) paymentRequest(order *models.Order, expiry time.Duration) payment.TransactionRequest {
+	address := order.Address
+	return payment.TransactionRequest{
+		OrderID:     order.OrderCode,
+		GrossAmount: order.GrandTotal.IntPart(),
+		Items:       buildPaymentItems(order.OrderItems, order),
+		Customer: payment.Customer{
+			FirstName: order.User.FirstName,
+			LastName:  order.User.LastName,
+			Email:     order.User.Email,
+			Phone:     order.User.Phone,
+			Address: payment.Address{
+				Name:     address.Name,
+				Address:  address.Address1,
+				City:     address.LocationName,
+				PostCode: address.PostCode,
+				Phone:    address.Phone,
+			},
 		},
+		Expiry:    expiry,
+		FinishURL: configs.GetAppBaseURL() + "/checkout/finish?order_code=" + order.OrderCode,
 	}
+}
 
-	snapResp, errMidtrans := snapClient.CreateTransaction(snapReq)
+func (s *CheckoutService) releaseGateway(ctx context.Context, attempt *models.CheckoutAttempt, cause error) {
+	if err := s.attemptRepo.ReleaseGateway(ctx, attempt.ID, cause.Error()); err != nil {
+		log.Printf("ERROR: Failed to release checkout attempt %s: %v", attempt.ID, err)
+	}
+}
 
-	if errMidtrans != nil {
-		log.Printf("Midtrans CreateTransaction Error: %v", errMidtrans)
-		tx.Rollback()
-		return nil, "", fmt.Errorf("failed to initiate Midtrans transaction: %w", errMidtrans)
+// closeAttempt menutup percobaan yang order-nya sudah tidak menunggu token, misalnya sudah dibayar atau
+// dibatalkan di luar checkout.
+func (s *CheckoutService) closeAttempt(ctx context.Context, attempt *models.CheckoutAttempt, order *models.Order) (*models.Order, string, error) {
+	if order != nil && order.PaymentStatus == "Paid" {
+		if err := s.attemptRepo.MarkReady(ctx, s.db, attempt.ID, order.MidtransPaymentURL); err != nil {
+			return nil, "", err
+		}
+		return order, order.MidtransPaymentURL, nil
 	}
+	if err := s.attemptRepo.MarkCancelled(ctx, s.db, attempt.ID, "order tidak lagi menunggu pembayaran"); err != nil {
+		return nil, "", err
+	}
+	return nil, "", ErrCheckoutCancelled
+}
 
-	if snapResp == nil || snapResp.RedirectURL == "" || snapResp.Token == "" {
-		log.Printf("Midtrans CreateTransaction returned empty or invalid response for OrderCode: %s. Response: %+v", order.OrderCode, snapResp)
-		tx.Rollback()
-		return nil, "", errors.New("midtrans transaction initiated but returned invalid response (missing redirect URL or token)")
+// cancelPendingOrder membatalkan order yang tidak pernah mendapat token pembayaran dan melepas reservasi
+// stok serta pemakaian vouchernya. attempt boleh nil untuk order yang dibuat sebelum ada CheckoutAttempt.
+func (s *CheckoutService) cancelPendingOrder(ctx context.Context, attempt *models.CheckoutAttempt, order *models.Order, reason string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := s.orderRepo.LockByCode(ctx, tx, order.OrderCode)
+		if err != nil {
+			return err
+		}
+		if locked == nil || locked.Status != models.OrderStatusPending {
+			return nil
+		}
+		if err := s.orderRepo.UpdatePaymentStatusAndOrderStatus(ctx, tx, order.ID, "Cancelled", models.OrderStatusCancelled); err != nil {
+			return fmt.Errorf("failed to cancel order %s: %w", order.ID, err)
+		}
+		if err := tx.WithContext(ctx).Model(&models.Payment{}).Where("order_id = ?", order.ID).Update("status", "Cancelled").Error; err != nil {
+			return fmt.Errorf("failed to cancel payment for order %s: %w", order.ID, err)
+		}
+		if _, err := s.reservationRepo.ReleaseByOrderID(ctx, tx, order.ID); err != nil {
+			return fmt.Errorf("failed to release stock reservations for order %s: %w", order.ID, err)
+		}
+		if err := s.voucherSvc.ReleaseForOrder(ctx, tx, order.ID); err != nil {
+			return fmt.Errorf("failed to release voucher redemption for order %s: %w", order.ID, err)
+		}
+		if attempt == nil {
+			return nil
+		}
+		return s.attemptRepo.MarkCancelled(ctx, tx, attempt.ID, reason)
+	})
+	if err != nil {
+		return err
 	}
+	log.Printf("INFO: Pending order %s cancelled: %s", order.OrderCode, reason)
+	return nil
+}
 
-	newPayment.Token = snapResp.Token
+// RecoverStuckCheckouts melanjutkan percobaan checkout yang tertahan di antara langkah, misalnya karena
+// gateway timeout atau proses mati setelah order disimpan. Transaksi yang ternyata sudah ada di gateway dicatat
+// atau statusnya dijalankan; jika belum ada, token diminta ulang selama reservasi stok masih berlaku dan
+// setelah itu order dibatalkan.
+func (s *CheckoutService) RecoverStuckCheckouts(ctx context.Context) error {
+	attempts, err := s.attemptRepo.FindStuck(ctx, time.Now().Add(-checkoutGatewayLease), checkoutRecoveryBatch)
+	if err != nil {
+		return err
+	}
+	for i := range attempts {
+		attempt := &attempts[i]
+		_, _, err := s.requestPayment(ctx, attempt)
+		if err != nil && !errors.Is(err, ErrCheckoutCancelled) && !errors.Is(err, ErrCheckoutInProgress) {
+			log.Printf("CheckoutService: Gagal memulihkan checkout %s untuk order %s: %v", attempt.ID, attempt.OrderID, err)
+		}
+	}
+	return nil
+}
 
-	if err := s.paymentRepo.Create(ctx, tx, newPayment); err != nil {
-		tx.Rollback()
-		log.Printf("ERROR: Failed to create payment record for OrderID %s: %v", order.ID, err)
-		return nil, "", fmt.Errorf("failed to create payment record: %w", err)
+// StartRecoveryWorker menjalankan RecoverStuckCheckouts setiap interval sampai ctx dibatalkan.
+func (s *CheckoutService) StartRecoveryWorker(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.RecoverStuckCheckouts(ctx); err != nil {
+					log.Printf("CheckoutService: Gagal memulihkan checkout yang tertahan: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// ExpireUnpaidOrders membatalkan order gateway yang tidak dibayar sampai batas waktunya, untuk berjaga jika
+// notifikasi expire dari gateway tidak pernah sampai. Status transaksi dicek dulu lewat CheckStatus: status
+// selain pending dijalankan seperti notifikasi (termasuk pembayaran yang ternyata sudah masuk), transaksi
+// yang tidak ada di gateway dibatalkan lewat cancelPendingOrder, dan transaksi yang masih pending di gateway
+// baru di-expire setelah checkoutExpiryGrace. Reservasi stok dan pemakaian voucher ikut dilepas.
+func (s *CheckoutService) ExpireUnpaidOrders(ctx context.Context) error {
+	now := time.Now()
+	payments, err := s.paymentRepo.FindExpiredGatewayPending(ctx, now.Add(-checkoutGatewayLease), now.Add(-configs.GetStockReservationTTL()-checkoutGatewayLease), checkoutRecoveryBatch)
+	if err != nil {
+		return err
+	}
+	for i := range payments {
+		if err := s.expireUnpaidOrder(ctx, &payments[i]); err != nil {
+			log.Printf("ERROR: CheckoutService: Gagal membatalkan order %s yang tidak dibayar: %v", payments[i].Number, err)
+		}
 	}
+	return nil
+}
 
-	err = s.orderRepo.UpdatePaymentStatusAndOrderStatus(ctx, tx, order.ID, "Pending", models.OrderStatusPending)
+func (s *CheckoutService) expireUnpaidOrder(ctx context.Context, paymentRecord *models.Payment) error {
+	order := &paymentRecord.Order
+	status, err := s.provider.CheckStatus(ctx, order.OrderCode)
+	if errors.Is(err, payment.ErrTransactionNotFound) {
+		attempt, err := s.attemptRepo.FindByOrderID(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		return s.cancelPendingOrder(ctx, attempt, order, "transaksi pembayaran tidak dibuat sampai batas waktu")
+	}
 	if err != nil {
-		tx.Rollback()
-		log.Printf("ERROR: Failed to update order status for OrderID %s: %v", order.ID, err)
-		return nil, "", fmt.Errorf("failed to update order status: %w", err)
+		return fmt.Errorf("gagal mengecek status pembayaran: %w", err)
+	}
+
+	if status.TransactionStatus == payment.StatusPending {
+		expiresAt := order.CreatedAt.Add(configs.GetStockReservationTTL())
+		if paymentRecord.ExpiresAt != nil {
+			expiresAt = *paymentRecord.ExpiresAt
+		}
+		if time.Since(expiresAt) < checkoutExpiryGrace {
+			return nil
+		}
+		status = &payment.Status{OrderID: order.OrderCode, TransactionStatus: payment.StatusExpire}
 	}
 
-	err = tx.Commit().Error
+	result, err := s.paymentSvc.ApplyGatewayStatus(ctx, status)
 	if err != nil {
-		log.Printf("ERROR: Failed to commit database transaction after payment/order status update: %v", err)
-		return nil, "", fmt.Errorf("failed to commit database transaction: %w", err)
+		return err
 	}
+	log.Printf("INFO: CheckoutService: Order %s yang melewati batas pembayaran diproses: %s", order.OrderCode, result.Summary())
+	return nil
+}
+
+// StartExpiryWorker menjalankan ExpireUnpaidOrders setiap interval sampai ctx dibatalkan.
+func (s *CheckoutService) StartExpiryWorker(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.ExpireUnpaidOrders(ctx); err != nil {
+					log.Printf("CheckoutService: Gagal membatalkan order yang tidak dibayar: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// buildPaymentItems menyusun rincian item gateway dari OrderItem yang sudah disimpan, satu baris per
+// OrderItem. Gateway hanya menerima harga satuan bulat, jadi item yang GrandTotal-nya tidak habis dibagi
+// Qty dikirim sebagai satu baris Qty 1 seharga GrandTotal item, dengan jumlah unit di namanya.
+func buildPaymentItems(orderItems []models.OrderItem, order *models.Order) []payment.Item {
+	var itemDetails []payment.Item
+
+	for _, item := range orderItems {
+		if item.Qty <= 0 {
+			continue
+		}
+		itemName := item.ProductName
+		if item.VariantName != "" {
+			itemName = fmt.Sprintf("%s (%s)", item.ProductName, item.VariantName)
+		}
+		itemID := item.ProductID
+		if item.VariantID != "" {
+			itemID = item.VariantID
+		}
+
+		lineTotal := item.GrandTotal.IntPart()
+		qty := int64(item.Qty)
+		price := lineTotal / qty
+		if lineTotal%qty != 0 {
+			itemName = fmt.Sprintf("%dx %s", qty, itemName)
+			price = lineTotal
+			qty = 1
+		}
+		itemName = truncatePaymentItemName(itemName)
 
-	log.Printf("SUCCESS: Order %s created, Payment record created, and Midtrans Snap initiated. Redirect URL: %s", order.OrderCode, snapResp.RedirectURL)
-	return order, snapResp.RedirectURL, nil
+		itemDetails = append(itemDetails, payment.Item{
+			ID:    itemID,
+			Name:  itemName,
+			Price: price,
+			Qty:   int32(qty),
+		})
+	}
+
+	shippingItemName := fmt.Sprintf("Biaya Pengiriman (%s - %s)", order.ShippingServiceCode, order.ShippingServiceName)
+	shippingItemName = truncatePaymentItemName(shippingItemName)
+	itemDetails = append(itemDetails, payment.Item{
+		ID:    "SHIPPING_FEE",
+		Name:  shippingItemName,
+		Price: order.ShippingCost.IntPart(),
+		Qty:   1,
+	})
+
+	return itemDetails
+}
+
+// truncatePaymentItemName memotong nama item ke 50 karakter, batas nama item Midtrans. Dipotong per rune
+// supaya nama dengan karakter multibyte tetap UTF-8 yang valid.
+func truncatePaymentItemName(name string) string {
+	runes := []rune(name)
+	if len(runes) > 50 {
+		return string(runes[:50])
+	}
+	return name
 }
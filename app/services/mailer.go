@@ -2,8 +2,14 @@ package services
 
 import (
 	"fmt"
+	"html"
 	"log"
 	"net/smtp"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	format "github.com/Rakhulsr/go-ecommerce/app/utils/format"
+	"github.com/shopspring/decimal"
 )
 
 type Config struct {
@@ -91,3 +97,209 @@ func BuildOTPEmailBody(otpCode string, expiryMinutes int) string {
         </html>
     `, otpCode, expiryMinutes)
 }
+
+func BuildBackInStockEmailBody(productName, productURL string) string {
+	return fmt.Sprintf(`
+        <!DOCTYPE html>
+        <html>
+        <head>
+            <meta charset="utf-8">
+            <title>Produk Wishlist Anda Tersedia Kembali</title>
+            <style>
+                body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+                .container { max-width: 600px; margin: 20px auto; padding: 20px; border: 1px solid #ddd; border-radius: 5px; }
+                .header { background-color: #f8f8f8; padding: 10px 0; text-align: center; border-bottom: 1px solid #ddd; }
+                .content { padding: 20px; text-align: center; }
+                .button { display: inline-block; margin: 20px 0; padding: 10px 20px; background-color: #007bff; color: #fff; text-decoration: none; border-radius: 5px; }
+                .footer { font-size: 0.8em; color: #777; text-align: center; margin-top: 20px; border-top: 1px solid #ddd; padding-top: 10px; }
+            </style>
+        </head>
+        <body>
+            <div class="container">
+                <div class="header">
+                    <h2>Stok Tersedia Kembali</h2>
+                </div>
+                <div class="content">
+                    <p>Kabar baik! <strong>%s</strong> yang ada di wishlist Anda sudah tersedia kembali.</p>
+                    <p>Stok bisa cepat habis, segera amankan pesanan Anda.</p>
+                    <a class="button" href="%s">Lihat Produk</a>
+                    <p>Terima kasih,</p>
+                    <p>Tim Toko Bulan</p>
+                </div>
+                <div class="footer">
+                    <p>Anda menerima email ini karena mengaktifkan notifikasi stok di wishlist.</p>
+                    <p>&copy; 2025 Toko Bulan. Semua hak dilindungi.</p>
+                </div>
+            </div>
+        </body>
+        </html>
+    `, html.EscapeString(productName), productURL)
+}
+
+func BuildPriceDropEmailBody(productName string, oldPrice, newPrice decimal.Decimal, productURL string) string {
+	return fmt.Sprintf(`
+        <!DOCTYPE html>
+        <html>
+        <head>
+            <meta charset="utf-8">
+            <title>Harga Produk Wishlist Anda Turun</title>
+            <style>
+                body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+                .container { max-width: 600px; margin: 20px auto; padding: 20px; border: 1px solid #ddd; border-radius: 5px; }
+                .header { background-color: #f8f8f8; padding: 10px 0; text-align: center; border-bottom: 1px solid #ddd; }
+                .content { padding: 20px; text-align: center; }
+                .old-price { color: #999; text-decoration: line-through; }
+                .new-price { font-size: 1.6em; font-weight: bold; color: #28a745; }
+                .button { display: inline-block; margin: 20px 0; padding: 10px 20px; background-color: #007bff; color: #fff; text-decoration: none; border-radius: 5px; }
+                .footer { font-size: 0.8em; color: #777; text-align: center; margin-top: 20px; border-top: 1px solid #ddd; padding-top: 10px; }
+            </style>
+        </head>
+        <body>
+            <div class="container">
+                <div class="header">
+                    <h2>Harga Turun!</h2>
+                </div>
+                <div class="content">
+                    <p>Harga <strong>%s</strong> di wishlist Anda baru saja turun.</p>
+                    <p class="old-price">%s</p>
+                    <p class="new-price">%s</p>
+                    <a class="button" href="%s">Lihat Produk</a>
+                    <p>Terima kasih,</p>
+                    <p>Tim Toko Bulan</p>
+                </div>
+                <div class="footer">
+                    <p>Anda menerima email ini karena mengaktifkan notifikasi harga di wishlist.</p>
+                    <p>&copy; 2025 Toko Bulan. Semua hak dilindungi.</p>
+                </div>
+            </div>
+        </body>
+        </html>
+    `, html.EscapeString(productName), format.FormatRupiah(oldPrice), format.FormatRupiah(newPrice), productURL)
+}
+
+// BuildAbandonedCartEmailBody membuat isi email pengingat keranjang yang ditinggalkan. restoreURL adalah tautan
+// bertanda tangan yang mengembalikan isi keranjang.
+func BuildAbandonedCartEmailBody(firstName string, items []models.CartRecoveryItem, total decimal.Decimal, restoreURL string) string {
+	var rows strings.Builder
+	for _, item := range items {
+		fmt.Fprintf(&rows, `
+                        <tr>
+                            <td style="padding: 6px; border-bottom: 1px solid #eee;">%s</td>
+                            <td style="padding: 6px; border-bottom: 1px solid #eee; text-align: center;">%d</td>
+                            <td style="padding: 6px; border-bottom: 1px solid #eee; text-align: right;">%s</td>
+                        </tr>`, html.EscapeString(item.Name), item.Qty, format.FormatRupiah(item.Subtotal))
+	}
+
+	return fmt.Sprintf(`
+        <!DOCTYPE html>
+        <html>
+        <head>
+            <meta charset="utf-8">
+            <title>Keranjang Anda Masih Menunggu</title>
+            <style>
+                body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+                .container { max-width: 600px; margin: 20px auto; padding: 20px; border: 1px solid #ddd; border-radius: 5px; }
+                .header { background-color: #f8f8f8; padding: 10px 0; text-align: center; border-bottom: 1px solid #ddd; }
+                .content { padding: 20px; }
+                table { width: 100%%; border-collapse: collapse; margin: 15px 0; }
+                .total { font-size: 1.2em; font-weight: bold; text-align: right; }
+                .button { display: inline-block; margin: 20px 0; padding: 10px 20px; background-color: #007bff; color: #fff; text-decoration: none; border-radius: 5px; }
+                .footer { font-size: 0.8em; color: #777; text-align: center; margin-top: 20px; border-top: 1px solid #ddd; padding-top: 10px; }
+            </style>
+        </head>
+        <body>
+            <div class="container">
+                <div class="header">
+                    <h2>Keranjang Anda Masih Menunggu</h2>
+                </div>
+                <div class="content">
+                    <p>Halo %s,</p>
+                    <p>Anda masih memiliki produk di keranjang belanja yang belum dibayar:</p>
+                    <table>
+                        <tr>
+                            <th style="padding: 6px; text-align: left;">Produk</th>
+                            <th style="padding: 6px;">Jumlah</th>
+                            <th style="padding: 6px; text-align: right;">Subtotal</th>
+                        </tr>%s
+                    </table>
+                    <p class="total">Total: %s</p>
+                    <p style="text-align: center;"><a class="button" href="%s">Lanjutkan Belanja</a></p>
+                    <p>Harga dan stok dapat berubah sewaktu-waktu.</p>
+                    <p>Terima kasih,</p>
+                    <p>Tim Toko Bulan</p>
+                </div>
+                <div class="footer">
+                    <p>Anda menerima email ini karena meninggalkan produk di keranjang belanja.</p>
+                    <p>&copy; 2025 Toko Bulan. Semua hak dilindungi.</p>
+                </div>
+            </div>
+        </body>
+        </html>
+    `, html.EscapeString(firstName), rows.String(), format.FormatRupiah(total), restoreURL)
+}
+
+// BuildRefundEmailBody membuat isi email pemberitahuan pengembalian dana. items boleh kosong untuk refund
+// dengan nominal bebas.
+func BuildRefundEmailBody(firstName, orderCode string, amount decimal.Decimal, reason string, items []models.RefundItem, full bool) string {
+	var rows strings.Builder
+	for _, item := range items {
+		fmt.Fprintf(&rows, `
+                        <tr>
+                            <td style="padding: 6px; border-bottom: 1px solid #eee;">%s</td>
+                            <td style="padding: 6px; border-bottom: 1px solid #eee; text-align: center;">%d</td>
+                        </tr>`, html.EscapeString(item.Name), item.Qty)
+	}
+	itemsTable := ""
+	if rows.Len() > 0 {
+		itemsTable = `
+                    <table>
+                        <tr>
+                            <th style="padding: 6px; text-align: left;">Produk</th>
+                            <th style="padding: 6px;">Jumlah</th>
+                        </tr>` + rows.String() + `
+                    </table>`
+	}
+	scope := "sebagian"
+	if full {
+		scope = "seluruh"
+	}
+
+	return fmt.Sprintf(`
+        <!DOCTYPE html>
+        <html>
+        <head>
+            <meta charset="utf-8">
+            <title>Pengembalian Dana Pesanan</title>
+            <style>
+                body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+                .container { max-width: 600px; margin: 20px auto; padding: 20px; border: 1px solid #ddd; border-radius: 5px; }
+                .header { background-color: #f8f8f8; padding: 10px 0; text-align: center; border-bottom: 1px solid #ddd; }
+                .content { padding: 20px; }
+                table { width: 100%%; border-collapse: collapse; margin: 15px 0; }
+                .total { font-size: 1.2em; font-weight: bold; text-align: right; }
+                .footer { font-size: 0.8em; color: #777; text-align: center; margin-top: 20px; border-top: 1px solid #ddd; padding-top: 10px; }
+            </style>
+        </head>
+        <body>
+            <div class="container">
+                <div class="header">
+                    <h2>Pengembalian Dana Pesanan</h2>
+                </div>
+                <div class="content">
+                    <p>Halo %s,</p>
+                    <p>Kami telah memproses pengembalian dana untuk %s pembayaran pesanan <strong>%s</strong>.</p>%s
+                    <p class="total">Jumlah dikembalikan: %s</p>
+                    <p>Alasan: %s</p>
+                    <p>Dana akan diterima melalui metode pembayaran yang Anda gunakan. Lama prosesnya mengikuti ketentuan bank atau penyedia pembayaran.</p>
+                    <p>Terima kasih,</p>
+                    <p>Tim Toko Bulan</p>
+                </div>
+                <div class="footer">
+                    <p>Anda menerima email ini karena ada pengembalian dana untuk pesanan Anda.</p>
+                    <p>&copy; 2025 Toko Bulan. Semua hak dilindungi.</p>
+                </div>
+            </div>
+        </body>
+        </html>
+    `, html.EscapeString(firstName), scope, html.EscapeString(orderCode), itemsTable, format.FormatRupiah(amount), html.EscapeString(reason))
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/configs"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/payment"
+	"gorm.io/gorm"
+)
+
+const manualTransferExpiryBatch = 100
+
+var (
+	ErrManualTransferNotFound     = errors.New("pesanan dengan transfer manual tidak ditemukan")
+	ErrManualTransferClosed       = errors.New("pesanan tidak lagi menunggu pembayaran")
+	ErrManualTransferExpired      = errors.New("batas waktu pembayaran sudah lewat")
+	ErrPaymentProofPending        = errors.New("bukti transfer sebelumnya masih menunggu verifikasi")
+	ErrPaymentProofNotFound       = errors.New("bukti transfer tidak ditemukan")
+	ErrPaymentProofReviewed       = errors.New("bukti transfer sudah diverifikasi")
+	ErrPaymentProofReasonRequired = errors.New("alasan penolakan wajib diisi")
+	ErrPaymentProofImageRequired  = errors.New("foto bukti transfer wajib diunggah")
+	ErrPaymentProofSenderRequired = errors.New("nama pemilik rekening pengirim wajib diisi")
+)
+
+// PaymentProofInput adalah data bukti transfer dari pembeli. ImagePath adalah URL hasil upload ke storage.
+type PaymentProofInput struct {
+	ImagePath  string
+	SenderName string
+	SenderBank string
+}
+
+// ManualTransferPayment adalah data halaman instruksi transfer manual.
+type ManualTransferPayment struct {
+	Order           *models.Order
+	Payment         *models.Payment
+	Account         configs.ManualTransferConfig
+	Proofs          []models.PaymentProof
+	HasPendingProof bool
+	CanUpload       bool
+}
+
+// ManualTransferService mengelola transfer manual ke rekening toko: bukti transfer dari pembeli, verifikasi
+// admin, dan pembatalan order yang tidak dibayar sampai batas waktunya. Persetujuan dan pembatalan dijalankan
+// lewat PaymentService sehingga efeknya sama dengan notifikasi settlement atau expire dari gateway.
+type ManualTransferService struct {
+	db              *gorm.DB
+	orderRepo       repositories.OrderRepository
+	paymentRepo     repositories.PaymentRepositoryImpl
+	proofRepo       repositories.PaymentProofRepository
+	reservationRepo repositories.StockReservationRepository
+	paymentSvc      *PaymentService
+}
+
+func NewManualTransferService(
+	db *gorm.DB,
+	orderRepo repositories.OrderRepository,
+	paymentRepo repositories.PaymentRepositoryImpl,
+	proofRepo repositories.PaymentProofRepository,
+	reservationRepo repositories.StockReservationRepository,
+	paymentSvc *PaymentService,
+) *ManualTransferService {
+	return &ManualTransferService{
+		db:              db,
+		orderRepo:       orderRepo,
+		paymentRepo:     paymentRepo,
+		proofRepo:       proofRepo,
+		reservationRepo: reservationRepo,
+		paymentSvc:      paymentSvc,
+	}
+}
+
+// GetPayment mengembalikan data transfer manual milik userID untuk halaman instruksi pembayaran.
+func (s *ManualTransferService) GetPayment(ctx context.Context, userID, orderCode string) (*ManualTransferPayment, error) {
+	order, err := s.orderRepo.FindByCodeWithDetails(ctx, orderCode)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil pesanan %s: %w", orderCode, err)
+	}
+	if order == nil || order.UserID != userID {
+		return nil, ErrManualTransferNotFound
+	}
+	paymentRecord, err := s.paymentRepo.FindByOrderID(ctx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil pembayaran pesanan %s: %w", orderCode, err)
+	}
+	if paymentRecord == nil || !paymentRecord.IsManualTransfer() {
+		return nil, ErrManualTransferNotFound
+	}
+	proofs, err := s.proofRepo.FindByOrderID(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ManualTransferPayment{
+		Order:   order,
+		Payment: paymentRecord,
+		Account: configs.GetManualTransferConfig(),
+		Proofs:  proofs,
+	}
+	for _, proof := range proofs {
+		if proof.Status == models.PaymentProofPending {
+			result.HasPendingProof = true
+		}
+	}
+	result.CanUpload = !result.HasPendingProof && order.Status == models.OrderStatusPending && !manualTransferExpired(paymentRecord)
+	return result, nil
+}
+
+// SubmitProof menyimpan bukti transfer untuk antrean verifikasi admin. Hanya satu bukti yang boleh menunggu
+// verifikasi per order; setelah ditolak pembeli boleh mengunggah ulang. Reservasi stok order diperpanjang
+// selama batas waktu transfer manual karena ExpireUnpaid tidak membatalkan order yang buktinya belum diperiksa;
+// tanpa itu stok bisa habis dipesan orang lain sebelum bukti disetujui.
+func (s *ManualTransferService) SubmitProof(ctx context.Context, userID, orderCode string, input PaymentProofInput) (*models.PaymentProof, error) {
+	input.SenderName = strings.TrimSpace(input.SenderName)
+	input.SenderBank = strings.TrimSpace(input.SenderBank)
+	if input.ImagePath == "" {
+		return nil, ErrPaymentProofImageRequired
+	}
+	if input.SenderName == "" {
+		return nil, ErrPaymentProofSenderRequired
+	}
+
+	data, err := s.GetPayment(ctx, userID, orderCode)
+	if err != nil {
+		return nil, err
+	}
+	if data.Order.Status != models.OrderStatusPending {
+		return nil, ErrManualTransferClosed
+	}
+	if manualTransferExpired(data.Payment) {
+		return nil, ErrManualTransferExpired
+	}
+
+	var proof *models.PaymentProof
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// baris order dikunci agar dua unggahan bersamaan tidak sama-sama lolos pemeriksaan bukti pending
+		order, err := s.orderRepo.LockByCode(ctx, tx, orderCode)
+		if err != nil {
+			return err
+		}
+		if order == nil || order.Status != models.OrderStatusPending {
+			return ErrManualTransferClosed
+		}
+		pending, err := s.proofRepo.HasPending(ctx, tx, order.ID)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return ErrPaymentProofPending
+		}
+		extended, err := s.reservationRepo.ExtendByOrderID(ctx, tx, order.ID, time.Now().Add(configs.GetManualTransferConfig().TTL))
+		if err != nil {
+			return err
+		}
+		if extended == 0 {
+			log.Printf("WARNING: ManualTransferService: Order %s has no active stock reservation left to extend", orderCode)
+		}
+		proof = &models.PaymentProof{
+			OrderID:    order.ID,
+			PaymentID:  data.Payment.ID,
+			ImagePath:  input.ImagePath,
+			SenderName: input.SenderName,
+			SenderBank: input.SenderBank,
+			Status:     models.PaymentProofPending,
+		}
+		return s.proofRepo.Create(ctx, tx, proof)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("INFO: ManualTransferService: Payment proof %s submitted for order %s", proof.ID, orderCode)
+	return proof, nil
+}
+
+// ApproveProof menyetujui bukti transfer lalu menjalankan efek settlement: order diproses, stok dikurangi dan
+// keranjang dikosongkan, dalam satu transaksi dengan status bukti.
+func (s *ManualTransferService) ApproveProof(ctx context.Context, proofID, reviewerID string) (*PaymentTransition, error) {
+	proof, err := s.proofRepo.FindByID(ctx, proofID)
+	if err != nil {
+		return nil, err
+	}
+	if proof == nil || proof.Order == nil {
+		return nil, ErrPaymentProofNotFound
+	}
+
+	var result *PaymentTransition
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := s.orderRepo.LockByCode(ctx, tx, proof.Order.OrderCode)
+		if err != nil {
+			return err
+		}
+		if order == nil || order.Status != models.OrderStatusPending {
+			return ErrManualTransferClosed
+		}
+		claimed, err := s.proofRepo.MarkReviewed(ctx, tx, proof.ID, models.PaymentProofApproved, "", reviewerID)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return ErrPaymentProofReviewed
+		}
+		result, err = s.paymentSvc.ApplyStatusTx(ctx, tx, order.OrderCode, payment.StatusSettlement)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("SUCCESS: ManualTransferService: Payment proof %s approved by %s. Order %s: %s", proof.ID, reviewerID, proof.Order.OrderCode, result.Summary())
+	return result, nil
+}
+
+// RejectProof menolak bukti transfer. Order tetap menunggu pembayaran sehingga pembeli bisa mengunggah ulang
+// sebelum batas waktu.
+func (s *ManualTransferService) RejectProof(ctx context.Context, proofID, reviewerID, reason string) (*models.PaymentProof, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrPaymentProofReasonRequired
+	}
+	proof, err := s.proofRepo.FindByID(ctx, proofID)
+	if err != nil {
+		return nil, err
+	}
+	if proof == nil {
+		return nil, ErrPaymentProofNotFound
+	}
+
+	claimed, err := s.proofRepo.MarkReviewed(ctx, s.db, proof.ID, models.PaymentProofRejected, reason, reviewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrPaymentProofReviewed
+	}
+
+	log.Printf("INFO: ManualTransferService: Payment proof %s rejected by %s: %s", proof.ID, reviewerID, reason)
+	return proof, nil
+}
+
+// ExpireUnpaid membatalkan order transfer manual yang melewati batas waktu tanpa bukti transfer yang sedang
+// menunggu verifikasi. Pembatalan memakai efek notifikasi expire: reservasi stok dan voucher dilepas.
+func (s *ManualTransferService) ExpireUnpaid(ctx context.Context) error {
+	payments, err := s.paymentRepo.FindExpiredPending(ctx, models.PaymentTypeManualTransfer, time.Now(), manualTransferExpiryBatch)
+	if err != nil {
+		return err
+	}
+	for _, p := range payments {
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			order, err := s.orderRepo.LockByCode(ctx, tx, p.Number)
+			if err != nil {
+				return err
+			}
+			if order == nil || order.Status != models.OrderStatusPending {
+				return nil
+			}
+			pending, err := s.proofRepo.HasPending(ctx, tx, order.ID)
+			if err != nil || pending {
+				return err
+			}
+			result, err := s.paymentSvc.ApplyStatusTx(ctx, tx, order.OrderCode, payment.StatusExpire)
+			if err != nil {
+				return err
+			}
+			log.Printf("INFO: ManualTransferService: Order %s expired without payment: %s", order.OrderCode, result.Summary())
+			return nil
+		})
+		if err != nil {
+			log.Printf("ERROR: ManualTransferService: Failed to expire order %s: %v", p.Number, err)
+		}
+	}
+	return nil
+}
+
+// StartExpiryWorker menjalankan ExpireUnpaid secara berkala sampai ctx dibatalkan.
+func (s *ManualTransferService) StartExpiryWorker(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.ExpireUnpaid(ctx); err != nil {
+					log.Printf("ManualTransferService: gagal membatalkan transfer manual kedaluwarsa: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func manualTransferExpired(p *models.Payment) bool {
+	return p.ExpiresAt != nil && time.Now().After(*p.ExpiresAt)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/calc"
+	"github.com/shopspring/decimal"
+)
+
+// orderLineAmounts adalah nilai rupiah bulat satu item order. GrandTotal selalu sama dengan
+// BaseTotal - VoucherDiscount (+ TaxAmount jika harga belum termasuk pajak).
+type orderLineAmounts struct {
+	BaseTotal       decimal.Decimal
+	VoucherDiscount decimal.Decimal
+	TaxAmount       decimal.Decimal
+	GrandTotal      decimal.Decimal
+}
+
+// allocateOrderLines membulatkan subtotal, potongan voucher dan pajak keranjang ke rupiah lalu
+// membaginya ke item dengan calc.Allocate, berbobot nilai per item yang sudah dihitung CartService dan
+// TaxService. Potongan voucher per item dibatasi BaseTotal item itu sehingga GrandTotal tidak pernah
+// negatif. Karena setiap komponen dibagi tepat sebesar totalnya, jumlah item selalu sama dengan
+// total order dan bisa dikirim apa adanya ke payment gateway.
+func allocateOrderLines(cart *models.Cart) []orderLineAmounts {
+	n := len(cart.CartItems)
+	subtotals := make([]decimal.Decimal, n)
+	vouchers := make([]decimal.Decimal, n)
+	taxes := make([]decimal.Decimal, n)
+	voucherTotal := decimal.Zero
+	taxTotal := decimal.Zero
+	for i, item := range cart.CartItems {
+		subtotals[i] = item.Subtotal
+		vouchers[i] = item.VoucherDiscount
+		taxes[i] = item.TaxAmount
+		voucherTotal = voucherTotal.Add(item.VoucherDiscount)
+		taxTotal = taxTotal.Add(item.TaxAmount)
+	}
+
+	baseTotals := calc.Allocate(cart.BaseTotalPrice, subtotals, 0)
+	voucherShares := capVoucherShares(calc.Allocate(decimal.Min(voucherTotal.Round(0), cart.BaseTotalPrice.Round(0)), vouchers, 0), baseTotals)
+	taxShares := calc.Allocate(taxTotal, taxes, 0)
+
+	lines := make([]orderLineAmounts, n)
+	for i := range lines {
+		grandTotal := baseTotals[i].Sub(voucherShares[i])
+		if !cart.TaxIncluded {
+			grandTotal = grandTotal.Add(taxShares[i])
+		}
+		lines[i] = orderLineAmounts{
+			BaseTotal:       baseTotals[i],
+			VoucherDiscount: voucherShares[i],
+			TaxAmount:       taxShares[i],
+			GrandTotal:      grandTotal,
+		}
+	}
+	return lines
+}
+
+// capVoucherShares memotong bagian voucher yang melebihi BaseTotal item akibat pembulatan terpisah, lalu
+// memindahkan kelebihannya ke item lain yang masih punya ruang, dimulai dari indeks terkecil. Jumlah
+// bagian tidak berubah selama totalnya tidak melebihi jumlah baseTotals.
+func capVoucherShares(shares, baseTotals []decimal.Decimal) []decimal.Decimal {
+	excess := decimal.Zero
+	for i := range shares {
+		if shares[i].GreaterThan(baseTotals[i]) {
+			excess = excess.Add(shares[i].Sub(baseTotals[i]))
+			shares[i] = baseTotals[i]
+		}
+	}
+	for i := range shares {
+		if !excess.IsPositive() {
+			break
+		}
+		room := baseTotals[i].Sub(shares[i])
+		if !room.IsPositive() {
+			continue
+		}
+		moved := decimal.Min(room, excess)
+		shares[i] = shares[i].Add(moved)
+		excess = excess.Sub(moved)
+	}
+	return shares
+}
@@ -2,191 +2,551 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
-	"github.com/midtrans/midtrans-go"
-	"github.com/midtrans/midtrans-go/coreapi"
-
-	"github.com/Rakhulsr/go-ecommerce/app/configs"
 	"github.com/Rakhulsr/go-ecommerce/app/models"
 	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/payment"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
-type MidtransNotificationPayload struct {
-	TransactionStatus string `json:"transaction_status"`
-	OrderID           string `json:"order_id"`
-	PaymentType       string `json:"payment_type"`
-	FraudStatus       string `json:"fraud_status"`
-	GrossAmount       string `json:"gross_amount"`
-	SignatureKey      string `json:"signature_key"`
+// paymentNotificationLease adalah batas waktu notifikasi berstatus received dianggap milik request yang
+// sudah mati sehingga boleh diproses ulang.
+const paymentNotificationLease = 2 * time.Minute
 
-	StatusCode string `json:"status_code"`
-	Currency   string `json:"currency"`
-}
+var (
+	ErrNotificationInProgress = errors.New("notifikasi pembayaran yang sama sedang diproses")
+	ErrNotificationNotFound   = errors.New("notifikasi pembayaran tidak ditemukan")
+)
 
 type PaymentService struct {
-	orderRepo             repositories.OrderRepository
-	paymentRepo           repositories.PaymentRepositoryImpl
-	db                    *gorm.DB
-	midtransCoreAPIClient coreapi.Client
+	orderRepo         repositories.OrderRepository
+	paymentRepo       repositories.PaymentRepositoryImpl
+	reservationRepo   repositories.StockReservationRepository
+	voucherSvc        *VoucherService
+	db                *gorm.DB
+	provider          payment.PaymentProvider
+	notificationRepo  repositories.PaymentNotificationRepository
+	productRepo       repositories.ProductRepositoryImpl
+	stockMovementRepo repositories.StockMovementRepository
+	cartRepo          repositories.CartRepositoryImpl
+	cartItemRepo      repositories.CartItemRepositoryImpl
+	refundRepo        repositories.RefundRepository
+	wishlistSvc       *WishlistService
 }
 
 func NewPaymentService(
 	orderRepo repositories.OrderRepository,
 	paymentRepo repositories.PaymentRepositoryImpl,
+	reservationRepo repositories.StockReservationRepository,
+	voucherSvc *VoucherService,
 	db *gorm.DB,
+	provider payment.PaymentProvider,
+	notificationRepo repositories.PaymentNotificationRepository,
+	productRepo repositories.ProductRepositoryImpl,
+	stockMovementRepo repositories.StockMovementRepository,
+	cartRepo repositories.CartRepositoryImpl,
+	cartItemRepo repositories.CartItemRepositoryImpl,
+	refundRepo repositories.RefundRepository,
+	wishlistSvc *WishlistService,
 ) *PaymentService {
-	coreAPIClient := configs.GetMidtransCoreAPIClient()
 	return &PaymentService{
-		orderRepo:             orderRepo,
-		paymentRepo:           paymentRepo,
-		db:                    db,
-		midtransCoreAPIClient: coreAPIClient,
+		orderRepo:         orderRepo,
+		paymentRepo:       paymentRepo,
+		reservationRepo:   reservationRepo,
+		voucherSvc:        voucherSvc,
+		db:                db,
+		provider:          provider,
+		notificationRepo:  notificationRepo,
+		productRepo:       productRepo,
+		stockMovementRepo: stockMovementRepo,
+		cartRepo:          cartRepo,
+		cartItemRepo:      cartItemRepo,
+		refundRepo:        refundRepo,
+		wishlistSvc:       wishlistSvc,
 	}
 }
 
-func (s *PaymentService) ProcessMidtransNotification(ctx context.Context, payload MidtransNotificationPayload) (
-	newPaymentStatus string,
-	newOrderStatus int,
-	shouldReduceStock bool,
-	shouldClearCart bool,
-	shouldRefundStock bool,
-	order *models.Order,
-	err error,
-) {
+// ReceiveNotification memverifikasi signature body webhook secara lokal lalu menyimpannya ke log notifikasi,
+// termasuk yang ditolak. duplicate bernilai true jika body yang sama sudah pernah diterima dan tidak perlu
+// diproses lagi; notifikasi yang sebelumnya gagal diproses dikembalikan dengan duplicate false.
+func (s *PaymentService) ReceiveNotification(ctx context.Context, body []byte) (notification *models.PaymentNotification, duplicate bool, err error) {
+	sum := sha256.Sum256(body)
+	notification = &models.PaymentNotification{
+		Provider:   s.provider.Name(),
+		DedupKey:   hex.EncodeToString(sum[:]),
+		RawBody:    string(body),
+		Outcome:    models.PaymentNotificationReceived,
+		Deliveries: 1,
+	}
+	verifyErr := s.verifyNotification(ctx, notification)
+	if verifyErr != nil && !errors.Is(verifyErr, payment.ErrInvalidNotification) {
+		// gagal membaca pembayaran dari database; notifikasi tidak dicatat ditolak agar gateway mengirim ulang
+		return nil, false, verifyErr
+	}
+	if verifyErr != nil {
+		log.Printf("WARNING: PaymentService: Rejected %s notification for OrderCode %s: %v", s.provider.Name(), notification.OrderCode, verifyErr)
+		now := time.Now()
+		notification.Outcome = models.PaymentNotificationRejected
+		notification.VerifyError = verifyErr.Error()
+		notification.ProcessedAt = &now
+	}
 
-	var transactionStatus *coreapi.TransactionStatusResponse
-	var midtransErr *midtrans.Error
+	if err := s.notificationRepo.Create(ctx, notification); err != nil {
+		existing, findErr := s.notificationRepo.FindByDedupKey(ctx, notification.DedupKey)
+		if findErr != nil || existing == nil {
+			return nil, false, err
+		}
+		return s.redeliveredNotification(ctx, existing)
+	}
+	if verifyErr != nil {
+		return notification, false, verifyErr
+	}
+	return notification, false, nil
+}
 
-	transactionStatus, midtransErr = s.midtransCoreAPIClient.CheckTransaction(payload.OrderID)
+func (s *PaymentService) redeliveredNotification(ctx context.Context, existing *models.PaymentNotification) (*models.PaymentNotification, bool, error) {
+	if err := s.notificationRepo.RecordDelivery(ctx, existing.ID); err != nil {
+		log.Printf("WARNING: PaymentService: %v", err)
+	}
 
-	if midtransErr != nil {
-		log.Printf("ERROR: PaymentService: Failed to check transaction status with Midtrans API for OrderCode %s: %v", payload.OrderID, midtransErr.Error())
-		return "", 0, false, false, false, nil, fmt.Errorf("failed to verify transaction with Midtrans (API error): %w", midtransErr.RawError)
+	switch existing.Outcome {
+	case models.PaymentNotificationRejected:
+		return existing, true, fmt.Errorf("%w: pengiriman ulang notifikasi yang sudah ditolak", payment.ErrInvalidNotification)
+	case models.PaymentNotificationProcessed:
+		log.Printf("INFO: PaymentService: Duplicate notification %s for OrderCode %s acknowledged without reprocessing.", existing.ID, existing.OrderCode)
+		return existing, true, nil
 	}
 
-	if transactionStatus == nil {
-		log.Printf("ERROR: PaymentService: Midtrans API returned nil transaction status for OrderCode %s (no API error). This indicates an unexpected response structure.", payload.OrderID)
-		return "", 0, false, false, false, nil, errors.New("invalid transaction status from Midtrans API (nil response)")
+	claimed, err := s.notificationRepo.Claim(ctx, existing.ID, time.Now().Add(-paymentNotificationLease))
+	if err != nil {
+		return nil, false, err
+	}
+	if !claimed {
+		return existing, true, ErrNotificationInProgress
 	}
+	log.Printf("INFO: PaymentService: Reprocessing notification %s for OrderCode %s after previous outcome %s.", existing.ID, existing.OrderCode, existing.Outcome)
+	return existing, false, nil
+}
 
-	if transactionStatus.StatusCode == "404" {
-		log.Printf("WARNING: PaymentService: Order %s not found in Midtrans system (StatusCode: 404).", payload.OrderID)
-		return "", 0, false, false, false, nil, errors.New("order not found in Midtrans system")
+// ReplayNotification memverifikasi ulang notifikasi tersimpan lalu memprosesnya lagi atas permintaan admin,
+// apa pun hasil pemrosesan sebelumnya.
+func (s *PaymentService) ReplayNotification(ctx context.Context, id string) (*PaymentTransition, error) {
+	notification, err := s.notificationRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	if len(transactionStatus.StatusCode) > 0 && transactionStatus.StatusCode[0] == '5' {
-		log.Printf("ERROR: PaymentService: Midtrans API returned server error for OrderCode %s (StatusCode: %s).", payload.OrderID, transactionStatus.StatusCode)
-		return "", 0, false, false, false, nil, fmt.Errorf("midtrans API server error: %s", transactionStatus.StatusCode)
+	if notification == nil {
+		return nil, ErrNotificationNotFound
+	}
+	if err := s.verifyNotification(ctx, notification); err != nil {
+		return nil, err
 	}
 
-	if transactionStatus.TransactionStatus != payload.TransactionStatus ||
-		transactionStatus.FraudStatus != payload.FraudStatus {
-		log.Printf("WARNING: PaymentService: Mismatch in transaction status for OrderCode %s. API: %s/%s, Notification: %s/%s. Proceeding with API status.",
-			payload.OrderID,
-			transactionStatus.TransactionStatus, transactionStatus.FraudStatus,
-			payload.TransactionStatus, payload.FraudStatus)
+	claimed, err := s.notificationRepo.ClaimReplay(ctx, notification.ID, time.Now().Add(-paymentNotificationLease))
+	if err != nil {
+		return nil, err
 	}
+	if !claimed {
+		return nil, ErrNotificationInProgress
+	}
+	log.Printf("INFO: PaymentService: Replaying notification %s for OrderCode %s.", notification.ID, notification.OrderCode)
+	return s.ProcessNotification(ctx, notification)
+}
+
+// verifyNotification membaca RawBody, memeriksa signature-nya lewat provider, lalu mencocokkan gross_amount
+// dengan Payment. Field status pada notification diisi dari body supaya notifikasi yang ditolak pun tetap bisa ditelusuri di log.
+func (s *PaymentService) verifyNotification(ctx context.Context, notification *models.PaymentNotification) error {
+	parsed, err := s.provider.ParseNotification([]byte(notification.RawBody))
+	if err != nil {
+		return err
+	}
+	notification.OrderCode = parsed.OrderID
+	notification.TransactionID = parsed.TransactionID
+	notification.TransactionStatus = parsed.TransactionStatus
+	notification.FraudStatus = parsed.FraudStatus
+	notification.StatusCode = parsed.StatusCode
+	notification.GrossAmount = parsed.GrossAmount
+
+	status, err := s.provider.VerifyNotification(ctx, parsed)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyGrossAmount(ctx, status); err != nil {
+		return err
+	}
+	notification.Verified = true
+	notification.TransactionStatus = status.TransactionStatus
+	notification.FraudStatus = status.FraudStatus
+	return nil
+}
 
-	order, err = s.orderRepo.FindByCodeWithDetails(ctx, payload.OrderID)
+// verifyGrossAmount menolak notifikasi yang gross_amount-nya berbeda dengan nominal Payment yang tersimpan.
+// Signature hanya membuktikan notifikasi berasal dari gateway, bukan bahwa transaksinya dibayar sesuai
+// nominal order. Order yang tidak dikenal dibiarkan dan ditolak saat diproses.
+func (s *PaymentService) verifyGrossAmount(ctx context.Context, status *payment.Status) error {
+	order, err := s.orderRepo.FindByCode(ctx, status.OrderID)
 	if err != nil {
-		log.Printf("ERROR: PaymentService: Failed to find order %s: %v", payload.OrderID, err)
-		return "", 0, false, false, false, nil, fmt.Errorf("order not found or database error: %w", err)
+		return fmt.Errorf("gagal mengambil order %s: %w", status.OrderID, err)
 	}
 	if order == nil {
-		log.Printf("WARNING: PaymentService: Order %s not found in database.", payload.OrderID)
-		return "", 0, false, false, false, nil, errors.New("order not found")
+		return nil
+	}
+	paymentRecord, err := s.paymentRepo.FindByOrderID(ctx, order.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("gagal mengambil pembayaran order %s: %w", status.OrderID, err)
 	}
 
-	payment, err := s.paymentRepo.FindByOrderID(ctx, order.ID)
+	amount, err := decimal.NewFromString(status.GrossAmount)
 	if err != nil {
-		log.Printf("ERROR: PaymentService: Failed to get payment for order %s: %v", order.ID, err)
-		return "", 0, false, false, false, nil, fmt.Errorf("payment record not found or database error: %w", err)
+		return fmt.Errorf("%w: gross_amount %q tidak valid", payment.ErrInvalidNotification, status.GrossAmount)
 	}
-	if payment == nil {
-		log.Printf("WARNING: PaymentService: Payment record for OrderID %s not found. This should not happen.", order.ID)
-		return "", 0, false, false, false, nil, errors.New("payment record not found")
+	if !amount.Equal(paymentRecord.Amount) {
+		return fmt.Errorf("%w: gross_amount %s tidak sama dengan nominal pembayaran %s", payment.ErrInvalidNotification, status.GrossAmount, paymentRecord.Amount.StringFixed(2))
 	}
+	return nil
+}
 
-	if order.Status == models.OrderStatusCompleted ||
-		order.Status == models.OrderStatusCancelled ||
+// PaymentTransition adalah hasil satu perubahan status pembayaran dari notifikasi.
+type PaymentTransition struct {
+	Order         *models.Order
+	PaymentStatus string
+	OrderStatus   int
+	// Skipped berarti status dari gateway tidak mengubah apa pun, alasannya ada di SkipReason dan ikut
+	// tercatat di log notifikasi.
+	Skipped       bool
+	SkipReason    string
+	StockReduced  bool
+	StockRefunded bool
+	CartCleared   bool
+}
+
+func (t *PaymentTransition) Summary() string {
+	if t.Skipped {
+		return fmt.Sprintf("dilewati, %s (payment %s, status order %d)", t.SkipReason, t.PaymentStatus, t.OrderStatus)
+	}
+	return fmt.Sprintf("payment %s, status order %d, stok dikurangi: %t, stok dikembalikan: %t, keranjang dikosongkan: %t",
+		t.PaymentStatus, t.OrderStatus, t.StockReduced, t.StockRefunded, t.CartCleared)
+}
+
+// ProcessNotification menjalankan notifikasi yang sudah lolos verifikasi: status order dan payment, reservasi,
+// voucher, stok, keranjang dan hasil di log notifikasi diubah dalam satu transaksi dengan baris order
+// dikunci, jadi tidak ada kondisi order sudah dibayar tetapi stoknya belum dikurangi.
+func (s *PaymentService) ProcessNotification(ctx context.Context, notification *models.PaymentNotification) (*PaymentTransition, error) {
+	if !notification.Verified {
+		return nil, payment.ErrInvalidNotification
+	}
+
+	var result *PaymentTransition
+	// stok yang dikembalikan bisa membuat produk tersedia lagi; notifikasi wishlist dikirim setelah commit
+	restockedBefore := make(map[string]ProductAlertState)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = s.applyTransition(ctx, tx, notification.OrderCode, notification.TransactionStatus, notification.FraudStatus, restockedBefore)
+		if err != nil {
+			return err
+		}
+		return s.notificationRepo.MarkOutcome(ctx, tx, notification.ID, models.PaymentNotificationProcessed, result.Summary())
+	})
+	if err != nil {
+		log.Printf("ERROR: PaymentService: Failed to process notification %s for OrderCode %s: %v", notification.ID, notification.OrderCode, err)
+		if markErr := s.notificationRepo.MarkOutcome(ctx, s.db, notification.ID, models.PaymentNotificationFailed, err.Error()); markErr != nil {
+			log.Printf("ERROR: PaymentService: %v", markErr)
+		}
+		return nil, err
+	}
+
+	for productID, before := range restockedBefore {
+		s.wishlistSvc.NotifyProductChange(ctx, productID, before)
+	}
+
+	log.Printf("SUCCESS: PaymentService: Order %s: %s", notification.OrderCode, result.Summary())
+	return result, nil
+}
+
+// ApplyStatusTx menjalankan perubahan status yang sama dengan notifikasi gateway di dalam transaksi pemanggil,
+// untuk pembayaran yang dikonfirmasi di luar gateway seperti transfer manual yang diverifikasi admin.
+// Pemanggil wajib memastikan order masih menunggu pembayaran; stok yang dikembalikan tidak memicu notifikasi
+// wishlist.
+func (s *PaymentService) ApplyStatusTx(ctx context.Context, tx *gorm.DB, orderCode, transactionStatus string) (*PaymentTransition, error) {
+	return s.applyTransition(ctx, tx, orderCode, transactionStatus, payment.FraudAccept, make(map[string]ProductAlertState))
+}
+
+// ApplyGatewayStatus menjalankan status transaksi yang dibaca langsung dari gateway lewat CheckStatus, misalnya
+// saat pemulihan checkout menemukan transaksi yang sudah dibuat di gateway tetapi belum tercatat.
+func (s *PaymentService) ApplyGatewayStatus(ctx context.Context, status *payment.Status) (*PaymentTransition, error) {
+	var result *PaymentTransition
+	restockedBefore := make(map[string]ProductAlertState)
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = s.applyTransition(ctx, tx, status.OrderID, status.TransactionStatus, status.FraudStatus, restockedBefore)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for productID, before := range restockedBefore {
+		s.wishlistSvc.NotifyProductChange(ctx, productID, before)
+	}
+
+	log.Printf("SUCCESS: PaymentService: Order %s from gateway status check: %s", status.OrderID, result.Summary())
+	return result, nil
+}
+
+func (s *PaymentService) applyTransition(ctx context.Context, tx *gorm.DB, orderCode, transactionStatus, fraudStatus string, restockedBefore map[string]ProductAlertState) (*PaymentTransition, error) {
+	order, err := s.orderRepo.LockByCode(ctx, tx, orderCode)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		log.Printf("WARNING: PaymentService: Order %s not found in database.", orderCode)
+		return nil, errors.New("order not found")
+	}
+
+	paymentRecord, err := s.paymentRepo.FindByOrderIDTx(ctx, tx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("payment record not found or database error: %w", err)
+	}
+
+	result := &PaymentTransition{Order: order, PaymentStatus: paymentRecord.Status, OrderStatus: order.Status}
+
+	skip := func(reason string) (*PaymentTransition, error) {
+		log.Printf("INFO: PaymentService: Order %s status %s dilewati: %s (payment %s, status order %d).", order.OrderCode, transactionStatus, reason, paymentRecord.Status, order.Status)
+		result.Skipped = true
+		result.SkipReason = reason
+		return result, nil
+	}
+
+	refundStatus := transactionStatus == payment.StatusRefund || transactionStatus == payment.StatusPartialRefund ||
+		transactionStatus == payment.StatusChargeback || transactionStatus == payment.StatusPartialChargeback
+
+	// hanya order dan payment yang sudah batal, gagal atau dikembalikan penuh yang benar-benar final; order yang
+	// sudah dibayar masih bisa ditolak, dibatalkan, di-refund atau di-chargeback oleh gateway, dan order yang baru
+	// dikembalikan sebagian masih bisa di-refund lagi
+	if order.Status == models.OrderStatusCancelled ||
 		order.Status == models.OrderStatusFailed ||
-		order.Status == models.OrderStatusRefunded {
-		log.Printf("INFO: PaymentService: Order %s already in final status (%d). Skipping update.", order.ID, order.Status)
-		return payment.Status, order.Status, false, false, false, order, nil
+		order.Status == models.OrderStatusRefunded ||
+		(order.Status == models.OrderStatusPartiallyRefunded && !refundStatus) {
+		return skip(fmt.Sprintf("status order sudah final, status %s diabaikan", transactionStatus))
 	}
-	if payment.Status == "Paid" || payment.Status == "Failed" || payment.Status == "Cancelled" || payment.Status == "Refunded" {
-		log.Printf("INFO: PaymentService: Payment %s already in final status (%s). Skipping update.", payment.ID, payment.Status)
-		return payment.Status, order.Status, false, false, false, order, nil
+	if paymentRecord.Status == "Failed" || paymentRecord.Status == "Cancelled" || paymentRecord.Status == "Refunded" ||
+		(paymentRecord.Status == "Partially Refunded" && !refundStatus) {
+		return skip(fmt.Sprintf("status pembayaran sudah final, status %s diabaikan", transactionStatus))
+	}
+
+	// paid berarti stok order ini sudah dikurangi saat pembayaran diterima
+	paid := paymentRecord.Status == "Paid" || order.PaymentStatus == "Paid" || paymentRecord.Status == "Partially Refunded"
+	if paid {
+		switch transactionStatus {
+		case payment.StatusCapture, payment.StatusSettlement:
+			if fraudStatus == payment.FraudAccept {
+				return skip("pembayaran sudah tercatat lunas")
+			}
+		case payment.StatusPending, payment.StatusExpire:
+			return skip(fmt.Sprintf("order sudah dibayar, status %s tidak berlaku lagi", transactionStatus))
+		}
 	}
 
-	switch transactionStatus.TransactionStatus {
-	case "capture", "settlement":
-		if transactionStatus.FraudStatus == "accept" {
-			newPaymentStatus = "Paid"
-			newOrderStatus = models.OrderStatusProcessing
+	// refund yang dimulai admin lewat RefundService juga dikirim gateway sebagai notifikasi, bisa sebelum
+	// RefundService selesai mencatatnya; status dan restock order itu hanya diubah RefundService
+	if refundStatus {
+		adminRefunds, err := s.refundRepo.SumAmount(ctx, tx, order.ID, models.RefundStatusPending, models.RefundStatusSucceeded)
+		if err != nil {
+			return nil, err
+		}
+		if adminRefunds.IsPositive() {
+			return skip(fmt.Sprintf("order punya refund dari admin, status %s dicatat oleh refund tersebut", transactionStatus))
+		}
+	}
 
+	var shouldReduceStock, shouldClearCart, shouldRefundStock bool
+	switch transactionStatus {
+	case payment.StatusCapture, payment.StatusSettlement:
+		if fraudStatus == payment.FraudAccept {
+			result.PaymentStatus = "Paid"
+			result.OrderStatus = models.OrderStatusProcessing
 			if order.Status == models.OrderStatusPending {
 				shouldReduceStock = true
 				shouldClearCart = true
-				log.Printf("INFO: PaymentService: Order %s is settling. Flags set for stock reduction and cart clearing.", order.ID)
-			} else {
-				log.Printf("INFO: PaymentService: Order %s already in a processed state (%d). No new stock reduction/cart clearing flags needed.", order.ID, order.Status)
-			}
-		} else {
-			newPaymentStatus = "Failed"
-			newOrderStatus = models.OrderStatusFailed
-
-			if order.PaymentStatus == "Paid" || order.Status == models.OrderStatusProcessing || order.Status == models.OrderStatusCompleted {
-				shouldRefundStock = true
-				log.Printf("INFO: PaymentService: Order %s failed due to fraud. Flag set for stock refund.", order.ID)
-			} else {
-				log.Printf("INFO: PaymentService: Order %s failed due to fraud, but stock was not previously reduced. No refund flag needed.", order.ID)
 			}
-		}
-	case "pending":
-		newPaymentStatus = "Pending"
-		newOrderStatus = models.OrderStatusPending
-		log.Printf("INFO: PaymentService: Order %s is still pending. No stock/cart flags applied.", order.ID)
-	case "deny", "expire", "cancel":
-		newPaymentStatus = "Failed"
-		newOrderStatus = models.OrderStatusCancelled
-
-		if order.Status == models.OrderStatusProcessing || order.Status == models.OrderStatusCompleted {
-			shouldRefundStock = true
-			log.Printf("INFO: PaymentService: Order %s was cancelled/expired/denied after being processed. Flag set for stock refund.", order.ID)
 		} else {
-			log.Printf("INFO: PaymentService: Order %s was cancelled/expired/denied while pending. No stock/cart flags needed.", order.ID)
+			result.PaymentStatus = "Failed"
+			result.OrderStatus = models.OrderStatusFailed
+			shouldRefundStock = paid
 		}
-	case "refund", "partial_refund":
-		newPaymentStatus = "Refunded"
-		newOrderStatus = models.OrderStatusRefunded
-		shouldRefundStock = true
-		log.Printf("INFO: PaymentService: Order %s is being refunded. Flag set for stock refund.", order.ID)
+	case payment.StatusPending:
+		result.PaymentStatus = "Pending"
+		result.OrderStatus = models.OrderStatusPending
+	case payment.StatusDeny, payment.StatusExpire, payment.StatusCancel:
+		result.PaymentStatus = "Failed"
+		result.OrderStatus = models.OrderStatusCancelled
+		shouldRefundStock = paid
+	case payment.StatusRefund, payment.StatusChargeback:
+		result.PaymentStatus = "Refunded"
+		result.OrderStatus = models.OrderStatusRefunded
+		shouldRefundStock = paid
+	case payment.StatusPartialRefund, payment.StatusPartialChargeback:
+		// item yang dikembalikan tidak diketahui dari notifikasi, jadi stok tidak diubah otomatis
+		result.PaymentStatus = "Partially Refunded"
+		result.OrderStatus = models.OrderStatusPartiallyRefunded
 	default:
-		log.Printf("WARNING: PaymentService: Unhandled transaction status from Midtrans: %s", transactionStatus.TransactionStatus)
-		return "", 0, false, false, false, nil, errors.New("unhandled transaction status")
+		log.Printf("WARNING: PaymentService: Unhandled transaction status from %s: %s", s.provider.Name(), transactionStatus)
+		return nil, errors.New("unhandled transaction status")
+	}
+
+	if err := s.paymentRepo.UpdatePaymentStatusTx(ctx, tx, paymentRecord.ID, result.PaymentStatus); err != nil {
+		return nil, fmt.Errorf("failed to update payment status for payment ID %s: %w", paymentRecord.ID, err)
+	}
+	if err := s.orderRepo.UpdatePaymentStatusAndOrderStatus(ctx, tx, order.ID, result.PaymentStatus, result.OrderStatus); err != nil {
+		return nil, fmt.Errorf("failed to update order status for order ID %s: %w", order.ID, err)
+	}
+	order.PaymentStatus = result.PaymentStatus
+	order.Status = result.OrderStatus
+
+	if result.OrderStatus == models.OrderStatusCancelled || result.OrderStatus == models.OrderStatusFailed || result.OrderStatus == models.OrderStatusRefunded {
+		released, err := s.reservationRepo.ReleaseByOrderID(ctx, tx, order.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to release stock reservations for order ID %s: %w", order.ID, err)
+		}
+		if released > 0 {
+			log.Printf("INFO: PaymentService: Released %d stock reservation(s) for order %s.", released, order.ID)
+		}
+	}
+
+	if ReleasesVoucher(result.OrderStatus) {
+		if err := s.voucherSvc.ReleaseForOrder(ctx, tx, order.ID); err != nil {
+			return nil, fmt.Errorf("failed to release voucher redemption for order ID %s: %w", order.ID, err)
+		}
 	}
 
-	txErr := s.db.Transaction(func(tx *gorm.DB) error {
-		err = s.paymentRepo.UpdatePaymentStatusTx(ctx, tx, payment.ID, newPaymentStatus)
+	if shouldReduceStock {
+		if err := s.reduceStock(ctx, tx, order); err != nil {
+			return nil, err
+		}
+		result.StockReduced = true
+	} else if shouldRefundStock {
+		if err := s.restock(ctx, tx, order, restockedBefore); err != nil {
+			return nil, err
+		}
+		result.StockRefunded = true
+	}
+
+	if shouldClearCart {
+		cleared, err := s.clearCart(ctx, tx, order)
 		if err != nil {
-			return fmt.Errorf("failed to update payment status for payment ID %s: %w", payment.ID, err)
+			return nil, err
 		}
+		result.CartCleared = cleared
+	}
+	return result, nil
+}
+
+// reduceStock mengubah reservasi order menjadi penjualan lalu mengurangi stok setiap item.
+func (s *PaymentService) reduceStock(ctx context.Context, tx *gorm.DB, order *models.Order) error {
+	converted, err := s.reservationRepo.ConvertByOrderID(ctx, tx, order.ID)
+	if err != nil {
+		return fmt.Errorf("failed to convert stock reservations for order %s: %w", order.ID, err)
+	}
+	if converted == 0 {
+		log.Printf("WARNING: No active stock reservation for order %s at settlement (expired or released). Deducting stock directly.", order.OrderCode)
+	}
 
-		err = s.orderRepo.UpdatePaymentStatusAndOrderStatus(ctx, tx, order.ID, newPaymentStatus, newOrderStatus)
+	for _, item := range order.OrderItems {
+		product, err := s.productRepo.GetByID(ctx, item.ProductID)
 		if err != nil {
-			return fmt.Errorf("failed to update order status for order ID %s: %w", order.ID, err)
+			log.Printf("WARNING: Failed to get product %s for stock reduction: %v", item.ProductID, err)
+			return fmt.Errorf("product %s not found during stock reduction: %w", item.ProductID, err)
 		}
-		return nil
-	})
+		if product == nil {
+			continue
+		}
+		variantID := ""
+		if item.VariantID != "" {
+			variant := product.FindVariant(item.VariantID)
+			if variant == nil {
+				return fmt.Errorf("variant %s of product %s not found during stock reduction", item.VariantID, product.Name)
+			}
+			variantID = variant.ID
+		}
+		if _, err := s.stockMovementRepo.Adjust(ctx, tx, repositories.StockAdjustment{
+			ProductID: product.ID,
+			VariantID: variantID,
+			Delta:     -item.Qty,
+			Reason:    models.StockMovementSale,
+			Reference: order.OrderCode,
+		}); err != nil {
+			log.Printf("CRITICAL: Failed to reduce stock for product %s (ID: %s), Ordered: %d: %v. Rolling back transaction.", product.Name, product.ID, item.Qty, err)
+			return fmt.Errorf("failed to reduce stock for product %s: %w", product.Name, err)
+		}
+	}
+	return nil
+}
 
-	if txErr != nil {
-		log.Printf("ERROR: PaymentService: During Midtrans notification transaction for OrderID %s (status update only): %v", order.ID, txErr)
-		return "", 0, false, false, false, order, fmt.Errorf("internal server error during status update: %w", txErr)
+// restock mengembalikan stok setiap item order dan mencatat kondisi produk sebelumnya di restockedBefore.
+func (s *PaymentService) restock(ctx context.Context, tx *gorm.DB, order *models.Order, restockedBefore map[string]ProductAlertState) error {
+	for _, item := range order.OrderItems {
+		product, err := s.productRepo.GetByID(ctx, item.ProductID)
+		if err != nil {
+			log.Printf("WARNING: Failed to get product %s for stock refund: %v", item.ProductID, err)
+			continue
+		}
+		if product == nil {
+			continue
+		}
+		variantID := ""
+		if item.VariantID != "" && product.FindVariant(item.VariantID) != nil {
+			variantID = item.VariantID
+		}
+		if _, seen := restockedBefore[product.ID]; !seen {
+			restockedBefore[product.ID] = NewProductAlertState(product)
+		}
+		movement, err := s.stockMovementRepo.Adjust(ctx, tx, repositories.StockAdjustment{
+			ProductID: product.ID,
+			VariantID: variantID,
+			Delta:     item.Qty,
+			Reason:    models.StockMovementRefund,
+			Reference: order.OrderCode,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to refund stock for product %s: %w", product.Name, err)
+		}
+		log.Printf("Stock refunded for product %s (ID: %s). New stock: %d", product.Name, product.ID, movement.ResultingQty)
 	}
+	return nil
+}
 
-	return newPaymentStatus, newOrderStatus, shouldReduceStock, shouldClearCart, shouldRefundStock, order, nil
+// clearCart mengosongkan keranjang pemilik order setelah pembayaran berhasil.
+func (s *PaymentService) clearCart(ctx context.Context, tx *gorm.DB, order *models.Order) (bool, error) {
+	if order.UserID == "" {
+		log.Printf("WARNING: UserID not found for Order %s. Cannot clear cart for anonymous user.", order.OrderCode)
+		return false, nil
+	}
+	cart, err := s.cartRepo.GetCartByUserID(ctx, order.UserID)
+	if err != nil {
+		log.Printf("ERROR: Failed to find cart for user %s associated with order %s for clearing: %v", order.UserID, order.ID, err)
+		return false, fmt.Errorf("failed to find cart for clearing: %w", err)
+	}
+	if cart == nil {
+		log.Printf("INFO: No active cart found for user %s associated with order %s to clear. Possibly a guest checkout or cart already cleared.", order.UserID, order.OrderCode)
+		return false, nil
+	}
+	if err := s.cartItemRepo.DeleteAllItemsByCartID(ctx, tx, cart.ID); err != nil {
+		return false, fmt.Errorf("failed to delete cart items for cart %s: %w", cart.ID, err)
+	}
+	if err := s.cartRepo.UpdateCartTotalPrice(ctx, tx, cart.ID, decimal.Zero, decimal.Zero, decimal.Zero, decimal.Zero, decimal.Zero, 0); err != nil {
+		return false, fmt.Errorf("failed to reset cart totals for cart %s: %w", cart.ID, err)
+	}
+	return true, nil
 }
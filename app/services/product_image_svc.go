@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"path"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/imaging"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	ProductImageKeyPrefix = "products/"
+
+	productImageBatchSize = 100
+)
+
+type ProductImageService struct {
+	productRepo repositories.ProductRepositoryImpl
+	store       storage.Storage
+}
+
+func NewProductImageService(productRepo repositories.ProductRepositoryImpl, store storage.Storage) *ProductImageService {
+	return &ProductImageService{
+		productRepo: productRepo,
+		store:       store,
+	}
+}
+
+// IsImageValidationError menandai error yang pesannya aman ditampilkan ke admin.
+func IsImageValidationError(err error) bool {
+	return errors.Is(err, imaging.ErrTooLarge) || errors.Is(err, imaging.ErrUnsupportedType) || errors.Is(err, imaging.ErrDimensions)
+}
+
+// SaveUpload memvalidasi dan memproses file upload lalu menyimpan master beserta keempat ukurannya.
+// ProductImage yang dikembalikan baru berisi path; ID, ProductID dan VariantID diisi pemanggil.
+func (s *ProductImageService) SaveUpload(ctx context.Context, fileHeader *multipart.FileHeader) (*models.ProductImage, error) {
+	if fileHeader.Size > imaging.MaxUploadBytes {
+		return nil, imaging.ErrTooLarge
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuka file yang diunggah: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, imaging.MaxUploadBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca file yang diunggah: %w", err)
+	}
+	return s.Save(ctx, data)
+}
+
+// Save memvalidasi dan memproses data gambar mentah (upload, hasil unduhan import) lalu menyimpan
+// master beserta keempat ukurannya.
+func (s *ProductImageService) Save(ctx context.Context, data []byte) (*models.ProductImage, error) {
+	if err := imaging.Validate(data); err != nil {
+		return nil, err
+	}
+
+	result, err := imaging.Process(data)
+	if err != nil {
+		return nil, err
+	}
+
+	image := &models.ProductImage{}
+	if err := s.write(ctx, uuid.New().String(), result, image, true); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// Regenerate membuat ulang semua ukuran dari file master lalu menyimpan path baru. Gambar lama (semua
+// kolom berisi path upload mentah) juga dibuatkan master baru tanpa EXIF; master hasil pipeline tidak
+// di-encode ulang agar kualitasnya tidak turun setiap kali regenerate. File lama yang tidak lagi
+// dipakai dihapus setelah database diperbarui.
+func (s *ProductImageService) Regenerate(ctx context.Context, image *models.ProductImage) error {
+	if image.Path == "" {
+		return fmt.Errorf("gambar %s tidak memiliki path master", image.ID)
+	}
+
+	key, ok := s.store.Key(image.Path)
+	if !ok {
+		return fmt.Errorf("%w: %s", storage.ErrForeignURL, image.Path)
+	}
+	reader, err := s.store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("gagal membaca master %s: %w", image.Path, err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("gagal membaca master %s: %w", image.Path, err)
+	}
+
+	result, err := imaging.Process(data)
+	if err != nil {
+		return fmt.Errorf("gagal memproses %s: %w", image.Path, err)
+	}
+
+	oldPaths := image.FilePaths()
+	base := strings.TrimSuffix(path.Base(key), path.Ext(key))
+	updated := *image
+	if err := s.write(ctx, base, result, &updated, isLegacyImage(image)); err != nil {
+		return err
+	}
+	if err := s.productRepo.UpdateProductImageFiles(ctx, &updated); err != nil {
+		return err
+	}
+
+	current := make(map[string]bool, 5)
+	for _, path := range updated.FilePaths() {
+		current[path] = true
+	}
+	for _, oldPath := range oldPaths {
+		if !current[oldPath] {
+			s.removeImageFile(ctx, oldPath)
+		}
+	}
+	*image = updated
+	return nil
+}
+
+// RegenerateAll memproses ulang semua gambar produk secara bertahap. onResult dipanggil untuk setiap
+// gambar; kegagalan satu gambar tidak menghentikan proses.
+func (s *ProductImageService) RegenerateAll(ctx context.Context, onResult func(image models.ProductImage, err error)) (processed, failed int, err error) {
+	for offset := 0; ; offset += productImageBatchSize {
+		images, err := s.productRepo.GetProductImagesPaginated(ctx, productImageBatchSize, offset)
+		if err != nil {
+			return processed, failed, err
+		}
+		for i := range images {
+			if ctx.Err() != nil {
+				return processed, failed, ctx.Err()
+			}
+			regenErr := s.Regenerate(ctx, &images[i])
+			processed++
+			if regenErr != nil {
+				failed++
+			}
+			if onResult != nil {
+				onResult(images[i], regenErr)
+			}
+		}
+		if len(images) < productImageBatchSize {
+			return processed, failed, nil
+		}
+	}
+}
+
+// RemoveFiles menghapus master dan semua ukuran gambar dari storage.
+func (s *ProductImageService) RemoveFiles(ctx context.Context, image models.ProductImage) {
+	for _, filePath := range image.FilePaths() {
+		s.removeImageFile(ctx, filePath)
+	}
+}
+
+// isLegacyImage mengenali gambar yang diunggah sebelum ada pipeline: semua ukuran menunjuk file yang sama.
+func isLegacyImage(image *models.ProductImage) bool {
+	return image.Small == "" || image.Small == image.Path
+}
+
+func (s *ProductImageService) write(ctx context.Context, base string, result *imaging.Result, image *models.ProductImage, includeMaster bool) error {
+	files := []struct {
+		suffix string
+		data   []byte
+		dst    *string
+	}{
+		{"", result.Master, &image.Path},
+		{"_" + imaging.SizeExtraLarge.Name, result.ExtraLarge, &image.ExtraLarge},
+		{"_" + imaging.SizeLarge.Name, result.Large, &image.Large},
+		{"_" + imaging.SizeMedium.Name, result.Medium, &image.Medium},
+		{"_" + imaging.SizeSmall.Name, result.Small, &image.Small},
+	}
+
+	var written []string
+	if !includeMaster {
+		files = files[1:]
+	}
+	for _, file := range files {
+		key := ProductImageKeyPrefix + base + file.suffix + result.Ext
+		if err := s.store.Put(ctx, key, bytes.NewReader(file.data), int64(len(file.data)), storage.ContentType(key)); err != nil {
+			for _, writtenPath := range written {
+				s.removeImageFile(ctx, writtenPath)
+			}
+			return fmt.Errorf("gagal menyimpan gambar %s: %w", key, err)
+		}
+		*file.dst = s.store.URL(key)
+		written = append(written, *file.dst)
+	}
+	return nil
+}
+
+func (s *ProductImageService) removeImageFile(ctx context.Context, publicPath string) {
+	if err := storage.DeleteURL(ctx, s.store, publicPath); err != nil {
+		log.Printf("ProductImageService: Gagal menghapus file %s: %v", publicPath, err)
+	}
+}
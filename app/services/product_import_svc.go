@@ -0,0 +1,569 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/helpers"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/calc"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/imaging"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/spreadsheet"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/storage"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ProductImportMaxRows = 2000
+	// ProductImportMaxImages sama dengan batas gambar pada form produk admin.
+	ProductImportMaxImages = 3
+
+	ProductImportActionCreate = "create"
+	ProductImportActionUpdate = "update"
+
+	productImportListSeparator = "|"
+	productImportImageTimeout  = 30 * time.Second
+)
+
+// ProductImportColumns adalah header file import sekaligus urutan kolom file export.
+var ProductImportColumns = []string{"name", "sku", "description", "price", "stock", "weight", "discount", "categories", "images"}
+
+var ErrProductImportInvalid = errors.New("file import memiliki baris yang tidak valid")
+
+// ProductImportRow adalah satu baris file import apa adanya (string), ditambah hasil validasinya.
+type ProductImportRow struct {
+	Line        int
+	Name        string
+	SKU         string
+	Description string
+	Price       string
+	Stock       string
+	Weight      string
+	Discount    string
+	Categories  string
+	Images      string
+
+	Action string
+	Errors []string
+}
+
+func (r *ProductImportRow) addError(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r ProductImportRow) values() []string {
+	return []string{r.Name, r.SKU, r.Description, r.Price, r.Stock, r.Weight, r.Discount, r.Categories, r.Images}
+}
+
+type ProductImportReport struct {
+	Rows    []ProductImportRow
+	Created int
+	Updated int
+	Invalid int
+}
+
+func (r *ProductImportReport) HasErrors() bool {
+	return r.Invalid > 0
+}
+
+type ProductImportOptions struct {
+	// AllowLocalFiles mengizinkan kolom images berisi path file di mesin yang menjalankan import
+	// (hanya untuk CLI). Dari panel admin hanya URL http(s) dan file di storage yang diterima.
+	AllowLocalFiles bool
+	// Reference dicatat pada StockMovement, misalnya email admin atau "cli".
+	Reference string
+}
+
+type ProductImportService struct {
+	productRepo  repositories.ProductRepositoryImpl
+	variantRepo  repositories.ProductVariantRepositoryImpl
+	categoryRepo repositories.CategoryRepositoryImpl
+	imageSvc     *ProductImageService
+	wishlistSvc  *WishlistService
+	store        storage.Storage
+	httpClient   *http.Client
+}
+
+func NewProductImportService(productRepo repositories.ProductRepositoryImpl, variantRepo repositories.ProductVariantRepositoryImpl, categoryRepo repositories.CategoryRepositoryImpl, imageSvc *ProductImageService, wishlistSvc *WishlistService, store storage.Storage) *ProductImportService {
+	return &ProductImportService{
+		productRepo:  productRepo,
+		variantRepo:  variantRepo,
+		categoryRepo: categoryRepo,
+		imageSvc:     imageSvc,
+		wishlistSvc:  wishlistSvc,
+		store:        store,
+		httpClient:   &http.Client{Timeout: productImportImageTimeout},
+	}
+}
+
+// ParseRows membaca file CSV/XLSX. Baris pertama harus header dengan nama kolom dari
+// ProductImportColumns (urutan bebas); name, sku dan price wajib ada.
+func (s *ProductImportService) ParseRows(r io.Reader, format string) ([]ProductImportRow, error) {
+	records, err := spreadsheet.Read(r, format)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file kosong")
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, header := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+	for _, required := range []string{"name", "sku", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("kolom %q tidak ditemukan pada header", required)
+		}
+	}
+
+	cell := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []ProductImportRow
+	for i, record := range records[1:] {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		if len(rows) == ProductImportMaxRows {
+			return nil, fmt.Errorf("maksimal %d produk per import", ProductImportMaxRows)
+		}
+		rows = append(rows, ProductImportRow{
+			Line:        i + 2,
+			Name:        cell(record, "name"),
+			SKU:         cell(record, "sku"),
+			Description: cell(record, "description"),
+			Price:       cell(record, "price"),
+			Stock:       cell(record, "stock"),
+			Weight:      cell(record, "weight"),
+			Discount:    cell(record, "discount"),
+			Categories:  cell(record, "categories"),
+			Images:      cell(record, "images"),
+		})
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("file tidak berisi data produk")
+	}
+	return rows, nil
+}
+
+// EncodeRows menulis ulang baris ke CSV, dipakai panel admin untuk membawa hasil preview ke langkah apply.
+func (s *ProductImportService) EncodeRows(w io.Writer, rows []ProductImportRow) error {
+	records := make([][]string, 0, len(rows)+1)
+	records = append(records, ProductImportColumns)
+	for _, row := range rows {
+		records = append(records, row.values())
+	}
+	return spreadsheet.Write(w, spreadsheet.FormatCSV, "", records)
+}
+
+// productImportPlan adalah hasil validasi satu baris yang siap disimpan.
+type productImportPlan struct {
+	row        *ProductImportRow
+	product    *models.Product
+	isNew      bool
+	stock      *int
+	categories []models.Category
+	imageRefs  []string
+	existing   []models.ProductImage
+}
+
+// Preview memvalidasi semua baris tanpa menyimpan apa pun (dry-run).
+func (s *ProductImportService) Preview(ctx context.Context, rows []ProductImportRow, opts ProductImportOptions) (*ProductImportReport, error) {
+	report, _, err := s.plan(ctx, rows, opts)
+	return report, err
+}
+
+// Apply memvalidasi ulang semua baris lalu menyimpannya dalam satu transaksi. Jika ada baris yang tidak
+// valid, tidak ada yang disimpan dan report dikembalikan bersama ErrProductImportInvalid.
+func (s *ProductImportService) Apply(ctx context.Context, rows []ProductImportRow, opts ProductImportOptions) (*ProductImportReport, error) {
+	report, plans, err := s.plan(ctx, rows, opts)
+	if err != nil {
+		return nil, err
+	}
+	if report.HasErrors() {
+		return report, ErrProductImportInvalid
+	}
+
+	var saved []models.ProductImage
+	cleanup := func() {
+		for _, img := range saved {
+			s.imageSvc.RemoveFiles(ctx, img)
+		}
+	}
+
+	upserts := make([]repositories.ProductUpsert, 0, len(plans))
+	for _, plan := range plans {
+		upsert := repositories.ProductUpsert{
+			IsNew:      plan.isNew,
+			Product:    plan.product,
+			Stock:      plan.stock,
+			Categories: plan.categories,
+		}
+		if plan.imageRefs != nil {
+			images, newImages, err := s.resolveImages(ctx, plan, opts)
+			saved = append(saved, newImages...)
+			if err != nil {
+				plan.row.addError("%v", err)
+				report.Invalid++
+				if plan.isNew {
+					report.Created--
+				} else {
+					report.Updated--
+				}
+				cleanup()
+				return report, ErrProductImportInvalid
+			}
+			upsert.Images = images
+		}
+		upserts = append(upserts, upsert)
+	}
+
+	// import bisa mengisi ulang stok atau menurunkan harga produk yang ada di wishlist
+	before := s.wishlistSvc.SnapshotWatchedProducts(ctx)
+	if err := s.productRepo.UpsertProducts(ctx, upserts, opts.Reference); err != nil {
+		cleanup()
+		return report, fmt.Errorf("gagal menyimpan import produk: %w", err)
+	}
+	s.wishlistSvc.NotifyWatchedProducts(ctx, before)
+	return report, nil
+}
+
+func (s *ProductImportService) plan(ctx context.Context, rows []ProductImportRow, opts ProductImportOptions) (*ProductImportReport, []*productImportPlan, error) {
+	report := &ProductImportReport{Rows: rows}
+	plans := make([]*productImportPlan, 0, len(rows))
+	categoryCache := make(map[string]*models.Category)
+	seenSKU := make(map[string]int, len(rows))
+
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		row.Errors = nil
+		row.Action = ""
+
+		plan, err := s.planRow(ctx, row, opts, categoryCache)
+		if err != nil {
+			return nil, nil, err
+		}
+		if line, ok := seenSKU[row.SKU]; ok && row.SKU != "" {
+			row.addError("SKU %s sudah dipakai di baris %d", row.SKU, line)
+		} else {
+			seenSKU[row.SKU] = row.Line
+		}
+
+		if len(row.Errors) > 0 {
+			report.Invalid++
+			continue
+		}
+		if plan.isNew {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+		plans = append(plans, plan)
+	}
+	return report, plans, nil
+}
+
+// planRow memvalidasi satu baris. Error yang dikembalikan hanya untuk kegagalan database; masalah data
+// dicatat di row.Errors.
+func (s *ProductImportService) planRow(ctx context.Context, row *ProductImportRow, opts ProductImportOptions, categoryCache map[string]*models.Category) (*productImportPlan, error) {
+	plan := &productImportPlan{row: row}
+
+	if row.Name == "" {
+		row.addError("nama wajib diisi")
+	} else if len(row.Name) > 255 {
+		row.addError("nama maksimal 255 karakter")
+	}
+	if row.SKU == "" {
+		row.addError("SKU wajib diisi")
+		return plan, nil
+	}
+	if len(row.SKU) > 100 {
+		row.addError("SKU maksimal 100 karakter")
+		return plan, nil
+	}
+
+	exists, err := s.productRepo.IsSKUExists(ctx, row.SKU)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengecek SKU %s: %w", row.SKU, err)
+	}
+	if exists {
+		existing, err := s.productRepo.GetBySKU(ctx, row.SKU)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, fmt.Errorf("produk dengan SKU %s tidak ditemukan", row.SKU)
+		}
+		plan.product = existing
+		plan.existing = existing.ProductImages
+		row.Action = ProductImportActionUpdate
+	} else {
+		variantSKU, err := s.variantRepo.IsSKUExists(ctx, row.SKU, "")
+		if err != nil {
+			return nil, fmt.Errorf("gagal mengecek SKU varian %s: %w", row.SKU, err)
+		}
+		if variantSKU {
+			row.addError("SKU %s sudah dipakai oleh varian produk lain", row.SKU)
+			return plan, nil
+		}
+		id := uuid.New().String()
+		plan.isNew = true
+		plan.product = &models.Product{
+			ID:   id,
+			Sku:  row.SKU,
+			Slug: helpers.GenerateSlug(row.Name) + "-" + id[:8],
+		}
+		row.Action = ProductImportActionCreate
+	}
+	product := plan.product
+	product.Name = row.Name
+	if row.Description != "" || plan.isNew {
+		product.Description = row.Description
+	}
+
+	price, err := decimal.NewFromString(row.Price)
+	if err != nil || price.IsNegative() {
+		row.addError("harga %q tidak valid", row.Price)
+	} else {
+		product.Price = price
+	}
+
+	switch {
+	case row.Weight != "":
+		weight, err := decimal.NewFromString(row.Weight)
+		if err != nil || !weight.IsPositive() {
+			row.addError("berat %q tidak valid", row.Weight)
+		} else {
+			product.Weight = weight
+		}
+	case plan.isNew:
+		row.addError("berat wajib diisi untuk produk baru")
+	}
+
+	if row.Discount != "" {
+		discount, err := decimal.NewFromString(row.Discount)
+		if err != nil || discount.IsNegative() || discount.GreaterThan(decimal.NewFromInt(100)) {
+			row.addError("diskon %q harus 0-100", row.Discount)
+		} else {
+			product.DiscountPercent = discount
+		}
+	}
+	product.DiscountAmount = calc.CalculateDiscount(product.Price, product.DiscountPercent)
+
+	if row.Stock != "" {
+		stock, err := strconv.Atoi(row.Stock)
+		switch {
+		case err != nil || stock < 0:
+			row.addError("stok %q tidak valid", row.Stock)
+		case product.HasVariants():
+			// stok produk bervarian adalah total stok varian; nilai yang sama (hasil export) tetap diterima
+			if stock != product.Stock {
+				row.addError("produk memiliki varian, stok diatur per varian di halaman edit produk")
+			}
+		default:
+			plan.stock = &stock
+		}
+	} else if plan.isNew {
+		zero := 0
+		plan.stock = &zero
+	}
+
+	if row.Categories != "" {
+		plan.categories = []models.Category{}
+		for _, slug := range splitImportList(row.Categories) {
+			category, ok := categoryCache[slug]
+			if !ok {
+				category, err = s.categoryRepo.GetBySlug(ctx, slug)
+				if err != nil {
+					return nil, fmt.Errorf("gagal mengambil kategori %s: %w", slug, err)
+				}
+				categoryCache[slug] = category
+			}
+			if category == nil {
+				row.addError("kategori %q tidak ditemukan", slug)
+				continue
+			}
+			plan.categories = append(plan.categories, *category)
+		}
+	} else if plan.isNew {
+		row.addError("minimal satu kategori wajib diisi untuk produk baru")
+	}
+
+	if row.Images != "" {
+		plan.imageRefs = splitImportList(row.Images)
+		if len(plan.imageRefs) > ProductImportMaxImages {
+			row.addError("maksimal %d gambar per produk", ProductImportMaxImages)
+		}
+		for _, ref := range plan.imageRefs {
+			if err := s.checkImageRef(ctx, plan, ref, opts); err != nil {
+				row.addError("gambar %q: %v", ref, err)
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// checkImageRef hanya memeriksa sumber gambar tanpa mengunduhnya; URL http(s) baru diunduh saat Apply.
+func (s *ProductImportService) checkImageRef(ctx context.Context, plan *productImportPlan, ref string, opts ProductImportOptions) error {
+	if existingImage(plan.existing, ref) != nil {
+		return nil
+	}
+	if key, ok := s.store.Key(ref); ok {
+		reader, err := s.store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		reader.Close()
+		return nil
+	}
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		return nil
+	}
+	if opts.AllowLocalFiles {
+		if _, err := os.Stat(ref); err != nil {
+			return fmt.Errorf("file tidak ditemukan")
+		}
+		return nil
+	}
+	return fmt.Errorf("harus berupa URL http(s) atau file di storage")
+}
+
+// resolveImages menyusun daftar akhir gambar: gambar lama yang disebut lagi dipertahankan, sisanya
+// diunduh/dibaca lalu diproses lewat pipeline gambar. newImages dikembalikan juga saat error agar
+// file yang sudah tersimpan bisa dibersihkan.
+func (s *ProductImportService) resolveImages(ctx context.Context, plan *productImportPlan, opts ProductImportOptions) (images, newImages []models.ProductImage, err error) {
+	images = []models.ProductImage{}
+	for _, ref := range plan.imageRefs {
+		if img := existingImage(plan.existing, ref); img != nil {
+			images = append(images, *img)
+			continue
+		}
+		data, err := s.fetchImage(ctx, ref, opts)
+		if err != nil {
+			return images, newImages, fmt.Errorf("gambar %q: %w", ref, err)
+		}
+		img, err := s.imageSvc.Save(ctx, data)
+		if err != nil {
+			return images, newImages, fmt.Errorf("gambar %q: %w", ref, err)
+		}
+		images = append(images, *img)
+		newImages = append(newImages, *img)
+	}
+	return images, newImages, nil
+}
+
+func (s *ProductImportService) fetchImage(ctx context.Context, ref string, opts ProductImportOptions) ([]byte, error) {
+	var reader io.ReadCloser
+	if key, ok := s.store.Key(ref); ok {
+		r, err := s.store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		reader = r
+	} else if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+		if err != nil {
+			return nil, fmt.Errorf("URL tidak valid: %w", err)
+		}
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("gagal mengunduh: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("gagal mengunduh: status %d", resp.StatusCode)
+		}
+		reader = resp.Body
+	} else if opts.AllowLocalFiles {
+		file, err := os.Open(ref)
+		if err != nil {
+			return nil, fmt.Errorf("gagal membuka file: %w", err)
+		}
+		reader = file
+	} else {
+		return nil, fmt.Errorf("harus berupa URL http(s) atau file di storage")
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, imaging.MaxUploadBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca gambar: %w", err)
+	}
+	return data, nil
+}
+
+// Export menulis seluruh katalog dengan kolom yang sama seperti file import, sehingga hasil export
+// bisa langsung diedit lalu diimport kembali.
+func (s *ProductImportService) Export(ctx context.Context, w io.Writer, format string) (int, error) {
+	products, err := s.productRepo.GetAllForExport(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	records := make([][]string, 0, len(products)+1)
+	records = append(records, ProductImportColumns)
+	for _, product := range products {
+		categories := make([]string, 0, len(product.Categories))
+		for _, category := range product.Categories {
+			categories = append(categories, category.Slug)
+		}
+		images := make([]string, 0, len(product.ProductImages))
+		for _, img := range product.ProductImages {
+			images = append(images, img.Path)
+		}
+		records = append(records, []string{
+			product.Name,
+			product.Sku,
+			product.Description,
+			product.Price.String(),
+			strconv.Itoa(product.Stock),
+			product.Weight.String(),
+			product.DiscountPercent.String(),
+			strings.Join(categories, productImportListSeparator),
+			strings.Join(images, productImportListSeparator),
+		})
+	}
+
+	if err := spreadsheet.Write(w, format, "Products", records); err != nil {
+		log.Printf("ProductImportService.Export: %v", err)
+		return 0, err
+	}
+	return len(products), nil
+}
+
+func existingImage(images []models.ProductImage, ref string) *models.ProductImage {
+	for i := range images {
+		if images[i].Path == ref {
+			return &images[i]
+		}
+	}
+	return nil
+}
+
+// splitImportList memecah kolom kategori/gambar yang dipisahkan "|".
+func splitImportList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, productImportListSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
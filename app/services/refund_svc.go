@@ -0,0 +1,495 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/payment"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefundOrderNotFound  = errors.New("order tidak ditemukan")
+	ErrRefundNotAllowed     = errors.New("order belum dibayar atau sudah dikembalikan penuh")
+	ErrRefundReasonRequired = errors.New("alasan refund wajib diisi")
+	ErrRefundEmpty          = errors.New("pilih item atau isi jumlah refund")
+	ErrRefundInvalidItem    = errors.New("item atau jumlah item refund tidak valid")
+	ErrRefundAmountExceeded = errors.New("jumlah refund melebihi sisa pembayaran yang bisa dikembalikan")
+	ErrRefundNotFound       = errors.New("refund tidak ditemukan")
+	ErrRefundNotPending     = errors.New("refund sudah tidak menunggu konfirmasi gateway")
+	ErrRefundUncertain      = errors.New("hasil refund dari gateway belum pasti; refund tetap menunggu dan bisa dicek ulang dari riwayat refund")
+)
+
+type RefundItemRequest struct {
+	OrderItemID string
+	Qty         int
+}
+
+// RefundRequest adalah permintaan refund dari admin. Amount nol berarti nilai refund dihitung dari item
+// yang dipilih; jika diisi, Amount dipakai apa adanya dan item hanya dicatat serta dipakai untuk restock.
+type RefundRequest struct {
+	OrderCode string
+	Items     []RefundItemRequest
+	Amount    decimal.Decimal
+	Reason    string
+	Restock   bool
+	ActorID   string
+}
+
+// RefundableLine adalah item order beserta jumlah yang masih bisa direfund.
+type RefundableLine struct {
+	Item         models.OrderItem
+	RefundedQty  int
+	RemainingQty int
+}
+
+// OrderRefundSummary adalah data refund satu order untuk halaman admin.
+type OrderRefundSummary struct {
+	Order            *models.Order
+	Payment          *models.Payment
+	Customer         *models.OrderCustomer
+	Lines            []RefundableLine
+	Refunds          []models.Refund
+	RefundableAmount decimal.Decimal
+	CanRefund        bool
+}
+
+type RefundService struct {
+	db                *gorm.DB
+	orderRepo         repositories.OrderRepository
+	paymentRepo       repositories.PaymentRepositoryImpl
+	refundRepo        repositories.RefundRepository
+	orderCustomerRepo repositories.OrderCustomerRepository
+	productRepo       repositories.ProductRepositoryImpl
+	stockMovementRepo repositories.StockMovementRepository
+	wishlistSvc       *WishlistService
+	provider          payment.PaymentProvider
+	mailer            *Mailer
+}
+
+func NewRefundService(
+	db *gorm.DB,
+	orderRepo repositories.OrderRepository,
+	paymentRepo repositories.PaymentRepositoryImpl,
+	refundRepo repositories.RefundRepository,
+	orderCustomerRepo repositories.OrderCustomerRepository,
+	productRepo repositories.ProductRepositoryImpl,
+	stockMovementRepo repositories.StockMovementRepository,
+	wishlistSvc *WishlistService,
+	provider payment.PaymentProvider,
+	mailer *Mailer,
+) *RefundService {
+	return &RefundService{
+		db:                db,
+		orderRepo:         orderRepo,
+		paymentRepo:       paymentRepo,
+		refundRepo:        refundRepo,
+		orderCustomerRepo: orderCustomerRepo,
+		productRepo:       productRepo,
+		stockMovementRepo: stockMovementRepo,
+		wishlistSvc:       wishlistSvc,
+		provider:          provider,
+		mailer:            mailer,
+	}
+}
+
+// refundableOrder menandai order yang pembayarannya sudah diterima dan masih punya sisa untuk direfund.
+func refundableOrder(order *models.Order) bool {
+	switch order.Status {
+	case models.OrderStatusProcessing, models.OrderStatusShipped, models.OrderStatusCompleted, models.OrderStatusPartiallyRefunded:
+	default:
+		return false
+	}
+	return order.PaymentStatus == "Paid" || order.PaymentStatus == "Partially Refunded"
+}
+
+// GetOrderRefunds menyusun item yang masih bisa direfund, riwayat refund dan sisa nilai yang bisa dikembalikan.
+func (s *RefundService) GetOrderRefunds(ctx context.Context, orderCode string) (*OrderRefundSummary, error) {
+	order, err := s.orderRepo.FindByCodeWithDetails(ctx, orderCode)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil order %s: %w", orderCode, err)
+	}
+	if order == nil {
+		return nil, ErrRefundOrderNotFound
+	}
+
+	summary := &OrderRefundSummary{Order: order}
+	if paymentRecord, err := s.paymentRepo.FindByOrderID(ctx, order.ID); err == nil {
+		summary.Payment = paymentRecord
+	}
+	if summary.Customer, err = s.orderCustomerRepo.FindByOrderID(ctx, order.ID); err != nil {
+		log.Printf("RefundService: Gagal mengambil data pembeli order %s: %v", orderCode, err)
+	}
+	if summary.Refunds, err = s.refundRepo.FindByOrderID(ctx, order.ID); err != nil {
+		return nil, err
+	}
+
+	refundedQty, err := s.refundRepo.RefundedQtyByItem(ctx, s.db, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range order.OrderItems {
+		summary.Lines = append(summary.Lines, RefundableLine{
+			Item:         item,
+			RefundedQty:  refundedQty[item.ID],
+			RemainingQty: item.Qty - refundedQty[item.ID],
+		})
+	}
+
+	refunded, err := s.refundRepo.SumAmount(ctx, s.db, order.ID, models.RefundStatusPending, models.RefundStatusSucceeded)
+	if err != nil {
+		return nil, err
+	}
+	summary.RefundableAmount = decimal.NewFromInt(order.GrandTotal.IntPart()).Sub(refunded)
+	summary.CanRefund = refundableOrder(order) && summary.RefundableAmount.IsPositive()
+	return summary, nil
+}
+
+// Refund mengembalikan dana order lewat gateway pembayaran. Refund dicatat pending di dalam transaksi yang
+// mengunci order sebelum gateway dipanggil, sehingga dua refund bersamaan tidak bisa melebihi nilai order.
+// Setelah gateway menerima refund, status refund, restock dan status order serta payment diubah dalam satu
+// transaksi, lalu pembeli dikirimi email. Jika hasil dari gateway belum pasti, refund tetap pending dan
+// diselesaikan lewat RetryRefund dengan RefundKey yang sama.
+func (s *RefundService) Refund(ctx context.Context, req RefundRequest) (*models.Refund, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return nil, ErrRefundReasonRequired
+	}
+
+	var refund *models.Refund
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		refund, err = s.createPendingRefund(ctx, tx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.refundThroughGateway(ctx, req.OrderCode, refund)
+	if err != nil {
+		return nil, s.gatewayRefundFailed(ctx, req.OrderCode, refund, err)
+	}
+	return s.finishRefund(ctx, req.OrderCode, refund, result)
+}
+
+// RetryRefund menyelesaikan refund yang masih pending, misalnya setelah gateway timeout atau refund diterima
+// gateway tetapi gagal dicatat. Status transaksi dicek dulu lewat CheckStatus; jika RefundKey sudah tercatat
+// di gateway refund langsung diselesaikan, jika belum gateway dipanggil ulang dengan RefundKey yang sama.
+func (s *RefundService) RetryRefund(ctx context.Context, orderCode, refundID string) (*models.Refund, error) {
+	order, err := s.orderRepo.FindByCode(ctx, orderCode)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil order %s: %w", orderCode, err)
+	}
+	if order == nil {
+		return nil, ErrRefundOrderNotFound
+	}
+	refund, err := s.refundRepo.FindByID(ctx, s.db, refundID)
+	if err != nil {
+		return nil, err
+	}
+	if refund == nil || refund.OrderID != order.ID {
+		return nil, ErrRefundNotFound
+	}
+	if refund.Status != models.RefundStatusPending {
+		return nil, ErrRefundNotPending
+	}
+
+	result, err := s.gatewayRefundResult(ctx, orderCode, refund)
+	if err != nil {
+		return nil, s.gatewayRefundFailed(ctx, orderCode, refund, err)
+	}
+	return s.finishRefund(ctx, orderCode, refund, result)
+}
+
+// gatewayRefundResult mengembalikan hasil refund yang sudah diterima gateway, atau meneruskan refund ulang
+// dengan RefundKey yang sama jika gateway belum mengenalnya.
+func (s *RefundService) gatewayRefundResult(ctx context.Context, orderCode string, refund *models.Refund) (*payment.RefundResult, error) {
+	paymentRecord, err := s.paymentRepo.FindByOrderID(ctx, refund.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if paymentRecord != nil && paymentRecord.IsManualTransfer() {
+		return s.refundThroughGateway(ctx, orderCode, refund)
+	}
+
+	status, err := s.provider.CheckStatus(ctx, orderCode)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengecek status refund di gateway: %w", err)
+	}
+	if slices.Contains(status.RefundKeys, refund.RefundKey) {
+		log.Printf("INFO: RefundService: Refund %s untuk order %s sudah diterima gateway, dicatat tanpa refund ulang", refund.ID, orderCode)
+		return &payment.RefundResult{Key: refund.RefundKey, Amount: refund.Amount.String(), TransactionStatus: status.TransactionStatus}, nil
+	}
+	return s.refundThroughGateway(ctx, orderCode, refund)
+}
+
+// gatewayRefundFailed menandai refund gagal hanya jika gateway pasti menolaknya. Error lain seperti timeout
+// membuat refund tetap pending karena dananya mungkin sudah dikembalikan; refund baru dengan RefundKey lain
+// bisa mengembalikan dana dua kali.
+func (s *RefundService) gatewayRefundFailed(ctx context.Context, orderCode string, refund *models.Refund, err error) error {
+	if errors.Is(err, payment.ErrRefundRejected) {
+		log.Printf("ERROR: RefundService: Gateway menolak refund %s untuk order %s: %v", refund.ID, orderCode, err)
+		if markErr := s.refundRepo.MarkStatus(ctx, s.db, refund.ID, models.RefundStatusFailed, "", err.Error()); markErr != nil {
+			log.Printf("ERROR: RefundService: %v", markErr)
+		}
+		return err
+	}
+
+	log.Printf("WARNING: RefundService: Hasil refund %s untuk order %s belum pasti, refund tetap pending: %v", refund.ID, orderCode, err)
+	if markErr := s.refundRepo.MarkStatus(ctx, s.db, refund.ID, models.RefundStatusPending, "", err.Error()); markErr != nil {
+		log.Printf("ERROR: RefundService: %v", markErr)
+	}
+	return fmt.Errorf("%w: %v", ErrRefundUncertain, err)
+}
+
+// finishRefund mencatat refund yang sudah diterima gateway, mengirim notifikasi wishlist dan email pembeli.
+func (s *RefundService) finishRefund(ctx context.Context, orderCode string, refund *models.Refund, result *payment.RefundResult) (*models.Refund, error) {
+	restockedBefore := make(map[string]ProductAlertState)
+	var order *models.Order
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = s.completeRefund(ctx, tx, orderCode, refund, result, restockedBefore)
+		return err
+	})
+	if err != nil {
+		// dana sudah dikembalikan gateway; refund tetap pending supaya sisa nilai order tidak direfund dua kali
+		log.Printf("CRITICAL: RefundService: Refund %s untuk order %s diterima gateway tetapi gagal dicatat: %v", refund.ID, orderCode, err)
+		return nil, fmt.Errorf("refund diterima gateway tetapi gagal dicatat: %w", err)
+	}
+	if order == nil {
+		return nil, ErrRefundNotPending
+	}
+	refund.Status = models.RefundStatusSucceeded
+	refund.GatewayStatus = result.TransactionStatus
+
+	for productID, before := range restockedBefore {
+		s.wishlistSvc.NotifyProductChange(ctx, productID, before)
+	}
+	go s.sendRefundEmail(context.Background(), order, refund)
+
+	log.Printf("SUCCESS: RefundService: Refund %s sebesar %s untuk order %s oleh %s", refund.ID, refund.Amount.String(), order.OrderCode, refund.ActorID)
+	return refund, nil
+}
+
+// refundThroughGateway meneruskan refund ke gateway. Order transfer manual tidak punya transaksi di gateway;
+// dananya dikembalikan admin sendiri, jadi refund langsung dicatat berhasil.
+func (s *RefundService) refundThroughGateway(ctx context.Context, orderCode string, refund *models.Refund) (*payment.RefundResult, error) {
+	paymentRecord, err := s.paymentRepo.FindByOrderID(ctx, refund.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if paymentRecord != nil && paymentRecord.IsManualTransfer() {
+		return &payment.RefundResult{Key: refund.RefundKey, Amount: refund.Amount.String(), TransactionStatus: models.PaymentTypeManualTransfer}, nil
+	}
+	return s.provider.Refund(ctx, orderCode, payment.RefundRequest{
+		Key:    refund.RefundKey,
+		Amount: refund.Amount.IntPart(),
+		Reason: refund.Reason,
+	})
+}
+
+func (s *RefundService) createPendingRefund(ctx context.Context, tx *gorm.DB, req RefundRequest) (*models.Refund, error) {
+	order, err := s.orderRepo.LockByCode(ctx, tx, req.OrderCode)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrRefundOrderNotFound
+	}
+	if !refundableOrder(order) {
+		return nil, ErrRefundNotAllowed
+	}
+
+	refunded, err := s.refundRepo.SumAmount(ctx, tx, order.ID, models.RefundStatusPending, models.RefundStatusSucceeded)
+	if err != nil {
+		return nil, err
+	}
+	remaining := decimal.NewFromInt(order.GrandTotal.IntPart()).Sub(refunded)
+	if !remaining.IsPositive() {
+		return nil, ErrRefundNotAllowed
+	}
+
+	refundedQty, err := s.refundRepo.RefundedQtyByItem(ctx, tx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	items, itemsTotal, err := refundItems(order, req.Items, refundedQty)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := itemsTotal
+	if req.Amount.IsPositive() {
+		amount = req.Amount.Floor()
+	}
+	if !amount.IsPositive() {
+		return nil, ErrRefundEmpty
+	}
+	if amount.GreaterThan(remaining) {
+		return nil, ErrRefundAmountExceeded
+	}
+
+	refund := &models.Refund{
+		OrderID:   order.ID,
+		RefundKey: uuid.New().String(),
+		Amount:    amount,
+		Reason:    req.Reason,
+		Restock:   req.Restock && len(items) > 0,
+		Status:    models.RefundStatusPending,
+		ActorID:   req.ActorID,
+		Items:     items,
+	}
+	if err := s.refundRepo.Create(ctx, tx, refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// refundItems memvalidasi item yang dipilih dan menghitung nilainya dari GrandTotal item. Nilai dibagi per
+// unit secara kumulatif, jadi jumlah semua refund sebuah item tepat sama dengan total baris yang ditagih.
+func refundItems(order *models.Order, requested []RefundItemRequest, refundedQty map[string]int) ([]models.RefundItem, decimal.Decimal, error) {
+	orderItems := make(map[string]models.OrderItem, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		orderItems[item.ID] = item
+	}
+
+	var items []models.RefundItem
+	total := decimal.Zero
+	seen := make(map[string]bool, len(requested))
+	for _, req := range requested {
+		if req.Qty == 0 {
+			continue
+		}
+		item, ok := orderItems[req.OrderItemID]
+		if !ok || seen[req.OrderItemID] || req.Qty < 0 || refundedQty[item.ID]+req.Qty > item.Qty {
+			return nil, decimal.Zero, ErrRefundInvalidItem
+		}
+		seen[req.OrderItemID] = true
+
+		lineTotal := item.GrandTotal.IntPart()
+		before := lineTotal * int64(refundedQty[item.ID]) / int64(item.Qty)
+		after := lineTotal * int64(refundedQty[item.ID]+req.Qty) / int64(item.Qty)
+		amount := decimal.NewFromInt(after - before)
+
+		name := item.ProductName
+		if item.VariantName != "" {
+			name += " - " + item.VariantName
+		}
+		items = append(items, models.RefundItem{
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			Name:        name,
+			Qty:         req.Qty,
+			Amount:      amount,
+		})
+		total = total.Add(amount)
+	}
+	return items, total, nil
+}
+
+func (s *RefundService) completeRefund(ctx context.Context, tx *gorm.DB, orderCode string, refund *models.Refund, result *payment.RefundResult, restockedBefore map[string]ProductAlertState) (*models.Order, error) {
+	order, err := s.orderRepo.LockByCode(ctx, tx, orderCode)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrRefundOrderNotFound
+	}
+	// refund yang sama bisa diselesaikan dua request sekaligus (RetryRefund); hanya yang pertama yang mencatat
+	current, err := s.refundRepo.FindByID(ctx, tx, refund.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.Status != models.RefundStatusPending {
+		return nil, nil
+	}
+	if err := s.refundRepo.MarkStatus(ctx, tx, refund.ID, models.RefundStatusSucceeded, result.TransactionStatus, ""); err != nil {
+		return nil, err
+	}
+	// order yang sudah dikembalikan penuh, misalnya lewat chargeback, sudah direstock dan tidak diubah lagi
+	if order.Status == models.OrderStatusRefunded {
+		log.Printf("WARNING: RefundService: Order %s sudah berstatus Refunded, refund %s dicatat tanpa restock dan perubahan status", order.OrderCode, refund.ID)
+		return order, nil
+	}
+
+	if refund.Restock {
+		for _, item := range refund.Items {
+			if err := s.restockItem(ctx, tx, order, item, restockedBefore); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	refunded, err := s.refundRepo.SumAmount(ctx, tx, order.ID, models.RefundStatusSucceeded)
+	if err != nil {
+		return nil, err
+	}
+	paymentStatus, orderStatus := "Partially Refunded", models.OrderStatusPartiallyRefunded
+	if refunded.GreaterThanOrEqual(decimal.NewFromInt(order.GrandTotal.IntPart())) {
+		paymentStatus, orderStatus = "Refunded", models.OrderStatusRefunded
+	}
+
+	paymentRecord, err := s.paymentRepo.FindByOrderIDTx(ctx, tx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("payment record not found or database error: %w", err)
+	}
+	if err := s.paymentRepo.UpdatePaymentStatusTx(ctx, tx, paymentRecord.ID, paymentStatus); err != nil {
+		return nil, fmt.Errorf("failed to update payment status for payment ID %s: %w", paymentRecord.ID, err)
+	}
+	if err := s.orderRepo.UpdatePaymentStatusAndOrderStatus(ctx, tx, order.ID, paymentStatus, orderStatus); err != nil {
+		return nil, fmt.Errorf("failed to update order status for order ID %s: %w", order.ID, err)
+	}
+	order.PaymentStatus = paymentStatus
+	order.Status = orderStatus
+	return order, nil
+}
+
+func (s *RefundService) restockItem(ctx context.Context, tx *gorm.DB, order *models.Order, item models.RefundItem, restockedBefore map[string]ProductAlertState) error {
+	product, err := s.productRepo.GetByID(ctx, item.ProductID)
+	if err != nil {
+		log.Printf("WARNING: RefundService: Gagal mengambil produk %s untuk restock: %v", item.ProductID, err)
+		return nil
+	}
+	if product == nil {
+		return nil
+	}
+	variantID := ""
+	if item.VariantID != "" && product.FindVariant(item.VariantID) != nil {
+		variantID = item.VariantID
+	}
+	if _, seen := restockedBefore[product.ID]; !seen {
+		restockedBefore[product.ID] = NewProductAlertState(product)
+	}
+	if _, err := s.stockMovementRepo.Adjust(ctx, tx, repositories.StockAdjustment{
+		ProductID: product.ID,
+		VariantID: variantID,
+		Delta:     item.Qty,
+		Reason:    models.StockMovementRefund,
+		Reference: order.OrderCode,
+	}); err != nil {
+		return fmt.Errorf("gagal mengembalikan stok produk %s: %w", product.Name, err)
+	}
+	return nil
+}
+
+func (s *RefundService) sendRefundEmail(ctx context.Context, order *models.Order, refund *models.Refund) {
+	customer, err := s.orderCustomerRepo.FindByOrderID(ctx, order.ID)
+	if err != nil || customer == nil || customer.Email == "" {
+		log.Printf("RefundService: Email refund order %s tidak dikirim, data pembeli tidak ditemukan: %v", order.OrderCode, err)
+		return
+	}
+	body := BuildRefundEmailBody(customer.FirstName, order.OrderCode, refund.Amount, refund.Reason, refund.Items, order.Status == models.OrderStatusRefunded)
+	if err := s.mailer.SendHTMLEmail(customer.Email, "Pengembalian dana pesanan "+order.OrderCode, body); err != nil {
+		log.Printf("RefundService: Gagal mengirim email refund order %s: %v", order.OrderCode, err)
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+)
+
+const MaxReviewPhotos = 3
+
+var (
+	ErrReviewItemNotFound  = errors.New("item pesanan tidak ditemukan")
+	ErrReviewNotEligible   = errors.New("ulasan hanya bisa diberikan untuk pesanan yang sudah selesai")
+	ErrReviewAlreadyExists = errors.New("item pesanan ini sudah diulas")
+	ErrReviewInvalidRating = errors.New("rating harus antara 1 sampai 5")
+)
+
+type ReviewInput struct {
+	Rating     int
+	Title      string
+	Body       string
+	PhotoPaths []string
+}
+
+type ReviewService struct {
+	reviewRepo repositories.ReviewRepository
+	orderRepo  repositories.OrderRepository
+}
+
+func NewReviewService(reviewRepo repositories.ReviewRepository, orderRepo repositories.OrderRepository) *ReviewService {
+	return &ReviewService{
+		reviewRepo: reviewRepo,
+		orderRepo:  orderRepo,
+	}
+}
+
+// GetReviewableItem memastikan item milik order user, order sudah selesai, dan item belum pernah diulas.
+func (s *ReviewService) GetReviewableItem(ctx context.Context, userID, orderCode, orderItemID string) (*models.Order, *models.OrderItem, error) {
+	order, err := s.orderRepo.FindByCodeWithDetails(ctx, orderCode)
+	if err != nil {
+		return nil, nil, fmt.Errorf("gagal mengambil pesanan %s: %w", orderCode, err)
+	}
+	if order == nil || order.UserID != userID {
+		return nil, nil, ErrReviewItemNotFound
+	}
+
+	var item *models.OrderItem
+	for i := range order.OrderItems {
+		if order.OrderItems[i].ID == orderItemID {
+			item = &order.OrderItems[i]
+			break
+		}
+	}
+	if item == nil {
+		return nil, nil, ErrReviewItemNotFound
+	}
+	if order.Status != models.OrderStatusCompleted {
+		return order, item, ErrReviewNotEligible
+	}
+
+	exists, err := s.reviewRepo.ExistsForOrderItem(ctx, item.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exists {
+		return order, item, ErrReviewAlreadyExists
+	}
+	return order, item, nil
+}
+
+// Submit membuat ulasan baru berstatus pending. Unique index pada order_item_id menjaga satu ulasan per item
+// walaupun dua request datang bersamaan.
+func (s *ReviewService) Submit(ctx context.Context, userID, orderCode, orderItemID string, input ReviewInput) (*models.Review, error) {
+	if input.Rating < 1 || input.Rating > 5 {
+		return nil, ErrReviewInvalidRating
+	}
+
+	order, item, err := s.GetReviewableItem(ctx, userID, orderCode, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	review := &models.Review{
+		ProductID:   item.ProductID,
+		UserID:      userID,
+		OrderID:     order.ID,
+		OrderItemID: item.ID,
+		VariantName: item.VariantName,
+		Rating:      input.Rating,
+		Title:       strings.TrimSpace(input.Title),
+		Body:        strings.TrimSpace(input.Body),
+		Status:      models.ReviewStatusPending,
+	}
+	for _, path := range input.PhotoPaths {
+		review.Photos = append(review.Photos, models.ReviewPhoto{Path: path})
+	}
+
+	if err := s.reviewRepo.Create(ctx, review); err != nil {
+		if exists, checkErr := s.reviewRepo.ExistsForOrderItem(ctx, item.ID); checkErr == nil && exists {
+			return nil, ErrReviewAlreadyExists
+		}
+		return nil, err
+	}
+	return review, nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/models/other"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+)
+
+type ProductSearchResult struct {
+	Products       []models.Product
+	Total          int64
+	CorrectedQuery string
+	// Filter adalah filter yang benar-benar dipakai, termasuk kata kunci hasil koreksi.
+	Filter other.ProductFilter
+}
+
+// searchVocabularyTTL adalah lama kamus koreksi typo dipakai sebelum dibaca ulang dari database. Produk baru
+// atau yang diganti namanya baru bisa menjadi saran koreksi setelah kamus diperbarui.
+const searchVocabularyTTL = 10 * time.Minute
+
+type ProductSearchService struct {
+	productRepo repositories.ProductRepositoryImpl
+	searchRepo  repositories.SearchQueryRepository
+
+	vocabularyMutex    sync.RWMutex
+	vocabulary         map[string]bool
+	vocabularyLoadedAt time.Time
+}
+
+func NewProductSearchService(productRepo repositories.ProductRepositoryImpl, searchRepo repositories.SearchQueryRepository) *ProductSearchService {
+	return &ProductSearchService{
+		productRepo: productRepo,
+		searchRepo:  searchRepo,
+	}
+}
+
+// Search menjalankan listing katalog lewat ProductRepository.FindProducts. Jika kata kunci tidak
+// memberi hasil, setiap kata dikoreksi ke kata terdekat dari nama/SKU produk lalu dicari ulang dengan
+// filter yang sama. recordQuery sebaiknya hanya true di halaman pertama agar perpindahan halaman
+// tidak tercatat sebagai pencarian baru.
+func (s *ProductSearchService) Search(ctx context.Context, filter other.ProductFilter, userID string, limit, offset int, recordQuery bool) (*ProductSearchResult, error) {
+	query := strings.TrimSpace(filter.Keyword)
+	filter.Keyword = query
+
+	products, total, err := s.productRepo.FindProducts(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mencari produk: %w", err)
+	}
+	result := &ProductSearchResult{Products: products, Total: total, Filter: filter}
+	if query == "" {
+		return result, nil
+	}
+
+	if total == 0 {
+		corrected, err := s.correctQuery(ctx, query)
+		if err != nil {
+			log.Printf("ProductSearchService.Search: Gagal mengoreksi query '%s': %v", query, err)
+		} else if corrected != "" {
+			correctedFilter := filter
+			correctedFilter.Keyword = corrected
+			products, total, err = s.productRepo.FindProducts(ctx, correctedFilter, limit, offset)
+			if err != nil {
+				return nil, fmt.Errorf("gagal mencari produk dengan koreksi: %w", err)
+			}
+			if total > 0 {
+				result = &ProductSearchResult{Products: products, Total: total, CorrectedQuery: corrected, Filter: correctedFilter}
+			}
+		}
+	}
+
+	if recordQuery {
+		entry := &models.SearchQuery{
+			Query:          strings.ToLower(query),
+			CorrectedQuery: result.CorrectedQuery,
+			ResultCount:    result.Total,
+			UsedFallback:   result.CorrectedQuery != "",
+			UserID:         userID,
+		}
+		if err := s.searchRepo.Create(ctx, entry); err != nil {
+			log.Printf("ProductSearchService.Search: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// correctQuery mengembalikan query dengan kata yang sudah dikoreksi, atau string kosong jika tidak ada yang berubah.
+func (s *ProductSearchService) correctQuery(ctx context.Context, query string) (string, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return "", nil
+	}
+
+	vocabulary, err := s.searchVocabulary(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	changed := false
+	for i, term := range terms {
+		if vocabulary[term] {
+			continue
+		}
+		if suggestion := closestWord(term, vocabulary); suggestion != "" {
+			terms[i] = suggestion
+			changed = true
+		}
+	}
+	if !changed {
+		return "", nil
+	}
+	return strings.Join(terms, " "), nil
+}
+
+// searchVocabulary mengembalikan kamus kata dari nama/SKU produk, dibaca ulang dari database paling cepat
+// setiap searchVocabularyTTL. Map yang dikembalikan tidak boleh diubah pemanggil.
+func (s *ProductSearchService) searchVocabulary(ctx context.Context) (map[string]bool, error) {
+	s.vocabularyMutex.RLock()
+	vocabulary, loadedAt := s.vocabulary, s.vocabularyLoadedAt
+	s.vocabularyMutex.RUnlock()
+	if vocabulary != nil && time.Since(loadedAt) < searchVocabularyTTL {
+		return vocabulary, nil
+	}
+
+	entries, err := s.productRepo.GetSearchVocabulary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vocabulary = make(map[string]bool)
+	for _, entry := range entries {
+		for _, word := range searchTerms(entry) {
+			if len([]rune(word)) >= 3 {
+				vocabulary[word] = true
+			}
+		}
+	}
+
+	s.vocabularyMutex.Lock()
+	s.vocabulary = vocabulary
+	s.vocabularyLoadedAt = time.Now()
+	s.vocabularyMutex.Unlock()
+	return vocabulary, nil
+}
+
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// closestWord mencari kata dengan jarak Levenshtein terkecil. Kata pendek hanya boleh berbeda 1 huruf.
+func closestWord(term string, vocabulary map[string]bool) string {
+	maxDistance := 2
+	if len([]rune(term)) <= 5 {
+		maxDistance = 1
+	}
+
+	best := ""
+	bestDistance := maxDistance + 1
+	for word := range vocabulary {
+		d := levenshtein(term, word)
+		if d < bestDistance || (d == bestDistance && best != "" && word < best) {
+			best = word
+			bestDistance = d
+		}
+	}
+	if bestDistance > maxDistance {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/configs"
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrShippingQuoteNotFound = errors.New("quote ongkir tidak ditemukan")
+	ErrShippingQuoteExpired  = errors.New("quote ongkir sudah kedaluwarsa")
+	ErrShippingQuoteMismatch = errors.New("quote ongkir tidak sesuai dengan keranjang atau alamat saat ini")
+)
+
+// ShippingQuoteService menghitung ongkir lewat Komerce dan menyimpan setiap opsi sebagai quote yang
+// terikat pada keranjang, alamat tujuan, dan berat saat itu.
+type ShippingQuoteService struct {
+	quoteRepo  repositories.ShippingQuoteRepository
+	komerceSvc KomerceRajaOngkirClient
+	originID   int
+	ttl        time.Duration
+}
+
+func NewShippingQuoteService(quoteRepo repositories.ShippingQuoteRepository, komerceSvc KomerceRajaOngkirClient, originID int) *ShippingQuoteService {
+	return &ShippingQuoteService{
+		quoteRepo:  quoteRepo,
+		komerceSvc: komerceSvc,
+		originID:   originID,
+		ttl:        configs.GetShippingQuoteTTL(),
+	}
+}
+
+// CartShippingWeight menghitung berat kirim keranjang dalam gram dari item-itemnya (dibulatkan ke atas,
+// minimal 1 gram) sehingga tidak bergantung pada berat yang dikirim browser.
+func CartShippingWeight(cart *models.Cart) int {
+	total := decimal.Zero
+	for i := range cart.CartItems {
+		total = total.Add(cart.CartItems[i].UnitWeight().Mul(decimal.NewFromInt(int64(cart.CartItems[i].Qty))))
+	}
+	if weight := int(total.Ceil().IntPart()); weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// CreateQuotes meminta ongkir ke Komerce untuk keranjang dan alamat yang diberikan lalu menyimpan
+// setiap opsi layanan sebagai quote baru.
+func (s *ShippingQuoteService) CreateQuotes(ctx context.Context, cart *models.Cart, address *models.Address, courier string) ([]models.ShippingQuote, error) {
+	if s.originID == 0 {
+		return nil, errors.New("origin pengiriman toko belum dikonfigurasi")
+	}
+	destinationID, err := strconv.Atoi(address.LocationID)
+	if err != nil || destinationID == 0 {
+		return nil, fmt.Errorf("lokasi alamat %s tidak valid: %q", address.ID, address.LocationID)
+	}
+
+	weight := CartShippingWeight(cart)
+	costs, err := s.komerceSvc.CalculateCost(ctx, s.originID, destinationID, weight, courier)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.ttl)
+	quotes := make([]models.ShippingQuote, 0, len(costs))
+	for _, cost := range costs {
+		quotes = append(quotes, models.ShippingQuote{
+			CartID:        cart.ID,
+			AddressID:     address.ID,
+			OriginID:      s.originID,
+			DestinationID: address.LocationID,
+			Weight:        weight,
+			Courier:       courier,
+			Name:          cost.Name,
+			Code:          cost.Code,
+			Service:       cost.Service,
+			Description:   cost.Description,
+			Cost:          decimal.NewFromInt(int64(cost.Cost)),
+			Etd:           cost.Etd,
+			ExpiresAt:     expiresAt,
+		})
+	}
+	if err := s.quoteRepo.CreateBatch(ctx, quotes); err != nil {
+		return nil, err
+	}
+	return quotes, nil
+}
+
+// ValidateQuote memastikan quote masih berlaku dan dibuat untuk keranjang, alamat, dan berat keranjang
+// saat ini. Keranjang yang berubah setelah ongkir dihitung membuat quote lama ditolak.
+func (s *ShippingQuoteService) ValidateQuote(ctx context.Context, quoteID string, cart *models.Cart, address *models.Address) (*models.ShippingQuote, error) {
+	if quoteID == "" {
+		return nil, ErrShippingQuoteNotFound
+	}
+	quote, err := s.quoteRepo.FindByID(ctx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if quote == nil {
+		return nil, ErrShippingQuoteNotFound
+	}
+
+	if quote.CartID != cart.ID || quote.AddressID != address.ID || quote.DestinationID != address.LocationID ||
+		quote.OriginID != s.originID || quote.Weight != CartShippingWeight(cart) {
+		log.Printf("ShippingQuoteService.ValidateQuote: Quote %s tidak cocok. Cart: %s/%s, Address: %s/%s, Destination: %s/%s, Weight: %d/%d",
+			quote.ID, quote.CartID, cart.ID, quote.AddressID, address.ID, quote.DestinationID, address.LocationID, quote.Weight, CartShippingWeight(cart))
+		return nil, ErrShippingQuoteMismatch
+	}
+	if !time.Now().Before(quote.ExpiresAt) {
+		return nil, ErrShippingQuoteExpired
+	}
+	return quote, nil
+}
+
+// StartCleanupWorker menghapus quote yang sudah kedaluwarsa lebih dari satu TTL secara berkala sampai
+// ctx dibatalkan. Quote yang baru kedaluwarsa dibiarkan agar checkout masih bisa membedakan quote
+// kedaluwarsa dari quote yang tidak dikenal.
+func (s *ShippingQuoteService) StartCleanupWorker(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := s.quoteRepo.DeleteExpiredBefore(ctx, time.Now().Add(-s.ttl))
+				if err != nil {
+					log.Printf("ShippingQuoteService: gagal menghapus quote ongkir kedaluwarsa: %v", err)
+					continue
+				}
+				if deleted > 0 {
+					log.Printf("ShippingQuoteService: %d quote ongkir kedaluwarsa dihapus", deleted)
+				}
+			}
+		}
+	}()
+}
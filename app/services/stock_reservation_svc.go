@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+)
+
+type StockReservationService struct {
+	reservationRepo repositories.StockReservationRepository
+}
+
+func NewStockReservationService(reservationRepo repositories.StockReservationRepository) *StockReservationService {
+	return &StockReservationService{reservationRepo: reservationRepo}
+}
+
+// AvailableStock mengembalikan stok dikurangi hold aktif untuk produk atau varian.
+func (s *StockReservationService) AvailableStock(ctx context.Context, product *models.Product, variant *models.ProductVariant) (int, error) {
+	stock := product.Stock
+	variantID := ""
+	if variant != nil {
+		stock = variant.Stock
+		variantID = variant.ID
+	}
+
+	held, err := s.reservationRepo.GetActiveQty(ctx, product.ID, variantID)
+	if err != nil {
+		return 0, err
+	}
+	if available := stock - held; available > 0 {
+		return available, nil
+	}
+	return 0, nil
+}
+
+// ApplyAvailableStock mengganti Stock produk dan variannya dengan stok tersedia untuk keperluan tampilan.
+// Jangan simpan kembali produk yang sudah melalui fungsi ini.
+func (s *StockReservationService) ApplyAvailableStock(ctx context.Context, product *models.Product) error {
+	held, err := s.reservationRepo.GetActiveQty(ctx, product.ID, "")
+	if err != nil {
+		return err
+	}
+	product.Stock = max(product.Stock-held, 0)
+
+	if !product.HasVariants() {
+		return nil
+	}
+	heldByVariant, err := s.reservationRepo.GetActiveQtyByVariant(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+	for i := range product.Variants {
+		product.Variants[i].Stock = max(product.Variants[i].Stock-heldByVariant[product.Variants[i].ID], 0)
+	}
+	return nil
+}
+
+// StartExpiryWorker melepas hold yang melewati TTL secara berkala sampai ctx dibatalkan.
+func (s *StockReservationService) StartExpiryWorker(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				released, err := s.reservationRepo.ReleaseExpired(ctx)
+				if err != nil {
+					log.Printf("StockReservationService: gagal melepas reservasi kedaluwarsa: %v", err)
+					continue
+				}
+				if released > 0 {
+					log.Printf("StockReservationService: %d reservasi stok kedaluwarsa dilepas", released)
+				}
+			}
+		}
+	}()
+}
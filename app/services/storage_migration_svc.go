@@ -0,0 +1,211 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/storage"
+)
+
+const storageMigrationBatchSize = 100
+
+// StorageMigrationService menyalin file upload (gambar produk, foto ulasan dan bukti transfer) dari satu backend
+// storage ke backend lain lalu menulis ulang URL yang tersimpan di database. File yang URL-nya
+// sudah milik storage tujuan dilewati, sehingga migrasi aman dijalankan ulang.
+type StorageMigrationService struct {
+	productRepo repositories.ProductRepositoryImpl
+	reviewRepo  repositories.ReviewRepository
+	proofRepo   repositories.PaymentProofRepository
+	from        storage.Storage
+	to          storage.Storage
+}
+
+func NewStorageMigrationService(productRepo repositories.ProductRepositoryImpl, reviewRepo repositories.ReviewRepository, proofRepo repositories.PaymentProofRepository, from, to storage.Storage) *StorageMigrationService {
+	return &StorageMigrationService{
+		productRepo: productRepo,
+		reviewRepo:  reviewRepo,
+		proofRepo:   proofRepo,
+		from:        from,
+		to:          to,
+	}
+}
+
+// StorageMigrationResult melaporkan hasil migrasi satu record; Migrated false tanpa Err berarti
+// record sudah berada di storage tujuan.
+type StorageMigrationResult struct {
+	Kind     string
+	ID       string
+	Path     string
+	Migrated bool
+	Err      error
+}
+
+// MigrateProductImages memindahkan master dan semua ukuran setiap ProductImage. Jika deleteSource
+// bernilai true, file di storage asal dihapus setelah path di database diperbarui.
+func (s *StorageMigrationService) MigrateProductImages(ctx context.Context, deleteSource bool, onResult func(StorageMigrationResult)) (migrated, failed int, err error) {
+	for offset := 0; ; offset += storageMigrationBatchSize {
+		images, err := s.productRepo.GetProductImagesPaginated(ctx, storageMigrationBatchSize, offset)
+		if err != nil {
+			return migrated, failed, err
+		}
+		for i := range images {
+			if ctx.Err() != nil {
+				return migrated, failed, ctx.Err()
+			}
+			image := images[i]
+			result := StorageMigrationResult{Kind: "product_image", ID: image.ID, Path: image.Path}
+
+			keys, copyErr := s.copyPaths(ctx, &image.Path, &image.ExtraLarge, &image.Large, &image.Medium, &image.Small)
+			if copyErr == nil && len(keys) > 0 {
+				copyErr = s.productRepo.UpdateProductImageFiles(ctx, &image)
+				if copyErr != nil {
+					s.rollbackCopies(ctx, keys)
+				}
+			}
+			s.finish(ctx, &result, keys, copyErr, deleteSource, &migrated, &failed, onResult)
+		}
+		if len(images) < storageMigrationBatchSize {
+			return migrated, failed, nil
+		}
+	}
+}
+
+// MigrateReviewPhotos sama seperti MigrateProductImages untuk foto ulasan.
+func (s *StorageMigrationService) MigrateReviewPhotos(ctx context.Context, deleteSource bool, onResult func(StorageMigrationResult)) (migrated, failed int, err error) {
+	for offset := 0; ; offset += storageMigrationBatchSize {
+		photos, err := s.reviewRepo.GetPhotosPaginated(ctx, storageMigrationBatchSize, offset)
+		if err != nil {
+			return migrated, failed, err
+		}
+		for i := range photos {
+			if ctx.Err() != nil {
+				return migrated, failed, ctx.Err()
+			}
+			photo := photos[i]
+			result := StorageMigrationResult{Kind: "review_photo", ID: photo.ID, Path: photo.Path}
+
+			keys, copyErr := s.copyPaths(ctx, &photo.Path)
+			if copyErr == nil && len(keys) > 0 {
+				copyErr = s.reviewRepo.UpdatePhotoPath(ctx, photo.ID, photo.Path)
+				if copyErr != nil {
+					s.rollbackCopies(ctx, keys)
+				}
+			}
+			s.finish(ctx, &result, keys, copyErr, deleteSource, &migrated, &failed, onResult)
+		}
+		if len(photos) < storageMigrationBatchSize {
+			return migrated, failed, nil
+		}
+	}
+}
+
+// MigratePaymentProofs sama seperti MigrateProductImages untuk foto bukti transfer manual.
+func (s *StorageMigrationService) MigratePaymentProofs(ctx context.Context, deleteSource bool, onResult func(StorageMigrationResult)) (migrated, failed int, err error) {
+	for offset := 0; ; offset += storageMigrationBatchSize {
+		proofs, err := s.proofRepo.GetPaginated(ctx, storageMigrationBatchSize, offset)
+		if err != nil {
+			return migrated, failed, err
+		}
+		for i := range proofs {
+			if ctx.Err() != nil {
+				return migrated, failed, ctx.Err()
+			}
+			proof := proofs[i]
+			result := StorageMigrationResult{Kind: "payment_proof", ID: proof.ID, Path: proof.ImagePath}
+
+			keys, copyErr := s.copyPaths(ctx, &proof.ImagePath)
+			if copyErr == nil && len(keys) > 0 {
+				copyErr = s.proofRepo.UpdateImagePath(ctx, proof.ID, proof.ImagePath)
+				if copyErr != nil {
+					s.rollbackCopies(ctx, keys)
+				}
+			}
+			s.finish(ctx, &result, keys, copyErr, deleteSource, &migrated, &failed, onResult)
+		}
+		if len(proofs) < storageMigrationBatchSize {
+			return migrated, failed, nil
+		}
+	}
+}
+
+func (s *StorageMigrationService) finish(ctx context.Context, result *StorageMigrationResult, keys []string, err error, deleteSource bool, migrated, failed *int, onResult func(StorageMigrationResult)) {
+	switch {
+	case err != nil:
+		result.Err = err
+		*failed++
+	case len(keys) > 0:
+		result.Migrated = true
+		*migrated++
+		if deleteSource {
+			for _, key := range keys {
+				if err := s.from.Delete(ctx, key); err != nil {
+					log.Printf("StorageMigrationService: Gagal menghapus %s dari storage asal: %v", key, err)
+				}
+			}
+		}
+	}
+	if onResult != nil {
+		onResult(*result)
+	}
+}
+
+// copyPaths menyalin setiap path ke storage tujuan dan mengganti nilainya dengan URL baru. Path yang
+// sama (gambar lama memakai satu file untuk semua ukuran) hanya disalin sekali. Jika salah satu gagal,
+// salinan yang sudah dibuat dihapus lagi dan path tidak diubah.
+func (s *StorageMigrationService) copyPaths(ctx context.Context, paths ...*string) ([]string, error) {
+	copied := make(map[string]string, len(paths))
+	var keys []string
+	for _, p := range paths {
+		if *p == "" {
+			continue
+		}
+		if _, ok := copied[*p]; ok {
+			continue
+		}
+		key, ok := s.from.Key(*p)
+		if !ok {
+			if _, ok := s.to.Key(*p); ok {
+				continue
+			}
+			s.rollbackCopies(ctx, keys)
+			return nil, fmt.Errorf("%w: %s", storage.ErrForeignURL, *p)
+		}
+		if err := s.copyObject(ctx, key); err != nil {
+			s.rollbackCopies(ctx, keys)
+			return nil, err
+		}
+		copied[*p] = s.to.URL(key)
+		keys = append(keys, key)
+	}
+	for _, p := range paths {
+		if newURL, ok := copied[*p]; ok {
+			*p = newURL
+		}
+	}
+	return keys, nil
+}
+
+func (s *StorageMigrationService) copyObject(ctx context.Context, key string) error {
+	reader, err := s.from.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("gagal membaca %s: %w", key, err)
+	}
+	return s.to.Put(ctx, key, bytes.NewReader(data), int64(len(data)), storage.ContentType(key))
+}
+
+func (s *StorageMigrationService) rollbackCopies(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.to.Delete(ctx, key); err != nil {
+			log.Printf("StorageMigrationService: Gagal menghapus salinan %s di storage tujuan: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/Rakhulsr/go-ecommerce/app/utils/calc"
+	"github.com/shopspring/decimal"
+)
+
+// TaxService menghitung pajak per item keranjang berdasarkan kelas pajak produk dan pengaturan harga
+// termasuk/tidak termasuk pajak.
+type TaxService struct {
+	taxRepo     repositories.TaxRepository
+	settingRepo repositories.SettingRepository
+}
+
+func NewTaxService(taxRepo repositories.TaxRepository, settingRepo repositories.SettingRepository) *TaxService {
+	return &TaxService{taxRepo: taxRepo, settingRepo: settingRepo}
+}
+
+// PricesIncludeTax mengecek apakah harga katalog sudah termasuk pajak. Jika pengaturan belum ada atau
+// gagal dibaca, harga dianggap belum termasuk pajak seperti perilaku sebelumnya.
+func (s *TaxService) PricesIncludeTax(ctx context.Context) bool {
+	value, ok, err := s.settingRepo.Get(ctx, models.SettingPricesIncludeTax)
+	if err != nil {
+		log.Printf("TaxService.PricesIncludeTax: Gagal membaca pengaturan pajak: %v", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	include, _ := strconv.ParseBool(value)
+	return include
+}
+
+func (s *TaxService) SetPricesIncludeTax(ctx context.Context, include bool) error {
+	return s.settingRepo.Set(ctx, models.SettingPricesIncludeTax, strconv.FormatBool(include))
+}
+
+// ApplyToCart menghitung pajak setiap item dari subtotalnya setelah dikurangi bagian potongan voucher,
+// lalu mengisi TaxAmount, TaxPercent, TaxIncluded dan GrandTotal keranjang. TaxAmount keranjang selalu
+// sama dengan jumlah pajak per item. BaseTotalPrice dan VoucherDiscount harus sudah dihitung.
+func (s *TaxService) ApplyToCart(ctx context.Context, cart *models.Cart) error {
+	productIDs := make([]string, 0, len(cart.CartItems))
+	for _, item := range cart.CartItems {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	classes, err := s.taxRepo.ClassesForProducts(ctx, productIDs)
+	if err != nil {
+		return err
+	}
+	inclusive := s.PricesIncludeTax(ctx)
+
+	allocateVoucherDiscount(cart)
+
+	taxAmount := decimal.Zero
+	netAmount := decimal.Zero
+	rates := make(map[string]decimal.Decimal)
+	for i := range cart.CartItems {
+		item := &cart.CartItems[i]
+		class := classes[item.ProductID]
+
+		lineAmount := item.Subtotal.Sub(item.VoucherDiscount)
+		if lineAmount.IsNegative() {
+			lineAmount = decimal.Zero
+		}
+		item.TaxPercent = class.Rate
+		item.TaxAmount = class.TaxOn(lineAmount, inclusive)
+
+		taxAmount = taxAmount.Add(item.TaxAmount)
+		if inclusive {
+			lineAmount = lineAmount.Sub(item.TaxAmount)
+		}
+		netAmount = netAmount.Add(lineAmount)
+		if lineAmount.IsPositive() {
+			rates[class.Rate.StringFixed(2)] = class.Rate
+		}
+	}
+
+	taxableAmount := cart.BaseTotalPrice.Sub(cart.VoucherDiscount)
+	if taxableAmount.IsNegative() {
+		taxableAmount = decimal.Zero
+	}
+
+	cart.TaxIncluded = inclusive
+	cart.TaxAmount = taxAmount
+	cart.TaxPercent = cartTaxPercent(rates, taxAmount, netAmount)
+	cart.GrandTotal = taxableAmount
+	if !inclusive {
+		cart.GrandTotal = taxableAmount.Add(taxAmount)
+	}
+	return nil
+}
+
+// cartTaxPercent mengembalikan tarif yang ditampilkan untuk keranjang: tarif item jika semuanya sama,
+// atau tarif efektif (pajak dibagi dasar pengenaan pajak) jika keranjang berisi beberapa kelas pajak.
+func cartTaxPercent(rates map[string]decimal.Decimal, taxAmount, netAmount decimal.Decimal) decimal.Decimal {
+	if len(rates) == 1 {
+		for _, rate := range rates {
+			return rate
+		}
+	}
+	if !netAmount.IsPositive() {
+		return decimal.Zero
+	}
+	return taxAmount.Div(netAmount).Mul(decimal.NewFromInt(100)).Round(2)
+}
+
+// allocateVoucherDiscount membagi potongan voucher keranjang ke item yang memenuhi syarat voucher,
+// sebanding dengan subtotalnya, memakai calc.Allocate agar jumlahnya tepat sama dengan potongan.
+func allocateVoucherDiscount(cart *models.Cart) {
+	eligible := make([]int, 0, len(cart.CartItems))
+	for i := range cart.CartItems {
+		cart.CartItems[i].VoucherDiscount = decimal.Zero
+		if cart.CartItems[i].VoucherEligible {
+			eligible = append(eligible, i)
+		}
+	}
+	if !cart.VoucherDiscount.IsPositive() {
+		return
+	}
+	if len(eligible) == 0 {
+		for i := range cart.CartItems {
+			eligible = append(eligible, i)
+		}
+	}
+
+	weights := make([]decimal.Decimal, len(eligible))
+	for n, i := range eligible {
+		weights[n] = cart.CartItems[i].Subtotal
+	}
+	for n, share := range calc.Allocate(cart.VoucherDiscount, weights, 2) {
+		cart.CartItems[eligible[n]].VoucherDiscount = share
+	}
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	format "github.com/Rakhulsr/go-ecommerce/app/utils/format"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrVoucherNotFound       = errors.New("kode voucher tidak ditemukan")
+	ErrVoucherInactive       = errors.New("voucher sedang tidak aktif")
+	ErrVoucherNotStarted     = errors.New("voucher belum berlaku")
+	ErrVoucherEnded          = errors.New("masa berlaku voucher sudah berakhir")
+	ErrVoucherMinSpend       = errors.New("total belanja belum memenuhi minimum voucher")
+	ErrVoucherNotApplicable  = errors.New("voucher tidak berlaku untuk produk di keranjang")
+	ErrVoucherUsageLimit     = repositories.ErrVoucherUsageLimitReached
+	ErrVoucherUserLimit      = repositories.ErrVoucherUserLimitReached
+	ErrVoucherDiscountChange = errors.New("potongan voucher berubah, silakan periksa kembali keranjang Anda")
+)
+
+// VoucherService memeriksa syarat voucher terhadap keranjang dan mencatat pemakaiannya pada order.
+type VoucherService struct {
+	voucherRepo repositories.VoucherRepository
+	db          *gorm.DB
+}
+
+func NewVoucherService(voucherRepo repositories.VoucherRepository, db *gorm.DB) *VoucherService {
+	return &VoucherService{voucherRepo: voucherRepo, db: db}
+}
+
+// NormalizeVoucherCode menyeragamkan kode voucher yang diketik pelanggan atau admin.
+func NormalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Evaluate memeriksa seluruh syarat voucher untuk keranjang dan mengembalikan potongannya. Potongan
+// dihitung dari subtotal item setelah diskon produk dan promosi. VoucherEligible setiap item ikut diisi.
+func (s *VoucherService) Evaluate(ctx context.Context, cart *models.Cart, code string) (*models.Voucher, decimal.Decimal, error) {
+	code = NormalizeVoucherCode(code)
+	if code == "" {
+		return nil, decimal.Zero, ErrVoucherNotFound
+	}
+	voucher, err := s.voucherRepo.FindByCode(ctx, code)
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+	if voucher == nil {
+		return nil, decimal.Zero, ErrVoucherNotFound
+	}
+
+	if !voucher.IsActive {
+		return voucher, decimal.Zero, ErrVoucherInactive
+	}
+	now := time.Now()
+	if voucher.StartsAt != nil && now.Before(*voucher.StartsAt) {
+		return voucher, decimal.Zero, ErrVoucherNotStarted
+	}
+	if voucher.EndsAt != nil && !now.Before(*voucher.EndsAt) {
+		return voucher, decimal.Zero, ErrVoucherEnded
+	}
+
+	if err := s.markEligibleItems(ctx, voucher, cart); err != nil {
+		return voucher, decimal.Zero, err
+	}
+
+	subtotal := decimal.Zero
+	eligibleSubtotal := decimal.Zero
+	for _, item := range cart.CartItems {
+		if item.Product == nil {
+			continue
+		}
+		_, _, finalPriceUnit := item.Product.UnitPricing(item.Variant)
+		itemSubtotal := finalPriceUnit.Mul(decimal.NewFromInt(int64(item.Qty)))
+		subtotal = subtotal.Add(itemSubtotal)
+		if item.VoucherEligible {
+			eligibleSubtotal = eligibleSubtotal.Add(itemSubtotal)
+		}
+	}
+
+	if subtotal.LessThan(voucher.MinSpend) {
+		return voucher, decimal.Zero, fmt.Errorf("%w (minimal %s)", ErrVoucherMinSpend, format.FormatRupiah(voucher.MinSpend))
+	}
+	if !eligibleSubtotal.IsPositive() {
+		return voucher, decimal.Zero, ErrVoucherNotApplicable
+	}
+
+	total, byUser, err := s.voucherRepo.CountActiveRedemptions(ctx, voucher.ID, cart.UserID)
+	if err != nil {
+		return voucher, decimal.Zero, err
+	}
+	if voucher.UsageLimit > 0 && total >= int64(voucher.UsageLimit) {
+		return voucher, decimal.Zero, ErrVoucherUsageLimit
+	}
+	if voucher.PerUserLimit > 0 && byUser >= int64(voucher.PerUserLimit) {
+		return voucher, decimal.Zero, ErrVoucherUserLimit
+	}
+
+	return voucher, voucher.DiscountFor(eligibleSubtotal), nil
+}
+
+// MarkEligibleItems mengisi VoucherEligible item keranjang untuk voucher yang terpasang tanpa memeriksa
+// ulang syaratnya, agar potongan yang tersimpan bisa dibagi ke item saat menghitung pajak.
+func (s *VoucherService) MarkEligibleItems(ctx context.Context, cart *models.Cart) error {
+	if cart.VoucherID == "" {
+		return nil
+	}
+	voucher, err := s.voucherRepo.FindByID(ctx, cart.VoucherID)
+	if err != nil {
+		return err
+	}
+	if voucher == nil {
+		return nil
+	}
+	return s.markEligibleItems(ctx, voucher, cart)
+}
+
+func (s *VoucherService) markEligibleItems(ctx context.Context, voucher *models.Voucher, cart *models.Cart) error {
+	var categoryIDs map[string][]string
+	if voucher.IsRestricted() {
+		productIDs := make([]string, 0, len(cart.CartItems))
+		for _, item := range cart.CartItems {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		var err error
+		categoryIDs, err = s.voucherRepo.CategoryIDsByProductIDs(ctx, productIDs)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range cart.CartItems {
+		item := &cart.CartItems[i]
+		item.VoucherEligible = voucher.AppliesTo(item.ProductID, categoryIDs[item.ProductID])
+	}
+	return nil
+}
+
+// RefreshCartVoucher menghitung ulang potongan voucher yang terpasang di keranjang. Jika syarat tidak
+// lagi terpenuhi, kode tetap disimpan dengan potongan nol dan alasannya diisi di VoucherMessage.
+func (s *VoucherService) RefreshCartVoucher(ctx context.Context, cart *models.Cart) {
+	if cart.VoucherCode == "" {
+		cart.ClearVoucher()
+		return
+	}
+
+	voucher, discount, err := s.Evaluate(ctx, cart, cart.VoucherCode)
+	if err != nil {
+		if errors.Is(err, ErrVoucherNotFound) {
+			cart.ClearVoucher()
+			cart.VoucherMessage = err.Error()
+			return
+		}
+		cart.VoucherDiscount = decimal.Zero
+		if IsVoucherRuleError(err) {
+			cart.VoucherMessage = err.Error()
+		} else {
+			log.Printf("VoucherService.RefreshCartVoucher: Gagal mengevaluasi voucher %s untuk cart %s: %v", cart.VoucherCode, cart.ID, err)
+			cart.VoucherMessage = "Voucher tidak dapat diperiksa saat ini."
+		}
+		return
+	}
+	cart.VoucherID = voucher.ID
+	cart.VoucherCode = voucher.Code
+	cart.VoucherDiscount = discount
+	cart.VoucherMessage = ""
+}
+
+// RedeemForOrder mencatat pemakaian voucher keranjang untuk order di dalam transaksi checkout. Potongan
+// dihitung ulang dan harus sama dengan yang tersimpan di keranjang agar total yang dilihat pelanggan
+// tidak berubah diam-diam.
+func (s *VoucherService) RedeemForOrder(ctx context.Context, tx *gorm.DB, cart *models.Cart, order *models.Order) error {
+	if cart.VoucherCode == "" {
+		return nil
+	}
+
+	voucher, discount, err := s.Evaluate(ctx, cart, cart.VoucherCode)
+	if err != nil {
+		return err
+	}
+	if voucher.ID != cart.VoucherID || !discount.Equal(cart.VoucherDiscount) {
+		log.Printf("VoucherService.RedeemForOrder: Potongan voucher %s berubah untuk cart %s. Tersimpan: %s, Baru: %s",
+			cart.VoucherCode, cart.ID, cart.VoucherDiscount.String(), discount.String())
+		return ErrVoucherDiscountChange
+	}
+
+	return s.voucherRepo.Redeem(ctx, tx, &models.VoucherRedemption{
+		VoucherID:      voucher.ID,
+		UserID:         cart.UserID,
+		OrderID:        order.ID,
+		Code:           voucher.Code,
+		DiscountAmount: discount,
+	}, voucher.UsageLimit, voucher.PerUserLimit)
+}
+
+// ReleaseForOrder melepas pemakaian voucher order yang dibatalkan atau kedaluwarsa.
+func (s *VoucherService) ReleaseForOrder(ctx context.Context, tx *gorm.DB, orderID string) error {
+	released, err := s.voucherRepo.ReleaseByOrderID(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	if released > 0 {
+		log.Printf("VoucherService: Pemakaian voucher untuk order %s dilepas", orderID)
+	}
+	return nil
+}
+
+// ReleaseForOrderStatus melepas pemakaian voucher jika status baru order membatalkannya. Dipakai untuk
+// perubahan status di luar transaksi pembayaran, misalnya dari halaman admin.
+func (s *VoucherService) ReleaseForOrderStatus(ctx context.Context, orderID string, status int) error {
+	if !ReleasesVoucher(status) {
+		return nil
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.ReleaseForOrder(ctx, tx, orderID)
+	})
+}
+
+// ReleasesVoucher menandai status order yang mengembalikan kuota voucher.
+func ReleasesVoucher(status int) bool {
+	return status == models.OrderStatusCancelled || status == models.OrderStatusFailed
+}
+
+// IsVoucherRuleError membedakan voucher yang tidak memenuhi syarat dari kegagalan teknis.
+func IsVoucherRuleError(err error) bool {
+	for _, target := range []error{
+		ErrVoucherNotFound, ErrVoucherInactive, ErrVoucherNotStarted, ErrVoucherEnded, ErrVoucherMinSpend,
+		ErrVoucherNotApplicable, ErrVoucherUsageLimit, ErrVoucherUserLimit, ErrVoucherDiscountChange,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
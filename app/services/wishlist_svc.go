@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/models"
+	"github.com/Rakhulsr/go-ecommerce/app/repositories"
+	"github.com/shopspring/decimal"
+)
+
+const wishlistAlertQueueSize = 256
+
+var (
+	ErrWishlistProductNotFound = errors.New("produk tidak ditemukan")
+	ErrWishlistVariantRequired = errors.New("produk ini memiliki varian, silakan pilih varian di halaman produk")
+)
+
+// ProductAlertState adalah potret stok dan harga efektif produk sebelum diubah. Dibandingkan dengan
+// kondisi sesudahnya untuk menentukan notifikasi wishlist yang perlu dikirim.
+type ProductAlertState struct {
+	Stock int
+	Price decimal.Decimal
+}
+
+// NewProductAlertState memakai harga efektif termurah; untuk produk bervarian dihitung dari variannya.
+func NewProductAlertState(product *models.Product) ProductAlertState {
+	state := ProductAlertState{Stock: product.Stock}
+	_, _, state.Price = product.UnitPricing(nil)
+	for i := range product.Variants {
+		_, _, price := product.UnitPricing(&product.Variants[i])
+		if i == 0 || price.LessThan(state.Price) {
+			state.Price = price
+		}
+	}
+	return state
+}
+
+type wishlistAlertEmail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type WishlistService struct {
+	wishlistRepo repositories.WishlistRepository
+	productRepo  repositories.ProductRepositoryImpl
+	cartSvc      *CartService
+	mailer       *Mailer
+	appURL       string
+	alerts       chan wishlistAlertEmail
+}
+
+func NewWishlistService(
+	wishlistRepo repositories.WishlistRepository,
+	productRepo repositories.ProductRepositoryImpl,
+	cartSvc *CartService,
+	mailer *Mailer,
+	appURL string,
+) *WishlistService {
+	return &WishlistService{
+		wishlistRepo: wishlistRepo,
+		productRepo:  productRepo,
+		cartSvc:      cartSvc,
+		mailer:       mailer,
+		appURL:       strings.TrimRight(appURL, "/"),
+		alerts:       make(chan wishlistAlertEmail, wishlistAlertQueueSize),
+	}
+}
+
+func (s *WishlistService) Add(ctx context.Context, userID, productID string) (*models.Product, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil produk %s: %w", productID, err)
+	}
+	if product == nil {
+		return nil, ErrWishlistProductNotFound
+	}
+	if err := s.wishlistRepo.Add(ctx, userID, productID); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (s *WishlistService) Remove(ctx context.Context, userID, productID string) error {
+	return s.wishlistRepo.Remove(ctx, userID, productID)
+}
+
+func (s *WishlistService) GetItems(ctx context.Context, userID string) ([]models.WishlistItem, error) {
+	return s.wishlistRepo.GetByUserID(ctx, userID)
+}
+
+func (s *WishlistService) GetProductIDs(ctx context.Context, userID string) (map[string]bool, error) {
+	if userID == "" {
+		return map[string]bool{}, nil
+	}
+	return s.wishlistRepo.GetProductIDsByUser(ctx, userID)
+}
+
+func (s *WishlistService) SetAlerts(ctx context.Context, userID, productID string, backInStock, priceDrop bool) error {
+	item, err := s.wishlistRepo.FindByUserAndProduct(ctx, userID, productID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return ErrWishlistProductNotFound
+	}
+	return s.wishlistRepo.SetAlerts(ctx, userID, productID, backInStock, priceDrop)
+}
+
+// MoveToCart memasukkan satu unit produk ke keranjang lalu menghapusnya dari wishlist.
+// Produk bervarian harus dipilih variannya di halaman produk.
+func (s *WishlistService) MoveToCart(ctx context.Context, cartID, userID, productID string) (*models.Product, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil produk %s: %w", productID, err)
+	}
+	if product == nil {
+		return nil, ErrWishlistProductNotFound
+	}
+	if product.HasVariants() {
+		return product, ErrWishlistVariantRequired
+	}
+
+	if err := s.cartSvc.AddItemToCart(ctx, cartID, userID, productID, "", 1); err != nil {
+		return product, err
+	}
+	if err := s.wishlistRepo.Remove(ctx, userID, productID); err != nil {
+		log.Printf("WishlistService.MoveToCart: Produk %s sudah masuk keranjang tapi gagal dihapus dari wishlist: %v", productID, err)
+	}
+	return product, nil
+}
+
+// NotifyProductChange membandingkan kondisi produk saat ini dengan before lalu mengantrekan email
+// back-in-stock (stok 0 menjadi positif) dan price-drop (harga efektif turun) ke pelanggan yang opt-in.
+func (s *WishlistService) NotifyProductChange(ctx context.Context, productID string, before ProductAlertState) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil || product == nil {
+		log.Printf("WishlistService.NotifyProductChange: Gagal mengambil produk %s: %v", productID, err)
+		return
+	}
+	after := NewProductAlertState(product)
+	productURL := s.appURL + "/products/" + product.Slug
+
+	if before.Stock <= 0 && after.Stock > 0 {
+		subscribers, err := s.wishlistRepo.GetBackInStockSubscribers(ctx, productID)
+		if err != nil {
+			log.Printf("WishlistService.NotifyProductChange: %v", err)
+		}
+		for _, item := range subscribers {
+			s.enqueue(wishlistAlertEmail{
+				To:      item.User.Email,
+				Subject: fmt.Sprintf("%s tersedia kembali", product.Name),
+				Body:    BuildBackInStockEmailBody(product.Name, productURL),
+			})
+		}
+	}
+
+	if after.Price.LessThan(before.Price) {
+		subscribers, err := s.wishlistRepo.GetPriceDropSubscribers(ctx, productID)
+		if err != nil {
+			log.Printf("WishlistService.NotifyProductChange: %v", err)
+		}
+		for _, item := range subscribers {
+			s.enqueue(wishlistAlertEmail{
+				To:      item.User.Email,
+				Subject: fmt.Sprintf("Harga %s turun", product.Name),
+				Body:    BuildPriceDropEmailBody(product.Name, before.Price, after.Price, productURL),
+			})
+		}
+	}
+}
+
+// WatchedProductIDs mengembalikan produk yang punya minimal satu pelanggan notifikasi, dipakai untuk
+// perubahan massal agar potret hanya diambil untuk produk yang relevan.
+func (s *WishlistService) WatchedProductIDs(ctx context.Context) (map[string]bool, error) {
+	return s.wishlistRepo.GetWatchedProductIDs(ctx)
+}
+
+// SnapshotWatchedProducts memotret produk yang punya pelanggan notifikasi sebelum perubahan massal,
+// misalnya promosi. Hasilnya diteruskan ke NotifyWatchedProducts setelah perubahan selesai.
+func (s *WishlistService) SnapshotWatchedProducts(ctx context.Context) map[string]ProductAlertState {
+	watched, err := s.WatchedProductIDs(ctx)
+	if err != nil {
+		log.Printf("WishlistService.SnapshotWatchedProducts: Gagal mengambil produk yang dipantau: %v", err)
+	}
+	states := make(map[string]ProductAlertState, len(watched))
+	for productID := range watched {
+		if product, err := s.productRepo.GetByID(ctx, productID); err == nil && product != nil {
+			states[productID] = NewProductAlertState(product)
+		}
+	}
+	return states
+}
+
+func (s *WishlistService) NotifyWatchedProducts(ctx context.Context, before map[string]ProductAlertState) {
+	for productID, state := range before {
+		s.NotifyProductChange(ctx, productID, state)
+	}
+}
+
+// StartPromotionAlertWorker memeriksa promosi yang mulai atau berakhir sejak pemeriksaan sebelumnya.
+// Harga sebelum perubahan dihitung dengan promosi yang berlaku pada waktu pemeriksaan sebelumnya.
+func (s *WishlistService) StartPromotionAlertWorker(ctx context.Context, promoRepo repositories.PromotionRepository, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				scheduled, err := promoRepo.GetScheduledBetween(ctx, last, now)
+				if err != nil {
+					log.Printf("WishlistService: Gagal memeriksa jadwal promosi: %v", err)
+					continue
+				}
+				if len(scheduled) > 0 {
+					s.notifyScheduledPromotions(ctx, promoRepo, last)
+				}
+				last = now
+			}
+		}
+	}()
+}
+
+func (s *WishlistService) notifyScheduledPromotions(ctx context.Context, promoRepo repositories.PromotionRepository, since time.Time) {
+	watched, err := s.WatchedProductIDs(ctx)
+	if err != nil {
+		log.Printf("WishlistService: Gagal mengambil produk yang dipantau: %v", err)
+		return
+	}
+	for productID := range watched {
+		product, err := s.productRepo.GetByID(ctx, productID)
+		if err != nil || product == nil {
+			continue
+		}
+		if err := promoRepo.ApplyToProducts(ctx, since, product); err != nil {
+			log.Printf("WishlistService: Gagal menghitung harga sebelum jadwal promosi: %v", err)
+			return
+		}
+		s.NotifyProductChange(ctx, productID, NewProductAlertState(product))
+	}
+}
+
+// enqueue tidak pernah memblokir request; email dibuang (dengan log) bila antrean penuh.
+func (s *WishlistService) enqueue(email wishlistAlertEmail) {
+	if email.To == "" {
+		return
+	}
+	select {
+	case s.alerts <- email:
+	default:
+		log.Printf("WishlistService: Antrean notifikasi penuh, email '%s' ke %s dibuang", email.Subject, email.To)
+	}
+}
+
+// StartAlertWorker mengirim email notifikasi wishlist dari antrean sampai ctx dibatalkan.
+func (s *WishlistService) StartAlertWorker(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case email := <-s.alerts:
+				s.sendAlert(email)
+			}
+		}
+	}()
+}
+
+// SendQueuedAlerts mengirim langsung semua email yang masih di antrean. Dipakai proses singkat seperti
+// perintah CLI yang tidak menjalankan StartAlertWorker.
+func (s *WishlistService) SendQueuedAlerts() {
+	for {
+		select {
+		case email := <-s.alerts:
+			s.sendAlert(email)
+		default:
+			return
+		}
+	}
+}
+
+func (s *WishlistService) sendAlert(email wishlistAlertEmail) {
+	if err := s.mailer.SendHTMLEmail(email.To, email.Subject, email.Body); err != nil {
+		log.Printf("WishlistService: Gagal mengirim notifikasi '%s' ke %s: %v", email.Subject, email.To, err)
+	}
+}
@@ -0,0 +1,71 @@
+package calc
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// Allocate membagi total ke beberapa bagian sebanding dengan weights, dibulatkan ke places desimal,
+// memakai metode sisa terbesar (largest remainder). Setiap bagian dibulatkan ke bawah lebih dulu, lalu
+// sisa satuan terkecil diberikan satu per satu ke bagian dengan sisa pecahan terbesar; jika sama,
+// bagian dengan indeks lebih kecil didahulukan. Hasilnya deterministik dan jumlahnya selalu sama dengan
+// total yang sudah dibulatkan. Bobot negatif dianggap nol; jika semua bobot nol, total dibagi rata.
+func Allocate(total decimal.Decimal, weights []decimal.Decimal, places int32) []decimal.Decimal {
+	parts := make([]decimal.Decimal, len(weights))
+	for i := range parts {
+		parts[i] = decimal.Zero
+	}
+	total = total.Round(places)
+	if len(weights) == 0 || total.IsZero() {
+		return parts
+	}
+
+	sign := decimal.NewFromInt(1)
+	if total.IsNegative() {
+		sign = sign.Neg()
+		total = total.Neg()
+	}
+
+	normalized := make([]decimal.Decimal, len(weights))
+	weightSum := decimal.Zero
+	for i, weight := range weights {
+		normalized[i] = decimal.Max(weight, decimal.Zero)
+		weightSum = weightSum.Add(normalized[i])
+	}
+	if weightSum.IsZero() {
+		for i := range normalized {
+			normalized[i] = decimal.NewFromInt(1)
+		}
+		weightSum = decimal.NewFromInt(int64(len(normalized)))
+	}
+
+	remainders := make([]decimal.Decimal, len(weights))
+	allocated := decimal.Zero
+	for i, weight := range normalized {
+		quota := total.Mul(weight).Div(weightSum)
+		parts[i] = quota.RoundFloor(places)
+		remainders[i] = quota.Sub(parts[i])
+		allocated = allocated.Add(parts[i])
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].GreaterThan(remainders[order[b]])
+	})
+
+	unit := decimal.New(1, -places)
+	leftover := total.Sub(allocated).Div(unit).IntPart()
+	for n := int64(0); n < leftover; n++ {
+		i := order[n%int64(len(order))]
+		parts[i] = parts[i].Add(unit)
+	}
+
+	for i := range parts {
+		parts[i] = parts[i].Mul(sign)
+	}
+	return parts
+}
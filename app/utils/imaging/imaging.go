@@ -0,0 +1,174 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	MaxUploadBytes = 5 << 20
+	MaxDimension   = 6000
+	MinDimension   = 100
+	// MasterDimension adalah sisi terpanjang salinan master yang disimpan di ProductImage.Path
+	// dan menjadi sumber saat varian dibuat ulang.
+	MasterDimension = 2400
+	jpegQuality     = 85
+)
+
+var (
+	ErrTooLarge        = fmt.Errorf("ukuran file maksimal %d MB", MaxUploadBytes>>20)
+	ErrUnsupportedType = errors.New("format gambar harus JPEG, PNG, GIF atau WebP")
+	ErrDimensions      = fmt.Errorf("dimensi gambar harus antara %dpx dan %dpx", MinDimension, MaxDimension)
+)
+
+// Size adalah satu ukuran turunan. Gambar diperkecil agar sisi terpanjangnya tidak melebihi MaxSide
+// dan tidak pernah diperbesar.
+type Size struct {
+	Name    string
+	MaxSide int
+}
+
+var (
+	SizeExtraLarge = Size{Name: "xl", MaxSide: 1600}
+	SizeLarge      = Size{Name: "lg", MaxSide: 1024}
+	SizeMedium     = Size{Name: "md", MaxSide: 600}
+	SizeSmall      = Size{Name: "sm", MaxSide: 240}
+)
+
+// Result berisi master dan keempat ukuran turunan yang sudah di-encode ulang tanpa metadata EXIF.
+type Result struct {
+	Ext        string
+	Master     []byte
+	ExtraLarge []byte
+	Large      []byte
+	Medium     []byte
+	Small      []byte
+}
+
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Validate memeriksa ukuran file upload, tipe berdasarkan isi (bukan ekstensi), dan dimensi gambar
+// tanpa men-decode seluruh piksel.
+func Validate(data []byte) error {
+	if len(data) > MaxUploadBytes {
+		return ErrTooLarge
+	}
+	return validateContent(data)
+}
+
+func validateContent(data []byte) error {
+	if !allowedTypes[http.DetectContentType(data)] {
+		return ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ErrUnsupportedType
+	}
+	if config.Width < MinDimension || config.Height < MinDimension || config.Width > MaxDimension || config.Height > MaxDimension {
+		return ErrDimensions
+	}
+	return nil
+}
+
+// Process men-decode, memutar sesuai orientasi EXIF, dan meng-encode ulang gambar ke semua ukuran.
+// Gambar dengan transparansi disimpan sebagai PNG, selainnya JPEG. Batas ukuran file tidak diperiksa
+// di sini agar gambar lama yang besar tetap bisa diproses ulang; upload baru harus lolos Validate.
+func Process(data []byte) (*Result, error) {
+	if err := validateContent(data); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca gambar: %w", err)
+	}
+
+	src := toNRGBA(decoded)
+	if orientation := jpegOrientation(data); orientation > 1 {
+		src = applyOrientation(src, orientation)
+	}
+
+	ext := ".jpg"
+	if !src.Opaque() {
+		ext = ".png"
+	}
+
+	result := &Result{Ext: ext}
+	targets := []struct {
+		size Size
+		dst  *[]byte
+	}{
+		{Size{Name: "master", MaxSide: MasterDimension}, &result.Master},
+		{SizeExtraLarge, &result.ExtraLarge},
+		{SizeLarge, &result.Large},
+		{SizeMedium, &result.Medium},
+		{SizeSmall, &result.Small},
+	}
+	for _, target := range targets {
+		encoded, err := encode(resize(src, target.size.MaxSide), ext)
+		if err != nil {
+			return nil, fmt.Errorf("gagal menyimpan ukuran %s: %w", target.size.Name, err)
+		}
+		*target.dst = encoded
+	}
+	return result, nil
+}
+
+func toNRGBA(src image.Image) *image.NRGBA {
+	if nrgba, ok := src.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	return dst
+}
+
+func resize(src *image.NRGBA, maxSide int) *image.NRGBA {
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	if width <= maxSide && height <= maxSide {
+		return src
+	}
+
+	if width >= height {
+		height = max(1, height*maxSide/width)
+		width = maxSide
+	} else {
+		width = max(1, width*maxSide/height)
+		height = maxSide
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, ext string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if ext == ".png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
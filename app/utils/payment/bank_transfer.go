@@ -0,0 +1,61 @@
+package payment
+
+import "time"
+
+// Bank untuk pembayaran transfer lewat Core API. Mandiri memakai Mandiri Bill (echannel) dengan biller code
+// dan bill key, bank lain memakai nomor virtual account.
+const (
+	BankBCA     = "bca"
+	BankBNI     = "bni"
+	BankBRI     = "bri"
+	BankPermata = "permata"
+	BankMandiri = "mandiri"
+)
+
+// BankTransferBanks adalah urutan bank yang ditawarkan di halaman checkout.
+var BankTransferBanks = []string{BankBCA, BankBNI, BankBRI, BankPermata, BankMandiri}
+
+// BankTransfer adalah hasil charge transfer bank yang harus dibayar pembeli sebelum ExpiresAt.
+type BankTransfer struct {
+	TransactionID string
+	Bank          string
+	VaNumber      string
+	BillerCode    string
+	BillKey       string
+	ExpiresAt     time.Time
+}
+
+func IsBankTransferBank(bank string) bool {
+	for _, b := range BankTransferBanks {
+		if b == bank {
+			return true
+		}
+	}
+	return false
+}
+
+// BankLabel adalah nama metode pembayaran untuk bank transfer yang disimpan di Payment.
+func BankLabel(bank string) string {
+	switch bank {
+	case BankBCA:
+		return "BCA Virtual Account"
+	case BankBNI:
+		return "BNI Virtual Account"
+	case BankBRI:
+		return "BRI Virtual Account"
+	case BankPermata:
+		return "Permata Virtual Account"
+	case BankMandiri:
+		return "Mandiri Bill Payment"
+	default:
+		return "Transfer Bank"
+	}
+}
+
+// BankPaymentType adalah payment_type Midtrans untuk bank tersebut.
+func BankPaymentType(bank string) string {
+	if bank == BankMandiri {
+		return "echannel"
+	}
+	return "bank_transfer"
+}
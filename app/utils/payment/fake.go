@@ -0,0 +1,367 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// FakeGatewayPath adalah prefix route halaman pembayaran gateway fake di aplikasi.
+	FakeGatewayPath = "/fake-gateway/"
+	// DefaultFakeServerKey dipakai untuk signature notifikasi jika MIDTRANS_SERVER_KEY kosong.
+	DefaultFakeServerKey = "fake-server-key"
+)
+
+// FakeProvider adalah gateway offline untuk development dan pengujian otomatis. Transaksi disimpan di
+// memori; halaman pembayarannya dilayani aplikasi sendiri di FakeGatewayPath dengan tombol bayar, gagal
+// dan kedaluwarsa yang mengirim notifikasi berformat Midtrans ke webhook aplikasi.
+type FakeProvider struct {
+	serverKey       string
+	appURL          string
+	notificationURL string
+	client          *http.Client
+
+	mu           sync.Mutex
+	transactions map[string]*fakeTransaction
+	tokens       map[string]string
+}
+
+type fakeTransaction struct {
+	request   TransactionRequest
+	token     string
+	status    Status
+	expiresAt time.Time
+	refunded  int64
+}
+
+func NewFakeProvider(cfg Config) *FakeProvider {
+	serverKey := cfg.ServerKey
+	if serverKey == "" {
+		serverKey = DefaultFakeServerKey
+	}
+	log.Printf("Payment: memakai gateway fake, halaman pembayaran di %s%s", cfg.AppURL, FakeGatewayPath)
+	return &FakeProvider{
+		serverKey:       serverKey,
+		appURL:          cfg.AppURL,
+		notificationURL: cfg.NotificationURL,
+		client:          &http.Client{Timeout: 30 * time.Second},
+		transactions:    make(map[string]*fakeTransaction),
+		tokens:          make(map[string]string),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *FakeProvider) CreateTransaction(ctx context.Context, req TransactionRequest) (*Transaction, error) {
+	txn, err := p.newTransaction(req, "fake")
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{Token: txn.token, RedirectURL: p.PayURL(txn.token)}, nil
+}
+
+// ChargeBankTransfer membuat nomor virtual account atau bill key acak. Transaction ID-nya sama dengan token
+// halaman pembayaran fake, jadi pembayaran bisa disimulasikan lewat PayURL(TransactionID).
+func (p *FakeProvider) ChargeBankTransfer(ctx context.Context, req TransactionRequest, bank string) (*BankTransfer, error) {
+	if !IsBankTransferBank(bank) {
+		return nil, fmt.Errorf("fake gateway: bank transfer %q tidak didukung", bank)
+	}
+	txn, err := p.newTransaction(req, BankPaymentType(bank))
+	if err != nil {
+		return nil, err
+	}
+
+	digits := fmt.Sprintf("%012d", uuid.New().ID())
+	transfer := &BankTransfer{TransactionID: txn.token, Bank: bank, ExpiresAt: txn.expiresAt}
+	if bank == BankMandiri {
+		transfer.BillerCode = "70012"
+		transfer.BillKey = digits
+	} else {
+		transfer.VaNumber = "8808" + digits
+	}
+	p.mu.Lock()
+	txn.status.VaNumber, txn.status.BillerCode, txn.status.BillKey = transfer.VaNumber, transfer.BillerCode, transfer.BillKey
+	p.mu.Unlock()
+	return transfer, nil
+}
+
+// PayURL adalah halaman pembayaran fake untuk token transaksi.
+func (p *FakeProvider) PayURL(token string) string {
+	return p.appURL + FakeGatewayPath + "pay/" + token
+}
+
+func (p *FakeProvider) newTransaction(req TransactionRequest, paymentType string) (*fakeTransaction, error) {
+	if req.OrderID == "" || req.GrossAmount <= 0 {
+		return nil, errors.New("fake gateway: order_id dan gross_amount wajib diisi")
+	}
+	var itemsTotal int64
+	for _, item := range req.Items {
+		itemsTotal += item.Price * int64(item.Qty)
+	}
+	if len(req.Items) > 0 && itemsTotal != req.GrossAmount {
+		return nil, fmt.Errorf("fake gateway: jumlah item %d tidak sama dengan gross_amount %d", itemsTotal, req.GrossAmount)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// sama seperti Midtrans, order_id yang sudah pernah dipakai ditolak walaupun transaksinya masih pending
+	if _, ok := p.transactions[req.OrderID]; ok {
+		return nil, fmt.Errorf("fake gateway: order_id %s sudah digunakan", req.OrderID)
+	}
+
+	token := uuid.New().String()
+	txn := &fakeTransaction{
+		request:   req,
+		token:     token,
+		expiresAt: time.Now().Add(req.Expiry),
+		status: Status{
+			OrderID:           req.OrderID,
+			TransactionID:     token,
+			TransactionStatus: StatusPending,
+			StatusCode:        "201",
+			GrossAmount:       fmt.Sprintf("%d.00", req.GrossAmount),
+			PaymentType:       paymentType,
+		},
+	}
+	p.transactions[req.OrderID] = txn
+	p.tokens[token] = req.OrderID
+	return txn, nil
+}
+
+func (p *FakeProvider) CheckStatus(ctx context.Context, orderID string) (*Status, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	txn, ok := p.transactions[orderID]
+	if !ok {
+		return nil, ErrTransactionNotFound
+	}
+	status := txn.status
+	return &status, nil
+}
+
+func (p *FakeProvider) ParseNotification(body []byte) (*Notification, error) {
+	var n Notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+	if n.OrderID == "" {
+		return nil, fmt.Errorf("%w: order_id kosong", ErrInvalidNotification)
+	}
+	return &n, nil
+}
+
+func (p *FakeProvider) VerifyNotification(ctx context.Context, n *Notification) (*Status, error) {
+	if err := VerifySignature(n, p.serverKey); err != nil {
+		return nil, err
+	}
+	status := n.Status
+	return &status, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, orderID string, req RefundRequest) (*RefundResult, error) {
+	p.mu.Lock()
+	txn, ok := p.transactions[orderID]
+	if !ok {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("%w: %w", ErrRefundRejected, ErrTransactionNotFound)
+	}
+	// sama seperti Midtrans, Key yang sudah diterima tidak memotong dana lagi dan hasilnya dikembalikan ulang
+	if slices.Contains(txn.status.RefundKeys, req.Key) {
+		transactionStatus := txn.status.TransactionStatus
+		p.mu.Unlock()
+		return &RefundResult{
+			Key:               req.Key,
+			Amount:            fmt.Sprintf("%d.00", req.Amount),
+			TransactionStatus: transactionStatus,
+			StatusCode:        "200",
+		}, nil
+	}
+	switch txn.status.TransactionStatus {
+	case StatusSettlement, StatusCapture, StatusPartialRefund:
+	default:
+		p.mu.Unlock()
+		return nil, fmt.Errorf("%w: fake gateway: transaksi %s berstatus %s tidak bisa direfund", ErrRefundRejected, orderID, txn.status.TransactionStatus)
+	}
+	if req.Amount <= 0 || txn.refunded+req.Amount > txn.request.GrossAmount {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("%w: fake gateway: jumlah refund %d melebihi sisa transaksi", ErrRefundRejected, req.Amount)
+	}
+	txn.refunded += req.Amount
+	txn.status.RefundKeys = append(slices.Clip(txn.status.RefundKeys), req.Key)
+	txn.status.TransactionStatus = StatusPartialRefund
+	if txn.refunded == txn.request.GrossAmount {
+		txn.status.TransactionStatus = StatusRefund
+	}
+	txn.status.StatusCode = "200"
+	notification := p.notification(txn)
+	p.mu.Unlock()
+
+	// Midtrans juga mengirim notifikasi refund secara terpisah dari respons API
+	go p.notify(notification)
+
+	return &RefundResult{
+		Key:               req.Key,
+		Amount:            fmt.Sprintf("%d.00", req.Amount),
+		TransactionStatus: notification.TransactionStatus,
+		StatusCode:        "200",
+	}, nil
+}
+
+// ServeHTTP melayani halaman pembayaran fake: GET menampilkan rincian transaksi, POST dengan action
+// pay, fail atau expire mengubah status, mengirim notifikasi, lalu kembali ke FinishURL.
+func (p *FakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.URL.Path, FakeGatewayPath+"pay/")
+	if !ok || token == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	p.mu.Lock()
+	txn, found := p.transactions[p.tokens[token]]
+	if !found || txn.token != token {
+		p.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		data := fakePageData{
+			Request:   txn.request,
+			Status:    txn.status.TransactionStatus,
+			Pending:   txn.status.TransactionStatus == StatusPending,
+			ExpiresAt: txn.expiresAt,
+			Action:    FakeGatewayPath + "pay/" + token,
+		}
+		p.mu.Unlock()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := fakePageTemplate.Execute(w, data); err != nil {
+			log.Printf("FakeProvider: Gagal merender halaman pembayaran: %v", err)
+		}
+		return
+	}
+
+	if txn.status.TransactionStatus != StatusPending {
+		p.mu.Unlock()
+		http.Error(w, "transaksi sudah tidak menunggu pembayaran", http.StatusConflict)
+		return
+	}
+	switch r.FormValue("action") {
+	case "pay":
+		txn.status.TransactionStatus, txn.status.FraudStatus, txn.status.StatusCode = StatusSettlement, FraudAccept, "200"
+	case "fail":
+		txn.status.TransactionStatus, txn.status.FraudStatus, txn.status.StatusCode = StatusDeny, FraudAccept, "202"
+	case "expire":
+		txn.status.TransactionStatus, txn.status.FraudStatus, txn.status.StatusCode = StatusExpire, "", "407"
+	default:
+		p.mu.Unlock()
+		http.Error(w, "action tidak dikenal", http.StatusBadRequest)
+		return
+	}
+	notification := p.notification(txn)
+	finishURL := txn.request.FinishURL
+	p.mu.Unlock()
+
+	p.notify(notification)
+
+	if finishURL == "" {
+		finishURL = p.appURL + "/"
+	}
+	http.Redirect(w, r, finishURLWithStatus(finishURL, notification), http.StatusSeeOther)
+}
+
+func (p *FakeProvider) notification(txn *fakeTransaction) Notification {
+	n := Notification{Status: txn.status, Currency: "IDR"}
+	n.SignatureKey = SignatureKey(n.OrderID, n.StatusCode, n.GrossAmount, p.serverKey)
+	return n
+}
+
+func (p *FakeProvider) notify(n Notification) {
+	if p.notificationURL == "" {
+		return
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		log.Printf("FakeProvider: Gagal menyusun notifikasi %s: %v", n.OrderID, err)
+		return
+	}
+	resp, err := p.client.Post(p.notificationURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("FakeProvider: Gagal mengirim notifikasi %s ke %s: %v", n.OrderID, p.notificationURL, err)
+		return
+	}
+	resp.Body.Close()
+	log.Printf("FakeProvider: Notifikasi %s (%s) dikirim, respons %d", n.OrderID, n.TransactionStatus, resp.StatusCode)
+}
+
+// finishURLWithStatus menambahkan parameter yang juga dikirim Midtrans saat kembali ke FinishURL.
+func finishURLWithStatus(finishURL string, n Notification) string {
+	u, err := url.Parse(finishURL)
+	if err != nil {
+		return finishURL
+	}
+	q := u.Query()
+	q.Set("order_id", n.OrderID)
+	q.Set("status_code", n.StatusCode)
+	q.Set("transaction_status", n.TransactionStatus)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+type fakePageData struct {
+	Request   TransactionRequest
+	Status    string
+	Pending   bool
+	ExpiresAt time.Time
+	Action    string
+}
+
+var fakePageTemplate = template.Must(template.New("fake").Parse(`<!DOCTYPE html>
+<html lang="id">
+<head>
+<meta charset="utf-8">
+<title>Fake Payment Gateway - {{ .Request.OrderID }}</title>
+<style>
+body { font-family: sans-serif; max-width: 560px; margin: 40px auto; color: #1f2937; }
+table { width: 100%; border-collapse: collapse; margin: 16px 0; }
+td { padding: 6px 0; border-bottom: 1px solid #e5e7eb; }
+td.num { text-align: right; }
+.notice { background: #fef3c7; padding: 8px 12px; border-radius: 6px; font-size: 14px; }
+button { padding: 10px 16px; margin-right: 8px; border: 0; border-radius: 6px; color: #fff; cursor: pointer; }
+.pay { background: #059669; } .fail { background: #dc2626; } .expire { background: #6b7280; }
+</style>
+</head>
+<body>
+<p class="notice">Gateway pembayaran fake untuk development. Tidak ada uang yang ditagih.</p>
+<h1>Order {{ .Request.OrderID }}</h1>
+<p>Status: <strong>{{ .Status }}</strong> &middot; berlaku sampai {{ .ExpiresAt.Format "02 Jan 2006 15:04" }}</p>
+<table>
+{{ range .Request.Items }}<tr><td>{{ .Name }} &times; {{ .Qty }}</td><td class="num">Rp {{ .Price }}</td></tr>
+{{ end }}<tr><td><strong>Total</strong></td><td class="num"><strong>Rp {{ .Request.GrossAmount }}</strong></td></tr>
+</table>
+{{ if .Pending }}
+<form method="POST" action="{{ .Action }}">
+<button class="pay" name="action" value="pay">Bayar</button>
+<button class="fail" name="action" value="fail">Gagal</button>
+<button class="expire" name="action" value="expire">Kedaluwarsa</button>
+</form>
+{{ else if .Request.FinishURL }}
+<p><a href="{{ .Request.FinishURL }}">Kembali ke toko</a></p>
+{{ end }}
+</body>
+</html>
+`))
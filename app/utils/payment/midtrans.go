@@ -0,0 +1,249 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/coreapi"
+	"github.com/midtrans/midtrans-go/snap"
+)
+
+// MidtransProvider memakai Snap untuk halaman pembayaran dan Core API untuk charge bank transfer, status
+// serta refund.
+type MidtransProvider struct {
+	serverKey     string
+	snapClient    snap.Client
+	coreAPIClient coreapi.Client
+}
+
+func NewMidtransProvider(cfg Config) (*MidtransProvider, error) {
+	if cfg.ServerKey == "" {
+		return nil, errors.New("MIDTRANS_SERVER_KEY belum diatur")
+	}
+	env := midtrans.Sandbox
+	if cfg.Production {
+		env = midtrans.Production
+	}
+
+	p := &MidtransProvider{serverKey: cfg.ServerKey}
+	p.snapClient.New(cfg.ServerKey, env)
+	p.coreAPIClient.New(cfg.ServerKey, env)
+	log.Println("Midtrans Snap dan CoreAPI client initialized.")
+	return p, nil
+}
+
+func (p *MidtransProvider) Name() string {
+	return ProviderMidtrans
+}
+
+func midtransItems(req TransactionRequest) *[]midtrans.ItemDetails {
+	items := make([]midtrans.ItemDetails, 0, len(req.Items))
+	for _, item := range req.Items {
+		items = append(items, midtrans.ItemDetails{ID: item.ID, Name: item.Name, Price: item.Price, Qty: item.Qty})
+	}
+	return &items
+}
+
+func midtransCustomer(req TransactionRequest) *midtrans.CustomerDetails {
+	address := &midtrans.CustomerAddress{
+		FName:       req.Customer.Address.Name,
+		Address:     req.Customer.Address.Address,
+		City:        req.Customer.Address.City,
+		Postcode:    req.Customer.Address.PostCode,
+		Phone:       req.Customer.Address.Phone,
+		CountryCode: "IDN",
+	}
+	return &midtrans.CustomerDetails{
+		FName:    req.Customer.FirstName,
+		LName:    req.Customer.LastName,
+		Email:    req.Customer.Email,
+		Phone:    req.Customer.Phone,
+		BillAddr: address,
+		ShipAddr: address,
+	}
+}
+
+func (p *MidtransProvider) CreateTransaction(ctx context.Context, req TransactionRequest) (*Transaction, error) {
+	snapReq := &snap.Request{
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:  req.OrderID,
+			GrossAmt: req.GrossAmount,
+		},
+		Items:           midtransItems(req),
+		CustomerDetail:  midtransCustomer(req),
+		EnabledPayments: snap.AllSnapPaymentType,
+		Expiry: &snap.ExpiryDetails{
+			Unit:     "minute",
+			Duration: int64(req.Expiry / time.Minute),
+		},
+		Callbacks: &snap.Callbacks{
+			Finish: req.FinishURL,
+		},
+	}
+
+	snapResp, errMidtrans := p.snapClient.CreateTransaction(snapReq)
+	if errMidtrans != nil {
+		return nil, errMidtrans
+	}
+	if snapResp == nil || snapResp.RedirectURL == "" || snapResp.Token == "" {
+		log.Printf("Midtrans CreateTransaction returned empty or invalid response for OrderCode: %s. Response: %+v", req.OrderID, snapResp)
+		return nil, errors.New("midtrans transaction initiated but returned invalid response (missing redirect URL or token)")
+	}
+	return &Transaction{Token: snapResp.Token, RedirectURL: snapResp.RedirectURL}, nil
+}
+
+// midtransExpiryLocation adalah zona waktu expiry_time pada respons Core API.
+var midtransExpiryLocation = time.FixedZone("WIB", 7*60*60)
+
+func (p *MidtransProvider) ChargeBankTransfer(ctx context.Context, req TransactionRequest, bank string) (*BankTransfer, error) {
+	chargeReq := &coreapi.ChargeReq{
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:  req.OrderID,
+			GrossAmt: req.GrossAmount,
+		},
+		Items:           midtransItems(req),
+		CustomerDetails: midtransCustomer(req),
+		CustomExpiry: &coreapi.CustomExpiry{
+			ExpiryDuration: int(req.Expiry / time.Minute),
+			Unit:           "minute",
+		},
+	}
+	switch bank {
+	case BankMandiri:
+		chargeReq.PaymentType = coreapi.PaymentTypeEChannel
+		chargeReq.EChannel = &coreapi.EChannelDetail{BillInfo1: "Pembayaran:", BillInfo2: req.OrderID}
+	case BankBCA, BankBNI, BankBRI, BankPermata:
+		chargeReq.PaymentType = coreapi.PaymentTypeBankTransfer
+		chargeReq.BankTransfer = &coreapi.BankTransferDetails{Bank: midtrans.Bank(bank)}
+	default:
+		return nil, fmt.Errorf("bank transfer %q tidak didukung", bank)
+	}
+
+	resp, errMidtrans := p.coreAPIClient.ChargeTransaction(chargeReq)
+	if errMidtrans != nil {
+		return nil, fmt.Errorf("failed to charge bank transfer with Midtrans: %w", errMidtrans)
+	}
+	if resp == nil {
+		return nil, errors.New("invalid charge response from Midtrans API (nil response)")
+	}
+	if resp.StatusCode != "201" {
+		return nil, fmt.Errorf("midtrans menolak charge %s (%s): %s", bank, resp.StatusCode, resp.StatusMessage)
+	}
+
+	transfer := &BankTransfer{
+		TransactionID: resp.TransactionID,
+		Bank:          bank,
+		VaNumber:      resp.PermataVaNumber,
+		BillerCode:    resp.BillerCode,
+		BillKey:       resp.BillKey,
+		ExpiresAt:     time.Now().Add(req.Expiry),
+	}
+	for _, va := range resp.VaNumbers {
+		if va.Bank == bank {
+			transfer.VaNumber = va.VANumber
+		}
+	}
+	if expiresAt, err := time.ParseInLocation("2006-01-02 15:04:05", resp.ExpiryTime, midtransExpiryLocation); err == nil {
+		transfer.ExpiresAt = expiresAt
+	}
+	if transfer.VaNumber == "" && transfer.BillKey == "" {
+		log.Printf("Midtrans ChargeTransaction returned no payment number for OrderCode: %s. Response: %+v", req.OrderID, resp)
+		return nil, errors.New("midtrans charge succeeded but returned no virtual account number or bill key")
+	}
+	return transfer, nil
+}
+
+func (p *MidtransProvider) CheckStatus(ctx context.Context, orderID string) (*Status, error) {
+	resp, errMidtrans := p.coreAPIClient.CheckTransaction(orderID)
+	if errMidtrans != nil {
+		if errMidtrans.StatusCode == 404 {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, fmt.Errorf("failed to check transaction with Midtrans (API error): %w", errMidtrans)
+	}
+	if resp == nil {
+		return nil, errors.New("invalid transaction status from Midtrans API (nil response)")
+	}
+	if resp.StatusCode == "404" {
+		return nil, ErrTransactionNotFound
+	}
+	if len(resp.StatusCode) > 0 && resp.StatusCode[0] == '5' {
+		return nil, fmt.Errorf("midtrans API server error: %s", resp.StatusCode)
+	}
+	status := &Status{
+		OrderID:           resp.OrderID,
+		TransactionID:     resp.TransactionID,
+		TransactionStatus: resp.TransactionStatus,
+		FraudStatus:       resp.FraudStatus,
+		StatusCode:        resp.StatusCode,
+		GrossAmount:       resp.GrossAmount,
+		PaymentType:       resp.PaymentType,
+		VaNumber:          resp.PermataVaNumber,
+		BillerCode:        resp.BillerCode,
+		BillKey:           resp.BillKey,
+	}
+	if len(resp.VaNumbers) > 0 {
+		status.VaNumber = resp.VaNumbers[0].VANumber
+	}
+	for _, refund := range resp.Refunds {
+		status.RefundKeys = append(status.RefundKeys, refund.RefundKey)
+	}
+	return status, nil
+}
+
+func (p *MidtransProvider) ParseNotification(body []byte) (*Notification, error) {
+	var n Notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+	if n.OrderID == "" {
+		return nil, fmt.Errorf("%w: order_id kosong", ErrInvalidNotification)
+	}
+	return &n, nil
+}
+
+// VerifyNotification memeriksa signature_key secara lokal dengan server key, jadi isi notifikasi yang lolos
+// bisa dipercaya tanpa memanggil CheckTransaction untuk setiap webhook.
+func (p *MidtransProvider) VerifyNotification(ctx context.Context, n *Notification) (*Status, error) {
+	if err := VerifySignature(n, p.serverKey); err != nil {
+		return nil, err
+	}
+	status := n.Status
+	return &status, nil
+}
+
+func (p *MidtransProvider) Refund(ctx context.Context, orderID string, req RefundRequest) (*RefundResult, error) {
+	resp, errMidtrans := p.coreAPIClient.RefundTransaction(orderID, &coreapi.RefundReq{
+		RefundKey: req.Key,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+	})
+	if errMidtrans != nil {
+		// hanya respons 4xx yang pasti ditolak; timeout, gangguan jaringan dan 5xx belum tentu gagal
+		if errMidtrans.StatusCode >= 400 && errMidtrans.StatusCode < 500 {
+			return nil, fmt.Errorf("%w: %v", ErrRefundRejected, errMidtrans)
+		}
+		return nil, fmt.Errorf("failed to refund transaction with Midtrans: %w", errMidtrans)
+	}
+	if resp == nil {
+		return nil, errors.New("invalid refund response from Midtrans API (nil response)")
+	}
+	if resp.StatusCode != "200" {
+		if strings.HasPrefix(resp.StatusCode, "4") {
+			return nil, fmt.Errorf("%w (%s): %s", ErrRefundRejected, resp.StatusCode, resp.StatusMessage)
+		}
+		return nil, fmt.Errorf("midtrans belum memastikan refund (%s): %s", resp.StatusCode, resp.StatusMessage)
+	}
+	return &RefundResult{
+		Key:               resp.RefundKey,
+		Amount:            resp.RefundAmount,
+		TransactionStatus: resp.TransactionStatus,
+		StatusCode:        resp.StatusCode,
+	}, nil
+}
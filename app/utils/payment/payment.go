@@ -0,0 +1,225 @@
+package payment
+
+import (
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Rakhulsr/go-ecommerce/app/configs"
+)
+
+const (
+	ProviderMidtrans = "midtrans"
+	ProviderFake     = "fake"
+
+	// DefaultNotificationPath adalah route webhook aplikasi yang menerima notifikasi pembayaran.
+	DefaultNotificationPath = "/midtrans-notification"
+)
+
+// Status transaksi mengikuti penamaan Midtrans karena itu yang disimpan dan diproses aplikasi.
+const (
+	StatusCapture           = "capture"
+	StatusSettlement        = "settlement"
+	StatusPending           = "pending"
+	StatusDeny              = "deny"
+	StatusExpire            = "expire"
+	StatusCancel            = "cancel"
+	StatusRefund            = "refund"
+	StatusPartialRefund     = "partial_refund"
+	StatusChargeback        = "chargeback"
+	StatusPartialChargeback = "partial_chargeback"
+
+	FraudAccept = "accept"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaksi tidak ditemukan di gateway pembayaran")
+	ErrInvalidNotification = errors.New("notifikasi pembayaran tidak valid")
+	ErrRefundRejected      = errors.New("refund ditolak gateway pembayaran")
+)
+
+// PaymentProvider adalah gateway pembayaran yang dipakai checkout dan webhook. OrderID di semua method
+// adalah kode order (OrderCode) yang dikirim ke gateway, bukan ID baris order.
+type PaymentProvider interface {
+	Name() string
+	// CreateTransaction membuat transaksi baru dan mengembalikan token serta URL halaman pembayaran.
+	CreateTransaction(ctx context.Context, req TransactionRequest) (*Transaction, error)
+	// ChargeBankTransfer membuat transaksi virtual account atau Mandiri Bill langsung lewat Core API tanpa
+	// halaman pembayaran gateway; nomor pembayarannya ditampilkan aplikasi sendiri.
+	ChargeBankTransfer(ctx context.Context, req TransactionRequest, bank string) (*BankTransfer, error)
+	// CheckStatus membaca status transaksi terbaru langsung dari gateway.
+	CheckStatus(ctx context.Context, orderID string) (*Status, error)
+	// ParseNotification membaca body webhook tanpa memverifikasinya.
+	ParseNotification(body []byte) (*Notification, error)
+	// VerifyNotification memastikan notifikasi benar berasal dari gateway tanpa memanggil API gateway dan
+	// mengembalikan status yang boleh dipakai untuk memproses order.
+	VerifyNotification(ctx context.Context, n *Notification) (*Status, error)
+	// Refund meminta pengembalian dana. Error yang membungkus ErrRefundRejected berarti gateway pasti tidak
+	// memproses refund; error lain (timeout, gangguan jaringan, error server gateway) berarti hasilnya belum
+	// pasti dan harus dicek lewat CheckStatus sebelum diulang dengan Key yang sama.
+	Refund(ctx context.Context, orderID string, req RefundRequest) (*RefundResult, error)
+}
+
+type TransactionRequest struct {
+	OrderID     string
+	GrossAmount int64
+	Items       []Item
+	Customer    Customer
+	// Expiry adalah lama transaksi boleh dibayar sejak dibuat.
+	Expiry    time.Duration
+	FinishURL string
+}
+
+// Item adalah satu baris rincian pembayaran. Harga harus bulat dan jumlah Price*Qty semua item sama
+// dengan GrossAmount.
+type Item struct {
+	ID    string
+	Name  string
+	Price int64
+	Qty   int32
+}
+
+type Customer struct {
+	FirstName string
+	LastName  string
+	Email     string
+	Phone     string
+	Address   Address
+}
+
+type Address struct {
+	Name     string
+	Address  string
+	City     string
+	PostCode string
+	Phone    string
+}
+
+type Transaction struct {
+	Token       string
+	RedirectURL string
+}
+
+type Status struct {
+	OrderID           string `json:"order_id"`
+	TransactionID     string `json:"transaction_id"`
+	TransactionStatus string `json:"transaction_status"`
+	FraudStatus       string `json:"fraud_status"`
+	StatusCode        string `json:"status_code"`
+	GrossAmount       string `json:"gross_amount"`
+	PaymentType       string `json:"payment_type"`
+	// Nomor pembayaran transfer bank hanya diisi CheckStatus; bentuknya di body notifikasi berbeda per bank.
+	VaNumber   string `json:"-"`
+	BillerCode string `json:"-"`
+	BillKey    string `json:"-"`
+	// RefundKeys adalah Key semua refund yang sudah diterima gateway untuk transaksi ini, hanya diisi CheckStatus.
+	RefundKeys []string `json:"-"`
+}
+
+type Notification struct {
+	Status
+	SignatureKey string `json:"signature_key"`
+	Currency     string `json:"currency"`
+}
+
+type RefundRequest struct {
+	// Key mencegah refund yang sama diproses dua kali oleh gateway.
+	Key    string
+	Amount int64
+	Reason string
+}
+
+type RefundResult struct {
+	Key               string
+	Amount            string
+	TransactionStatus string
+	StatusCode        string
+}
+
+type Config struct {
+	Provider        string
+	ServerKey       string
+	Production      bool
+	AppURL          string
+	NotificationURL string
+}
+
+func ConfigFromEnv(env configs.ENV) Config {
+	appURL := strings.TrimRight(env.APP_URL, "/")
+	return Config{
+		Provider:        env.PAYMENT_PROVIDER,
+		ServerKey:       env.MIDTRANS_SERVER_KEY,
+		Production:      env.APP_ENV == "production",
+		AppURL:          appURL,
+		NotificationURL: appURL + DefaultNotificationPath,
+	}
+}
+
+// New membuat provider sesuai cfg.Provider; provider kosong berarti Midtrans.
+func New(cfg Config) (PaymentProvider, error) {
+	switch cfg.Provider {
+	case "", ProviderMidtrans:
+		return NewMidtransProvider(cfg)
+	case ProviderFake:
+		if cfg.Production {
+			return nil, errors.New("gateway pembayaran fake tidak boleh dipakai di production")
+		}
+		return NewFakeProvider(cfg), nil
+	default:
+		return nil, fmt.Errorf("gateway pembayaran tidak dikenal: %q", cfg.Provider)
+	}
+}
+
+// MethodLabel adalah nama metode pembayaran yang disimpan di Payment dan ditampilkan ke pembeli.
+func MethodLabel(provider string) string {
+	switch provider {
+	case ProviderFake:
+		return "Fake Gateway"
+	default:
+		return "Midtrans Snap"
+	}
+}
+
+// SignatureKey menghitung signature notifikasi dengan rumus Midtrans:
+// SHA512(order_id + status_code + gross_amount + server key).
+func SignatureKey(orderID, statusCode, grossAmount, serverKey string) string {
+	sum := sha512.Sum512([]byte(orderID + statusCode + grossAmount + serverKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// statusCodes adalah status_code yang sah untuk setiap transaction_status. Midtrans mengirim 407 untuk expire
+// dan 200 untuk sebagian cancel, jadi keduanya ikut diterima.
+var statusCodes = map[string][]string{
+	StatusCapture:           {"200"},
+	StatusSettlement:        {"200"},
+	StatusRefund:            {"200"},
+	StatusPartialRefund:     {"200"},
+	StatusChargeback:        {"200"},
+	StatusPartialChargeback: {"200"},
+	StatusPending:           {"201"},
+	StatusDeny:              {"202"},
+	StatusCancel:            {"202", "200"},
+	StatusExpire:            {"202", "407"},
+}
+
+// VerifySignature mencocokkan signature_key notifikasi dengan SignatureKey yang dihitung ulang, lalu memastikan
+// status_code sesuai dengan transaction_status.
+func VerifySignature(n *Notification, serverKey string) error {
+	if n.SignatureKey == "" {
+		return fmt.Errorf("%w: signature kosong", ErrInvalidNotification)
+	}
+	expected := SignatureKey(n.OrderID, n.StatusCode, n.GrossAmount, serverKey)
+	if subtle.ConstantTimeCompare([]byte(n.SignatureKey), []byte(expected)) != 1 {
+		return fmt.Errorf("%w: signature tidak cocok", ErrInvalidNotification)
+	}
+	if codes, ok := statusCodes[n.TransactionStatus]; ok && !slices.Contains(codes, n.StatusCode) {
+		return fmt.Errorf("%w: status_code %s tidak sesuai dengan transaction_status %s", ErrInvalidNotification, n.StatusCode, n.TransactionStatus)
+	}
+	return nil
+}
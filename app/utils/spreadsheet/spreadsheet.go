@@ -0,0 +1,118 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var ErrUnsupportedFormat = errors.New("format file harus CSV atau XLSX")
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// FormatFromFilename menentukan format dari ekstensi file.
+func FormatFromFilename(name string) (string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".xlsx":
+		return FormatXLSX, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// ContentType mengembalikan content type untuk header download.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Read membaca semua baris dari sheet pertama (XLSX) atau seluruh file (CSV). Baris boleh memiliki
+// jumlah kolom yang berbeda.
+func Read(r io.Reader, format string) ([][]string, error) {
+	switch format {
+	case FormatCSV:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("gagal membaca file CSV: %w", err)
+		}
+		// file CSV dari Excel sering diawali BOM UTF-8
+		reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("format CSV tidak valid: %w", err)
+		}
+		return rows, nil
+	case FormatXLSX:
+		file, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("format XLSX tidak valid: %w", err)
+		}
+		defer file.Close()
+
+		sheets := file.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("file XLSX tidak memiliki sheet")
+		}
+		rows, err := file.GetRows(sheets[0])
+		if err != nil {
+			return nil, fmt.Errorf("gagal membaca sheet %s: %w", sheets[0], err)
+		}
+		return rows, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// Write menulis rows ke w. sheetName hanya dipakai untuk XLSX.
+func Write(w io.Writer, format, sheetName string, rows [][]string) error {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.WriteAll(rows); err != nil {
+			return fmt.Errorf("gagal menulis CSV: %w", err)
+		}
+		return nil
+	case FormatXLSX:
+		file := excelize.NewFile()
+		defer file.Close()
+
+		if err := file.SetSheetName(file.GetSheetName(0), sheetName); err != nil {
+			return fmt.Errorf("gagal membuat sheet %s: %w", sheetName, err)
+		}
+		for i, row := range rows {
+			cells := make([]interface{}, len(row))
+			for j, value := range row {
+				cells[j] = value
+			}
+			cell, err := excelize.CoordinatesToCellName(1, i+1)
+			if err != nil {
+				return err
+			}
+			if err := file.SetSheetRow(sheetName, cell, &cells); err != nil {
+				return fmt.Errorf("gagal menulis baris %d: %w", i+1, err)
+			}
+		}
+		if err := file.Write(w); err != nil {
+			return fmt.Errorf("gagal menulis XLSX: %w", err)
+		}
+		return nil
+	default:
+		return ErrUnsupportedFormat
+	}
+}
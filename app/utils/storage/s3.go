@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	s3CacheControl     = "public, max-age=31536000, immutable"
+	s3PublicReadPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["*"]},"Action":["s3:GetObject"],"Resource":["arn:aws:s3:::%s/*"]}]}`
+)
+
+// S3Storage menyimpan file di bucket S3-compatible (AWS S3, MinIO, R2, dll). Objek harus bisa
+// dibaca publik lewat S3PublicURL (bucket policy atau CDN di depannya).
+type S3Storage struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+// NewS3Storage membuat client dan memastikan bucket ada; bucket dibuat jika belum ada.
+func NewS3Storage(ctx context.Context, cfg Config) (*S3Storage, error) {
+	if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT dan S3_BUCKET wajib diisi untuk storage s3")
+	}
+
+	endpoint, useSSL := cfg.S3Endpoint, cfg.S3UseSSL
+	if rest, ok := strings.CutPrefix(endpoint, "https://"); ok {
+		endpoint, useSSL = rest, true
+	} else if rest, ok := strings.CutPrefix(endpoint, "http://"); ok {
+		endpoint, useSSL = rest, false
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure:       useSSL,
+		Region:       cfg.S3Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat client S3: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.S3Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("gagal memeriksa bucket %s: %w", cfg.S3Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.S3Bucket, minio.MakeBucketOptions{Region: cfg.S3Region}); err != nil {
+			return nil, fmt.Errorf("gagal membuat bucket %s: %w", cfg.S3Bucket, err)
+		}
+		// bucket baru (misalnya MinIO lokal) langsung dibuat publik-baca agar gambar bisa tampil di browser
+		if err := client.SetBucketPolicy(ctx, cfg.S3Bucket, fmt.Sprintf(s3PublicReadPolicy, cfg.S3Bucket)); err != nil {
+			log.Printf("NewS3Storage: Gagal mengatur bucket %s agar publik-baca: %v", cfg.S3Bucket, err)
+		}
+	}
+
+	baseURL := cfg.S3PublicURL
+	if baseURL == "" {
+		scheme := "http"
+		if useSSL {
+			scheme = "https"
+		}
+		baseURL = fmt.Sprintf("%s://%s/%s", scheme, endpoint, cfg.S3Bucket)
+	}
+
+	return &S3Storage{client: client, bucket: cfg.S3Bucket, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if _, err := cleanKey(key); err != nil {
+		return err
+	}
+	if _, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: s3CacheControl,
+	}); err != nil {
+		return fmt.Errorf("gagal mengunggah %s ke bucket %s: %w", key, s.bucket, err)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if _, err := cleanKey(key); err != nil {
+		return nil, err
+	}
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil %s: %w", key, err)
+	}
+	// GetObject bersifat lazy; Stat memastikan objeknya memang ada sebelum dibaca.
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return nil, fmt.Errorf("gagal mengambil %s: %w", key, err)
+	}
+	return object, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if _, err := cleanKey(key); err != nil {
+		return err
+	}
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("gagal menghapus %s dari bucket %s: %w", key, s.bucket, err)
+	}
+	return nil
+}
+
+func (s *S3Storage) URL(key string) string {
+	return joinURL(s.baseURL, key)
+}
+
+func (s *S3Storage) Key(publicURL string) (string, bool) {
+	return keyFromURL(s.baseURL, publicURL)
+}
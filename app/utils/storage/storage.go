@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/Rakhulsr/go-ecommerce/app/configs"
+)
+
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+
+	DefaultLocalDir = "./static/uploads"
+	DefaultLocalURL = "/static/uploads"
+)
+
+var (
+	ErrNotFound   = errors.New("objek tidak ditemukan di storage")
+	ErrInvalidKey = errors.New("key storage tidak valid")
+	ErrForeignURL = errors.New("URL bukan milik storage ini")
+)
+
+// Storage menyimpan file upload (gambar produk, foto ulasan) dengan key relatif seperti
+// "products/<uuid>.jpg". Yang disimpan di database adalah URL publik hasil URL(key).
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete tidak mengembalikan error jika objek memang sudah tidak ada.
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+	// Key kebalikan dari URL; ok bernilai false jika URL tidak berasal dari storage ini.
+	Key(publicURL string) (key string, ok bool)
+}
+
+type Config struct {
+	Driver string
+
+	LocalDir string
+	LocalURL string
+
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+	S3PublicURL string
+}
+
+func ConfigFromEnv(env configs.ENV) Config {
+	return Config{
+		Driver:      env.STORAGE_DRIVER,
+		LocalDir:    env.STORAGE_LOCAL_DIR,
+		LocalURL:    env.STORAGE_LOCAL_URL,
+		S3Endpoint:  env.S3_ENDPOINT,
+		S3Region:    env.S3_REGION,
+		S3Bucket:    env.S3_BUCKET,
+		S3AccessKey: env.S3_ACCESS_KEY,
+		S3SecretKey: env.S3_SECRET_KEY,
+		S3UseSSL:    env.S3_USE_SSL == "true",
+		S3PublicURL: env.S3_PUBLIC_URL,
+	}
+}
+
+// New membuat storage sesuai cfg.Driver; driver kosong berarti local.
+func New(ctx context.Context, cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", DriverLocal:
+		dir, baseURL := cfg.LocalDir, cfg.LocalURL
+		if dir == "" {
+			dir = DefaultLocalDir
+		}
+		if baseURL == "" {
+			baseURL = DefaultLocalURL
+		}
+		return NewLocalStorage(dir, baseURL), nil
+	case DriverS3:
+		return NewS3Storage(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("driver storage tidak dikenal: %q", cfg.Driver)
+	}
+}
+
+// DeleteURL menghapus objek berdasarkan URL publik yang tersimpan di database.
+func DeleteURL(ctx context.Context, s Storage, publicURL string) error {
+	if publicURL == "" {
+		return nil
+	}
+	key, ok := s.Key(publicURL)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrForeignURL, publicURL)
+	}
+	return s.Delete(ctx, key)
+}
+
+// Origin mengembalikan scheme://host storage jika URL publiknya absolut (misalnya bucket S3),
+// dipakai untuk menambahkan img-src pada CSP. Storage local mengembalikan string kosong.
+func Origin(s Storage) string {
+	u, err := url.Parse(s.URL(""))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// ContentType menebak content type dari ekstensi key.
+func ContentType(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// cleanKey menolak key kosong, absolut, atau yang keluar dari root lewat "..".
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return cleaned, nil
+}
+
+func joinURL(baseURL, key string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + key
+}
+
+func keyFromURL(baseURL, publicURL string) (string, bool) {
+	key, ok := strings.CutPrefix(publicURL, strings.TrimSuffix(baseURL, "/")+"/")
+	if !ok {
+		return "", false
+	}
+	if _, err := cleanKey(key); err != nil {
+		return "", false
+	}
+	return key, true
+}
@@ -11,25 +11,8 @@ import (
 	"github.com/Rakhulsr/go-ecommerce/app/configs"
 	"github.com/Rakhulsr/go-ecommerce/app/models/migrations"
 	"github.com/Rakhulsr/go-ecommerce/app/routes"
-	"github.com/midtrans/midtrans-go"
-	"github.com/midtrans/midtrans-go/snap"
 )
 
-var MidtransSnapClient snap.Client
-
-func init() {
-
-	MidtransSnapClient.New(configs.LoadENV.MIDTRANS_SERVER_KEY, midtrans.Sandbox)
-
-	midtrans.ClientKey = configs.LoadENV.MIDTRANS_CLIENT_KEY
-
-	midtrans.ServerKey = configs.LoadENV.MIDTRANS_SERVER_KEY
-
-	midtrans.Environment = midtrans.Sandbox
-
-	log.Println("✅ Midtrans Snap Client initialized.")
-}
-
 func main() {
 
 	if len(os.Args) > 1 {
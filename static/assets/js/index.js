@@ -17,7 +17,7 @@ document.addEventListener("DOMContentLoaded", function () {
         subtotal,
       });
     } else {
-      const price = parseFloat(priceElement.dataset.value) || 0;
+      let price = parseFloat(priceElement.dataset.value) || 0;
 
       window.setMainImage = function (imageUrl) {
         const mainImg = document.getElementById("mainImage");
@@ -48,6 +48,28 @@ document.addEventListener("DOMContentLoaded", function () {
       };
 
       quantityInput.addEventListener("input", updateSubtotal);
+
+      const rupiahFormat = new Intl.NumberFormat("id-ID", {
+        style: "currency",
+        currency: "IDR",
+      });
+      const displayPrice = document.getElementById("displayPrice");
+      const displayStock = document.getElementById("displayStock");
+      const displayWeight = document.getElementById("displayWeight");
+
+      document.querySelectorAll('input[name="variant_id"]').forEach((radio) => {
+        radio.addEventListener("change", function () {
+          price = parseFloat(this.dataset.price) || 0;
+          priceElement.dataset.value = this.dataset.price;
+          if (displayPrice) displayPrice.textContent = rupiahFormat.format(price);
+          if (displayStock) displayStock.textContent = "Sisa " + this.dataset.stock;
+          if (displayWeight) displayWeight.textContent = this.dataset.weight;
+          quantityInput.max = this.dataset.stock;
+          if (this.dataset.image) window.setMainImage(this.dataset.image);
+          updateSubtotal();
+        });
+      });
+
       updateSubtotal(); 
     }
   }